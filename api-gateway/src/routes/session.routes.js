@@ -26,6 +26,16 @@ const sessionClient = new sessionProto.SessionService(
   grpc.credentials.createInsecure()
 );
 
+// Forward the caller's token, which the session service checks itself
+const authMetadata = (req) => {
+  const metadata = new grpc.Metadata();
+  const authorization = req.header('Authorization');
+  if (authorization) {
+    metadata.set('authorization', authorization);
+  }
+  return metadata;
+};
+
 // Middleware to handle gRPC client errors
 const handleGrpcError = (err, res) => {
   console.error('gRPC error:', err);
@@ -38,6 +48,15 @@ const handleGrpcError = (err, res) => {
     return res.status(409).json({ message: 'Resource already exists' });
   }
   
+  // A full, cancelled or completed session can't be booked
+  if (err.code === grpc.status.RESOURCE_EXHAUSTED || err.code === grpc.status.FAILED_PRECONDITION) {
+    return res.status(409).json({ message: err.details });
+  }
+  
+  if (err.code === grpc.status.UNAUTHENTICATED) {
+    return res.status(401).json({ message: err.details });
+  }
+  
   if (err.code === grpc.status.PERMISSION_DENIED) {
     return res.status(403).json({ message: 'Permission denied' });
   }
@@ -47,20 +66,29 @@ const handleGrpcError = (err, res) => {
 
 // SESSIONS ENDPOINTS
 
-// GET /api/sessions - List all sessions
+// GET /api/sessions - List sessions by start time, a page at a time
 router.get('/', (req, res) => {
-  const { date, session_type, coach_id, include_past } = req.query;
-  const page = parseInt(req.query.page) || 1;
-  const limit = parseInt(req.query.limit) || 10;
+  const {
+    date, session_type, coach_id, include_past, start_from, start_before,
+    difficulty_level, location, exclude_cancelled, descending, page_token, series_id
+  } = req.query;
+  const page_size = parseInt(req.query.page_size) || 0;
   
   sessionClient.ListSessions({
     date,
     session_type,
     coach_id,
     include_past: include_past === 'true',
-    page,
-    limit
-  }, (err, response) => {
+    start_from,
+    start_before,
+    difficulty_level,
+    location,
+    exclude_cancelled: exclude_cancelled === 'true',
+    descending: descending === 'true',
+    page_size,
+    page_token,
+    series_id
+  }, authMetadata(req), (err, response) => {
     if (err) return handleGrpcError(err, res);
     res.json(response);
   });
@@ -68,15 +96,42 @@ router.get('/', (req, res) => {
 
 // GET /api/sessions/:id - Get session by ID
 router.get('/:id', (req, res) => {
-  sessionClient.GetSession({ session_id: req.params.id }, (err, response) => {
+  sessionClient.GetSession({ session_id: req.params.id }, authMetadata(req), (err, response) => {
     if (err) return handleGrpcError(err, res);
     res.json(response);
   });
 });
 
+// GET /api/sessions/:id/watch - Server-sent events with the session's state,
+// sent again whenever it changes, instead of polling GET /api/sessions/:id
+router.get('/:id/watch', (req, res) => {
+  const call = sessionClient.WatchSession({ session_id: req.params.id }, authMetadata(req));
+  let started = false;
+
+  call.on('data', (session) => {
+    if (!started) {
+      res.writeHead(200, {
+        'Content-Type': 'text/event-stream',
+        'Cache-Control': 'no-cache',
+        Connection: 'keep-alive'
+      });
+      started = true;
+    }
+    res.write(`data: ${JSON.stringify(session)}\n\n`);
+  });
+  call.on('end', () => res.end());
+  call.on('error', (err) => {
+    if (err.code === grpc.status.CANCELLED) return;
+    if (!started) return handleGrpcError(err, res);
+    res.write(`event: error\ndata: ${JSON.stringify({ message: err.details })}\n\n`);
+    res.end();
+  });
+  req.on('close', () => call.cancel());
+});
+
 // POST /api/sessions - Create a new session
 router.post('/', (req, res) => {
-  const { title, description, coach_id, capacity, start_time, end_time, location, session_type, difficulty_level } = req.body;
+  const { title, description, coach_id, capacity, start_time, end_time, location, session_type, difficulty_level, allow_conflicts } = req.body;
   
   sessionClient.CreateSession({
     title,
@@ -87,8 +142,9 @@ router.post('/', (req, res) => {
     end_time,
     location,
     session_type,
-    difficulty_level
-  }, (err, response) => {
+    difficulty_level,
+    allow_conflicts
+  }, authMetadata(req), (err, response) => {
     if (err) return handleGrpcError(err, res);
     res.status(201).json(response);
   });
@@ -96,7 +152,7 @@ router.post('/', (req, res) => {
 
 // PUT /api/sessions/:id - Update a session
 router.put('/:id', (req, res) => {
-  const { title, description, coach_id, capacity, start_time, end_time, location, session_type, difficulty_level, is_cancelled } = req.body;
+  const { title, description, coach_id, capacity, start_time, end_time, location, session_type, difficulty_level, is_cancelled, allow_conflicts } = req.body;
   
   sessionClient.UpdateSession({
     session_id: req.params.id,
@@ -109,8 +165,71 @@ router.put('/:id', (req, res) => {
     location,
     session_type,
     difficulty_level,
-    is_cancelled
-  }, (err, response) => {
+    is_cancelled,
+    allow_conflicts
+  }, authMetadata(req), (err, response) => {
+    if (err) return handleGrpcError(err, res);
+    res.json(response);
+  });
+});
+
+// POST /api/sessions/series - Create the occurrences of a recurring session
+router.post('/series', (req, res) => {
+  const { title, description, coach_id, capacity, start_time, end_time, location, session_type, difficulty_level, allow_conflicts, recurrence, time_zone, dry_run } = req.body;
+  
+  sessionClient.CreateSessionSeries({
+    session: {
+      title,
+      description,
+      coach_id,
+      capacity: parseInt(capacity),
+      start_time,
+      end_time,
+      location,
+      session_type,
+      difficulty_level,
+      allow_conflicts
+    },
+    recurrence,
+    time_zone,
+    dry_run
+  }, authMetadata(req), (err, response) => {
+    if (err) return handleGrpcError(err, res);
+    res.status(dry_run ? 200 : 201).json(response);
+  });
+});
+
+// PUT /api/sessions/:id/series - Update a session and the later occurrences of its series
+router.put('/:id/series', (req, res) => {
+  const { title, description, coach_id, capacity, start_time, end_time, location, session_type, difficulty_level, allow_conflicts } = req.body;
+  
+  sessionClient.UpdateSessionSeries({
+    session_id: req.params.id,
+    title,
+    description,
+    coach_id,
+    capacity: capacity ? parseInt(capacity) : undefined,
+    start_time,
+    end_time,
+    location,
+    session_type,
+    difficulty_level,
+    allow_conflicts
+  }, authMetadata(req), (err, response) => {
+    if (err) return handleGrpcError(err, res);
+    res.json(response);
+  });
+});
+
+// POST /api/sessions/:id/series/cancel - Cancel a session and the later occurrences of its series
+router.post('/:id/series/cancel', (req, res) => {
+  const { reason, dry_run } = req.body;
+  
+  sessionClient.CancelSessionSeries({
+    session_id: req.params.id,
+    reason,
+    dry_run
+  }, authMetadata(req), (err, response) => {
     if (err) return handleGrpcError(err, res);
     res.json(response);
   });
@@ -118,7 +237,7 @@ router.put('/:id', (req, res) => {
 
 // DELETE /api/sessions/:id - Delete a session
 router.delete('/:id', (req, res) => {
-  sessionClient.DeleteSession({ session_id: req.params.id }, (err, response) => {
+  sessionClient.DeleteSession({ session_id: req.params.id }, authMetadata(req), (err, response) => {
     if (err) return handleGrpcError(err, res);
     res.json(response);
   });
@@ -136,7 +255,7 @@ router.post('/', (req, res) => {
   sessionClient.CreateReservation({
     session_id,
     user_id: userId
-  }, (err, response) => {
+  }, authMetadata(req), (err, response) => {
     if (err) return handleGrpcError(err, res);
     res.status(201).json(response);
   });
@@ -144,7 +263,7 @@ router.post('/', (req, res) => {
 
 // GET /api/reservations/:id - Get reservation by ID
 router.get('/:id', (req, res) => {
-  sessionClient.GetReservation({ reservation_id: req.params.id }, (err, response) => {
+  sessionClient.GetReservation({ reservation_id: req.params.id }, authMetadata(req), (err, response) => {
     if (err) return handleGrpcError(err, res);
     res.json(response);
   });
@@ -155,7 +274,7 @@ router.delete('/:id', (req, res) => {
   sessionClient.CancelReservation({
     reservation_id: req.params.id,
     user_id: req.user.userId  // From JWT token
-  }, (err, response) => {
+  }, authMetadata(req), (err, response) => {
     if (err) return handleGrpcError(err, res);
     res.json(response);
   });
@@ -168,17 +287,16 @@ router.get('/user/:userId', (req, res) => {
     return res.status(403).json({ message: 'Permission denied' });
   }
   
-  const { status, include_past } = req.query;
-  const page = parseInt(req.query.page) || 1;
-  const limit = parseInt(req.query.limit) || 10;
+  const { status, include_past, page_token } = req.query;
+  const page_size = parseInt(req.query.page_size) || 0;
   
   sessionClient.ListUserReservations({
     user_id: req.params.userId,
     status,
     include_past: include_past === 'true',
-    page,
-    limit
-  }, (err, response) => {
+    page_size,
+    page_token
+  }, authMetadata(req), (err, response) => {
     if (err) return handleGrpcError(err, res);
     res.json(response);
   });
@@ -186,16 +304,37 @@ router.get('/user/:userId', (req, res) => {
 
 // GET /api/reservations/session/:sessionId - Get session reservations
 router.get('/session/:sessionId', (req, res) => {
-  const { status } = req.query;
-  const page = parseInt(req.query.page) || 1;
-  const limit = parseInt(req.query.limit) || 10;
+  const { status, page_token } = req.query;
+  const page_size = parseInt(req.query.page_size) || 0;
   
   sessionClient.ListSessionReservations({
     session_id: req.params.sessionId,
     status,
-    page,
-    limit
-  }, (err, response) => {
+    page_size,
+    page_token
+  }, authMetadata(req), (err, response) => {
+    if (err) return handleGrpcError(err, res);
+    res.json(response);
+  });
+});
+
+// POST /api/reservations/waitlist/:sessionId - Wait for a spot of a full session
+router.post('/waitlist/:sessionId', (req, res) => {
+  sessionClient.JoinWaitlist({
+    session_id: req.params.sessionId,
+    user_id: req.user.userId  // From JWT token
+  }, authMetadata(req), (err, response) => {
+    if (err) return handleGrpcError(err, res);
+    res.status(201).json(response);
+  });
+});
+
+// DELETE /api/reservations/waitlist/:sessionId - Stop waiting for a spot
+router.delete('/waitlist/:sessionId', (req, res) => {
+  sessionClient.LeaveWaitlist({
+    session_id: req.params.sessionId,
+    user_id: req.user.userId  // From JWT token
+  }, authMetadata(req), (err, response) => {
     if (err) return handleGrpcError(err, res);
     res.json(response);
   });
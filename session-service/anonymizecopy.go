@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"session-service/internal/anonymize"
+)
+
+// A table copied by copyAnonymized, parents before children
+type copyTable struct {
+	name    string
+	columns []string
+}
+
+var anonymizedTables = []copyTable{
+	{"sessions", []string{
+		"id", "gym_id", "title", "description", "coach_id", "coach_name", "capacity", "reserved_spots",
+		"start_time", "end_time", "location", "session_type", "difficulty_level", "is_cancelled",
+		"cancellation_reason", "is_completed", "queued_booking", "series_id", "created_at", "updated_at",
+	}},
+	{"reservations", []string{
+		"id", "gym_id", "session_id", "user_id", "user_name", "reservation_time", "status", "created_at", "updated_at",
+	}},
+	{"waitlist", []string{
+		"id", "gym_id", "session_id", "user_id", "user_name", "created_at",
+	}},
+}
+
+// Free text columns that may name people. They are replaced wholesale.
+var freeTextColumns = map[string]string{
+	"cancellation_reason": "Cancelled",
+}
+
+// Entry point of the anonymize command, which copies the database in
+// POSTGRES_URI to the one given with -to
+func runAnonymize(args []string) {
+	flags := flag.NewFlagSet("anonymize", flag.ExitOnError)
+	target := flags.String("to", "", "URL of the database to copy to (required)")
+	replace := flags.Bool("replace", false, "Delete the sessions and reservations already in the target")
+	flags.Parse(args)
+
+	key := os.Getenv("ANONYMIZE_KEY")
+	if *target == "" || key == "" {
+		log.Fatalf("Usage: ANONYMIZE_KEY=secret session-service anonymize -to URL [-replace]")
+	}
+	if *target == databaseURL() {
+		log.Fatalf("Refusing to copy the database onto itself")
+	}
+
+	src, err := sql.Open("postgres", databaseURL())
+	if err != nil {
+		log.Fatalf("Failed to connect to source database: %v", err)
+	}
+	defer src.Close()
+	dst, err := sql.Open("postgres", *target)
+	if err != nil {
+		log.Fatalf("Failed to connect to target database: %v", err)
+	}
+	defer dst.Close()
+
+	copied, err := copyAnonymized(context.Background(), src, dst, anonymize.New(key), *replace)
+	if err != nil {
+		log.Fatalf("Failed to copy data: %v", err)
+	}
+	log.Printf("Copied %d sessions, %d reservations and %d waitlist entries", copied["sessions"], copied["reservations"], copied["waitlist"])
+}
+
+// Implementation of the anonymize command. It copies every session and
+// reservation from src to dst, replacing user and coach IDs and names with
+// pseudonyms from h. IDs are kept so that rows still reference each other.
+// dst must be empty unless replace is set, in which case its rows are
+// deleted first. It returns the number of rows copied per table.
+func copyAnonymized(ctx context.Context, src, dst *sql.DB, h anonymize.Hasher, replace bool) (map[string]int, error) {
+	if err := initDatabase(ctx, dst); err != nil {
+		return nil, fmt.Errorf("setting up target database: %w", err)
+	}
+
+	// Read a consistent snapshot of the source
+	from, err := src.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer from.Rollback()
+
+	to, err := dst.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer to.Rollback()
+
+	if replace {
+		if _, err := to.ExecContext(ctx, `TRUNCATE waitlist, reservations, sessions RESTART IDENTITY`); err != nil {
+			return nil, err
+		}
+	} else {
+		var hasRows bool
+		if err := to.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM sessions)`).Scan(&hasRows); err != nil {
+			return nil, err
+		}
+		if hasRows {
+			return nil, fmt.Errorf("target database already has sessions; use -replace to overwrite them")
+		}
+	}
+
+	copied := make(map[string]int)
+	for _, table := range anonymizedTables {
+		n, err := copyTableRows(ctx, from, to, table, h)
+		if err != nil {
+			return nil, fmt.Errorf("copying %s: %w", table.name, err)
+		}
+		copied[table.name] = n
+
+		// Continue the ID sequence after the copied rows
+		_, err = to.ExecContext(ctx, fmt.Sprintf(
+			`SELECT setval(pg_get_serial_sequence('%[1]s', 'id'), COALESCE(MAX(id), 1), MAX(id) IS NOT NULL) FROM %[1]s`,
+			table.name))
+		if err != nil {
+			return nil, fmt.Errorf("resetting %s id sequence: %w", table.name, err)
+		}
+	}
+	// New series must not join the copied ones
+	if _, err := to.ExecContext(ctx,
+		`SELECT setval('session_series_id_seq', COALESCE(MAX(series_id), 1), MAX(series_id) IS NOT NULL) FROM sessions`); err != nil {
+		return nil, fmt.Errorf("resetting the series id sequence: %w", err)
+	}
+
+	if err := to.Commit(); err != nil {
+		return nil, err
+	}
+	return copied, nil
+}
+
+// Copy every row of table, anonymizing personal data on the way
+func copyTableRows(ctx context.Context, from, to *sql.Tx, table copyTable, h anonymize.Hasher) (int, error) {
+	placeholders := make([]string, len(table.columns))
+	for i := range placeholders {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+	}
+	columns := strings.Join(table.columns, ", ")
+
+	insert, err := to.PrepareContext(ctx, fmt.Sprintf(`INSERT INTO %s (%s) VALUES (%s)`,
+		table.name, columns, strings.Join(placeholders, ", ")))
+	if err != nil {
+		return 0, err
+	}
+	defer insert.Close()
+
+	rows, err := from.QueryContext(ctx, fmt.Sprintf(`SELECT %s FROM %s ORDER BY id`, columns, table.name))
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	values := make([]interface{}, len(table.columns))
+	dest := make([]interface{}, len(table.columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+
+	n := 0
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return n, err
+		}
+		for i, column := range table.columns {
+			// NULLs are kept; text comes back as string or []byte depending on the driver
+			var text string
+			switch v := values[i].(type) {
+			case string:
+				text = v
+			case []byte:
+				text = string(v)
+			default:
+				continue
+			}
+			if replacement, ok := freeTextColumns[column]; ok {
+				if text != "" {
+					values[i] = replacement
+				}
+				continue
+			}
+			values[i] = h.Field(column, text)
+		}
+
+		if _, err := insert.ExecContext(ctx, values...); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, rows.Err()
+}
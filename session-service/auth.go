@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"session-service/internal/auth"
+	"session-service/internal/clock"
+)
+
+// Who may call a method
+type access int
+
+const (
+	// Anyone, with or without a token: the schedule is public
+	publicAccess access = iota
+	// Any signed in user; members only for themselves
+	memberAccess
+	// Coaches and admins
+	staffAccess
+	// Admins
+	adminAccess
+)
+
+// Access to each method of the session service. Methods left out are
+// refused, so a new RPC is closed until it is given a rule here.
+var methodAccess = map[string]access{
+	"GetSession":              publicAccess,
+	"ListSessions":            publicAccess,
+	"StreamSessions":          publicAccess,
+	"WatchSession":            publicAccess,
+	"CreateReservation":       memberAccess,
+	"QueueReservation":        memberAccess,
+	"GetReservation":          memberAccess,
+	"CancelReservation":       memberAccess,
+	"ListUserReservations":    memberAccess,
+	"JoinWaitlist":            memberAccess,
+	"LeaveWaitlist":           memberAccess,
+	"CreateSession":           staffAccess,
+	"UpdateSession":           staffAccess,
+	"DeleteSession":           staffAccess,
+	"CancelSession":           staffAccess,
+	"CreateSessionSeries":     staffAccess,
+	"UpdateSessionSeries":     staffAccess,
+	"CancelSessionSeries":     staffAccess,
+	"BatchCreateReservations": staffAccess,
+	"CheckInReservation":      staffAccess,
+	"ListSessionReservations": staffAccess,
+	"RunSelfTest":             adminAccess,
+}
+
+// Services every caller may use, to probe the server
+var unauthenticatedServices = []string{
+	"/grpc.health.v1.Health/",
+	"/grpc.reflection.v1alpha.ServerReflection/",
+}
+
+// Create the verifier of the tokens from JWT_SECRET, the secret the user
+// service signs its tokens with, and JWT_PUBLIC_KEY_FILE, PEM encoded RSA
+// public keys of another issuer, restricted to JWT_ISSUER if set. Only the
+// development mode runs without either, and then without authentication.
+func tokenVerifier(dev bool) (*auth.Verifier, error) {
+	secret := os.Getenv("JWT_SECRET")
+	var publicKeys []byte
+	if path := os.Getenv("JWT_PUBLIC_KEY_FILE"); path != "" {
+		var err error
+		if publicKeys, err = os.ReadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if secret == "" && publicKeys == nil {
+		if dev {
+			log.Println("Development mode: no JWT_SECRET or JWT_PUBLIC_KEY_FILE, authentication disabled")
+			return nil, nil
+		}
+		return nil, errors.New("set JWT_SECRET or JWT_PUBLIC_KEY_FILE")
+	}
+	return auth.NewVerifier(secret, publicKeys, os.Getenv("JWT_ISSUER"))
+}
+
+// Checks the bearer token in the metadata of each call against the access
+// rule of the method, and adds the caller's identity to the context
+type authenticator struct {
+	verifier *auth.Verifier
+	clock    clock.Clock
+}
+
+// Request naming the member it is made for
+type userRequest interface {
+	GetUserId() string
+}
+
+func (a *authenticator) unary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := a.authenticate(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkOwnRequest(ctx, req); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (a *authenticator) stream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := a.authenticate(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authStream{contextStream{ss, ctx}})
+}
+
+// Check the caller may call method, and return ctx with their identity
+func (a *authenticator) authenticate(ctx context.Context, method string) (context.Context, error) {
+	for _, service := range unauthenticatedServices {
+		if strings.HasPrefix(method, service) {
+			return ctx, nil
+		}
+	}
+	rule, ok := methodAccess[method[strings.LastIndex(method, "/")+1:]]
+	if !ok {
+		return nil, status.Errorf(codes.PermissionDenied, "No access rule for %s", method)
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		if rule == publicAccess {
+			return ctx, nil
+		}
+		return nil, status.Error(codes.Unauthenticated, "Missing bearer token")
+	}
+	token := strings.TrimPrefix(values[0], "Bearer ")
+	if token == values[0] {
+		return nil, status.Error(codes.Unauthenticated, "Authorization is not a bearer token")
+	}
+	id, err := a.verifier.Verify(token, a.clock.Now())
+	if err != nil {
+		return nil, status.Errorf(codes.Unauthenticated, "Invalid token: %v", err)
+	}
+
+	switch {
+	case rule == staffAccess && !id.IsStaff():
+		return nil, status.Error(codes.PermissionDenied, "Only coaches and admins can do this")
+	case rule == adminAccess && id.Role != auth.RoleAdmin:
+		return nil, status.Error(codes.PermissionDenied, "Only admins can do this")
+	}
+	return auth.WithIdentity(ctx, id), nil
+}
+
+// Refuse a member's request made for another member. Coaches and admins
+// book for members, e.g. at the front desk.
+func checkOwnRequest(ctx context.Context, req interface{}) error {
+	id, ok := auth.FromContext(ctx)
+	if !ok || id.IsStaff() {
+		return nil
+	}
+	if r, ok := req.(userRequest); ok && r.GetUserId() != id.UserID {
+		return status.Error(codes.PermissionDenied, "Members can only act for themselves")
+	}
+	return nil
+}
+
+// Refuse allow_conflicts unless the caller is an admin. Without
+// authentication, in development mode, anyone may set it.
+func checkAllowConflicts(ctx context.Context, allow bool) error {
+	if id, ok := auth.FromContext(ctx); allow && ok && id.Role != auth.RoleAdmin {
+		return status.Error(codes.PermissionDenied, "Only admins can allow schedule conflicts")
+	}
+	return nil
+}
+
+// Stream checking the requests it receives with checkOwnRequest
+type authStream struct {
+	contextStream
+}
+
+func (s *authStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return checkOwnRequest(s.ctx, m)
+}
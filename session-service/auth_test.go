@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"session-service/internal/auth"
+	"session-service/internal/clock"
+	pb "session-service/proto"
+)
+
+var authTestNow = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// Context of a call with an HS256 token of user with role, signed with
+// "secret"
+func withToken(user, role string) context.Context {
+	header, _ := json.Marshal(map[string]string{"alg": "HS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]interface{}{"userId": user, "role": role, "exp": authTestNow.Add(time.Hour).Unix()})
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte(signed))
+	token := signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+}
+
+func newTestAuthenticator(t *testing.T) *authenticator {
+	verifier, err := auth.NewVerifier("secret", nil, "")
+	if err != nil {
+		t.Fatalf("NewVerifier failed: %v", err)
+	}
+	return &authenticator{verifier: verifier, clock: clock.NewFake(authTestNow)}
+}
+
+func TestEveryMethodHasAnAccessRule(t *testing.T) {
+	for _, m := range pb.SessionService_ServiceDesc.Methods {
+		if _, ok := methodAccess[m.MethodName]; !ok {
+			t.Errorf("No access rule for %s", m.MethodName)
+		}
+	}
+	for _, s := range pb.SessionService_ServiceDesc.Streams {
+		if _, ok := methodAccess[s.StreamName]; !ok {
+			t.Errorf("No access rule for %s", s.StreamName)
+		}
+	}
+}
+
+func TestAuthInterceptor(t *testing.T) {
+	a := newTestAuthenticator(t)
+	call := func(ctx context.Context, method string, req interface{}) (*auth.Identity, codes.Code) {
+		var caller *auth.Identity
+		info := &grpc.UnaryServerInfo{FullMethod: "/session.SessionService/" + method}
+		_, err := a.unary(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			caller, _ = auth.FromContext(ctx)
+			return nil, nil
+		})
+		return caller, status.Code(err)
+	}
+
+	anonymous := context.Background()
+	member, coach, admin := withToken("member-1", "member"), withToken("coach-1", "coach"), withToken("admin-1", "admin")
+	createSession := &pb.CreateSessionRequest{}
+	book := func(user string) *pb.CreateReservationRequest {
+		return &pb.CreateReservationRequest{SessionId: "1", UserId: user}
+	}
+	tests := map[string]struct {
+		ctx    context.Context
+		method string
+		req    interface{}
+		want   codes.Code
+	}{
+		"anonymous schedule":        {anonymous, "ListSessions", &pb.ListSessionsRequest{}, codes.OK},
+		"anonymous booking":         {anonymous, "CreateReservation", book("member-1"), codes.Unauthenticated},
+		"member booking":            {member, "CreateReservation", book("member-1"), codes.OK},
+		"member booking for others": {member, "CreateReservation", book("member-2"), codes.PermissionDenied},
+		"coach booking for members": {coach, "CreateReservation", book("member-2"), codes.OK},
+		"member creating a session": {member, "CreateSession", createSession, codes.PermissionDenied},
+		"coach creating a session":  {coach, "CreateSession", createSession, codes.OK},
+		"admin creating a session":  {admin, "CreateSession", createSession, codes.OK},
+		"member reading a roster":   {member, "ListSessionReservations", &pb.ListSessionReservationsRequest{}, codes.PermissionDenied},
+		"member checking in":        {member, "CheckInReservation", &pb.CheckInReservationRequest{}, codes.PermissionDenied},
+		"coach checking in":         {coach, "CheckInReservation", &pb.CheckInReservationRequest{}, codes.OK},
+		"coach self-test":           {coach, "RunSelfTest", &pb.RunSelfTestRequest{}, codes.PermissionDenied},
+		"admin self-test":           {admin, "RunSelfTest", &pb.RunSelfTestRequest{}, codes.OK},
+		"method without rule":       {admin, "Unknown", nil, codes.PermissionDenied},
+		"bad token on the schedule": {metadata.NewIncomingContext(anonymous, metadata.Pairs("authorization", "Bearer x")), "ListSessions", &pb.ListSessionsRequest{}, codes.Unauthenticated},
+		"not a bearer token":        {metadata.NewIncomingContext(anonymous, metadata.Pairs("authorization", "Basic x")), "CreateReservation", book("member-1"), codes.Unauthenticated},
+	}
+	for name, tt := range tests {
+		if _, got := call(tt.ctx, tt.method, tt.req); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", name, tt.want, got)
+		}
+	}
+
+	if caller, _ := call(coach, "CreateSession", createSession); caller == nil || caller.UserID != "coach-1" || caller.Role != auth.RoleCoach {
+		t.Errorf("Expected the coach's identity in the context, got %+v", caller)
+	}
+	// Probes work without a token
+	info := &grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Check"}
+	if _, err := a.unary(anonymous, nil, info, func(context.Context, interface{}) (interface{}, error) { return nil, nil }); err != nil {
+		t.Errorf("Expected health checks without a token, got %v", err)
+	}
+}
+
+// Stream of QueueReservation receiving req
+type recvStream struct {
+	grpc.ServerStream
+	ctx context.Context
+	req *pb.QueueReservationRequest
+}
+
+func (s *recvStream) Context() context.Context { return s.ctx }
+
+func (s *recvStream) RecvMsg(m interface{}) error {
+	proto.Merge(m.(proto.Message), s.req)
+	return nil
+}
+
+func TestAuthStreamInterceptor(t *testing.T) {
+	a := newTestAuthenticator(t)
+	info := &grpc.StreamServerInfo{FullMethod: "/session.SessionService/QueueReservation"}
+	call := func(ctx context.Context, user string) codes.Code {
+		ss := &recvStream{ctx: ctx, req: &pb.QueueReservationRequest{SessionId: "1", UserId: user}}
+		return status.Code(a.stream(nil, ss, info, func(srv interface{}, ss grpc.ServerStream) error {
+			return ss.RecvMsg(new(pb.QueueReservationRequest))
+		}))
+	}
+
+	if got := call(withToken("member-1", "member"), "member-1"); got != codes.OK {
+		t.Errorf("Expected a member to queue for themselves, got %v", got)
+	}
+	if got := call(withToken("member-1", "member"), "member-2"); got != codes.PermissionDenied {
+		t.Errorf("Expected PermissionDenied queueing for another member, got %v", got)
+	}
+	if got := call(context.Background(), "member-1"); got != codes.Unauthenticated {
+		t.Errorf("Expected Unauthenticated without a token, got %v", got)
+	}
+}
+
+func TestServerGetReservationOfAnotherMember(t *testing.T) {
+	s := newTestServer()
+	ctx := context.Background()
+	session, err := s.CreateSession(ctx, validCreateSessionRequest())
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	r, err := s.CreateReservation(ctx, &pb.CreateReservationRequest{SessionId: session.Id, UserId: "member-1"})
+	if err != nil {
+		t.Fatalf("CreateReservation failed: %v", err)
+	}
+
+	as := func(user, role string) context.Context {
+		return auth.WithIdentity(ctx, &auth.Identity{UserID: user, Role: role})
+	}
+	if _, err := s.GetReservation(as("member-1", auth.RoleMember), &pb.GetReservationRequest{ReservationId: r.Id}); err != nil {
+		t.Errorf("Expected a member to read their reservation, got %v", err)
+	}
+	if _, err := s.GetReservation(as("coach-1", auth.RoleCoach), &pb.GetReservationRequest{ReservationId: r.Id}); err != nil {
+		t.Errorf("Expected a coach to read any reservation, got %v", err)
+	}
+	_, err = s.GetReservation(as("member-2", auth.RoleMember), &pb.GetReservationRequest{ReservationId: r.Id})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound for another member's reservation, got %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"session-service/internal/fixtures"
+	"session-service/internal/store"
+	"session-service/internal/users"
+	pb "session-service/proto"
+)
+
+// Sessions created before each benchmark, so lookups don't hit a tiny map
+const benchSessions = 1000
+
+// Create a test server holding benchSessions sessions
+func newBenchServer(b *testing.B) *server {
+	b.Helper()
+	s := newTestServer()
+	for i := 0; i < benchSessions; i++ {
+		_, err := fixtures.NewTestSession().RelativeTo(s.clock.Now()).StartingIn(time.Duration(i)*time.Hour).
+			WithCapacity(1<<30).Create(context.Background(), s.repo)
+		if err != nil {
+			b.Fatalf("Failed to create fixture: %v", err)
+		}
+	}
+	return s
+}
+
+func BenchmarkGetSession(b *testing.B) {
+	s := newBenchServer(b)
+	ctx := context.Background()
+	ids := make([]string, benchSessions)
+	for i := range ids {
+		ids[i] = strconv.Itoa(i + 1)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := s.GetSession(ctx, &pb.GetSessionRequest{SessionId: ids[i%benchSessions]}); err != nil {
+			b.Fatalf("GetSession failed: %v", err)
+		}
+	}
+}
+
+func BenchmarkCreateSession(b *testing.B) {
+	s := newTestServer()
+	// The user service names the coach, as in production; without it every
+	// session would look up the name stored with the coach's latest one
+	s.users = userDirectory(&users.User{ID: "coach-1", FirstName: "Sarah", LastName: "Johnson", Role: "coach"})
+	ctx := context.Background()
+	req := validCreateSessionRequest()
+	// Every session in the same slot, as measured before conflicts were checked
+	req.AllowConflicts = true
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := s.CreateSession(ctx, req); err != nil {
+			b.Fatalf("CreateSession failed: %v", err)
+		}
+	}
+}
+
+func BenchmarkCreateReservation(b *testing.B) {
+	s := newBenchServer(b)
+	ctx := context.Background()
+	users := make([]string, b.N)
+	for i := range users {
+		users[i] = "user-" + strconv.Itoa(i)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r := &store.Reservation{SessionID: int64(i%benchSessions + 1), UserID: users[i], UserName: "Jane Doe"}
+		if err := s.repo.CreateReservation(ctx, r); err != nil {
+			b.Fatalf("CreateReservation failed: %v", err)
+		}
+	}
+}
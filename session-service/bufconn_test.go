@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"session-service/internal/anonymize"
+	"session-service/internal/recording"
+	pb "session-service/proto"
+)
+
+// Serve srv in-process through the same gRPC server and interceptor chain as
+// production, over an in-memory connection, and return a client for it.
+func startBufconnServer(t *testing.T, srv *server, opts serverOptions) pb.SessionServiceClient {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	s := newGRPCServer(srv, opts)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("Failed to dial bufconn server: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return pb.NewSessionServiceClient(conn)
+}
+
+func TestBufconnErrorMapping(t *testing.T) {
+	client := startBufconnServer(t, newTestServer(), serverOptions{debugLog: true})
+	ctx := context.Background()
+
+	req := validCreateSessionRequest()
+	req.StartTime = "next tuesday"
+	_, err := client.CreateSession(ctx, req)
+	if status.Code(err) != codes.InvalidArgument || !strings.Contains(status.Convert(err).Message(), "start_time") {
+		t.Errorf("Expected InvalidArgument naming start_time, got %v", err)
+	}
+
+	_, err = client.GetSession(ctx, &pb.GetSessionRequest{SessionId: "42"})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound, got %v", err)
+	}
+
+	_, err = client.DeleteSession(ctx, &pb.DeleteSessionRequest{SessionId: "42"})
+	if status.Code(err) != codes.Unimplemented {
+		t.Errorf("Expected Unimplemented, got %v", err)
+	}
+
+	created, err := client.CreateSession(ctx, validCreateSessionRequest())
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	_, err = client.CancelSession(ctx, &pb.CancelSessionRequest{SessionId: created.Id})
+	if err != nil {
+		t.Fatalf("CancelSession failed: %v", err)
+	}
+	_, err = client.CancelSession(ctx, &pb.CancelSessionRequest{SessionId: created.Id})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("Expected FailedPrecondition, got %v", err)
+	}
+}
+
+func TestBufconnRecording(t *testing.T) {
+	recorder, err := recording.NewRecorder(t.TempDir(), anonymize.New("key"))
+	if err != nil {
+		t.Fatalf("Failed to create recorder: %v", err)
+	}
+	client := startBufconnServer(t, newTestServer(), serverOptions{recorder: recorder})
+
+	req := validCreateSessionRequest()
+	if _, err := client.CreateSession(context.Background(), req); err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	_, err = client.GetSession(context.Background(), &pb.GetSessionRequest{SessionId: "42"})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound, got %v", err)
+	}
+	recorder.Close()
+
+	records, err := recording.ReadFile(recorder.Path())
+	if err != nil {
+		t.Fatalf("Failed to read recording: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 records, got %d", len(records))
+	}
+	if records[0].Method != "/session.SessionService/CreateSession" || records[1].Code != codes.NotFound.String() {
+		t.Errorf("Unexpected records %+v", records)
+	}
+	if strings.Contains(string(records[0].Request), req.CoachId) {
+		t.Errorf("Recording leaked the coach ID: %s", records[0].Request)
+	}
+}
@@ -0,0 +1,145 @@
+// Command benchcheck compares two sets of `go test -bench` results and fails
+// if a benchmark got slower, or allocates more, than the threshold allows.
+//
+//	benchcheck [-threshold 10] baseline.txt current.txt
+//
+// Run the benchmarks with -count > 1: the median of the runs is compared, so
+// a single noisy run does not fail the check.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// Measurements of one benchmark across runs, by unit (ns/op, allocs/op, ...)
+type results map[string][]float64
+
+// Units compared against the baseline
+var checkedUnits = []string{"ns/op", "allocs/op"}
+
+func main() {
+	threshold := flag.Float64("threshold", 10, "maximum slowdown in percent")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: benchcheck [-threshold PERCENT] BASELINE CURRENT")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	baseline, err := parseFile(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(2)
+	}
+	current, err := parseFile(flag.Arg(1))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(2)
+	}
+
+	if regressions := compare(os.Stdout, baseline, current, *threshold); regressions > 0 {
+		fmt.Fprintf(os.Stderr, "%d benchmark results regressed by more than %g%%\n", regressions, *threshold)
+		os.Exit(1)
+	}
+}
+
+// Print a comparison table and return the number of regressions
+func compare(out io.Writer, baseline, current map[string]results, threshold float64) int {
+	names := make([]string, 0, len(current))
+	for name := range current {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "BENCHMARK\tUNIT\tBASELINE\tCURRENT\tDELTA\t")
+	regressions := 0
+	for _, name := range names {
+		for _, unit := range checkedUnits {
+			now, ok := current[name][unit]
+			if !ok {
+				continue
+			}
+			then, ok := baseline[name][unit]
+			if !ok {
+				fmt.Fprintf(w, "%s\t%s\t-\t%.4g\tno baseline\t\n", name, unit, median(now))
+				continue
+			}
+
+			before, after := median(then), median(now)
+			delta := 0.0
+			if before > 0 {
+				delta = (after - before) / before * 100
+			} else if after > 0 {
+				delta = 100
+			}
+			verdict := ""
+			if delta > threshold {
+				verdict = "REGRESSION"
+				regressions++
+			}
+			fmt.Fprintf(w, "%s\t%s\t%.4g\t%.4g\t%+.1f%%\t%s\n", name, unit, before, after, delta, verdict)
+		}
+	}
+	w.Flush()
+	return regressions
+}
+
+// Read benchmark results from `go test -bench` output. Other lines are
+// ignored.
+func parseFile(path string) (map[string]results, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	all := make(map[string]results)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || !strings.HasPrefix(fields[0], "Benchmark") {
+			continue
+		}
+		// Drop the GOMAXPROCS suffix so results from different machines line up
+		name := fields[0]
+		if i := strings.LastIndex(name, "-"); i > 0 {
+			if _, err := strconv.Atoi(name[i+1:]); err == nil {
+				name = name[:i]
+			}
+		}
+		if all[name] == nil {
+			all[name] = make(results)
+		}
+		// fields[1] is the iteration count, then value/unit pairs
+		for i := 2; i+1 < len(fields); i += 2 {
+			v, err := strconv.ParseFloat(fields[i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s: bad value %q in %q", path, fields[i], scanner.Text())
+			}
+			all[name][fields[i+1]] = append(all[name][fields[i+1]], v)
+		}
+	}
+	return all, scanner.Err()
+}
+
+// Median of the values; it sorts them in place
+func median(values []float64) float64 {
+	sort.Float64s(values)
+	n := len(values)
+	if n%2 == 1 {
+		return values[n/2]
+	}
+	return (values[n/2-1] + values[n/2]) / 2
+}
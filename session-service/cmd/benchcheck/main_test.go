@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeResults(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCompare(t *testing.T) {
+	baseline, err := parseFile(writeResults(t, "baseline.txt", `goos: linux
+BenchmarkGetSession-8   	 1000000	      1000 ns/op	     400 B/op	       5 allocs/op
+BenchmarkGetSession-8   	 1000000	      1100 ns/op	     400 B/op	       5 allocs/op
+BenchmarkGetSession-8   	 1000000	       900 ns/op	     400 B/op	       5 allocs/op
+BenchmarkReserveSpot-8  	 1000000	       200 ns/op	     100 B/op	       1 allocs/op
+PASS
+`))
+	if err != nil {
+		t.Fatalf("parseFile failed: %v", err)
+	}
+	current, err := parseFile(writeResults(t, "current.txt", `BenchmarkGetSession-4   	 1000000	      1050 ns/op	     400 B/op	       5 allocs/op
+BenchmarkReserveSpot-4  	 1000000	       250 ns/op	     100 B/op	       1 allocs/op
+BenchmarkCreateSession-4	 1000000	       300 ns/op	     100 B/op	       2 allocs/op
+`))
+	if err != nil {
+		t.Fatalf("parseFile failed: %v", err)
+	}
+
+	var out strings.Builder
+	if n := compare(&out, baseline, current, 10); n != 1 {
+		t.Errorf("Expected 1 regression, got %d:\n%s", n, out.String())
+	}
+	reported := false
+	for _, line := range strings.Split(out.String(), "\n") {
+		if strings.HasPrefix(line, "BenchmarkReserveSpot") && strings.Contains(line, "+25.0%") && strings.Contains(line, "REGRESSION") {
+			reported = true
+		}
+	}
+	if !reported {
+		t.Errorf("ReserveSpot regression not reported:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "no baseline") {
+		t.Errorf("New benchmark not reported:\n%s", out.String())
+	}
+}
@@ -0,0 +1,102 @@
+// Command loadtest simulates bookings opening on a popular class: thousands
+// of members call CreateReservation on the same session at once. It reports
+// throughput, the mix of status codes and latency percentiles, and checks that
+// the session was not overbooked.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	pb "session-service/proto"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:50051", "session service address")
+	sessionID := flag.String("session", "", "book this existing session instead of creating one")
+	capacity := flag.Int("capacity", 50, "capacity of the session created for the run")
+	requests := flag.Int("requests", 2000, "number of CreateReservation calls, one per simulated member")
+	conns := flag.Int("conns", 4, "gRPC connections shared by the callers")
+	timeout := flag.Duration("timeout", 10*time.Second, "deadline of each call")
+	flag.Parse()
+
+	clients := make([]pb.SessionServiceClient, *conns)
+	for i := range clients {
+		conn, err := grpc.Dial(*addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			log.Fatalf("Failed to connect to %s: %v", *addr, err)
+		}
+		defer conn.Close()
+		clients[i] = pb.NewSessionServiceClient(conn)
+	}
+
+	ctx := context.Background()
+	if *sessionID == "" {
+		start := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Hour)
+		session, err := clients[0].CreateSession(ctx, &pb.CreateSessionRequest{
+			Title:           "Load test class",
+			CoachId:         "loadtest-coach",
+			Capacity:        int32(*capacity),
+			StartTime:       start.Format(time.RFC3339),
+			EndTime:         start.Add(time.Hour).Format(time.RFC3339),
+			Location:        "Load test studio",
+			SessionType:     "loadtest",
+			DifficultyLevel: "beginner",
+			// Runs within the same hour get the same slot
+			AllowConflicts: true,
+		})
+		if err != nil {
+			log.Fatalf("Failed to create session: %v", err)
+		}
+		*sessionID = session.Id
+	}
+	runID := time.Now().Unix()
+
+	// Every caller waits on the barrier so the calls hit the server together
+	results := make([]result, *requests)
+	barrier := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < *requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			client := clients[i%len(clients)]
+			<-barrier
+
+			callCtx, cancel := context.WithTimeout(ctx, *timeout)
+			defer cancel()
+			start := time.Now()
+			_, err := client.CreateReservation(callCtx, &pb.CreateReservationRequest{
+				SessionId: *sessionID,
+				UserId:    fmt.Sprintf("loadtest-%d-%d", runID, i),
+			})
+			results[i] = result{latency: time.Since(start), code: status.Code(err)}
+		}(i)
+	}
+
+	start := time.Now()
+	close(barrier)
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	session, err := clients[0].GetSession(ctx, &pb.GetSessionRequest{SessionId: *sessionID})
+	if err != nil {
+		log.Fatalf("Failed to read back session %s: %v", *sessionID, err)
+	}
+
+	r := summarize(results, elapsed)
+	r.print(os.Stdout, session)
+	if session.ReservedSpots > session.Capacity || r.codes[codes.OK] > int(session.Capacity) {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"google.golang.org/grpc/codes"
+
+	pb "session-service/proto"
+)
+
+// Outcome of one CreateReservation call
+type result struct {
+	latency time.Duration
+	code    codes.Code
+}
+
+type report struct {
+	total     int
+	elapsed   time.Duration
+	codes     map[codes.Code]int
+	latencies []time.Duration
+}
+
+func summarize(results []result, elapsed time.Duration) report {
+	r := report{total: len(results), elapsed: elapsed, codes: make(map[codes.Code]int)}
+	for _, res := range results {
+		r.codes[res.code]++
+		r.latencies = append(r.latencies, res.latency)
+	}
+	sort.Slice(r.latencies, func(i, j int) bool { return r.latencies[i] < r.latencies[j] })
+	return r
+}
+
+// Latency below which p percent of the calls completed
+func (r report) percentile(p float64) time.Duration {
+	if len(r.latencies) == 0 {
+		return 0
+	}
+	i := int(float64(len(r.latencies))*p/100+0.5) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(r.latencies) {
+		i = len(r.latencies) - 1
+	}
+	return r.latencies[i]
+}
+
+func (r report) print(w io.Writer, session *pb.Session) {
+	fmt.Fprintf(w, "Session %s: %d/%d spots reserved\n", session.Id, session.ReservedSpots, session.Capacity)
+	fmt.Fprintf(w, "Requests:   %d in %v (%.0f req/s)\n", r.total, r.elapsed.Round(time.Millisecond), float64(r.total)/r.elapsed.Seconds())
+
+	fmt.Fprintln(w, "Status codes:")
+	var seen []codes.Code
+	for c := range r.codes {
+		seen = append(seen, c)
+	}
+	sort.Slice(seen, func(i, j int) bool { return seen[i] < seen[j] })
+	for _, c := range seen {
+		fmt.Fprintf(w, "  %-20s %6d (%.1f%%)\n", c, r.codes[c], 100*float64(r.codes[c])/float64(r.total))
+	}
+
+	fmt.Fprintln(w, "Latency:")
+	for _, p := range []float64{50, 90, 99} {
+		fmt.Fprintf(w, "  p%-3.0f %v\n", p, r.percentile(p).Round(time.Microsecond))
+	}
+	if len(r.latencies) > 0 {
+		fmt.Fprintf(w, "  max  %v\n", r.latencies[len(r.latencies)-1].Round(time.Microsecond))
+	}
+
+	if session.ReservedSpots > session.Capacity || r.codes[codes.OK] > int(session.Capacity) {
+		fmt.Fprintln(w, "OVERBOOKED: more reservations succeeded than the session holds")
+	}
+}
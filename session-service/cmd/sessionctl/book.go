@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	pb "session-service/proto"
+)
+
+func newBookCmd() *cobra.Command {
+	var bestEffort bool
+
+	cmd := &cobra.Command{
+		Use:   "book SESSION_ID USER_ID...",
+		Short: "Book members on a session",
+		Long: "Book members on a session in one batch. Nobody is booked unless\n" +
+			"everyone can be, except with --best-effort.",
+		Args: cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, ctx, done, err := connect(cmd)
+			if err != nil {
+				return err
+			}
+			defer done()
+
+			resp, err := client.BatchCreateReservations(ctx, &pb.BatchCreateReservationsRequest{
+				SessionId:  args[0],
+				UserIds:    args[1:],
+				BestEffort: bestEffort,
+			})
+			if err != nil {
+				return err
+			}
+
+			out := cmd.OutOrStdout()
+			tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "USER ID\tRESULT\tRESERVATION")
+			for _, r := range resp.Results {
+				result := "booked"
+				if r.Code != "OK" {
+					result = r.Message
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%s\n", r.UserId, result, r.GetReservation().GetId())
+			}
+			if err := tw.Flush(); err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "\nBooked %d of %d members\n", resp.Booked, len(resp.Results))
+			if int(resp.Booked) < len(resp.Results) {
+				return fmt.Errorf("%d members not booked", len(resp.Results)-int(resp.Booked))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&bestEffort, "best-effort", false, "book whoever can be booked instead of all or nothing")
+	return cmd
+}
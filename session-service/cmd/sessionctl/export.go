@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/spf13/cobra"
+
+	pb "session-service/proto"
+)
+
+func newExportCmd() *cobra.Command {
+	var (
+		output      string
+		date        string
+		sessionType string
+		coachID     string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export sessions as CSV",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, ctx, done, err := connect(cmd)
+			if err != nil {
+				return err
+			}
+			defer done()
+
+			stream, err := client.StreamSessions(ctx, &pb.StreamSessionsRequest{
+				Date:        date,
+				SessionType: sessionType,
+				CoachId:     coachID,
+				IncludePast: true,
+			})
+			if err != nil {
+				return err
+			}
+
+			var w io.Writer = cmd.OutOrStdout()
+			if output != "-" {
+				f, err := os.Create(output)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				w = f
+			}
+			return writeSessionsCSV(w, stream)
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", "-", "file to write, - for stdout")
+	cmd.Flags().StringVar(&date, "date", "", "only sessions on this day (YYYY-MM-DD)")
+	cmd.Flags().StringVar(&sessionType, "type", "", "only sessions of this type")
+	cmd.Flags().StringVar(&coachID, "coach", "", "only sessions of this coach")
+	return cmd
+}
+
+// Write the sessions received on stream as CSV, each as it arrives
+func writeSessionsCSV(w io.Writer, stream pb.SessionService_StreamSessionsClient) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{
+		"id", "title", "description", "coach_id", "coach_name", "capacity", "reserved_spots",
+		"start_time", "end_time", "location", "session_type", "difficulty_level", "is_cancelled",
+		"cancellation_reason",
+	})
+	for {
+		s, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		cw.Write([]string{
+			s.Id, s.Title, s.Description, s.CoachId, s.CoachName,
+			strconv.Itoa(int(s.Capacity)), strconv.Itoa(int(s.ReservedSpots)),
+			s.StartTime, s.EndTime, s.Location, s.SessionType, s.DifficultyLevel,
+			strconv.FormatBool(s.IsCancelled), s.CancellationReason,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,83 @@
+// Command sessionctl is an admin CLI for the session service gRPC API.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+
+	pb "session-service/proto"
+)
+
+var (
+	addr    string
+	token   string
+	timeout time.Duration
+)
+
+func main() {
+	root := &cobra.Command{
+		Use:           "sessionctl",
+		Short:         "Administer gym sessions through the session service API",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+
+	defaultAddr := os.Getenv("SESSION_SERVICE_URL")
+	if defaultAddr == "" {
+		defaultAddr = "localhost:50051"
+	}
+	root.PersistentFlags().StringVar(&addr, "addr", defaultAddr, "session service address (env SESSION_SERVICE_URL)")
+	root.PersistentFlags().StringVar(&token, "token", os.Getenv("SESSIONCTL_TOKEN"), "bearer token of a coach or admin (env SESSIONCTL_TOKEN)")
+	root.PersistentFlags().DurationVar(&timeout, "timeout", 30*time.Second, "deadline for the whole command")
+
+	root.AddCommand(
+		newTodayCmd(),
+		newCancelCmd(),
+		newCapacityCmd(),
+		newRosterCmd(),
+		newBookCmd(),
+		newQueueCmd(),
+		newExportCmd(),
+		newReplayCmd(),
+		newSelfTestCmd(),
+	)
+
+	if err := root.Execute(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+// Connect to the session service. The returned function closes the
+// connection and releases the command deadline.
+func connect(cmd *cobra.Command) (pb.SessionServiceClient, context.Context, func(), error) {
+	conn, ctx, done, err := dial(cmd)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return pb.NewSessionServiceClient(conn), ctx, done, nil
+}
+
+// Like connect, for commands that need the raw connection
+func dial(cmd *cobra.Command) (*grpc.ClientConn, context.Context, func(), error) {
+	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+	ctx, cancel := context.WithTimeout(cmd.Context(), timeout)
+	if token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+	}
+
+	return conn, ctx, func() {
+		cancel()
+		conn.Close()
+	}, nil
+}
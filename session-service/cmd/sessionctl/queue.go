@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	pb "session-service/proto"
+)
+
+func newQueueCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "queue SESSION_ID USER_ID",
+		Short: "Book a member through the queue of a flash-sale session",
+		Long: "Join the booking queue of a session that takes queued bookings only,\n" +
+			"print the position in line as it changes and wait for the result.",
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, ctx, done, err := connect(cmd)
+			if err != nil {
+				return err
+			}
+			defer done()
+
+			stream, err := client.QueueReservation(ctx, &pb.QueueReservationRequest{SessionId: args[0], UserId: args[1]})
+			if err != nil {
+				return err
+			}
+			out := cmd.OutOrStdout()
+			for {
+				st, err := stream.Recv()
+				if err == io.EOF {
+					return fmt.Errorf("stream ended without a result")
+				}
+				if err != nil {
+					return err
+				}
+				switch st.State {
+				case "queued":
+					fmt.Fprintf(out, "Waiting, %d ahead\n", st.Position)
+				case "booked":
+					fmt.Fprintf(out, "Booked: reservation %s\n", st.Reservation.GetId())
+					return nil
+				default:
+					return fmt.Errorf("not booked: %s (%s)", st.Message, st.Code)
+				}
+			}
+		},
+	}
+}
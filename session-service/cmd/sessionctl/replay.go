@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"session-service/internal/recording"
+)
+
+func newReplayCmd() *cobra.Command {
+	var verbose bool
+
+	cmd := &cobra.Command{
+		Use:   "replay FILE...",
+		Short: "Re-send calls recorded with RECORD_RPC_DIR and compare the outcome",
+		Long: "Re-send calls recorded with RECORD_RPC_DIR, in order, and report every call\n" +
+			"whose status code differs from the recording. Point --addr at a local\n" +
+			"instance: replayed calls create and modify real data.",
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			conn, ctx, done, err := dial(cmd)
+			if err != nil {
+				return err
+			}
+			defer done()
+
+			out := cmd.OutOrStdout()
+			replayer := recording.NewReplayer(conn)
+			var total, mismatches int
+			for _, path := range args {
+				records, err := recording.ReadFile(path)
+				if err != nil {
+					return err
+				}
+				for _, rec := range records {
+					result, err := replayer.Replay(ctx, rec)
+					if err != nil {
+						return err
+					}
+					total++
+					if !result.Matches() {
+						mismatches++
+						fmt.Fprintf(out, "MISMATCH %s: recorded %s %q, got %s %q\n  request: %s\n",
+							rec.Method, rec.Code, rec.Message, result.Code, result.Message, rec.Request)
+					} else if verbose {
+						fmt.Fprintf(out, "ok       %s: %s\n", rec.Method, result.Code)
+					}
+				}
+			}
+
+			fmt.Fprintf(out, "Replayed %d calls, %d mismatches\n", total, mismatches)
+			if mismatches > 0 {
+				return fmt.Errorf("%d replayed calls did not match the recording", mismatches)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&verbose, "verbose", false, "also list calls that matched")
+	return cmd
+}
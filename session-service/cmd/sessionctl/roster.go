@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	pb "session-service/proto"
+)
+
+func newRosterCmd() *cobra.Command {
+	var all bool
+
+	cmd := &cobra.Command{
+		Use:   "roster SESSION_ID",
+		Short: "Show the members booked on a session",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, ctx, done, err := connect(cmd)
+			if err != nil {
+				return err
+			}
+			defer done()
+
+			session, err := client.GetSession(ctx, &pb.GetSessionRequest{SessionId: args[0]})
+			if err != nil {
+				return err
+			}
+
+			req := &pb.ListSessionReservationsRequest{SessionId: args[0], Status: "confirmed", PageSize: listPageSize}
+			if all {
+				req.Status = ""
+			}
+			var reservations []*pb.Reservation
+			for {
+				resp, err := client.ListSessionReservations(ctx, req)
+				if err != nil {
+					return err
+				}
+				reservations = append(reservations, resp.Reservations...)
+				if resp.NextPageToken == "" {
+					break
+				}
+				req.PageToken = resp.NextPageToken
+			}
+
+			out := cmd.OutOrStdout()
+			fmt.Fprintf(out, "%s (%s) - %d/%d booked\n\n", session.Title, session.StartTime, session.ReservedSpots, session.Capacity)
+			tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "RESERVATION\tUSER ID\tNAME\tSTATUS\tBOOKED AT")
+			for _, r := range reservations {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", r.Id, r.UserId, r.UserName, r.Status, r.ReservationTime)
+			}
+			return tw.Flush()
+		},
+	}
+	cmd.Flags().BoolVar(&all, "all", false, "include cancelled reservations")
+	return cmd
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	pb "session-service/proto"
+)
+
+func newSelfTestCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "selftest",
+		Short: "Run the service self-test and fail if any step fails",
+		Long: "Ask the service to create, book, cancel and delete a scratch session and\n" +
+			"print the latency of each step. Exits non-zero if any step fails, so it\n" +
+			"can gate a deployment.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, ctx, done, err := connect(cmd)
+			if err != nil {
+				return err
+			}
+			defer done()
+
+			resp, err := client.RunSelfTest(ctx, &pb.RunSelfTestRequest{})
+			if err != nil {
+				return err
+			}
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "STEP\tRESULT\tLATENCY\tERROR")
+			for _, step := range resp.Steps {
+				result := "ok"
+				if !step.Ok {
+					result = "FAILED"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%.1fms\t%s\n", step.Name, result, step.LatencyMs, step.Error)
+			}
+			fmt.Fprintf(w, "total\t\t%.1fms\t\n", resp.TotalLatencyMs)
+			w.Flush()
+
+			if !resp.Ok {
+				return fmt.Errorf("self-test failed")
+			}
+			return nil
+		},
+	}
+}
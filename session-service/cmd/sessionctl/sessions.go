@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	pb "session-service/proto"
+)
+
+// Page size used when walking through ListSessions results
+const listPageSize = 100
+
+func newTodayCmd() *cobra.Command {
+	var date string
+
+	cmd := &cobra.Command{
+		Use:   "today",
+		Short: "List today's sessions",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, ctx, done, err := connect(cmd)
+			if err != nil {
+				return err
+			}
+			defer done()
+
+			sessions, err := listSessions(ctx, client, &pb.ListSessionsRequest{Date: date, IncludePast: true})
+			if err != nil {
+				return err
+			}
+			printSessions(cmd.OutOrStdout(), sessions)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&date, "date", time.Now().Format("2006-01-02"), "day to list (YYYY-MM-DD)")
+	return cmd
+}
+
+func newCancelCmd() *cobra.Command {
+	var (
+		reason string
+		dryRun bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "cancel SESSION_ID",
+		Short: "Cancel a session",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, ctx, done, err := connect(cmd)
+			if err != nil {
+				return err
+			}
+			defer done()
+
+			session, err := client.CancelSession(ctx, &pb.CancelSessionRequest{SessionId: args[0], Reason: reason, DryRun: dryRun})
+			if err != nil {
+				return err
+			}
+			verb := "Cancelled"
+			if dryRun {
+				verb = "Would cancel"
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "%s session %s (%s, %d members booked)\n", verb, session.Id, session.Title, session.ReservedSpots)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&reason, "reason", "", "reason shown to booked members (required)")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "check the session can be cancelled without cancelling it")
+	cmd.MarkFlagRequired("reason")
+	return cmd
+}
+
+func newCapacityCmd() *cobra.Command {
+	var add, set int32
+
+	cmd := &cobra.Command{
+		Use:   "capacity SESSION_ID",
+		Short: "Change the capacity of a session",
+		Example: "  sessionctl capacity 42 --add 5\n" +
+			"  sessionctl capacity 42 --set 20",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if (add == 0) == (set == 0) {
+				return fmt.Errorf("exactly one of --add or --set is required")
+			}
+
+			client, ctx, done, err := connect(cmd)
+			if err != nil {
+				return err
+			}
+			defer done()
+
+			session, err := client.GetSession(ctx, &pb.GetSessionRequest{SessionId: args[0]})
+			if err != nil {
+				return err
+			}
+			capacity := set
+			if add != 0 {
+				capacity = session.Capacity + add
+			}
+			if capacity < session.ReservedSpots {
+				return fmt.Errorf("capacity %d is below the %d spots already reserved", capacity, session.ReservedSpots)
+			}
+
+			// UpdateSession replaces the whole session, so send back the current values
+			updated, err := client.UpdateSession(ctx, &pb.UpdateSessionRequest{
+				SessionId:       session.Id,
+				Title:           session.Title,
+				Description:     session.Description,
+				CoachId:         session.CoachId,
+				Capacity:        capacity,
+				StartTime:       session.StartTime,
+				EndTime:         session.EndTime,
+				Location:        session.Location,
+				SessionType:     session.SessionType,
+				DifficultyLevel: session.DifficultyLevel,
+				IsCancelled:     session.IsCancelled,
+			})
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Session %s capacity: %d -> %d\n", updated.Id, session.Capacity, updated.Capacity)
+			return nil
+		},
+	}
+	cmd.Flags().Int32Var(&add, "add", 0, "number of spots to add (negative to remove)")
+	cmd.Flags().Int32Var(&set, "set", 0, "new total capacity")
+	return cmd
+}
+
+// Fetch every page of a ListSessions query
+func listSessions(ctx context.Context, client pb.SessionServiceClient, req *pb.ListSessionsRequest) ([]*pb.Session, error) {
+	var sessions []*pb.Session
+	req.PageSize = listPageSize
+	for {
+		resp, err := client.ListSessions(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, resp.Sessions...)
+		if resp.NextPageToken == "" {
+			return sessions, nil
+		}
+		req.PageToken = resp.NextPageToken
+	}
+}
+
+func printSessions(w io.Writer, sessions []*pb.Session) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSTART\tEND\tTITLE\tCOACH\tBOOKED\tLOCATION\tSTATUS")
+	for _, s := range sessions {
+		state := "open"
+		if s.IsCancelled {
+			state = "cancelled"
+		} else if s.ReservedSpots >= s.Capacity {
+			state = "full"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%d/%d\t%s\t%s\n",
+			s.Id, s.StartTime, s.EndTime, s.Title, s.CoachName,
+			s.ReservedSpots, s.Capacity, s.Location, state)
+	}
+	tw.Flush()
+}
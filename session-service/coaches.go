@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"session-service/internal/auth"
+	"session-service/internal/store"
+	"session-service/internal/users"
+)
+
+// Default time a call to the user service may take, per attempt
+const defaultUserServiceTimeout = 2 * time.Second
+
+// Default time a user found in the user service is kept
+const defaultUserCacheTTL = 5 * time.Minute
+
+// Find the name of the coach to store with a session. The user service is
+// the reference, and a coach it doesn't know is refused. While it is down,
+// or when the service runs without one, the name stored with the coach's
+// latest session is used instead; a new coach then gets their ID as name,
+// or is refused if the user service is merely down.
+func (s *server) coachName(ctx context.Context, coachID string) (string, error) {
+	if s.users != nil {
+		user, err := s.users.GetUser(ctx, coachID, bearerToken(ctx))
+		switch {
+		case err == users.ErrNotFound:
+			return "", status.Errorf(codes.NotFound, "Coach not found: %v", coachID)
+		case err == nil && user.Role != auth.RoleCoach && user.Role != auth.RoleAdmin:
+			return "", status.Errorf(codes.InvalidArgument, "Invalid coach_id: user %v is not a coach", coachID)
+		case err == nil && user.Name() != "":
+			return user.Name(), nil
+		case err == nil:
+			err = errors.New("user without a name")
+		}
+		log.Printf("Using the stored name of coach %s: %v", coachID, err)
+	}
+
+	latest, err := s.repo.ListSessions(ctx, store.SessionFilter{CoachID: coachID}, nil, true, 1)
+	if err != nil {
+		return "", status.Errorf(storeErrorCode(err), "Failed to find coach: %v", err)
+	}
+	if len(latest) > 0 {
+		return latest[0].CoachName, nil
+	}
+	if s.users != nil {
+		return "", status.Errorf(codes.Unavailable, "Cannot check coach %v: user service unavailable", coachID)
+	}
+	return coachID, nil
+}
+
+// Bearer token the caller sent, forwarded to the user service
+func bearerToken(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get("authorization"); len(values) > 0 {
+		return strings.TrimPrefix(values[0], "Bearer ")
+	}
+	return ""
+}
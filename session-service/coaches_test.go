@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"session-service/internal/faults"
+	"session-service/internal/users"
+	"session-service/internal/users/usersmock"
+)
+
+// Directory of the user service knowing the given users only
+func userDirectory(known ...*users.User) *usersmock.DirectoryMock {
+	return &usersmock.DirectoryMock{
+		GetUserFunc: func(ctx context.Context, id, token string) (*users.User, error) {
+			for _, u := range known {
+				if u.ID == id {
+					return u, nil
+				}
+			}
+			return nil, users.ErrNotFound
+		},
+	}
+}
+
+func TestServerCreateSessionCoach(t *testing.T) {
+	s := newTestServer()
+	dir := userDirectory(
+		&users.User{ID: "coach-1", FirstName: "Sarah", LastName: "Johnson", Role: "coach"},
+		&users.User{ID: "member-1", FirstName: "Tom", LastName: "Lee", Role: "member"},
+	)
+	s.users = dir
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer token"))
+	create := func(coachID string) (string, error) {
+		req := validCreateSessionRequest()
+		req.CoachId = coachID
+		// Each time at the same place and time
+		req.AllowConflicts = true
+		session, err := s.CreateSession(ctx, req)
+		if err != nil {
+			return "", err
+		}
+		return session.CoachName, nil
+	}
+
+	if name, err := create("coach-1"); err != nil || name != "Sarah Johnson" {
+		t.Errorf("Expected Sarah Johnson, got %q, %v", name, err)
+	}
+	if calls := dir.GetUserCalls(); calls[0].Token != "token" {
+		t.Errorf("Expected the caller's token forwarded, got %q", calls[0].Token)
+	}
+	if _, err := create("coach-2"); status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound for an unknown coach, got %v", err)
+	}
+	if _, err := create("member-1"); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument for a member, got %v", err)
+	}
+
+	// While the user service is down, coaches keep the name they had
+	dir.GetUserFunc = func(ctx context.Context, id, token string) (*users.User, error) {
+		return nil, fmt.Errorf("%w: 503 Service Unavailable", users.ErrUnavailable)
+	}
+	if name, err := create("coach-1"); err != nil || name != "Sarah Johnson" {
+		t.Errorf("Expected the stored name, got %q, %v", name, err)
+	}
+	if _, err := create("coach-3"); status.Code(err) != codes.Unavailable {
+		t.Errorf("Expected Unavailable for a new coach, got %v", err)
+	}
+
+	// Without a user service coaches aren't checked
+	s.users = nil
+	if name, err := create("coach-3"); err != nil || name != "coach-3" {
+		t.Errorf("Expected the coach ID as name, got %q, %v", name, err)
+	}
+}
+
+func TestServerCreateSessionCoachTimeout(t *testing.T) {
+	s := newTestServer()
+	dir := userDirectory(&users.User{ID: "coach-1", FirstName: "Sarah", LastName: "Johnson", Role: "coach"})
+	s.users = dir
+	ctx := context.Background()
+	if _, err := s.CreateSession(ctx, validCreateSessionRequest()); err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+
+	// An unresponsive user service leaves the name stored before
+	injector, _ := faults.Parse("users_timeout=1")
+	s.users = faults.WrapDirectory(dir, injector, time.Millisecond)
+	req := validCreateSessionRequest()
+	req.AllowConflicts = true
+	session, err := s.CreateSession(ctx, req)
+	if err != nil || session.CoachName != "Sarah Johnson" {
+		t.Errorf("Expected the stored name, got %+v, %v", session, err)
+	}
+	req.CoachId = "coach-2"
+	if _, err := s.CreateSession(ctx, req); status.Code(err) != codes.Unavailable {
+		t.Errorf("Expected Unavailable for a new coach, got %v", err)
+	}
+	if n := len(dir.GetUserCalls()); n != 1 {
+		t.Errorf("Expected the user service asked once, got %d calls", n)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"session-service/internal/fixtures"
+	"session-service/internal/store"
+)
+
+// Fill the store with a week of classes starting today, so the API has
+// something to show
+func seedDemoData(ctx context.Context, repo store.Repository, now time.Time) error {
+	classes := []*fixtures.SessionBuilder{
+		fixtures.NewTestSession().Titled("Morning Yoga").OfType("yoga", "beginner").
+			WithCoach("coach-1", "Sarah Johnson").AtLocation("Studio A").WithCapacity(15).StartingIn(8 * time.Hour),
+		fixtures.NewTestSession().Titled("HIIT Express").OfType("cardio", "intermediate").
+			WithCoach("coach-2", "Mike Chen").AtLocation("Main Floor").WithCapacity(20).StartingIn(12 * time.Hour),
+		fixtures.NewTestSession().Titled("Strength Foundations").OfType("strength", "beginner").
+			WithCoach("coach-3", "Alex Rivera").AtLocation("Weight Room").WithCapacity(10).StartingIn(17 * time.Hour),
+		fixtures.NewTestSession().Titled("Power Vinyasa").OfType("yoga", "advanced").
+			WithCoach("coach-1", "Sarah Johnson").AtLocation("Studio A").WithCapacity(12).StartingIn(19 * time.Hour),
+	}
+
+	today := now.UTC().Truncate(24 * time.Hour)
+	for day := 0; day < 7; day++ {
+		for _, class := range classes {
+			_, err := class.RelativeTo(today.AddDate(0, 0, day)).Create(ctx, repo)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
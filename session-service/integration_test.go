@@ -0,0 +1,1409 @@
+//go:build integration
+// +build integration
+
+// Integration tests run the gRPC server against a throwaway Postgres
+// container. Each test gets its own database cloned from a migrated template,
+// so tests run in parallel. They need Docker and the generated protobuf code:
+//
+//	go test -tags=integration ./...
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"pgregory.net/rapid"
+
+	"session-service/internal/anonymize"
+	"session-service/internal/cache"
+	"session-service/internal/clock"
+	"session-service/internal/fixtures"
+	"session-service/internal/leader"
+	"session-service/internal/store"
+	"session-service/internal/store/storemock"
+	"session-service/internal/store/storetest"
+	"session-service/internal/testdb"
+	"session-service/internal/watch"
+	pb "session-service/proto"
+)
+
+var template *testdb.Template
+
+func TestMain(m *testing.M) {
+	ctx := context.Background()
+
+	container, dbURL, err := startPostgres(ctx)
+	if err != nil {
+		log.Fatalf("Failed to start postgres container: %v", err)
+	}
+
+	template, err = testdb.NewTemplate(ctx, dbURL, "gym_template", func(db *sql.DB) error {
+		return initDatabase(ctx, db)
+	})
+	if err != nil {
+		container.Terminate(ctx)
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+
+	code := m.Run()
+
+	template.Close()
+	container.Terminate(ctx)
+	os.Exit(code)
+}
+
+// Start a Postgres container and return its connection URL
+func startPostgres(ctx context.Context) (testcontainers.Container, string, error) {
+	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        "postgres:14-alpine",
+			ExposedPorts: []string{"5432/tcp"},
+			Env: map[string]string{
+				"POSTGRES_USER":     "postgres",
+				"POSTGRES_PASSWORD": "password",
+				"POSTGRES_DB":       "gym",
+			},
+			// Postgres logs this line twice: once for the init run, once for the real start
+			WaitingFor: wait.ForLog("database system is ready to accept connections").
+				WithOccurrence(2).
+				WithStartupTimeout(time.Minute),
+		},
+		Started: true,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	host, err := container.Host(ctx)
+	if err != nil {
+		container.Terminate(ctx)
+		return nil, "", err
+	}
+	port, err := container.MappedPort(ctx, "5432")
+	if err != nil {
+		container.Terminate(ctx)
+		return nil, "", err
+	}
+
+	return container, fmt.Sprintf("postgres://postgres:password@%s:%s/gym?sslmode=disable", host, port.Port()), nil
+}
+
+// Serve the session service on a random local port, backed by a fresh copy of
+// the template database, and return a client for it. The test runs in
+// parallel with the other tests calling startServer.
+func startServer(t *testing.T) pb.SessionServiceClient {
+	t.Helper()
+	t.Parallel()
+	db := template.Clone(t)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	s := newGRPCServer(newServer(store.NewPostgres(db), clock.Real{}), serverOptions{})
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Failed to dial server: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return pb.NewSessionServiceClient(conn)
+}
+
+func newCreateSessionRequest() *pb.CreateSessionRequest {
+	return &pb.CreateSessionRequest{
+		Title:           "Morning Yoga",
+		Description:     "Start your day with energizing yoga session",
+		CoachId:         "coach-1",
+		Capacity:        15,
+		StartTime:       "2030-05-15T08:00:00Z",
+		EndTime:         "2030-05-15T09:00:00Z",
+		Location:        "Studio A",
+		SessionType:     "yoga",
+		DifficultyLevel: "intermediate",
+	}
+}
+
+func assertCode(t *testing.T, err error, want codes.Code) {
+	t.Helper()
+	if got := status.Code(err); got != want {
+		t.Fatalf("Expected code %v, got %v (%v)", want, got, err)
+	}
+}
+
+func TestCreateAndGetSession(t *testing.T) {
+	client := startServer(t)
+	ctx := context.Background()
+
+	req := newCreateSessionRequest()
+	created, err := client.CreateSession(ctx, req)
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	if created.Id == "" {
+		t.Fatal("CreateSession returned an empty id")
+	}
+
+	got, err := client.GetSession(ctx, &pb.GetSessionRequest{SessionId: created.Id})
+	if err != nil {
+		t.Fatalf("GetSession failed: %v", err)
+	}
+	if got.Title != req.Title || got.CoachId != req.CoachId || got.Capacity != req.Capacity {
+		t.Errorf("GetSession returned %+v, want fields from %+v", got, req)
+	}
+	if got.StartTime != req.StartTime || got.EndTime != req.EndTime {
+		t.Errorf("GetSession returned times %s-%s, want %s-%s", got.StartTime, got.EndTime, req.StartTime, req.EndTime)
+	}
+	if got.ReservedSpots != 0 || got.IsCancelled {
+		t.Errorf("New session should be open and empty, got %+v", got)
+	}
+}
+
+func TestCreateSessionMissingFields(t *testing.T) {
+	client := startServer(t)
+
+	req := newCreateSessionRequest()
+	req.Title = ""
+	_, err := client.CreateSession(context.Background(), req)
+	assertCode(t, err, codes.InvalidArgument)
+
+	req = newCreateSessionRequest()
+	req.Capacity = 0
+	_, err = client.CreateSession(context.Background(), req)
+	assertCode(t, err, codes.InvalidArgument)
+}
+
+func TestGetSessionNotFound(t *testing.T) {
+	client := startServer(t)
+
+	_, err := client.GetSession(context.Background(), &pb.GetSessionRequest{SessionId: "999999"})
+	assertCode(t, err, codes.NotFound)
+}
+
+func TestCancelSession(t *testing.T) {
+	client := startServer(t)
+	ctx := context.Background()
+
+	created, err := client.CreateSession(ctx, newCreateSessionRequest())
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+
+	_, err = client.CancelSession(ctx, &pb.CancelSessionRequest{SessionId: created.Id, Reason: "Studio flooded"})
+	if err != nil {
+		t.Fatalf("CancelSession failed: %v", err)
+	}
+
+	got, err := client.GetSession(ctx, &pb.GetSessionRequest{SessionId: created.Id})
+	if err != nil {
+		t.Fatalf("GetSession failed: %v", err)
+	}
+	if !got.IsCancelled || got.CancellationReason != "Studio flooded" {
+		t.Errorf("Session not cancelled: %+v", got)
+	}
+
+	_, err = client.CancelSession(ctx, &pb.CancelSessionRequest{SessionId: created.Id})
+	assertCode(t, err, codes.FailedPrecondition)
+
+	_, err = client.CancelSession(ctx, &pb.CancelSessionRequest{SessionId: "999999"})
+	assertCode(t, err, codes.NotFound)
+}
+
+func TestUpdateSession(t *testing.T) {
+	client := startServer(t)
+	ctx := context.Background()
+
+	created, err := client.CreateSession(ctx, newCreateSessionRequest())
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	// The same coach, in the hour after, in another studio
+	req := newCreateSessionRequest()
+	req.StartTime, req.EndTime = "2030-05-15T09:00:00Z", "2030-05-15T10:00:00Z"
+	req.Location = "Studio B"
+	if _, err := client.CreateSession(ctx, req); err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+
+	update := &pb.UpdateSessionRequest{
+		SessionId:       created.Id,
+		Title:           "Sunrise Yoga",
+		Description:     created.Description,
+		CoachId:         created.CoachId,
+		Capacity:        20,
+		StartTime:       "2030-05-15T09:00:00+02:00",
+		EndTime:         "2030-05-15T10:00:00+02:00",
+		Location:        created.Location,
+		SessionType:     created.SessionType,
+		DifficultyLevel: created.DifficultyLevel,
+	}
+	if _, err := client.UpdateSession(ctx, update); err != nil {
+		t.Fatalf("UpdateSession failed: %v", err)
+	}
+	got, err := client.GetSession(ctx, &pb.GetSessionRequest{SessionId: created.Id})
+	if err != nil {
+		t.Fatalf("GetSession failed: %v", err)
+	}
+	if got.Title != "Sunrise Yoga" || got.Capacity != 20 || got.StartTime != "2030-05-15T07:00:00Z" {
+		t.Errorf("Session not updated: %+v", got)
+	}
+
+	// Half past eight to half past nine overlaps the coach's next session
+	update.StartTime, update.EndTime = "2030-05-15T08:30:00Z", "2030-05-15T09:30:00Z"
+	_, err = client.UpdateSession(ctx, update)
+	assertCode(t, err, codes.FailedPrecondition)
+
+	update.SessionId = "999999"
+	_, err = client.UpdateSession(ctx, update)
+	assertCode(t, err, codes.NotFound)
+}
+
+func TestSessionSeries(t *testing.T) {
+	client := startServer(t)
+	ctx := context.Background()
+
+	series, err := client.CreateSessionSeries(ctx, &pb.CreateSessionSeriesRequest{
+		Session:    newCreateSessionRequest(),
+		Recurrence: &pb.Recurrence{Weekdays: []string{"monday", "wednesday"}, Count: 4},
+		TimeZone:   "Europe/Paris",
+	})
+	if err != nil {
+		t.Fatalf("CreateSessionSeries failed: %v", err)
+	}
+	list, err := client.ListSessions(ctx, &pb.ListSessionsRequest{SeriesId: series.SeriesId, IncludePast: true})
+	if err != nil {
+		t.Fatalf("ListSessions failed: %v", err)
+	}
+	if len(list.Sessions) != 4 || list.Sessions[0].Id != series.Sessions[0].Id || list.Sessions[3].SeriesId != series.SeriesId {
+		t.Fatalf("Expected the 4 occurrences of series %s, got %+v", series.SeriesId, list.Sessions)
+	}
+
+	second := series.Sessions[1]
+	updated, err := client.UpdateSessionSeries(ctx, &pb.UpdateSessionRequest{
+		SessionId:       second.Id,
+		Title:           "Evening Yoga",
+		CoachId:         second.CoachId,
+		Capacity:        second.Capacity,
+		StartTime:       "2030-05-20T18:00:00Z",
+		EndTime:         "2030-05-20T19:00:00Z",
+		Location:        second.Location,
+		SessionType:     second.SessionType,
+		DifficultyLevel: second.DifficultyLevel,
+	})
+	if err != nil {
+		t.Fatalf("UpdateSessionSeries failed: %v", err)
+	}
+	if len(updated.Sessions) != 3 || updated.Sessions[2].StartTime != "2030-05-27T18:00:00Z" {
+		t.Errorf("Expected the last 3 occurrences moved to 18:00, got %+v", updated.Sessions)
+	}
+
+	cancelled, err := client.CancelSessionSeries(ctx, &pb.CancelSessionRequest{SessionId: second.Id, Reason: "Coach left"})
+	if err != nil {
+		t.Fatalf("CancelSessionSeries failed: %v", err)
+	}
+	if len(cancelled.Sessions) != 3 {
+		t.Errorf("Expected the last 3 occurrences cancelled, got %+v", cancelled.Sessions)
+	}
+	got, err := client.GetSession(ctx, &pb.GetSessionRequest{SessionId: series.Sessions[0].Id})
+	if err != nil || got.IsCancelled || got.Title != "Morning Yoga" {
+		t.Errorf("Expected the first occurrence left alone, got %+v, %v", got, err)
+	}
+}
+
+func TestListSessions(t *testing.T) {
+	client := startServer(t)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), gymMetadataKey, "north")
+
+	// A week of classes, two a day, and one in another gym
+	var week []string
+	for day := 0; day < 7; day++ {
+		for _, hour := range []int{18, 8} {
+			req := newCreateSessionRequest()
+			start := time.Date(2030, 5, 13+day, hour, 0, 0, 0, time.UTC)
+			req.StartTime = start.Format(time.RFC3339)
+			req.EndTime = start.Add(time.Hour).Format(time.RFC3339)
+			if hour == 18 {
+				req.SessionType = "cardio"
+			}
+			created, err := client.CreateSession(ctx, req)
+			if err != nil {
+				t.Fatalf("CreateSession failed: %v", err)
+			}
+			week = append(week, created.Id)
+		}
+	}
+	if _, err := client.CreateSession(context.Background(), newCreateSessionRequest()); err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+
+	req := &pb.ListSessionsRequest{
+		StartFrom:   "2030-05-13T00:00:00Z",
+		StartBefore: "2030-05-20T00:00:00Z",
+		PageSize:    4,
+	}
+	var got []*pb.Session
+	for pages := 1; ; pages++ {
+		resp, err := client.ListSessions(ctx, req)
+		if err != nil {
+			t.Fatalf("ListSessions failed: %v", err)
+		}
+		got = append(got, resp.Sessions...)
+		if resp.NextPageToken == "" {
+			if pages != 4 {
+				t.Errorf("Expected 4 pages, got %d", pages)
+			}
+			break
+		}
+		req.PageToken = resp.NextPageToken
+	}
+	if len(got) != len(week) {
+		t.Fatalf("Expected the %d sessions of the week, got %d", len(week), len(got))
+	}
+	for i := 1; i < len(got); i++ {
+		if got[i].StartTime < got[i-1].StartTime {
+			t.Errorf("Sessions out of order: %s after %s", got[i].StartTime, got[i-1].StartTime)
+		}
+	}
+
+	resp, err := client.ListSessions(ctx, &pb.ListSessionsRequest{Date: "2030-05-15", SessionType: "cardio"})
+	if err != nil || len(resp.Sessions) != 1 || resp.Sessions[0].Id != week[4] || resp.NextPageToken != "" {
+		t.Errorf("Expected the cardio class of May 15, got %v, %v", resp, err)
+	}
+
+	// A token only continues the list it came from
+	resp, err = client.ListSessions(ctx, &pb.ListSessionsRequest{PageSize: 1})
+	if err != nil || resp.NextPageToken == "" {
+		t.Fatalf("Expected a next page, got %v, %v", resp, err)
+	}
+	_, err = client.ListSessions(ctx, &pb.ListSessionsRequest{PageSize: 1, Descending: true, PageToken: resp.NextPageToken})
+	assertCode(t, err, codes.InvalidArgument)
+	_, err = client.ListSessions(ctx, &pb.ListSessionsRequest{PageToken: "garbage"})
+	assertCode(t, err, codes.InvalidArgument)
+	_, err = client.ListSessions(ctx, &pb.ListSessionsRequest{StartFrom: "2030-05-13"})
+	assertCode(t, err, codes.InvalidArgument)
+}
+
+func TestRunSelfTest(t *testing.T) {
+	client := startServer(t)
+
+	resp, err := client.RunSelfTest(context.Background(), &pb.RunSelfTestRequest{})
+	if err != nil {
+		t.Fatalf("RunSelfTest failed: %v", err)
+	}
+	if !resp.Ok || len(resp.Steps) != 4 {
+		t.Errorf("Expected 4 successful steps, got %+v", resp.Steps)
+	}
+}
+
+func TestQueueReservation(t *testing.T) {
+	client := startServer(t)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), gymMetadataKey, "north")
+
+	req := newCreateSessionRequest()
+	req.Capacity = 2
+	req.QueuedBooking = true
+	session, err := client.CreateSession(ctx, req)
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+
+	// Every member gets a final status, and only two are booked
+	results := make([]*pb.QueueStatus, 5)
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			stream, err := client.QueueReservation(ctx, &pb.QueueReservationRequest{
+				SessionId: session.Id,
+				UserId:    fmt.Sprintf("member-%d", i),
+			})
+			if err != nil {
+				t.Errorf("QueueReservation failed: %v", err)
+				return
+			}
+			for {
+				st, err := stream.Recv()
+				if err == io.EOF {
+					return
+				}
+				if err != nil {
+					t.Errorf("Receiving queue status failed: %v", err)
+					return
+				}
+				results[i] = st
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	booked := 0
+	for i, st := range results {
+		switch {
+		case st.GetState() == "booked":
+			booked++
+		case st.GetState() != "failed" || st.Code != codes.ResourceExhausted.String():
+			t.Errorf("Member %d: expected booked or ResourceExhausted, got %+v", i, st)
+		}
+	}
+	if booked != 2 {
+		t.Errorf("Expected 2 members booked, got %d", booked)
+	}
+
+	_, err = client.BatchCreateReservations(ctx, &pb.BatchCreateReservationsRequest{SessionId: session.Id, UserIds: []string{"member-9"}})
+	assertCode(t, err, codes.FailedPrecondition)
+}
+
+// Concurrent bookings over gRPC never take more spots than the session has
+func TestCreateReservation(t *testing.T) {
+	client := startServer(t)
+	ctx := context.Background()
+
+	req := newCreateSessionRequest()
+	req.Capacity = 3
+	session, err := client.CreateSession(ctx, req)
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+
+	codesSeen := make([]codes.Code, 10)
+	var wg sync.WaitGroup
+	for i := range codesSeen {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := client.CreateReservation(ctx, &pb.CreateReservationRequest{
+				SessionId: session.Id,
+				UserId:    fmt.Sprintf("member-%d", i),
+			})
+			codesSeen[i] = status.Code(err)
+		}(i)
+	}
+	wg.Wait()
+
+	booked := 0
+	for i, code := range codesSeen {
+		switch code {
+		case codes.OK:
+			booked++
+		case codes.ResourceExhausted:
+		default:
+			t.Errorf("Member %d: expected OK or ResourceExhausted, got %v", i, code)
+		}
+	}
+	if booked != 3 {
+		t.Errorf("Expected 3 members booked, got %d", booked)
+	}
+	got, err := client.GetSession(ctx, &pb.GetSessionRequest{SessionId: session.Id})
+	if err != nil || got.ReservedSpots != 3 {
+		t.Errorf("Expected 3 reserved spots, got %+v, %v", got, err)
+	}
+
+	roster, err := client.ListSessionReservations(ctx, &pb.ListSessionReservationsRequest{SessionId: session.Id, PageSize: 2})
+	if err != nil || len(roster.Reservations) != 2 || roster.NextPageToken == "" {
+		t.Fatalf("Expected a first page of 2 reservations, got %+v, %v", roster, err)
+	}
+	member := roster.Reservations[0].UserId
+	_, err = client.CreateReservation(ctx, &pb.CreateReservationRequest{SessionId: session.Id, UserId: member})
+	assertCode(t, err, codes.AlreadyExists)
+
+	// Cancelling frees a spot for somebody else
+	_, err = client.CancelReservation(ctx, &pb.CancelReservationRequest{ReservationId: roster.Reservations[0].Id, UserId: "someone-else"})
+	assertCode(t, err, codes.PermissionDenied)
+	if _, err := client.CancelReservation(ctx, &pb.CancelReservationRequest{ReservationId: roster.Reservations[0].Id, UserId: member}); err != nil {
+		t.Fatalf("CancelReservation failed: %v", err)
+	}
+	if _, err := client.CreateReservation(ctx, &pb.CreateReservationRequest{SessionId: session.Id, UserId: "member-late"}); err != nil {
+		t.Errorf("Expected the freed spot to be bookable, got %v", err)
+	}
+
+	mine, err := client.ListUserReservations(ctx, &pb.ListUserReservationsRequest{UserId: member})
+	if err != nil || len(mine.Reservations) != 1 || mine.Reservations[0].Status != "cancelled" {
+		t.Errorf("Expected the cancelled reservation of %s, got %+v, %v", member, mine, err)
+	}
+}
+
+// Cancellations hand their spots to the waitlist without ever overfilling
+// the session
+func TestWaitlist(t *testing.T) {
+	client := startServer(t)
+	ctx := context.Background()
+
+	req := newCreateSessionRequest()
+	req.Capacity = 3
+	session, err := client.CreateSession(ctx, req)
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+
+	var booked []*pb.Reservation
+	for i := 0; i < 3; i++ {
+		r, err := client.CreateReservation(ctx, &pb.CreateReservationRequest{SessionId: session.Id, UserId: fmt.Sprintf("member-%d", i)})
+		if err != nil {
+			t.Fatalf("CreateReservation failed: %v", err)
+		}
+		booked = append(booked, r)
+	}
+	for i := 0; i < 2; i++ {
+		entry, err := client.JoinWaitlist(ctx, &pb.JoinWaitlistRequest{SessionId: session.Id, UserId: fmt.Sprintf("waiting-%d", i)})
+		if err != nil || entry.Position != int32(i+1) {
+			t.Fatalf("Expected waiting-%d at position %d, got %+v, %v", i, i+1, entry, err)
+		}
+	}
+
+	// Three cancellations at once: two go to the waitlist, one stays free
+	var wg sync.WaitGroup
+	for _, r := range booked {
+		wg.Add(1)
+		go func(r *pb.Reservation) {
+			defer wg.Done()
+			if _, err := client.CancelReservation(ctx, &pb.CancelReservationRequest{ReservationId: r.Id, UserId: r.UserId}); err != nil {
+				t.Errorf("CancelReservation failed: %v", err)
+			}
+		}(r)
+	}
+	wg.Wait()
+
+	got, err := client.GetSession(ctx, &pb.GetSessionRequest{SessionId: session.Id})
+	if err != nil || got.ReservedSpots != 2 {
+		t.Errorf("Expected 2 reserved spots, got %+v, %v", got, err)
+	}
+	roster, err := client.ListSessionReservations(ctx, &pb.ListSessionReservationsRequest{SessionId: session.Id, Status: "confirmed"})
+	if err != nil {
+		t.Fatalf("ListSessionReservations failed: %v", err)
+	}
+	var users []string
+	for _, r := range roster.Reservations {
+		users = append(users, r.UserId)
+	}
+	if !reflect.DeepEqual(users, []string{"waiting-0", "waiting-1"}) {
+		t.Errorf("Expected both waiting members booked, got %v", users)
+	}
+
+	_, err = client.JoinWaitlist(ctx, &pb.JoinWaitlistRequest{SessionId: session.Id, UserId: "member-0"})
+	assertCode(t, err, codes.FailedPrecondition)
+}
+
+func TestCancelWhileRebooking(t *testing.T) {
+	client := startServer(t)
+	ctx := context.Background()
+
+	req := newCreateSessionRequest()
+	req.Capacity = 20
+	session, err := client.CreateSession(ctx, req)
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+
+	// Each member cancels and books again at once: both lock the session and
+	// the member's reservation, and must do so in the same order
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		r, err := client.CreateReservation(ctx, &pb.CreateReservationRequest{SessionId: session.Id, UserId: fmt.Sprintf("member-%d", i)})
+		if err != nil {
+			t.Fatalf("CreateReservation failed: %v", err)
+		}
+		wg.Add(2)
+		go func(r *pb.Reservation) {
+			defer wg.Done()
+			if _, err := client.CancelReservation(ctx, &pb.CancelReservationRequest{ReservationId: r.Id, UserId: r.UserId}); err != nil {
+				t.Errorf("CancelReservation failed: %v", err)
+			}
+		}(r)
+		go func(r *pb.Reservation) {
+			defer wg.Done()
+			_, err := client.CreateReservation(ctx, &pb.CreateReservationRequest{SessionId: session.Id, UserId: r.UserId})
+			if err != nil && status.Code(err) != codes.AlreadyExists {
+				t.Errorf("CreateReservation failed: %v", err)
+			}
+		}(r)
+	}
+	wg.Wait()
+
+	got, err := client.GetSession(ctx, &pb.GetSessionRequest{SessionId: session.Id})
+	if err != nil {
+		t.Fatalf("GetSession failed: %v", err)
+	}
+	roster, err := client.ListSessionReservations(ctx, &pb.ListSessionReservationsRequest{SessionId: session.Id, Status: "confirmed"})
+	if err != nil {
+		t.Fatalf("ListSessionReservations failed: %v", err)
+	}
+	if int(got.ReservedSpots) != len(roster.Reservations) {
+		t.Errorf("Expected %d reserved spots, got %d", len(roster.Reservations), got.ReservedSpots)
+	}
+}
+
+func TestBatchCreateReservations(t *testing.T) {
+	client := startServer(t)
+	ctx := context.Background()
+
+	req := newCreateSessionRequest()
+	req.Capacity = 2
+	created, err := client.CreateSession(ctx, req)
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+
+	// Three members don't fit, so nobody is booked
+	users := []string{"user-1", "user-2", "user-3"}
+	resp, err := client.BatchCreateReservations(ctx, &pb.BatchCreateReservationsRequest{SessionId: created.Id, UserIds: users})
+	if err != nil {
+		t.Fatalf("BatchCreateReservations failed: %v", err)
+	}
+	if resp.Booked != 0 || resp.Results[0].Code != "Aborted" || resp.Results[2].Code != "ResourceExhausted" {
+		t.Errorf("Expected the batch to be aborted, got %+v", resp.Results)
+	}
+	got, err := client.GetSession(ctx, &pb.GetSessionRequest{SessionId: created.Id})
+	if err != nil || got.ReservedSpots != 0 {
+		t.Fatalf("Aborted batch left %+v, %v", got, err)
+	}
+
+	resp, err = client.BatchCreateReservations(ctx, &pb.BatchCreateReservationsRequest{SessionId: created.Id, UserIds: users, BestEffort: true})
+	if err != nil {
+		t.Fatalf("BatchCreateReservations failed: %v", err)
+	}
+	if resp.Booked != 2 || resp.Results[1].Reservation.GetStatus() != "confirmed" || resp.Results[2].Code != "ResourceExhausted" {
+		t.Errorf("Expected the first two members booked, got %+v", resp.Results)
+	}
+	got, err = client.GetSession(ctx, &pb.GetSessionRequest{SessionId: created.Id})
+	if err != nil || got.ReservedSpots != 2 {
+		t.Errorf("Expected 2 reserved spots, got %+v, %v", got, err)
+	}
+
+	_, err = client.BatchCreateReservations(ctx, &pb.BatchCreateReservationsRequest{SessionId: "999999", UserIds: users})
+	assertCode(t, err, codes.NotFound)
+}
+
+func TestVerifySchema(t *testing.T) {
+	t.Parallel()
+	db := template.Clone(t)
+	ctx := context.Background()
+
+	var out strings.Builder
+	ok, err := verifySchema(ctx, db, &out)
+	if err != nil {
+		t.Fatalf("verifySchema failed: %v", err)
+	}
+	if !ok {
+		t.Fatalf("Expected a freshly set up database to match, got:\n%s", out.String())
+	}
+
+	if _, err := db.Exec(`ALTER TABLE sessions ADD COLUMN notes TEXT`); err != nil {
+		t.Fatalf("Failed to alter table: %v", err)
+	}
+	out.Reset()
+	ok, err = verifySchema(ctx, db, &out)
+	if err != nil {
+		t.Fatalf("verifySchema failed: %v", err)
+	}
+	if ok || !strings.Contains(out.String(), "+ column sessions.notes text") {
+		t.Errorf("Expected the added column to be reported, got:\n%s", out.String())
+	}
+}
+
+func TestCopyAnonymized(t *testing.T) {
+	t.Parallel()
+	src, dst := template.Clone(t), template.Clone(t)
+	ctx := context.Background()
+
+	session, err := fixtures.NewTestSession().WithCoach("coach-42", "Ahmed Benali").Cancelled("Coach Ahmed is sick").
+		Create(ctx, store.NewPostgres(src))
+	if err != nil {
+		t.Fatalf("Failed to create fixture: %v", err)
+	}
+	_, err = src.Exec(`INSERT INTO reservations (gym_id, session_id, user_id, user_name) VALUES ($1, $2, 'user-7', 'Jane Doe')`,
+		session.GymID, session.ID)
+	if err != nil {
+		t.Fatalf("Failed to create reservation: %v", err)
+	}
+
+	h := anonymize.New("secret")
+	copied, err := copyAnonymized(ctx, src, dst, h, false)
+	if err != nil {
+		t.Fatalf("copyAnonymized failed: %v", err)
+	}
+	if copied["sessions"] != 1 || copied["reservations"] != 1 {
+		t.Errorf("Unexpected row counts %v", copied)
+	}
+
+	got, err := store.NewPostgres(dst).GetSession(ctx, session.ID)
+	if err != nil {
+		t.Fatalf("GetSession failed: %v", err)
+	}
+	if got.CoachID != h.ID("coach-42") || got.CoachName != h.Name("Ahmed Benali") || got.Title != session.Title || got.CancellationReason != "Cancelled" {
+		t.Errorf("Session not anonymized as expected: %+v", got)
+	}
+
+	var userID, userName string
+	err = dst.QueryRow(`SELECT user_id, user_name FROM reservations WHERE session_id = $1`, session.ID).Scan(&userID, &userName)
+	if err != nil {
+		t.Fatalf("Failed to read copied reservation: %v", err)
+	}
+	if userID != h.ID("user-7") || userName == "Jane Doe" {
+		t.Errorf("Reservation not anonymized: %s %s", userID, userName)
+	}
+
+	// New rows must not collide with the copied IDs
+	if err := store.NewPostgres(dst).CreateSession(ctx, fixtures.NewTestSession().Build(), false); err != nil {
+		t.Errorf("CreateSession after copy failed: %v", err)
+	}
+
+	if _, err := copyAnonymized(ctx, src, dst, h, false); err == nil {
+		t.Error("Expected copying into a non-empty database to fail")
+	}
+	if _, err := copyAnonymized(ctx, src, dst, h, true); err != nil {
+		t.Errorf("Copy with replace failed: %v", err)
+	}
+}
+
+func TestReconcileReservedSpots(t *testing.T) {
+	t.Parallel()
+	db := template.Clone(t)
+	repo := store.NewPostgres(db)
+	ctx := context.Background()
+
+	session, err := fixtures.NewTestSession().WithReservedSpots(3).Create(ctx, repo)
+	if err != nil {
+		t.Fatalf("Failed to create fixture: %v", err)
+	}
+	if session.ReservedSpots != 3 {
+		t.Fatalf("Expected 3 reserved spots, got %d", session.ReservedSpots)
+	}
+
+	if _, err := db.Exec(`UPDATE sessions SET reserved_spots = 7 WHERE id = $1`, session.ID); err != nil {
+		t.Fatalf("Failed to corrupt reserved_spots: %v", err)
+	}
+	drift, err := repo.ReconcileReservedSpots(ctx)
+	if err != nil {
+		t.Fatalf("ReconcileReservedSpots failed: %v", err)
+	}
+	want := []store.SpotDrift{{SessionID: session.ID, Recorded: 7, Actual: 3}}
+	if !reflect.DeepEqual(drift, want) {
+		t.Errorf("Expected drift %+v, got %+v", want, drift)
+	}
+
+	got, err := repo.GetSession(ctx, session.ID)
+	if err != nil || got.ReservedSpots != 3 {
+		t.Errorf("Expected reserved spots to be repaired to 3, got %+v, %v", got, err)
+	}
+	if drift, err := repo.ReconcileReservedSpots(ctx); err != nil || len(drift) != 0 {
+		t.Errorf("Expected no drift after repair, got %+v, %v", drift, err)
+	}
+}
+
+func TestCacheInvalidationAcrossReplicas(t *testing.T) {
+	t.Parallel()
+	dsn := template.CloneURL(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Two replicas sharing the database, each caching for longer than the
+	// test and counting its reads from the database
+	replica := func(reads *int32) *cache.Repository {
+		db, err := sql.Open("postgres", dsn)
+		if err != nil {
+			t.Fatalf("Failed to connect to test database: %v", err)
+		}
+		t.Cleanup(func() { db.Close() })
+		pg := store.NewPostgres(db)
+		counted := &storemock.RepositoryMock{
+			CreateSessionFunc: pg.CreateSession,
+			GetSessionFunc: func(ctx context.Context, id int64) (*store.Session, error) {
+				atomic.AddInt32(reads, 1)
+				return pg.GetSession(ctx, id)
+			},
+			CreateReservationFunc: pg.CreateReservation,
+		}
+		notifier := cache.NewNotifier(db)
+		c := cache.New(counted, time.Hour, clock.Real{}, notifier)
+		go notifier.Listen(ctx, dsn, c)
+		return c
+	}
+	var readsA, readsB int32
+	a, b := replica(&readsA), replica(&readsB)
+
+	session, err := fixtures.NewTestSession().Create(ctx, a)
+	if err != nil {
+		t.Fatalf("Failed to create fixture: %v", err)
+	}
+
+	// Caching starts once the replica listens for invalidations
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		before := atomic.LoadInt32(&readsB)
+		b.GetSession(ctx, session.ID)
+		b.GetSession(ctx, session.ID)
+		if atomic.LoadInt32(&readsB)-before <= 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Replica b never started caching")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	// Watchers on b hear of the booking on a
+	watchers := watch.NewHub()
+	b.OnChange(watchers.Notify)
+	watcher := watchers.Watch(session.ID)
+	defer watcher.Stop()
+
+	if err := a.CreateReservation(ctx, &store.Reservation{SessionID: session.ID, UserID: "user-1", UserName: "Jane Doe"}); err != nil {
+		t.Fatalf("CreateReservation failed: %v", err)
+	}
+	select {
+	case <-watcher.Changed():
+	case <-time.After(time.Until(deadline)):
+		t.Fatal("Watcher on replica b not told of the booking on a")
+	}
+	for {
+		got, err := b.GetSession(ctx, session.ID)
+		if err != nil {
+			t.Fatalf("GetSession failed: %v", err)
+		}
+		if got.ReservedSpots == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Replica b still serves %d reserved spots after the booking on a", got.ReservedSpots)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// An invalidation too large for one notification reaches b too
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("Failed to connect to test database: %v", err)
+	}
+	defer db.Close()
+	many := make([]int64, 3000)
+	for i := range many {
+		many[i] = 1000000 + int64(i)
+	}
+	many[len(many)-1] = session.ID
+	watcher = watchers.Watch(session.ID)
+	defer watcher.Stop()
+	if err := cache.NewNotifier(db).Publish(ctx, many); err != nil {
+		t.Fatalf("Publish of %d sessions failed: %v", len(many), err)
+	}
+	select {
+	case <-watcher.Changed():
+	case <-time.After(10 * time.Second):
+		t.Fatal("Watcher on replica b not told of the last session of a large invalidation")
+	}
+}
+
+func TestLeaderElection(t *testing.T) {
+	t.Parallel()
+	db := template.Clone(t)
+	ctx := context.Background()
+
+	// Three replicas compete; each reports when it starts and stops leading
+	type change struct {
+		replica int
+		leading bool
+	}
+	changes := make(chan change, 10)
+	stops := make([]context.CancelFunc, 3)
+	for i := range stops {
+		i := i
+		var replicaCtx context.Context
+		replicaCtx, stops[i] = context.WithCancel(ctx)
+		defer stops[i]()
+
+		e := leader.New(db, t.Name())
+		e.RetryInterval = 50 * time.Millisecond
+		e.CheckInterval = 50 * time.Millisecond
+		go e.Run(replicaCtx, func(ctx context.Context) {
+			changes <- change{i, true}
+			<-ctx.Done()
+			changes <- change{i, false}
+		})
+	}
+
+	next := func() change {
+		select {
+		case c := <-changes:
+			return c
+		case <-time.After(10 * time.Second):
+			t.Fatal("No leadership change")
+			return change{}
+		}
+	}
+
+	first := next()
+	if !first.leading {
+		t.Fatalf("Expected a replica to become the leader, got %+v", first)
+	}
+	// The others keep retrying without getting the lock
+	select {
+	case c := <-changes:
+		t.Fatalf("Two leaders at once: %+v", c)
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	stops[first.replica]()
+	if c := next(); c != (change{first.replica, false}) {
+		t.Fatalf("Expected replica %d to step down, got %+v", first.replica, c)
+	}
+	if second := next(); !second.leading || second.replica == first.replica {
+		t.Fatalf("Expected another replica to take over, got %+v", second)
+	}
+}
+
+func TestPostgresQueryTimeout(t *testing.T) {
+	t.Parallel()
+	db := template.Clone(t)
+	ctx := context.Background()
+
+	session, err := fixtures.NewTestSession().Create(ctx, store.NewPostgres(db))
+	if err != nil {
+		t.Fatalf("Failed to create fixture: %v", err)
+	}
+
+	// Hold the session row lock so that cancelling has to wait
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`SELECT 1 FROM sessions WHERE id = $1 FOR UPDATE`, session.ID); err != nil {
+		t.Fatalf("Failed to lock session: %v", err)
+	}
+
+	start := time.Now()
+	_, err = store.NewPostgresWithTimeout(db, 200*time.Millisecond).CancelSession(ctx, session.ID, "Studio flooded")
+	if !store.IsTimeout(err) {
+		t.Errorf("Expected a timeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("CancelSession gave up after %v", elapsed)
+	}
+}
+
+func TestPostgresLockTimeout(t *testing.T) {
+	t.Parallel()
+	dsn, err := store.WithSessionParams(template.CloneURL(t), map[string]string{"lock_timeout": "100"})
+	if err != nil {
+		t.Fatalf("WithSessionParams failed: %v", err)
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer db.Close()
+	repo := store.NewPostgres(db)
+	ctx := context.Background()
+
+	var lockTimeout string
+	if err := db.QueryRow(`SHOW lock_timeout`).Scan(&lockTimeout); err != nil || lockTimeout != "100ms" {
+		t.Errorf("Expected lock_timeout 100ms, got %q, %v", lockTimeout, err)
+	}
+
+	session, err := fixtures.NewTestSession().Create(ctx, repo)
+	if err != nil {
+		t.Fatalf("Failed to create fixture: %v", err)
+	}
+
+	// A stuck transaction holds the session row
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`SELECT 1 FROM sessions WHERE id = $1 FOR UPDATE`, session.ID); err != nil {
+		t.Fatalf("Failed to lock session: %v", err)
+	}
+
+	err = repo.CreateReservation(ctx, &store.Reservation{SessionID: session.ID, UserID: "user-1", UserName: "Jane Doe"})
+	if !store.IsLockTimeout(err) {
+		t.Errorf("Expected a lock timeout, got %v", err)
+	}
+}
+
+func TestPostgresCapacity(t *testing.T) {
+	t.Parallel()
+	rapid.Check(t, storetest.Capacity(store.NewPostgres(template.Clone(t))))
+}
+
+func TestPostgresConflicts(t *testing.T) {
+	t.Parallel()
+	storetest.Conflicts(t, store.NewPostgres(template.Clone(t)))
+}
+
+func TestPostgresGymScope(t *testing.T) {
+	t.Parallel()
+	storetest.GymScope(t, store.NewPostgres(template.Clone(t)))
+}
+
+func TestPostgresLifecycle(t *testing.T) {
+	t.Parallel()
+	storetest.Lifecycle(t, store.NewPostgres(template.Clone(t)))
+}
+
+func TestPostgresList(t *testing.T) {
+	t.Parallel()
+	storetest.List(t, store.NewPostgres(template.Clone(t)))
+}
+
+func TestPostgresListAfter(t *testing.T) {
+	t.Parallel()
+	storetest.ListAfter(t, store.NewPostgres(template.Clone(t)))
+}
+
+func TestPostgresListReservations(t *testing.T) {
+	t.Parallel()
+	storetest.ListReservations(t, store.NewPostgres(template.Clone(t)))
+}
+
+func TestPostgresWaitlist(t *testing.T) {
+	t.Parallel()
+	storetest.Waitlist(t, store.NewPostgres(template.Clone(t)))
+}
+
+func TestPostgresUpdate(t *testing.T) {
+	t.Parallel()
+	storetest.Update(t, store.NewPostgres(template.Clone(t)))
+}
+
+func TestPostgresSeries(t *testing.T) {
+	t.Parallel()
+	storetest.Series(t, store.NewPostgres(template.Clone(t)))
+}
+
+func TestSchemaMigrations(t *testing.T) {
+	t.Parallel()
+	db := template.Clone(t)
+	ctx := context.Background()
+
+	expanded, contracted, ok, err := readSchemaVersion(ctx, db)
+	if err != nil || !ok || expanded != schemaVersion || contracted != schemaVersion {
+		t.Fatalf("Expected a new database at version %d, got %d/%d, %v, %v", schemaVersion, expanded, contracted, ok, err)
+	}
+
+	// A release adding a column, made NOT NULL once the old code is gone
+	next := schemaVersion + 1
+	migrations := append(append([]schemaMigration(nil), schemaMigrations...), schemaMigration{
+		version: next,
+		expand: func(ctx context.Context, db execer) error {
+			_, err := db.ExecContext(ctx, `ALTER TABLE sessions ADD COLUMN room TEXT DEFAULT 'main'`)
+			return err
+		},
+		contract: func(ctx context.Context, db execer) error {
+			_, err := db.ExecContext(ctx, `ALTER TABLE sessions ALTER COLUMN room SET NOT NULL`)
+			return err
+		},
+		down: func(ctx context.Context, db execer) error {
+			_, err := db.ExecContext(ctx, `ALTER TABLE sessions DROP COLUMN room`)
+			return err
+		},
+	})
+	nullable := func() string {
+		var n string
+		err := db.QueryRow(`SELECT is_nullable FROM information_schema.columns WHERE table_name = 'sessions' AND column_name = 'room'`).Scan(&n)
+		if err != nil && err != sql.ErrNoRows {
+			t.Fatalf("Failed to read the room column: %v", err)
+		}
+		return n
+	}
+
+	if _, err := migrateSchema(ctx, db, migrations, contractPhase, next); err == nil {
+		t.Error("Contracting before expanding must fail")
+	}
+	if from, err := migrateSchema(ctx, db, migrations, expandPhase, next); err != nil || from != schemaVersion {
+		t.Fatalf("Expand: expected from version %d, got %d, %v", schemaVersion, from, err)
+	}
+	if got := nullable(); got != "YES" {
+		t.Errorf("Expected the expanded column nullable, got %q", got)
+	}
+	if from, err := migrateSchema(ctx, db, migrations, expandPhase, next); err != nil || from != next {
+		t.Errorf("Expanding again must do nothing, got from %d, %v", from, err)
+	}
+	// The current code runs on the expanded schema, and so does the next
+	if err := initDatabase(ctx, db); err != nil {
+		t.Errorf("initDatabase failed on the expanded schema: %v", err)
+	}
+	if err := schemaCompatible(next, next, schemaVersion); err != nil {
+		t.Errorf("The next version must run on the expanded schema: %v", err)
+	}
+
+	// The release is rolled back before its contract, and out again
+	if from, err := migrateSchema(ctx, db, migrations, downPhase, schemaVersion); err != nil || from != next {
+		t.Fatalf("Down: expected from version %d, got %d, %v", next, from, err)
+	}
+	if got := nullable(); got != "" {
+		t.Errorf("Expected the column dropped, got nullable %q", got)
+	}
+	if expanded, _, _, err := readSchemaVersion(ctx, db); err != nil || expanded != schemaVersion {
+		t.Errorf("Expected expanded to version %d after down, got %d, %v", schemaVersion, expanded, err)
+	}
+	if _, err := migrateSchema(ctx, db, migrations, expandPhase, next); err != nil {
+		t.Fatalf("Expand failed: %v", err)
+	}
+
+	if _, err := migrateSchema(ctx, db, migrations, contractPhase, next); err != nil {
+		t.Fatalf("Contract failed: %v", err)
+	}
+	if got := nullable(); got != "NO" {
+		t.Errorf("Expected the contracted column NOT NULL, got %q", got)
+	}
+	if err := initDatabase(ctx, db); err == nil {
+		t.Error("The current code must not start on a schema contracted beyond its version")
+	}
+	if _, err := migrateSchema(ctx, db, migrations, downPhase, schemaVersion); err == nil {
+		t.Error("Going down past a contracted version must fail")
+	}
+
+	// A database from before versioning is taken as version 1, and must be
+	// expanded before this version runs on it
+	if _, err := db.Exec(`DROP TABLE schema_version`); err != nil {
+		t.Fatalf("Failed to drop schema_version: %v", err)
+	}
+	if err := initDatabase(ctx, db); err == nil {
+		t.Error("The service must not start on an unversioned schema before it is expanded")
+	}
+	if expanded, contracted, _, err := readSchemaVersion(ctx, db); err != nil || expanded != 1 || contracted != 1 {
+		t.Errorf("Expected version 1, got %d/%d, %v", expanded, contracted, err)
+	}
+	if _, err := migrateSchema(ctx, db, schemaMigrations, expandPhase, schemaVersion); err != nil {
+		t.Fatalf("Expand failed: %v", err)
+	}
+	if err := initDatabase(ctx, db); err != nil {
+		t.Errorf("initDatabase failed once expanded: %v", err)
+	}
+}
+
+func TestDatabaseHealth(t *testing.T) {
+	t.Parallel()
+	db := template.Clone(t)
+	healthServer := health.NewServer()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go checkDatabaseHealth(ctx, db, healthServer, 10*time.Millisecond)
+
+	state := func() healthpb.HealthCheckResponse_ServingStatus {
+		resp, err := healthServer.Check(ctx, &healthpb.HealthCheckRequest{Service: pb.SessionService_ServiceDesc.ServiceName})
+		if err != nil {
+			return healthpb.HealthCheckResponse_UNKNOWN
+		}
+		return resp.Status
+	}
+	time.Sleep(50 * time.Millisecond)
+	if got := state(); got == healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Fatalf("Expected serving while the database answers, got %v", got)
+	}
+
+	// A closed pool fails every ping, like a lost database
+	db.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for state() != healthpb.HealthCheckResponse_NOT_SERVING {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected not serving once the database is unreachable")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSchemaMigrationsDown(t *testing.T) {
+	t.Parallel()
+	db := template.Clone(t)
+	ctx := context.Background()
+
+	if _, err := migrateSchema(ctx, db, schemaMigrations, downPhase, 0); err == nil {
+		t.Error("Version 1 has no down step: going down to 0 must fail")
+	}
+	if from, err := migrateSchema(ctx, db, schemaMigrations, downPhase, 1); err != nil || from != schemaVersion {
+		t.Fatalf("Down: expected from version %d, got %d, %v", schemaVersion, from, err)
+	}
+	expanded, contracted, _, err := readSchemaVersion(ctx, db)
+	if err != nil || expanded != 1 || contracted != 1 {
+		t.Errorf("Expected version 1, got %d/%d, %v", expanded, contracted, err)
+	}
+	var waitlist bool
+	if err := db.QueryRow(`SELECT to_regclass('waitlist') IS NOT NULL`).Scan(&waitlist); err != nil || waitlist {
+		t.Errorf("Expected the waitlist table dropped, got %v, %v", waitlist, err)
+	}
+
+	// Expanding again converges to the schema of a new database
+	for _, phase := range []string{expandPhase, contractPhase} {
+		if _, err := migrateSchema(ctx, db, schemaMigrations, phase, schemaVersion); err != nil {
+			t.Fatalf("Failed to %s: %v", phase, err)
+		}
+	}
+	var out strings.Builder
+	if ok, err := verifySchema(ctx, db, &out); err != nil || !ok {
+		t.Errorf("Expected the schema of a new database, got %v:\n%s", err, out.String())
+	}
+}
+
+// Tables as the first release created them, long before the partitioning
+// by gym
+const unpartitionedTables = `
+	CREATE TABLE IF NOT EXISTS sessions (
+		id SERIAL PRIMARY KEY,
+		title VARCHAR(255) NOT NULL,
+		description TEXT,
+		coach_id VARCHAR(100) NOT NULL,
+		coach_name VARCHAR(255) NOT NULL,
+		capacity INT NOT NULL,
+		reserved_spots INT DEFAULT 0,
+		start_time TIMESTAMP NOT NULL,
+		end_time TIMESTAMP NOT NULL,
+		location VARCHAR(255) NOT NULL,
+		session_type VARCHAR(100) NOT NULL,
+		difficulty_level VARCHAR(50) NOT NULL,
+		is_cancelled BOOLEAN DEFAULT FALSE,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE TABLE IF NOT EXISTS reservations (
+		id SERIAL PRIMARY KEY,
+		session_id INT NOT NULL REFERENCES sessions(id) ON DELETE CASCADE,
+		user_id VARCHAR(100) NOT NULL,
+		user_name VARCHAR(255) NOT NULL,
+		reservation_time TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		status VARCHAR(50) DEFAULT 'confirmed',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(session_id, user_id)
+	);
+	INSERT INTO sessions (title, coach_id, coach_name, capacity, reserved_spots, start_time, end_time,
+		location, session_type, difficulty_level)
+	VALUES ('Morning Yoga', 'coach-1', 'Jane Doe', 10, 1, '2030-05-15 08:00', '2030-05-15 09:00',
+		'Studio A', 'yoga', 'beginner');
+	INSERT INTO reservations (session_id, user_id, user_name) VALUES (1, 'user-1', 'John Doe');
+`
+
+func TestPartitionTables(t *testing.T) {
+	t.Parallel()
+	db := template.Clone(t)
+	ctx := context.Background()
+
+	if _, err := db.Exec(`DROP TABLE reservations, sessions`); err != nil {
+		t.Fatalf("Failed to drop the tables: %v", err)
+	}
+	if _, err := db.Exec(unpartitionedTables); err != nil {
+		t.Fatalf("Failed to create the unpartitioned tables: %v", err)
+	}
+	if err := initDatabase(ctx, db); err == nil {
+		t.Fatal("The service must not start on unpartitioned tables")
+	}
+
+	copied, err := partitionTables(ctx, db, "north", false)
+	if err != nil {
+		t.Fatalf("partitionTables failed: %v", err)
+	}
+	if copied["sessions"] != 1 || copied["reservations"] != 1 {
+		t.Errorf("Expected 1 session and 1 reservation copied, got %v", copied)
+	}
+	if copied, err := partitionTables(ctx, db, "north", false); err != nil || copied != nil {
+		t.Errorf("Partitioning again must do nothing, got %v, %v", copied, err)
+	}
+	if err := initDatabase(ctx, db); err != nil {
+		t.Fatalf("initDatabase failed after partitioning: %v", err)
+	}
+	if ok, err := verifySchema(ctx, db, io.Discard); err != nil || !ok {
+		t.Errorf("Expected the partitioned schema to match, got %v, %v", ok, err)
+	}
+
+	repo := store.NewPostgres(db)
+	north := store.WithGym(ctx, "north")
+	session, err := repo.GetSession(north, 1)
+	if err != nil || session.Title != "Morning Yoga" || session.ReservedSpots != 1 {
+		t.Fatalf("Expected the old session in gym north, got %+v, %v", session, err)
+	}
+	r := &store.Reservation{SessionID: 1, UserID: "user-1", UserName: "John Doe"}
+	if err := repo.CreateReservation(north, r); err != store.ErrAlreadyBooked {
+		t.Errorf("Expected the old reservation to be kept, got %v", err)
+	}
+
+	// New rows continue the old IDs
+	created, err := fixtures.NewTestSession().Create(north, repo)
+	if err != nil || created.ID != 2 {
+		t.Errorf("Expected the next session to get ID 2, got %+v, %v", created, err)
+	}
+}
+
+// The Postgres side of the hot paths; see bench_test.go for the in-memory ones
+
+func BenchmarkPostgresGetSession(b *testing.B) {
+	repo := store.NewPostgres(template.Clone(b))
+	ctx := context.Background()
+	session, err := fixtures.NewTestSession().Create(ctx, repo)
+	if err != nil {
+		b.Fatalf("Failed to create fixture: %v", err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := repo.GetSession(ctx, session.ID); err != nil {
+			b.Fatalf("GetSession failed: %v", err)
+		}
+	}
+}
+
+func BenchmarkPostgresCreateReservation(b *testing.B) {
+	repo := store.NewPostgres(template.Clone(b))
+	ctx := context.Background()
+	session, err := fixtures.NewTestSession().WithCapacity(1<<30).Create(ctx, repo)
+	if err != nil {
+		b.Fatalf("Failed to create fixture: %v", err)
+	}
+	users := make([]string, b.N)
+	for i := range users {
+		users[i] = fmt.Sprintf("user-%d", i)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r := &store.Reservation{SessionID: session.ID, UserID: users[i], UserName: "Jane Doe"}
+		if err := repo.CreateReservation(ctx, r); err != nil {
+			b.Fatalf("CreateReservation failed: %v", err)
+		}
+	}
+}
+
+// RPCs declared in the proto that the server does not implement yet. Move
+// an entry into its own end-to-end test when the RPC lands.
+func TestUnimplementedRPCs(t *testing.T) {
+	client := startServer(t)
+	ctx := context.Background()
+
+	calls := map[string]func() error{
+		"DeleteSession": func() error {
+			_, err := client.DeleteSession(ctx, &pb.DeleteSessionRequest{SessionId: "1"})
+			return err
+		},
+	}
+
+	for name, call := range calls {
+		t.Run(name, func(t *testing.T) {
+			assertCode(t, call(), codes.Unimplemented)
+		})
+	}
+}
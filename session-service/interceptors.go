@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"session-service/internal/store"
+)
+
+// Metadata key naming the gym a call is about, set by the API gateway
+const gymMetadataKey = "x-gym-id"
+
+// Log every unary call with its outcome and duration
+func debugLogInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	log.Printf("[debug] %s %s in %v: %+v", info.FullMethod, status.Code(err), time.Since(start), req)
+	return resp, err
+}
+
+// Scope the store calls of each unary call to the gym named in its
+// metadata. Calls without one see every gym.
+func gymInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := gymContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// Like gymInterceptor, for streaming calls
+func gymStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := gymContext(ss.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, &contextStream{ss, ctx})
+}
+
+// Add the gym named in the metadata of ctx, if any, to ctx
+func gymContext(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(gymMetadataKey); len(values) > 0 {
+		if values[0] == "" {
+			return nil, status.Errorf(codes.InvalidArgument, "Invalid %s: empty", gymMetadataKey)
+		}
+		if err := validateText(gymMetadataKey, values[0], maxGymIDLength); err != nil {
+			return nil, err
+		}
+		ctx = store.WithGym(ctx, values[0])
+	}
+	return ctx, nil
+}
+
+// ServerStream whose context was replaced by an interceptor
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
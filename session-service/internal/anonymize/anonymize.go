@@ -0,0 +1,79 @@
+// Package anonymize replaces personal data with stable pseudonyms. The same
+// input and key always give the same output, so references between records
+// survive anonymization.
+package anonymize
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+)
+
+var (
+	firstNames = []string{
+		"Alex", "Sam", "Jordan", "Taylor", "Morgan", "Casey", "Riley", "Jamie",
+		"Avery", "Quinn", "Rowan", "Charlie", "Sasha", "Robin", "Drew", "Kai",
+	}
+	lastNames = []string{
+		"Smith", "Martin", "Garcia", "Rossi", "Muller", "Dubois", "Khan", "Silva",
+		"Novak", "Haddad", "Jensen", "Kowalski", "Moreau", "Ito", "Larsen", "Costa",
+	}
+)
+
+// Fields holding personal data, by their name in the proto messages and in
+// the database
+var (
+	idFields   = map[string]bool{"user_id": true, "user_ids": true, "coach_id": true}
+	nameFields = map[string]bool{"user_name": true, "coach_name": true}
+)
+
+// Hasher derives pseudonyms keyed by a secret, so they cannot be reversed by
+// hashing candidate IDs without the key.
+type Hasher struct {
+	key []byte
+}
+
+// New returns a Hasher using key.
+func New(key string) Hasher {
+	return Hasher{key: []byte(key)}
+}
+
+func (h Hasher) sum(kind, value string) []byte {
+	mac := hmac.New(sha256.New, h.key)
+	mac.Write([]byte(kind))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+	return mac.Sum(nil)
+}
+
+// ID replaces an identifier such as a user ID. Empty values stay empty.
+func (h Hasher) ID(value string) string {
+	if value == "" {
+		return ""
+	}
+	return "anon-" + hex.EncodeToString(h.sum("id", value)[:8])
+}
+
+// Name replaces a person's name with a made up one. Empty values stay empty.
+func (h Hasher) Name(value string) string {
+	if value == "" {
+		return ""
+	}
+	sum := h.sum("name", value)
+	first := binary.BigEndian.Uint32(sum[0:4]) % uint32(len(firstNames))
+	last := binary.BigEndian.Uint32(sum[4:8]) % uint32(len(lastNames))
+	return firstNames[first] + " " + lastNames[last]
+}
+
+// Field replaces value if the named field holds personal data, and returns
+// it unchanged otherwise.
+func (h Hasher) Field(name, value string) string {
+	switch {
+	case idFields[name]:
+		return h.ID(value)
+	case nameFields[name]:
+		return h.Name(value)
+	}
+	return value
+}
@@ -0,0 +1,37 @@
+package anonymize
+
+import "testing"
+
+func TestHasherIsStableAndKeyed(t *testing.T) {
+	h := New("secret")
+
+	if h.ID("user-1") != h.ID("user-1") || h.Name("Jane Doe") != h.Name("Jane Doe") {
+		t.Error("Pseudonyms should be stable for the same input")
+	}
+	if h.ID("user-1") == h.ID("user-2") {
+		t.Error("Different IDs should get different pseudonyms")
+	}
+	if h.ID("user-1") == New("other").ID("user-1") {
+		t.Error("Pseudonyms should depend on the key")
+	}
+	if h.ID("") != "" || h.Name("") != "" {
+		t.Error("Empty values should stay empty")
+	}
+	if h.Name("Jane Doe") == "Jane Doe" {
+		t.Error("Name was not replaced")
+	}
+}
+
+func TestHasherField(t *testing.T) {
+	h := New("secret")
+
+	if got := h.Field("user_id", "user-1"); got != h.ID("user-1") {
+		t.Errorf("user_id: got %q", got)
+	}
+	if got := h.Field("coach_name", "Jane Doe"); got != h.Name("Jane Doe") {
+		t.Errorf("coach_name: got %q", got)
+	}
+	if got := h.Field("title", "Morning Yoga"); got != "Morning Yoga" {
+		t.Errorf("title should be kept, got %q", got)
+	}
+}
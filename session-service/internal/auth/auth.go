@@ -0,0 +1,194 @@
+// Package auth verifies the JSON Web Tokens callers present and carries the
+// identity they prove through the context. Tokens are signed with HS256,
+// using the secret the user service and the API gateway share, or with
+// RS256 by an issuer whose public keys are configured.
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Roles of the users, as the user service assigns them
+const (
+	RoleMember = "member"
+	RoleCoach  = "coach"
+	RoleAdmin  = "admin"
+)
+
+// Identity is who a verified token says the caller is.
+type Identity struct {
+	UserID string
+	Role   string
+}
+
+// IsStaff reports whether the caller runs sessions: coaches and admins.
+func (id *Identity) IsStaff() bool {
+	return id.Role == RoleCoach || id.Role == RoleAdmin
+}
+
+type identityKey struct{}
+
+// WithIdentity returns a copy of ctx carrying the caller's identity.
+func WithIdentity(ctx context.Context, id *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// FromContext returns the identity WithIdentity added to ctx, if any.
+func FromContext(ctx context.Context) (*Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(*Identity)
+	return id, ok
+}
+
+// Verifier checks the signature and claims of tokens.
+type Verifier struct {
+	secret     []byte
+	publicKeys []*rsa.PublicKey
+	issuer     string
+}
+
+// NewVerifier returns a Verifier accepting HS256 tokens signed with secret,
+// if not empty, and RS256 tokens signed by one of the RSA public keys in
+// publicKeysPEM, if not empty. Tokens must come from issuer, unless it is
+// empty.
+func NewVerifier(secret string, publicKeysPEM []byte, issuer string) (*Verifier, error) {
+	v := &Verifier{secret: []byte(secret), issuer: issuer}
+	for rest := publicKeysPEM; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parsing public key: %w", err)
+		}
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("public key is a %T, not an RSA key", key)
+		}
+		v.publicKeys = append(v.publicKeys, rsaKey)
+	}
+	if len(publicKeysPEM) > 0 && len(v.publicKeys) == 0 {
+		return nil, errors.New("no PEM encoded public key found")
+	}
+	if secret == "" && len(v.publicKeys) == 0 {
+		return nil, errors.New("neither a secret nor public keys to verify tokens with")
+	}
+	return v, nil
+}
+
+// Claims read from a token. The user service names the user userId; other
+// issuers use the standard sub.
+type claims struct {
+	UserID    string `json:"userId"`
+	Subject   string `json:"sub"`
+	Role      string `json:"role"`
+	Issuer    string `json:"iss"`
+	ExpiresAt *int64 `json:"exp"`
+	NotBefore *int64 `json:"nbf"`
+}
+
+// Verify checks that token is signed with one of the configured keys and
+// valid at now, and returns the identity it carries. A token without a role
+// is a member's.
+func (v *Verifier) Verify(token string, now time.Time) (*Identity, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("malformed header: %w", err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed signature: %w", err)
+	}
+	if err := v.verifySignature(header.Alg, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	var c claims
+	if err := decodeSegment(parts[1], &c); err != nil {
+		return nil, fmt.Errorf("malformed claims: %w", err)
+	}
+	switch {
+	case c.ExpiresAt == nil:
+		return nil, errors.New("token without expiry")
+	case now.Unix() >= *c.ExpiresAt:
+		return nil, errors.New("token expired")
+	case c.NotBefore != nil && now.Unix() < *c.NotBefore:
+		return nil, errors.New("token not valid yet")
+	case v.issuer != "" && c.Issuer != v.issuer:
+		return nil, fmt.Errorf("token issued by %q", c.Issuer)
+	}
+
+	id := &Identity{UserID: c.UserID, Role: c.Role}
+	if id.UserID == "" {
+		id.UserID = c.Subject
+	}
+	if id.UserID == "" {
+		return nil, errors.New("token without user")
+	}
+	switch id.Role {
+	case "":
+		id.Role = RoleMember
+	case RoleMember, RoleCoach, RoleAdmin:
+	default:
+		return nil, fmt.Errorf("unknown role %q", id.Role)
+	}
+	return id, nil
+}
+
+// Check the signature of signed with the keys of algorithm alg. The
+// algorithm is only trusted to pick among the configured keys, so a token
+// can't choose none, or pass a public key off as an HMAC secret.
+func (v *Verifier) verifySignature(alg, signed string, signature []byte) error {
+	switch alg {
+	case "HS256":
+		if len(v.secret) == 0 {
+			break
+		}
+		mac := hmac.New(sha256.New, v.secret)
+		mac.Write([]byte(signed))
+		if !hmac.Equal(signature, mac.Sum(nil)) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	case "RS256":
+		if len(v.publicKeys) == 0 {
+			break
+		}
+		digest := sha256.Sum256([]byte(signed))
+		for _, key := range v.publicKeys {
+			if rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil {
+				return nil
+			}
+		}
+		return errors.New("invalid signature")
+	}
+	return fmt.Errorf("unexpected signing algorithm %q", alg)
+}
+
+// Decode a base64url encoded JSON segment of a token into v
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
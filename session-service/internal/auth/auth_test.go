@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"testing"
+	"time"
+)
+
+var now = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// Encode a token with the given header algorithm and claims, signed by sign
+func token(t *testing.T, alg string, claims map[string]interface{}, sign func(signed string) []byte) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": alg, "typ": "JWT"})
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sign(signed))
+}
+
+func hs256(secret string) func(string) []byte {
+	return func(signed string) []byte {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(signed))
+		return mac.Sum(nil)
+	}
+}
+
+func rs256(t *testing.T, key *rsa.PrivateKey) func(string) []byte {
+	return func(signed string) []byte {
+		digest := sha256.Sum256([]byte(signed))
+		signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signature
+	}
+}
+
+func valid(extra map[string]interface{}) map[string]interface{} {
+	claims := map[string]interface{}{"userId": "member-1", "exp": now.Add(time.Hour).Unix()}
+	for k, v := range extra {
+		claims[k] = v
+	}
+	return claims
+}
+
+func TestVerifyHS256(t *testing.T) {
+	v, err := NewVerifier("secret", nil, "")
+	if err != nil {
+		t.Fatalf("NewVerifier failed: %v", err)
+	}
+
+	id, err := v.Verify(token(t, "HS256", valid(nil), hs256("secret")), now)
+	if err != nil || id.UserID != "member-1" || id.Role != RoleMember {
+		t.Errorf("Expected member-1 as a member, got %+v, %v", id, err)
+	}
+	id, err = v.Verify(token(t, "HS256", valid(map[string]interface{}{"userId": nil, "sub": "coach-1", "role": "coach"}), hs256("secret")), now)
+	if err != nil || id.UserID != "coach-1" || !id.IsStaff() {
+		t.Errorf("Expected coach-1 as a coach, got %+v, %v", id, err)
+	}
+
+	invalid := map[string]string{
+		"other secret": token(t, "HS256", valid(nil), hs256("other")),
+		"expired":      token(t, "HS256", valid(map[string]interface{}{"exp": now.Unix()}), hs256("secret")),
+		"no expiry":    token(t, "HS256", valid(map[string]interface{}{"exp": nil}), hs256("secret")),
+		"not yet":      token(t, "HS256", valid(map[string]interface{}{"nbf": now.Add(time.Minute).Unix()}), hs256("secret")),
+		"no user":      token(t, "HS256", valid(map[string]interface{}{"userId": ""}), hs256("secret")),
+		"unknown role": token(t, "HS256", valid(map[string]interface{}{"role": "owner"}), hs256("secret")),
+		"unsigned":     token(t, "none", valid(nil), func(string) []byte { return nil }),
+		"no key":       token(t, "RS256", valid(nil), hs256("secret")),
+		"malformed":    "not.a-token",
+	}
+	for name, tok := range invalid {
+		if id, err := v.Verify(tok, now); err == nil {
+			t.Errorf("%s: expected an error, got %+v", name, id)
+		}
+	}
+}
+
+func TestVerifyRS256(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+
+	v, err := NewVerifier("", publicKey, "https://auth.example.com")
+	if err != nil {
+		t.Fatalf("NewVerifier failed: %v", err)
+	}
+	claims := valid(map[string]interface{}{"iss": "https://auth.example.com", "role": "admin"})
+	if id, err := v.Verify(token(t, "RS256", claims, rs256(t, key)), now); err != nil || id.Role != RoleAdmin {
+		t.Errorf("Expected an admin, got %+v, %v", id, err)
+	}
+
+	// The public key must not double as an HMAC secret
+	if _, err := v.Verify(token(t, "HS256", claims, hs256(string(publicKey))), now); err == nil {
+		t.Error("Expected an HS256 token refused without a secret")
+	}
+	claims["iss"] = "https://other.example.com"
+	if _, err := v.Verify(token(t, "RS256", claims, rs256(t, key)), now); err == nil {
+		t.Error("Expected a token of another issuer refused")
+	}
+}
+
+func TestNewVerifier(t *testing.T) {
+	if _, err := NewVerifier("", nil, ""); err == nil {
+		t.Error("Expected an error without keys")
+	}
+	if _, err := NewVerifier("", []byte("not PEM"), ""); err == nil {
+		t.Error("Expected an error without a PEM encoded key")
+	}
+}
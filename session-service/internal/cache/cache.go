@@ -0,0 +1,353 @@
+// Package cache keeps recently read sessions in memory in front of a
+// store.Repository. Every write through the cache invalidates exactly the
+// sessions it changed, here and, through a Publisher, on the other replicas,
+// so the number of free spots stays accurate while a popular session is
+// being booked. Entries also expire after a TTL, which bounds the damage of
+// a lost invalidation.
+package cache
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"session-service/internal/clock"
+	"session-service/internal/store"
+)
+
+// Most sessions kept at once; expired ones are dropped first when full
+const maxEntries = 10000
+
+// Number of generation counters sessions are spread over. A read of a
+// session is only cached if no session sharing its counter was invalidated
+// meanwhile, so a read racing with a write never caches the old value.
+const generationStripes = 256
+
+// Publisher tells the other replicas which sessions changed.
+type Publisher interface {
+	Publish(ctx context.Context, ids []int64) error
+}
+
+// A cached session
+type entry struct {
+	session store.Session
+	expires time.Time
+}
+
+// Repository is a store.Repository caching GetSession. It is safe for
+// concurrent use.
+type Repository struct {
+	store.Repository
+	ttl       time.Duration
+	clock     clock.Clock
+	publisher Publisher
+
+	mu          sync.Mutex
+	paused      bool
+	entries     map[int64]entry
+	generations [generationStripes]uint64
+	onChange    []func(ids ...int64)
+}
+
+// New returns repo with its sessions cached for ttl. Changes are published
+// with publisher, which may be nil when there is a single replica. With a
+// ttl of 0 nothing is cached, but changes are still tracked for OnChange.
+func New(repo store.Repository, ttl time.Duration, clk clock.Clock, publisher Publisher) *Repository {
+	return &Repository{
+		Repository: repo,
+		ttl:        ttl,
+		clock:      clk,
+		publisher:  publisher,
+		entries:    make(map[int64]entry),
+	}
+}
+
+// OnChange registers fn to be called with the sessions that changed, here
+// or on another replica, whenever they are invalidated. It is called
+// without IDs when any session may have changed unnoticed.
+func (c *Repository) OnChange(fn func(ids ...int64)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onChange = append(c.onChange, fn)
+}
+
+// Call the OnChange functions; the caller must not hold c.mu
+func (c *Repository) notify(ids ...int64) {
+	c.mu.Lock()
+	fns := c.onChange
+	c.mu.Unlock()
+	for _, fn := range fns {
+		fn(ids...)
+	}
+}
+
+// Invalidate drops the cached copies of the sessions, e.g. when another
+// replica changed them.
+func (c *Repository) Invalidate(ids ...int64) {
+	if len(ids) == 0 {
+		return
+	}
+	c.mu.Lock()
+	for _, id := range ids {
+		delete(c.entries, id)
+		c.generations[stripe(id)]++
+	}
+	c.mu.Unlock()
+	c.notify(ids...)
+}
+
+// Flush drops every cached session.
+func (c *Repository) Flush() {
+	c.mu.Lock()
+	c.flush()
+	c.mu.Unlock()
+	c.notify()
+}
+
+// Pause flushes the cache and stops caching until Resume, e.g. while
+// invalidations from the other replicas can't be received.
+func (c *Repository) Pause() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.paused = true
+	c.flush()
+}
+
+// Resume flushes the cache, which may have missed invalidations, and starts
+// caching again.
+func (c *Repository) Resume() {
+	c.mu.Lock()
+	c.paused = false
+	c.flush()
+	c.mu.Unlock()
+	c.notify()
+}
+
+// Index of the generation counter of a session
+func stripe(id int64) uint64 {
+	return uint64(id) % generationStripes
+}
+
+// Forget everything; the caller holds c.mu
+func (c *Repository) flush() {
+	c.entries = make(map[int64]entry)
+	for i := range c.generations {
+		c.generations[i]++
+	}
+}
+
+// GetSession returns the cached session or reads it from the store
+func (c *Repository) GetSession(ctx context.Context, id int64) (*store.Session, error) {
+	c.mu.Lock()
+	e, ok := c.entries[id]
+	generation := c.generations[stripe(id)]
+	c.mu.Unlock()
+	if ok && c.clock.Now().Before(e.expires) {
+		// Like the store, hide the sessions of other gyms
+		if gym := store.GymFromContext(ctx); gym != "" && gym != e.session.GymID {
+			return nil, store.ErrNotFound
+		}
+		return &e.session, nil
+	}
+
+	s, err := c.Repository.GetSession(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	c.put(s, generation)
+	return s, nil
+}
+
+// Cache a copy of s, read when its stripe was at generation
+func (c *Repository) put(s *store.Session, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.paused || c.ttl <= 0 || c.generations[stripe(s.ID)] != generation {
+		return
+	}
+	now := c.clock.Now()
+	if len(c.entries) >= maxEntries {
+		for id, e := range c.entries {
+			if !now.Before(e.expires) {
+				delete(c.entries, id)
+			}
+		}
+		if len(c.entries) >= maxEntries {
+			c.entries = make(map[int64]entry)
+		}
+	}
+	c.entries[s.ID] = entry{session: *s, expires: now.Add(c.ttl)}
+}
+
+// Invalidate the sessions here and on the other replicas
+func (c *Repository) changed(ctx context.Context, ids ...int64) {
+	if len(ids) == 0 {
+		return
+	}
+	c.Invalidate(ids...)
+	if c.publisher == nil {
+		return
+	}
+	// The write is done; a lost invalidation only lasts until the TTL
+	if err := c.publisher.Publish(ctx, ids); err != nil {
+		log.Printf("Failed to publish cache invalidation of sessions %v: %v", ids, err)
+	}
+}
+
+// Whether a failed write may have changed something. The store's own
+// errors are returned before anything is written; anything else, like a
+// timeout waiting for the commit, leaves it unknown.
+func mayHaveChanged(err error) bool {
+	switch err {
+	case store.ErrNotFound, store.ErrAlreadyCancelled, store.ErrSessionCompleted, store.ErrSessionFull,
+		store.ErrAlreadyBooked, store.ErrBatchAborted, store.ErrCapacityBelowReserved:
+		return false
+	}
+	var conflict *store.ConflictError
+	return !errors.As(err, &conflict)
+}
+
+// CreateSession stores the session and caches it
+func (c *Repository) CreateSession(ctx context.Context, s *store.Session, checkConflicts bool) error {
+	if err := c.Repository.CreateSession(ctx, s, checkConflicts); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	generation := c.generations[stripe(s.ID)]
+	c.mu.Unlock()
+	c.put(s, generation)
+	return nil
+}
+
+// UpdateSession updates the stored session and invalidates it
+func (c *Repository) UpdateSession(ctx context.Context, s *store.Session, checkConflicts bool) (*store.Session, error) {
+	updated, err := c.Repository.UpdateSession(ctx, s, checkConflicts)
+	if mayHaveChanged(err) {
+		c.changed(ctx, s.ID)
+	}
+	return updated, err
+}
+
+// CancelSession cancels the stored session and invalidates it
+func (c *Repository) CancelSession(ctx context.Context, id int64, reason string) (*store.Session, error) {
+	s, err := c.Repository.CancelSession(ctx, id, reason)
+	if mayHaveChanged(err) {
+		c.changed(ctx, id)
+	}
+	return s, err
+}
+
+// CreateSessionSeries stores the sessions and caches them
+func (c *Repository) CreateSessionSeries(ctx context.Context, ss []*store.Session, checkConflicts bool) error {
+	if err := c.Repository.CreateSessionSeries(ctx, ss, checkConflicts); err != nil {
+		return err
+	}
+	for _, s := range ss {
+		c.mu.Lock()
+		generation := c.generations[stripe(s.ID)]
+		c.mu.Unlock()
+		c.put(s, generation)
+	}
+	return nil
+}
+
+// UpdateSessionSeries updates the stored sessions and invalidates them
+func (c *Repository) UpdateSessionSeries(ctx context.Context, s *store.Session, checkConflicts bool) ([]*store.Session, error) {
+	updated, err := c.Repository.UpdateSessionSeries(ctx, s, checkConflicts)
+	c.seriesChanged(ctx, s.ID, updated, err)
+	return updated, err
+}
+
+// CancelSessionSeries cancels the stored sessions and invalidates them
+func (c *Repository) CancelSessionSeries(ctx context.Context, id int64, reason string) ([]*store.Session, error) {
+	cancelled, err := c.Repository.CancelSessionSeries(ctx, id, reason)
+	c.seriesChanged(ctx, id, cancelled, err)
+	return cancelled, err
+}
+
+// Invalidate the sessions a write to the series of session id changed. When
+// the outcome is unknown, so is the rest of the series: the whole cache is
+// flushed, and the other replicas only hear of session id.
+func (c *Repository) seriesChanged(ctx context.Context, id int64, changed []*store.Session, err error) {
+	switch {
+	case err == nil:
+		ids := make([]int64, len(changed))
+		for i, s := range changed {
+			ids[i] = s.ID
+		}
+		c.changed(ctx, ids...)
+	case mayHaveChanged(err):
+		c.changed(ctx, id)
+		c.Flush()
+	}
+}
+
+// DeleteSession deletes the stored session and invalidates it
+func (c *Repository) DeleteSession(ctx context.Context, id int64) error {
+	err := c.Repository.DeleteSession(ctx, id)
+	if mayHaveChanged(err) {
+		c.changed(ctx, id)
+	}
+	return err
+}
+
+// CompleteSessions completes the stored sessions and invalidates them
+func (c *Repository) CompleteSessions(ctx context.Context, endedBy time.Time) ([]int64, error) {
+	completed, err := c.Repository.CompleteSessions(ctx, endedBy)
+	c.changed(ctx, completed...)
+	return completed, err
+}
+
+// CreateReservation books the stored session and invalidates it
+func (c *Repository) CreateReservation(ctx context.Context, r *store.Reservation) error {
+	err := c.Repository.CreateReservation(ctx, r)
+	if mayHaveChanged(err) {
+		c.changed(ctx, r.SessionID)
+	}
+	return err
+}
+
+// CreateReservations books the stored sessions and invalidates the ones
+// that may have changed
+func (c *Repository) CreateReservations(ctx context.Context, rs []*store.Reservation, allOrNothing bool) ([]error, error) {
+	errs, err := c.Repository.CreateReservations(ctx, rs, allOrNothing)
+
+	var ids []int64
+	seen := make(map[int64]bool)
+	for i, r := range rs {
+		if seen[r.SessionID] || (err == nil && !mayHaveChanged(errs[i])) {
+			continue
+		}
+		seen[r.SessionID] = true
+		ids = append(ids, r.SessionID)
+	}
+	c.changed(ctx, ids...)
+	return errs, err
+}
+
+// CancelReservation cancels the stored reservation and invalidates its
+// session
+func (c *Repository) CancelReservation(ctx context.Context, id int64) (*store.Reservation, error) {
+	r, err := c.Repository.CancelReservation(ctx, id)
+	// Without the reservation its session is unknown; should the cancellation
+	// have gone through anyway, the session is stale until the TTL
+	if err == nil {
+		c.changed(ctx, r.SessionID)
+	}
+	return r, err
+}
+
+// ReconcileReservedSpots repairs the stored sessions and invalidates the
+// repaired ones
+func (c *Repository) ReconcileReservedSpots(ctx context.Context) ([]store.SpotDrift, error) {
+	drift, err := c.Repository.ReconcileReservedSpots(ctx)
+	ids := make([]int64, len(drift))
+	for i, d := range drift {
+		ids[i] = d.SessionID
+	}
+	c.changed(ctx, ids...)
+	return drift, err
+}
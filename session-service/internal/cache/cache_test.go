@@ -0,0 +1,245 @@
+package cache
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"session-service/internal/clock"
+	"session-service/internal/store"
+	"session-service/internal/store/storemock"
+)
+
+// Publisher recording what it is given
+type publisherFunc func(ids []int64)
+
+func (f publisherFunc) Publish(ctx context.Context, ids []int64) error {
+	f(ids)
+	return nil
+}
+
+// A cache over a mock store holding session 1 with the given reserved spots
+func newTestCache(reserved *int32) (*Repository, *storemock.RepositoryMock, *clock.Fake, *[][]int64) {
+	repo := &storemock.RepositoryMock{
+		GetSessionFunc: func(ctx context.Context, id int64) (*store.Session, error) {
+			if id != 1 {
+				return nil, store.ErrNotFound
+			}
+			return &store.Session{ID: 1, Capacity: 2, ReservedSpots: *reserved}, nil
+		},
+		CreateReservationFunc: func(ctx context.Context, r *store.Reservation) error {
+			if *reserved == 2 {
+				return store.ErrSessionFull
+			}
+			*reserved++
+			return nil
+		},
+	}
+	clk := clock.NewFake(time.Date(2030, 5, 15, 8, 0, 0, 0, time.UTC))
+	var published [][]int64
+	c := New(repo, time.Minute, clk, publisherFunc(func(ids []int64) { published = append(published, ids) }))
+	return c, repo, clk, &published
+}
+
+func TestGetSessionCached(t *testing.T) {
+	reserved := int32(0)
+	c, repo, clk, _ := newTestCache(&reserved)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		s, err := c.GetSession(ctx, 1)
+		if err != nil {
+			t.Fatalf("GetSession failed: %v", err)
+		}
+		// Callers may change what they get without changing the cache
+		s.Title = "Changed"
+	}
+	if n := len(repo.GetSessionCalls()); n != 1 {
+		t.Errorf("Expected 1 read from the store, got %d", n)
+	}
+	if s, _ := c.GetSession(ctx, 1); s.Title != "" {
+		t.Errorf("Cached session was changed by a caller: %+v", s)
+	}
+
+	// Missing sessions are not cached
+	for i := 0; i < 2; i++ {
+		if _, err := c.GetSession(ctx, 2); err != store.ErrNotFound {
+			t.Errorf("Expected ErrNotFound, got %v", err)
+		}
+	}
+	if n := len(repo.GetSessionCalls()); n != 3 {
+		t.Errorf("Expected 3 reads from the store, got %d", n)
+	}
+
+	clk.Advance(time.Minute)
+	c.GetSession(ctx, 1)
+	if n := len(repo.GetSessionCalls()); n != 4 {
+		t.Errorf("Expected an expired session to be read again, got %d reads", n)
+	}
+}
+
+func TestBookingInvalidates(t *testing.T) {
+	reserved := int32(0)
+	c, repo, _, published := newTestCache(&reserved)
+	ctx := context.Background()
+
+	for want := int32(1); want <= 2; want++ {
+		c.GetSession(ctx, 1)
+		if err := c.CreateReservation(ctx, &store.Reservation{SessionID: 1, UserID: "member-1"}); err != nil {
+			t.Fatalf("CreateReservation failed: %v", err)
+		}
+		s, err := c.GetSession(ctx, 1)
+		if err != nil || s.ReservedSpots != want {
+			t.Errorf("Expected %d reserved spots after booking, got %+v, %v", want, s, err)
+		}
+	}
+	if want := [][]int64{{1}, {1}}; !reflect.DeepEqual(*published, want) {
+		t.Errorf("Expected invalidations %v to be published, got %v", want, *published)
+	}
+
+	// A booking rejected by the store changes nothing
+	reads := len(repo.GetSessionCalls())
+	if err := c.CreateReservation(ctx, &store.Reservation{SessionID: 1, UserID: "member-3"}); err != store.ErrSessionFull {
+		t.Fatalf("Expected ErrSessionFull, got %v", err)
+	}
+	c.GetSession(ctx, 1)
+	if n := len(repo.GetSessionCalls()); n != reads || len(*published) != 2 {
+		t.Errorf("A rejected booking must not invalidate the session")
+	}
+}
+
+func TestReadRacingWithWriteNotCached(t *testing.T) {
+	reserved := int32(0)
+	c, repo, _, _ := newTestCache(&reserved)
+	ctx := context.Background()
+
+	// Another replica books while the old value is being read
+	get := repo.GetSessionFunc
+	repo.GetSessionFunc = func(ctx context.Context, id int64) (*store.Session, error) {
+		s, err := get(ctx, id)
+		reserved++
+		c.Invalidate(id)
+		return s, err
+	}
+	c.GetSession(ctx, 1)
+	repo.GetSessionFunc = get
+
+	s, err := c.GetSession(ctx, 1)
+	if err != nil || s.ReservedSpots != 1 {
+		t.Errorf("Expected the value read before the invalidation to be dropped, got %+v, %v", s, err)
+	}
+}
+
+func TestPause(t *testing.T) {
+	reserved := int32(0)
+	c, repo, _, _ := newTestCache(&reserved)
+	ctx := context.Background()
+
+	c.GetSession(ctx, 1)
+	c.Pause()
+	c.GetSession(ctx, 1)
+	c.GetSession(ctx, 1)
+	if n := len(repo.GetSessionCalls()); n != 3 {
+		t.Errorf("Expected every read to reach the store while paused, got %d reads", n)
+	}
+
+	c.Resume()
+	c.GetSession(ctx, 1)
+	c.GetSession(ctx, 1)
+	if n := len(repo.GetSessionCalls()); n != 4 {
+		t.Errorf("Expected caching to resume, got %d reads", n)
+	}
+}
+
+func TestOnChange(t *testing.T) {
+	reserved := int32(0)
+	c, _, _, _ := newTestCache(&reserved)
+	ctx := context.Background()
+
+	var changes [][]int64
+	c.OnChange(func(ids ...int64) { changes = append(changes, ids) })
+
+	c.CreateReservation(ctx, &store.Reservation{SessionID: 1, UserID: "member-1"})
+	c.Invalidate(7, 8)
+	c.Resume()
+	want := [][]int64{{1}, {7, 8}, nil}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("Expected changes %v, got %v", want, changes)
+	}
+}
+
+func TestZeroTTL(t *testing.T) {
+	reserved := int32(0)
+	repo := &storemock.RepositoryMock{
+		GetSessionFunc: func(ctx context.Context, id int64) (*store.Session, error) {
+			return &store.Session{ID: id, Capacity: 2, ReservedSpots: reserved}, nil
+		},
+	}
+	c := New(repo, 0, clock.NewFake(time.Date(2030, 5, 15, 8, 0, 0, 0, time.UTC)), nil)
+
+	c.GetSession(context.Background(), 1)
+	c.GetSession(context.Background(), 1)
+	if n := len(repo.GetSessionCalls()); n != 2 {
+		t.Errorf("Expected every read to reach the store without a TTL, got %d reads", n)
+	}
+}
+
+func TestPayloads(t *testing.T) {
+	ids := []int64{1, 42, 9000000000}
+	payloads := formatPayloads("replica-1", ids)
+	if len(payloads) != 1 {
+		t.Fatalf("Expected a single payload, got %q", payloads)
+	}
+	replica, formatted, _ := splitPayload(payloads[0])
+	got, err := parseIDs(formatted)
+	if replica != "replica-1" || err != nil || !reflect.DeepEqual(got, ids) {
+		t.Errorf("Round trip of %v gave %s, %v, %v", ids, replica, got, err)
+	}
+	for _, payload := range []string{"", "1,,2", "abc"} {
+		if _, err := parseIDs(payload); err == nil {
+			t.Errorf("parseIDs(%q): expected an error", payload)
+		}
+	}
+
+	// Too many for one notification, e.g. sessions completed at once
+	many := make([]int64, 3000)
+	for i := range many {
+		many[i] = 1000000 + int64(i)
+	}
+	payloads = formatPayloads("replica-1", many)
+	if len(payloads) < 2 {
+		t.Fatalf("Expected %d IDs split over several payloads, got %d", len(many), len(payloads))
+	}
+	var all []int64
+	for _, payload := range payloads {
+		if len(payload) > maxPayload {
+			t.Errorf("Payload of %d bytes, more than %d", len(payload), maxPayload)
+		}
+		_, formatted, _ := splitPayload(payload)
+		ids, err := parseIDs(formatted)
+		if err != nil {
+			t.Fatalf("Invalid payload %q: %v", payload, err)
+		}
+		all = append(all, ids...)
+	}
+	if !reflect.DeepEqual(all, many) {
+		t.Errorf("Expected every ID once across the payloads, got %d IDs", len(all))
+	}
+}
+
+func TestNotifierApply(t *testing.T) {
+	c := New(&storemock.RepositoryMock{}, time.Minute, clock.Real{}, nil)
+	var changes [][]int64
+	c.OnChange(func(ids ...int64) { changes = append(changes, ids) })
+	n := &Notifier{replica: "self"}
+
+	n.apply(c, formatPayloads("self", []int64{1})[0])
+	n.apply(c, formatPayloads("other", []int64{2, 3})[0])
+	n.apply(c, "4,5")
+	n.apply(c, "other:x")
+	want := [][]int64{{2, 3}, nil, nil}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("Expected the invalidations of the other replicas only, then a flush for each malformed one, got %v", changes)
+	}
+}
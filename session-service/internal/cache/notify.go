@@ -0,0 +1,152 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Postgres channel the replicas send their invalidations on
+const channel = "session_cache"
+
+// pq reconnects the listener after min, backing off up to max
+const (
+	minReconnectInterval = time.Second
+	maxReconnectInterval = time.Minute
+)
+
+// How often the listener connection is checked when no notification comes
+const pingInterval = 90 * time.Second
+
+// Longest payload of a notification: Postgres refuses those of 8000 bytes
+// or more
+const maxPayload = 8000 - 1
+
+// Notifier publishes invalidations to the other replicas with pg_notify,
+// and receives theirs with Listen.
+type Notifier struct {
+	db *sql.DB
+	// Tags the invalidations of this replica, which Listen skips
+	replica string
+}
+
+// NewNotifier returns a Notifier sending through db.
+func NewNotifier(db *sql.DB) *Notifier {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return &Notifier{db: db, replica: hex.EncodeToString(id)}
+}
+
+// Publish notifies the listening replicas that the sessions changed. Many
+// IDs are split over several notifications, sent by the same statement.
+func (n *Notifier) Publish(ctx context.Context, ids []int64) error {
+	_, err := n.db.ExecContext(ctx, `SELECT pg_notify($1, payload) FROM unnest($2::text[]) AS payload`,
+		channel, pq.Array(formatPayloads(n.replica, ids)))
+	return err
+}
+
+// Listen applies the invalidations published by the other replicas to c
+// until ctx is done; this one's were applied when published. Caching is
+// paused while the connection to the database is down, since invalidations
+// sent meanwhile are lost.
+func (n *Notifier) Listen(ctx context.Context, dsn string, c *Repository) error {
+	c.Pause()
+	listener := pq.NewListener(dsn, minReconnectInterval, maxReconnectInterval, func(event pq.ListenerEventType, err error) {
+		switch event {
+		case pq.ListenerEventConnected, pq.ListenerEventReconnected:
+			c.Resume()
+		case pq.ListenerEventDisconnected:
+			log.Printf("Cache invalidation listener disconnected, caching paused: %v", err)
+			c.Pause()
+		}
+	})
+	defer listener.Close()
+	if err := listener.Listen(channel); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case notification := <-listener.Notify:
+			// nil after a reconnection, which Resume already handled
+			if notification != nil {
+				n.apply(c, notification.Extra)
+			}
+		case <-ticker.C:
+			go listener.Ping()
+		}
+	}
+}
+
+// Apply to c the invalidation of payload, unless this replica sent it
+func (n *Notifier) apply(c *Repository, payload string) {
+	replica, ids, ok := splitPayload(payload)
+	if !ok {
+		log.Printf("Invalid cache invalidation %q: no replica", payload)
+		c.Flush()
+		return
+	}
+	if replica == n.replica {
+		return
+	}
+	parsed, err := parseIDs(ids)
+	if err != nil {
+		log.Printf("Invalid cache invalidation %q: %v", payload, err)
+		c.Flush()
+		return
+	}
+	c.Invalidate(parsed...)
+}
+
+// Payloads of the invalidation of ids by replica: the replica, a colon and
+// comma separated session IDs, as many of them as fit
+func formatPayloads(replica string, ids []int64) []string {
+	if len(ids) == 0 {
+		return nil
+	}
+	var payloads []string
+	prefix := replica + ":"
+	payload := prefix
+	for _, id := range ids {
+		formatted := strconv.FormatInt(id, 10)
+		if payload != prefix && len(payload)+1+len(formatted) > maxPayload {
+			payloads = append(payloads, payload)
+			payload = prefix
+		}
+		if payload != prefix {
+			payload += ","
+		}
+		payload += formatted
+	}
+	return append(payloads, payload)
+}
+
+// Split a payload into the replica that sent it and its IDs
+func splitPayload(payload string) (replica, ids string, ok bool) {
+	return strings.Cut(payload, ":")
+}
+
+// Parse comma separated session IDs
+func parseIDs(payload string) ([]int64, error) {
+	parts := strings.Split(payload, ",")
+	ids := make([]int64, len(parts))
+	for i, part := range parts {
+		id, err := strconv.ParseInt(part, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		ids[i] = id
+	}
+	return ids, nil
+}
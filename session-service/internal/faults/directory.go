@@ -0,0 +1,51 @@
+package faults
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"session-service/internal/users"
+)
+
+// Directory wraps a users.Directory and delays or times out its calls with
+// the user service faults enabled in the Injector.
+type Directory struct {
+	users.Directory
+	faults  *Injector
+	timeout time.Duration
+}
+
+// WrapDirectory returns dir with user service faults injected by i, for
+// calls that time out after timeout.
+func WrapDirectory(dir users.Directory, i *Injector, timeout time.Duration) *Directory {
+	return &Directory{Directory: dir, faults: i, timeout: timeout}
+}
+
+// GetUser times out, waits and calls the wrapped directory, or calls it
+func (d *Directory) GetUser(ctx context.Context, id, token string) (*users.User, error) {
+	if d.faults.Should(UsersTimeout) {
+		if err := wait(ctx, d.timeout); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %v (injected fault)", users.ErrUnavailable, context.DeadlineExceeded)
+	}
+	if d.faults.Should(UsersDelay) {
+		if err := wait(ctx, d.timeout/2); err != nil {
+			return nil, err
+		}
+	}
+	return d.Directory.GetUser(ctx, id, token)
+}
+
+// Wait for d, or fail like the user service client once ctx is done
+func wait(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("%w: %v", users.ErrUnavailable, ctx.Err())
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,103 @@
+// Package faults injects failures into the service's dependencies on
+// purpose, so that retries and fallbacks can be exercised before a real
+// outage does it.
+package faults
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Fault names a kind of dependency failure that can be injected.
+type Fault string
+
+const (
+	// DBSerialization makes database calls fail with a serialization failure
+	// (SQLSTATE 40001), as if a concurrent transaction had won.
+	DBSerialization Fault = "db_serialization"
+	// UsersDelay holds calls to the user service for half their timeout, as
+	// a slow user service would.
+	UsersDelay Fault = "users_delay"
+	// UsersTimeout makes calls to the user service fail once their timeout
+	// went by, as an unresponsive user service would.
+	UsersTimeout Fault = "users_timeout"
+)
+
+// Faults that Parse accepts
+var known = map[Fault]bool{
+	DBSerialization: true,
+	UsersDelay:      true,
+	UsersTimeout:    true,
+}
+
+// Injector decides whether a call should fail. A nil *Injector never
+// injects anything.
+type Injector struct {
+	mu    sync.Mutex
+	rand  *rand.Rand
+	rates map[Fault]float64
+}
+
+// Parse reads a comma-separated list of fault=rate pairs, where rate is the
+// probability between 0 and 1 that a call fails, e.g.
+// "db_serialization=0.1". An empty spec returns a nil Injector.
+func Parse(spec string) (*Injector, error) {
+	if strings.TrimSpace(spec) == "" {
+		return nil, nil
+	}
+
+	rates := make(map[Fault]float64)
+	for _, pair := range strings.Split(spec, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid fault %q: expected name=rate", pair)
+		}
+		fault := Fault(name)
+		if !known[fault] {
+			return nil, fmt.Errorf("unknown fault %q", name)
+		}
+		rate, err := strconv.ParseFloat(value, 64)
+		if err != nil || rate < 0 || rate > 1 {
+			return nil, fmt.Errorf("invalid rate for fault %s: %q", name, value)
+		}
+		rates[fault] = rate
+	}
+
+	return &Injector{
+		rand:  rand.New(rand.NewSource(time.Now().UnixNano())),
+		rates: rates,
+	}, nil
+}
+
+// Should reports whether the current call should fail with f.
+func (i *Injector) Should(f Fault) bool {
+	if i == nil {
+		return false
+	}
+	rate := i.rates[f]
+	if rate == 0 {
+		return false
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.rand.Float64() < rate
+}
+
+// String lists the configured faults, for the startup log.
+func (i *Injector) String() string {
+	if i == nil {
+		return "none"
+	}
+	pairs := make([]string, 0, len(i.rates))
+	for fault, rate := range i.rates {
+		pairs = append(pairs, fmt.Sprintf("%s=%g", fault, rate))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
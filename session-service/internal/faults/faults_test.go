@@ -0,0 +1,108 @@
+package faults
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"session-service/internal/store"
+	"session-service/internal/users"
+	"session-service/internal/users/usersmock"
+)
+
+func TestParse(t *testing.T) {
+	i, err := Parse("")
+	if err != nil || i != nil {
+		t.Errorf("Parse(\"\") = %v, %v; want nil injector", i, err)
+	}
+	if i.Should(DBSerialization) {
+		t.Error("A nil injector must not inject faults")
+	}
+
+	for _, spec := range []string{"db_serialization", "db_serialization=2", "db_serialization=x", "disk_full=0.5"} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Parse(%q): expected an error", spec)
+		}
+	}
+
+	i, err = Parse(" db_serialization=0.25 ")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if got := i.String(); got != "db_serialization=0.25" {
+		t.Errorf("String() = %q", got)
+	}
+}
+
+func TestShould(t *testing.T) {
+	always, _ := Parse("db_serialization=1")
+	never, _ := Parse("db_serialization=0")
+	for n := 0; n < 100; n++ {
+		if !always.Should(DBSerialization) {
+			t.Fatal("Rate 1 must always inject")
+		}
+		if never.Should(DBSerialization) {
+			t.Fatal("Rate 0 must never inject")
+		}
+	}
+}
+
+func TestWrapRepository(t *testing.T) {
+	ctx := context.Background()
+	mem := store.NewMemory()
+	session := &store.Session{Title: "Morning Yoga", Capacity: 1}
+	if err := mem.CreateSession(ctx, session, false); err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+
+	always, _ := Parse("db_serialization=1")
+	_, err := WrapRepository(mem, always).GetSession(ctx, session.ID)
+	if !store.IsSerializationFailure(err) {
+		t.Errorf("Expected a serialization failure, got %v", err)
+	}
+
+	never, _ := Parse("db_serialization=0")
+	if _, err := WrapRepository(mem, never).GetSession(ctx, session.ID); err != nil {
+		t.Errorf("Expected the call to pass through, got %v", err)
+	}
+}
+
+func TestWrapDirectory(t *testing.T) {
+	ctx := context.Background()
+	dir := &usersmock.DirectoryMock{
+		GetUserFunc: func(ctx context.Context, id, token string) (*users.User, error) {
+			return &users.User{ID: id, FirstName: "Sarah", Role: "coach"}, nil
+		},
+	}
+	const timeout = 20 * time.Millisecond
+
+	timingOut, _ := Parse("users_timeout=1")
+	start := time.Now()
+	_, err := WrapDirectory(dir, timingOut, timeout).GetUser(ctx, "coach-1", "")
+	if !errors.Is(err, users.ErrUnavailable) || time.Since(start) < timeout {
+		t.Errorf("Expected ErrUnavailable after %v, got %v after %v", timeout, err, time.Since(start))
+	}
+	if n := len(dir.GetUserCalls()); n != 0 {
+		t.Errorf("Expected the user service not called, got %d calls", n)
+	}
+
+	slow, _ := Parse("users_delay=1")
+	start = time.Now()
+	user, err := WrapDirectory(dir, slow, timeout).GetUser(ctx, "coach-1", "")
+	if err != nil || user.ID != "coach-1" || time.Since(start) < timeout/2 {
+		t.Errorf("Expected coach-1 after %v, got %+v, %v after %v", timeout/2, user, err, time.Since(start))
+	}
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := WrapDirectory(dir, slow, time.Hour).GetUser(cancelled, "coach-1", ""); !errors.Is(err, users.ErrUnavailable) {
+		t.Errorf("Expected ErrUnavailable once the call is cancelled, got %v", err)
+	}
+
+	if _, err := WrapDirectory(dir, nil, timeout).GetUser(ctx, "coach-1", ""); err != nil {
+		t.Errorf("Expected the call to pass through, got %v", err)
+	}
+	if n := len(dir.GetUserCalls()); n != 2 {
+		t.Errorf("Expected 2 calls to the user service, got %d", n)
+	}
+}
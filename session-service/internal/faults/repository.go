@@ -0,0 +1,202 @@
+package faults
+
+import (
+	"context"
+	"time"
+
+	"github.com/lib/pq"
+
+	"session-service/internal/store"
+)
+
+// Repository wraps a store.Repository and fails its calls with the database
+// faults enabled in the Injector.
+type Repository struct {
+	store.Repository
+	faults *Injector
+}
+
+// WrapRepository returns repo with database faults injected by i.
+func WrapRepository(repo store.Repository, i *Injector) *Repository {
+	return &Repository{Repository: repo, faults: i}
+}
+
+// Error returned for an injected serialization failure
+func (r *Repository) fail() error {
+	if r.faults.Should(DBSerialization) {
+		return &pq.Error{
+			Severity: "ERROR",
+			Code:     "40001",
+			Message:  "could not serialize access due to concurrent update (injected fault)",
+		}
+	}
+	return nil
+}
+
+// CreateSession fails or calls the wrapped repository
+func (r *Repository) CreateSession(ctx context.Context, s *store.Session, checkConflicts bool) error {
+	if err := r.fail(); err != nil {
+		return err
+	}
+	return r.Repository.CreateSession(ctx, s, checkConflicts)
+}
+
+// GetSession fails or calls the wrapped repository
+func (r *Repository) GetSession(ctx context.Context, id int64) (*store.Session, error) {
+	if err := r.fail(); err != nil {
+		return nil, err
+	}
+	return r.Repository.GetSession(ctx, id)
+}
+
+// UpdateSession fails or calls the wrapped repository
+func (r *Repository) UpdateSession(ctx context.Context, s *store.Session, checkConflicts bool) (*store.Session, error) {
+	if err := r.fail(); err != nil {
+		return nil, err
+	}
+	return r.Repository.UpdateSession(ctx, s, checkConflicts)
+}
+
+// CancelSession fails or calls the wrapped repository
+func (r *Repository) CancelSession(ctx context.Context, id int64, reason string) (*store.Session, error) {
+	if err := r.fail(); err != nil {
+		return nil, err
+	}
+	return r.Repository.CancelSession(ctx, id, reason)
+}
+
+// CreateSessionSeries fails or calls the wrapped repository
+func (r *Repository) CreateSessionSeries(ctx context.Context, ss []*store.Session, checkConflicts bool) error {
+	if err := r.fail(); err != nil {
+		return err
+	}
+	return r.Repository.CreateSessionSeries(ctx, ss, checkConflicts)
+}
+
+// FindConflicts fails or calls the wrapped repository
+func (r *Repository) FindConflicts(ctx context.Context, ss []*store.Session) ([]*store.Session, error) {
+	if err := r.fail(); err != nil {
+		return nil, err
+	}
+	return r.Repository.FindConflicts(ctx, ss)
+}
+
+// UpdateSessionSeries fails or calls the wrapped repository
+func (r *Repository) UpdateSessionSeries(ctx context.Context, s *store.Session, checkConflicts bool) ([]*store.Session, error) {
+	if err := r.fail(); err != nil {
+		return nil, err
+	}
+	return r.Repository.UpdateSessionSeries(ctx, s, checkConflicts)
+}
+
+// CancelSessionSeries fails or calls the wrapped repository
+func (r *Repository) CancelSessionSeries(ctx context.Context, id int64, reason string) ([]*store.Session, error) {
+	if err := r.fail(); err != nil {
+		return nil, err
+	}
+	return r.Repository.CancelSessionSeries(ctx, id, reason)
+}
+
+// ListSessions fails or calls the wrapped repository
+func (r *Repository) ListSessions(ctx context.Context, f store.SessionFilter, after *store.SessionCursor, descending bool, limit int) ([]*store.Session, error) {
+	if err := r.fail(); err != nil {
+		return nil, err
+	}
+	return r.Repository.ListSessions(ctx, f, after, descending, limit)
+}
+
+// ListSessionsAfter fails or calls the wrapped repository
+func (r *Repository) ListSessionsAfter(ctx context.Context, f store.SessionFilter, afterID int64, limit int) ([]*store.Session, error) {
+	if err := r.fail(); err != nil {
+		return nil, err
+	}
+	return r.Repository.ListSessionsAfter(ctx, f, afterID, limit)
+}
+
+// DeleteSession fails or calls the wrapped repository
+func (r *Repository) DeleteSession(ctx context.Context, id int64) error {
+	if err := r.fail(); err != nil {
+		return err
+	}
+	return r.Repository.DeleteSession(ctx, id)
+}
+
+// CompleteSessions fails or calls the wrapped repository
+func (r *Repository) CompleteSessions(ctx context.Context, endedBy time.Time) ([]int64, error) {
+	if err := r.fail(); err != nil {
+		return nil, err
+	}
+	return r.Repository.CompleteSessions(ctx, endedBy)
+}
+
+// CreateReservation fails or calls the wrapped repository
+func (r *Repository) CreateReservation(ctx context.Context, res *store.Reservation) error {
+	if err := r.fail(); err != nil {
+		return err
+	}
+	return r.Repository.CreateReservation(ctx, res)
+}
+
+// CreateReservations fails or calls the wrapped repository
+func (r *Repository) CreateReservations(ctx context.Context, rs []*store.Reservation, allOrNothing bool) ([]error, error) {
+	if err := r.fail(); err != nil {
+		return nil, err
+	}
+	return r.Repository.CreateReservations(ctx, rs, allOrNothing)
+}
+
+// GetReservation fails or calls the wrapped repository
+func (r *Repository) GetReservation(ctx context.Context, id int64) (*store.Reservation, error) {
+	if err := r.fail(); err != nil {
+		return nil, err
+	}
+	return r.Repository.GetReservation(ctx, id)
+}
+
+// ListReservations fails or calls the wrapped repository
+func (r *Repository) ListReservations(ctx context.Context, f store.ReservationFilter, afterID int64, limit int) ([]*store.Reservation, error) {
+	if err := r.fail(); err != nil {
+		return nil, err
+	}
+	return r.Repository.ListReservations(ctx, f, afterID, limit)
+}
+
+// CancelReservation fails or calls the wrapped repository
+func (r *Repository) CancelReservation(ctx context.Context, id int64) (*store.Reservation, error) {
+	if err := r.fail(); err != nil {
+		return nil, err
+	}
+	return r.Repository.CancelReservation(ctx, id)
+}
+
+// CheckIn fails or calls the wrapped repository
+func (r *Repository) CheckIn(ctx context.Context, id int64) (*store.Reservation, error) {
+	if err := r.fail(); err != nil {
+		return nil, err
+	}
+	return r.Repository.CheckIn(ctx, id)
+}
+
+// ReconcileReservedSpots fails or calls the wrapped repository
+func (r *Repository) ReconcileReservedSpots(ctx context.Context) ([]store.SpotDrift, error) {
+	if err := r.fail(); err != nil {
+		return nil, err
+	}
+	return r.Repository.ReconcileReservedSpots(ctx)
+}
+
+// JoinWaitlist fails or calls the wrapped repository
+func (r *Repository) JoinWaitlist(ctx context.Context, e *store.WaitlistEntry) error {
+	if err := r.fail(); err != nil {
+		return err
+	}
+	return r.Repository.JoinWaitlist(ctx, e)
+}
+
+// LeaveWaitlist fails or calls the wrapped repository
+func (r *Repository) LeaveWaitlist(ctx context.Context, sessionID int64, userID string) error {
+	if err := r.fail(); err != nil {
+		return err
+	}
+	return r.Repository.LeaveWaitlist(ctx, sessionID, userID)
+}
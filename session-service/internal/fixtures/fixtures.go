@@ -0,0 +1,187 @@
+// Package fixtures builds consistent sessions for tests and demo data:
+//
+//	session, err := fixtures.NewTestSession().Full().StartingIn(2 * time.Hour).Create(ctx, repo)
+//
+// Relative times are measured from the builder's reference time, which is
+// time.Now() unless set with RelativeTo.
+package fixtures
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"session-service/internal/store"
+)
+
+// SessionBuilder builds a store.Session. The zero configuration is a one
+// hour beginner yoga class with 15 spots starting in a day.
+type SessionBuilder struct {
+	now      time.Time
+	startIn  time.Duration
+	startAt  time.Time
+	duration time.Duration
+	full     bool
+	session  store.Session
+}
+
+// NewTestSession starts building a session with the default values.
+func NewTestSession() *SessionBuilder {
+	return &SessionBuilder{
+		now:      time.Now().UTC(),
+		startIn:  24 * time.Hour,
+		duration: time.Hour,
+		session: store.Session{
+			Title:           "Morning Yoga",
+			Description:     "Test session",
+			CoachID:         "coach-1",
+			CoachName:       "Coach Name",
+			Capacity:        15,
+			Location:        "Studio A",
+			SessionType:     "yoga",
+			DifficultyLevel: "beginner",
+		},
+	}
+}
+
+// RelativeTo sets the time StartingIn is measured from, e.g. a fake clock's.
+func (b *SessionBuilder) RelativeTo(now time.Time) *SessionBuilder {
+	b.now = now
+	return b
+}
+
+// StartingIn schedules the session d after the reference time. Use a
+// negative d for sessions in the past.
+func (b *SessionBuilder) StartingIn(d time.Duration) *SessionBuilder {
+	b.startIn = d
+	b.startAt = time.Time{}
+	return b
+}
+
+// StartingAt schedules the session at t.
+func (b *SessionBuilder) StartingAt(t time.Time) *SessionBuilder {
+	b.startAt = t
+	return b
+}
+
+// Lasting sets the session length.
+func (b *SessionBuilder) Lasting(d time.Duration) *SessionBuilder {
+	b.duration = d
+	return b
+}
+
+// InGym sets the gym the session belongs to.
+func (b *SessionBuilder) InGym(gymID string) *SessionBuilder {
+	b.session.GymID = gymID
+	return b
+}
+
+// Titled sets the session title.
+func (b *SessionBuilder) Titled(title string) *SessionBuilder {
+	b.session.Title = title
+	return b
+}
+
+// WithCoach sets the coach running the session.
+func (b *SessionBuilder) WithCoach(id, name string) *SessionBuilder {
+	b.session.CoachID = id
+	b.session.CoachName = name
+	return b
+}
+
+// WithCapacity sets the number of spots.
+func (b *SessionBuilder) WithCapacity(capacity int32) *SessionBuilder {
+	b.session.Capacity = capacity
+	return b
+}
+
+// AtLocation sets the room the session takes place in.
+func (b *SessionBuilder) AtLocation(location string) *SessionBuilder {
+	b.session.Location = location
+	return b
+}
+
+// OfType sets the session type and difficulty level.
+func (b *SessionBuilder) OfType(sessionType, difficultyLevel string) *SessionBuilder {
+	b.session.SessionType = sessionType
+	b.session.DifficultyLevel = difficultyLevel
+	return b
+}
+
+// WithReservedSpots marks n spots as taken. Create books them for made-up
+// members.
+func (b *SessionBuilder) WithReservedSpots(n int32) *SessionBuilder {
+	b.session.ReservedSpots = n
+	b.full = false
+	return b
+}
+
+// Full marks every spot as taken, whatever the final capacity.
+func (b *SessionBuilder) Full() *SessionBuilder {
+	b.full = true
+	return b
+}
+
+// Cancelled marks the session cancelled for reason.
+func (b *SessionBuilder) Cancelled(reason string) *SessionBuilder {
+	b.session.IsCancelled = true
+	b.session.CancellationReason = reason
+	return b
+}
+
+// Completed marks the session completed, as the lifecycle job does once it
+// ended.
+func (b *SessionBuilder) Completed() *SessionBuilder {
+	b.session.IsCompleted = true
+	return b
+}
+
+// Queued makes the session take bookings through the queue only.
+func (b *SessionBuilder) Queued() *SessionBuilder {
+	b.session.QueuedBooking = true
+	return b
+}
+
+// Build returns the configured session without storing it.
+func (b *SessionBuilder) Build() *store.Session {
+	s := b.session
+	s.StartTime = b.startAt
+	if s.StartTime.IsZero() {
+		s.StartTime = b.now.Add(b.startIn)
+	}
+	s.StartTime = s.StartTime.UTC().Truncate(time.Second)
+	s.EndTime = s.StartTime.Add(b.duration)
+	if b.full {
+		s.ReservedSpots = s.Capacity
+	}
+	return &s
+}
+
+// Create stores the configured session in repo and returns it. Taken spots
+// are booked by members "member-1", "member-2" and so on, and a cancelled
+// session is cancelled after they booked.
+func (b *SessionBuilder) Create(ctx context.Context, repo store.Repository) (*store.Session, error) {
+	s := b.Build()
+	reserved, cancelled, reason := s.ReservedSpots, s.IsCancelled, s.CancellationReason
+	s.ReservedSpots, s.IsCancelled, s.CancellationReason = 0, false, ""
+	if err := repo.CreateSession(ctx, s, false); err != nil {
+		return nil, err
+	}
+
+	for i := 1; i <= int(reserved); i++ {
+		err := repo.CreateReservation(ctx, &store.Reservation{
+			SessionID: s.ID,
+			UserID:    fmt.Sprintf("member-%d", i),
+			UserName:  fmt.Sprintf("Member %d", i),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("booking fixture session: %w", err)
+		}
+	}
+	if cancelled {
+		if _, err := repo.CancelSession(ctx, s.ID, reason); err != nil {
+			return nil, err
+		}
+	}
+	return repo.GetSession(ctx, s.ID)
+}
@@ -0,0 +1,225 @@
+// Package jobs runs background jobs in process: each registered job runs on
+// its own schedule, with optional jitter and retries, and the scheduler
+// keeps per-job counters. Stopping the scheduler lets the runs in progress
+// finish, up to a grace period.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"session-service/internal/clock"
+)
+
+// Job is a unit of background work.
+type Job struct {
+	Name     string
+	Schedule Schedule
+	Run      func(ctx context.Context) error
+
+	// Each run is delayed by a random duration up to Jitter, so that jobs
+	// on the same schedule do not all hit the database at once
+	Jitter time.Duration
+	// A failed run is retried up to Retries times, first after
+	// RetryBackoff and then twice as long each time, before the job waits
+	// for its next scheduled run
+	Retries      int
+	RetryBackoff time.Duration
+	// Timeout bounds each attempt; zero means no bound
+	Timeout time.Duration
+}
+
+// Stats are the counters of a job.
+type Stats struct {
+	Name         string
+	Runs         int64
+	Failures     int64
+	Retries      int64
+	Running      bool
+	LastRun      time.Time
+	LastDuration time.Duration
+	LastError    string
+	NextRun      time.Time
+}
+
+// Default of Scheduler.GracePeriod
+const DefaultGracePeriod = 30 * time.Second
+
+// Default of Job.RetryBackoff when retries are enabled
+const defaultRetryBackoff = time.Second
+
+// Scheduler runs the registered jobs.
+type Scheduler struct {
+	clk clock.Clock
+
+	mu    sync.Mutex
+	jobs  []*Job
+	stats map[string]*Stats
+
+	// How long Run waits for the runs in progress once its context is done,
+	// before cancelling theirs
+	GracePeriod time.Duration
+}
+
+// NewScheduler returns a Scheduler without jobs, reading the time from clk.
+func NewScheduler(clk clock.Clock) *Scheduler {
+	return &Scheduler{
+		clk:         clk,
+		stats:       make(map[string]*Stats),
+		GracePeriod: DefaultGracePeriod,
+	}
+}
+
+// Register adds a job. It must be called before Run.
+func (s *Scheduler) Register(j Job) error {
+	if j.Name == "" || j.Schedule == nil || j.Run == nil {
+		return errors.New("job needs a name, a schedule and a function")
+	}
+	if j.Retries > 0 && j.RetryBackoff <= 0 {
+		j.RetryBackoff = defaultRetryBackoff
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.stats[j.Name]; ok {
+		return fmt.Errorf("job %s already registered", j.Name)
+	}
+	s.jobs = append(s.jobs, &j)
+	s.stats[j.Name] = &Stats{Name: j.Name}
+	return nil
+}
+
+// Run runs the jobs on their schedules until ctx is done, then waits for the
+// runs in progress to finish. Those are cancelled if they take longer than
+// the grace period.
+func (s *Scheduler) Run(ctx context.Context) {
+	s.mu.Lock()
+	jobs := append([]*Job(nil), s.jobs...)
+	s.mu.Unlock()
+
+	// Runs only see their context cancelled once the grace period is over
+	runCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, j := range jobs {
+		wg.Add(1)
+		go func(j *Job) {
+			defer wg.Done()
+			s.loop(ctx, runCtx, j)
+		}(j)
+	}
+
+	<-ctx.Done()
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(s.GracePeriod):
+		log.Printf("Jobs still running after %v, cancelling them", s.GracePeriod)
+		cancel()
+		<-done
+	}
+}
+
+// Stats returns the counters of every job, by name.
+func (s *Scheduler) Stats() []Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := make([]Stats, 0, len(s.stats))
+	for _, st := range s.stats {
+		stats = append(stats, *st)
+	}
+	sort.Slice(stats, func(i, k int) bool { return stats[i].Name < stats[k].Name })
+	return stats
+}
+
+// Run j on its schedule until ctx is done; runs get runCtx
+func (s *Scheduler) loop(ctx, runCtx context.Context, j *Job) {
+	for {
+		next := j.Schedule.Next(s.clk.Now())
+		if next.IsZero() {
+			log.Printf("Job %s has no next run, stopping it", j.Name)
+			return
+		}
+		if j.Jitter > 0 {
+			next = next.Add(time.Duration(rand.Int63n(int64(j.Jitter))))
+		}
+		s.update(j, func(st *Stats) { st.NextRun = next })
+
+		if !sleep(ctx, next.Sub(s.clk.Now())) {
+			return
+		}
+		s.run(ctx, runCtx, j)
+	}
+}
+
+// Run j once, retrying failed attempts while ctx is not done
+func (s *Scheduler) run(ctx, runCtx context.Context, j *Job) {
+	s.update(j, func(st *Stats) { st.Running = true })
+	start := s.clk.Now()
+
+	backoff := j.RetryBackoff
+	err := s.attempt(runCtx, j)
+	for attempt := 1; err != nil && attempt <= j.Retries; attempt++ {
+		log.Printf("Job %s failed, retrying in %v (%d/%d): %v", j.Name, backoff, attempt, j.Retries, err)
+		if !sleep(ctx, backoff) {
+			break
+		}
+		s.update(j, func(st *Stats) { st.Retries++ })
+		err = s.attempt(runCtx, j)
+		backoff *= 2
+	}
+	if err != nil {
+		log.Printf("Job %s failed: %v", j.Name, err)
+	}
+
+	s.update(j, func(st *Stats) {
+		st.Running = false
+		st.Runs++
+		st.LastRun = start
+		st.LastDuration = s.clk.Now().Sub(start)
+		st.LastError = ""
+		if err != nil {
+			st.Failures++
+			st.LastError = err.Error()
+		}
+	})
+}
+
+func (s *Scheduler) attempt(ctx context.Context, j *Job) error {
+	if j.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, j.Timeout)
+		defer cancel()
+	}
+	return j.Run(ctx)
+}
+
+// Wait for d, or until ctx is done in which case it returns false
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+func (s *Scheduler) update(j *Job, f func(st *Stats)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f(s.stats[j.Name])
+}
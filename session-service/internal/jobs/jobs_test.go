@@ -0,0 +1,165 @@
+package jobs_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"session-service/internal/clock"
+	"session-service/internal/jobs"
+)
+
+func TestSchedulerRegister(t *testing.T) {
+	s := jobs.NewScheduler(clock.Real{})
+	run := func(context.Context) error { return nil }
+
+	if err := s.Register(jobs.Job{Name: "job", Schedule: jobs.Every(time.Second), Run: run}); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if err := s.Register(jobs.Job{Name: "job", Schedule: jobs.Every(time.Second), Run: run}); err == nil {
+		t.Error("Registering a name twice: expected an error")
+	}
+	if err := s.Register(jobs.Job{Name: "other", Run: run}); err == nil {
+		t.Error("Registering a job without a schedule: expected an error")
+	}
+}
+
+func TestSchedulerFailures(t *testing.T) {
+	s := jobs.NewScheduler(clock.Real{})
+	err := s.Register(jobs.Job{
+		Name:     "hourly",
+		Schedule: jobs.Every(time.Hour),
+		Run:      func(context.Context) error { return nil },
+	})
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	failing := errors.New("always failing")
+	err = s.Register(jobs.Job{
+		Name:         "broken",
+		Schedule:     jobs.Every(10 * time.Millisecond),
+		Retries:      1,
+		RetryBackoff: time.Millisecond,
+		Run:          func(context.Context) error { return failing },
+	})
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	s.Run(ctx)
+
+	stats := s.Stats()
+	if len(stats) != 2 || stats[0].Name != "broken" || stats[1].Name != "hourly" {
+		t.Fatalf("Expected the stats of both jobs by name, got %+v", stats)
+	}
+	broken := stats[0]
+	// Stopping interrupts the retry of the last run
+	if broken.Runs == 0 || broken.Failures != broken.Runs || broken.Retries < broken.Runs-1 {
+		t.Errorf("Expected every run of the broken job to fail after one retry, got %+v", broken)
+	}
+	if broken.LastError != failing.Error() {
+		t.Errorf("Expected last error %q, got %q", failing, broken.LastError)
+	}
+	if stats[1].Runs != 0 || stats[1].NextRun.IsZero() {
+		t.Errorf("Expected the hourly job scheduled but not run, got %+v", stats[1])
+	}
+}
+
+func TestSchedulerRetriesUntilSuccess(t *testing.T) {
+	s := jobs.NewScheduler(clock.Real{})
+	var calls int32
+	done := make(chan struct{})
+	err := s.Register(jobs.Job{
+		Name:         "flaky",
+		Schedule:     jobs.Every(time.Millisecond),
+		Retries:      2,
+		RetryBackoff: time.Millisecond,
+		Run: func(context.Context) error {
+			if atomic.AddInt32(&calls, 1) < 3 {
+				return errors.New("unavailable")
+			}
+			close(done)
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(stopped)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Job never succeeded")
+	}
+	cancel()
+	<-stopped
+
+	st := s.Stats()[0]
+	if st.Runs != 1 || st.Failures != 0 || st.Retries != 2 || st.LastError != "" {
+		t.Errorf("Expected one successful run after 2 retries, got %+v", st)
+	}
+}
+
+func TestSchedulerGracefulStop(t *testing.T) {
+	s := jobs.NewScheduler(clock.Real{})
+	s.GracePeriod = 50 * time.Millisecond
+	started := make(chan struct{})
+	var finished, cancelled int32
+	err := s.Register(jobs.Job{
+		Name:     "slow",
+		Schedule: jobs.Every(time.Millisecond),
+		Run: func(ctx context.Context) error {
+			close(started)
+			select {
+			case <-ctx.Done():
+				atomic.StoreInt32(&cancelled, 1)
+				return ctx.Err()
+			case <-time.After(20 * time.Millisecond):
+				atomic.StoreInt32(&finished, 1)
+				return nil
+			}
+		},
+	})
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	err = s.Register(jobs.Job{
+		Name:     "stuck",
+		Schedule: jobs.Every(time.Millisecond),
+		Run: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	})
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+	s.Run(ctx)
+
+	// The slow run finishes within the grace period, the stuck one is
+	// cancelled once it is over
+	if atomic.LoadInt32(&finished) != 1 || atomic.LoadInt32(&cancelled) != 0 {
+		t.Error("Expected the run in progress to finish")
+	}
+	for _, st := range s.Stats() {
+		if st.Runs != 1 || st.Running {
+			t.Errorf("Expected one finished run of %s, got %+v", st.Name, st)
+		}
+	}
+}
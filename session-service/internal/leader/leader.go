@@ -0,0 +1,128 @@
+// Package leader elects one replica of the service to run the background
+// jobs that must not run once per replica, like the reserved_spots
+// reconciliation. The leader holds a Postgres advisory lock on a connection
+// of its own; Postgres releases the lock when that connection ends, so a
+// replica that dies hands over leadership without any cleanup.
+//
+// Session-level advisory locks need a real database session: point the
+// service at Postgres directly, or at a pooler in session mode.
+package leader
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"hash/fnv"
+	"log"
+	"sync"
+	"time"
+)
+
+// Defaults of an Elector
+const (
+	// How often a follower tries to take the lock
+	DefaultRetryInterval = 10 * time.Second
+	// How often the leader checks that its connection, and so its lock, is
+	// still alive. After a network failure two leaders may overlap for up
+	// to this long.
+	DefaultCheckInterval = 5 * time.Second
+)
+
+// Elector runs work on one replica at a time.
+type Elector struct {
+	db   *sql.DB
+	name string
+	key  int64
+
+	RetryInterval time.Duration
+	CheckInterval time.Duration
+}
+
+// New returns an Elector competing with the other replicas for the lock
+// called name.
+func New(db *sql.DB, name string) *Elector {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return &Elector{
+		db:            db,
+		name:          name,
+		key:           int64(h.Sum64()),
+		RetryInterval: DefaultRetryInterval,
+		CheckInterval: DefaultCheckInterval,
+	}
+}
+
+// Run calls work whenever this replica becomes the leader, until ctx is
+// done. The context given to work is cancelled as soon as leadership is
+// lost, and Run waits for work to return before competing again.
+func (e *Elector) Run(ctx context.Context, work func(ctx context.Context)) {
+	for {
+		err := e.lead(ctx, work)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Leader election %s: %v", e.name, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(e.RetryInterval):
+		}
+	}
+}
+
+// Take the lock if it is free and run work while holding it
+func (e *Elector) lead(ctx context.Context, work func(ctx context.Context)) error {
+	conn, err := e.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var locked bool
+	if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, e.key).Scan(&locked); err != nil {
+		return err
+	}
+	if !locked {
+		return nil
+	}
+	// Rather than going back to the pool with the lock, the connection is
+	// closed, which releases the lock at once
+	defer conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+	log.Printf("Became the leader for %s", e.name)
+
+	leading, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		work(leading)
+	}()
+
+	ticker := time.NewTicker(e.CheckInterval)
+	defer ticker.Stop()
+	for err == nil {
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+		case <-ticker.C:
+			err = e.check(ctx, conn)
+		}
+	}
+	cancel()
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		log.Printf("Stepped down as the leader for %s", e.name)
+		return nil
+	}
+	log.Printf("Lost leadership for %s", e.name)
+	return err
+}
+
+// Check that the connection holding the lock is alive
+func (e *Elector) check(ctx context.Context, conn *sql.Conn) error {
+	ctx, cancel := context.WithTimeout(ctx, e.CheckInterval)
+	defer cancel()
+	_, err := conn.ExecContext(ctx, `SELECT 1`)
+	return err
+}
@@ -0,0 +1,112 @@
+// Package listen opens the sockets the service serves on, from a list of
+// addresses in its configuration: TCP addresses for clients on the network
+// and Unix domain sockets for a sidecar proxy on the same host.
+package listen
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// Prefix of the addresses of Unix domain sockets
+const unixPrefix = "unix:"
+
+// Address is where to listen: a network as understood by net.Listen and an
+// address on it.
+type Address struct {
+	Network string
+	Addr    string
+}
+
+// String returns the address as Parse accepts it.
+func (a Address) String() string {
+	if a.Network == "unix" {
+		return unixPrefix + a.Addr
+	}
+	return a.Addr
+}
+
+// Parse reads a comma-separated list of addresses, each either host:port or
+// :port for TCP, or unix:PATH for a Unix domain socket, e.g.
+// ":50051,unix:/run/session-service/grpc.sock". Unix paths may also be
+// written as gRPC targets, unix:///run/session-service/grpc.sock.
+func Parse(list string) ([]Address, error) {
+	var addrs []Address
+	seen := make(map[Address]bool)
+	for _, field := range strings.Split(list, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		var a Address
+		if path := strings.TrimPrefix(field, unixPrefix); path != field {
+			path = strings.TrimPrefix(path, "//")
+			if path == "" {
+				return nil, fmt.Errorf("invalid address %q: missing socket path", field)
+			}
+			a = Address{"unix", path}
+		} else {
+			if _, port, err := net.SplitHostPort(field); err != nil || port == "" {
+				return nil, fmt.Errorf("invalid address %q: expected host:port, :port or unix:PATH", field)
+			}
+			a = Address{"tcp", field}
+		}
+		if seen[a] {
+			return nil, fmt.Errorf("address %q listed twice", field)
+		}
+		seen[a] = true
+		addrs = append(addrs, a)
+	}
+	if len(addrs) == 0 {
+		return nil, errors.New("no address to listen on")
+	}
+	return addrs, nil
+}
+
+// Listen opens a listener on each address, or none if one fails. A socket
+// file left behind by a previous run is removed first; any other file at a
+// Unix socket path is an error. Unix sockets are removed again when their
+// listener is closed.
+func Listen(addrs []Address) ([]net.Listener, error) {
+	var listeners []net.Listener
+	for _, a := range addrs {
+		if a.Network == "unix" {
+			if err := removeStaleSocket(a.Addr); err != nil {
+				closeAll(listeners)
+				return nil, err
+			}
+		}
+		l, err := net.Listen(a.Network, a.Addr)
+		if err != nil {
+			closeAll(listeners)
+			return nil, fmt.Errorf("listening on %s: %w", a, err)
+		}
+		listeners = append(listeners, l)
+	}
+	return listeners, nil
+}
+
+// Remove the socket file at path, if there is one
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("listening on %s%s: not a socket, refusing to remove it", unixPrefix, path)
+	}
+	return os.Remove(path)
+}
+
+func closeAll(listeners []net.Listener) {
+	for _, l := range listeners {
+		l.Close()
+	}
+}
@@ -0,0 +1,97 @@
+package listen
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		list string
+		want []Address
+	}{
+		{":50051", []Address{{"tcp", ":50051"}}},
+		{"127.0.0.1:50051, [::1]:50052", []Address{{"tcp", "127.0.0.1:50051"}, {"tcp", "[::1]:50052"}}},
+		{"unix:/run/grpc.sock", []Address{{"unix", "/run/grpc.sock"}}},
+		{"unix:///run/grpc.sock,:50051,", []Address{{"unix", "/run/grpc.sock"}, {"tcp", ":50051"}}},
+		{"unix:grpc.sock", []Address{{"unix", "grpc.sock"}}},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.list)
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %v", tt.list, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Parse(%q): expected %v, got %v", tt.list, tt.want, got)
+		}
+	}
+
+	for _, list := range []string{"", " , ", "50051", "localhost", "unix:", "unix://", ":50051,:50051"} {
+		if _, err := Parse(list); err == nil {
+			t.Errorf("Parse(%q): expected an error", list)
+		}
+	}
+}
+
+func TestListen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "grpc.sock")
+	addrs := []Address{{"tcp", "127.0.0.1:0"}, {"unix", path}}
+
+	listeners, err := Listen(addrs)
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	for _, l := range listeners {
+		go func(l net.Listener) {
+			for {
+				c, err := l.Accept()
+				if err != nil {
+					return
+				}
+				c.Close()
+			}
+		}(l)
+		c, err := net.Dial(l.Addr().Network(), l.Addr().String())
+		if err != nil {
+			t.Errorf("Failed to connect to %v: %v", l.Addr(), err)
+			continue
+		}
+		c.Close()
+	}
+	closeAll(listeners)
+	if _, err := os.Lstat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected the socket removed on close, got %v", err)
+	}
+}
+
+func TestListenStaleSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "grpc.sock")
+
+	// A socket left behind by a process that did not close it
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("Failed to create socket: %v", err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	listeners, err := Listen([]Address{{"unix", path}})
+	if err != nil {
+		t.Fatalf("Listen over a stale socket failed: %v", err)
+	}
+	closeAll(listeners)
+
+	if err := os.WriteFile(path, []byte("data"), 0o600); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	if _, err := Listen([]Address{{"tcp", "127.0.0.1:0"}, {"unix", path}}); err == nil {
+		t.Error("Expected Listen to refuse to replace a regular file")
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != "data" {
+		t.Errorf("Expected the file left alone, got %q, %v", data, err)
+	}
+}
@@ -0,0 +1,194 @@
+// Package queue books the sessions that sell out in seconds fairly: booking
+// requests wait in a line per session and are served one at a time, in
+// arrival order, at a bounded rate. Members see their place in the line
+// instead of retrying as fast as they can.
+//
+// A line lives in the replica that took the requests. Replicas behind a
+// load balancer each serve their own line; bookings from all of them still
+// go through the same row lock, so capacity holds.
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"session-service/internal/store"
+)
+
+// ErrQueueFull is returned by Join when a session has MaxWaiting requests
+// waiting already.
+var ErrQueueFull = errors.New("queue full")
+
+// Defaults of a Queue
+const (
+	// Pause after each booking of a session, which bounds the load a flash
+	// sale puts on the database to 20 bookings a second per session
+	DefaultInterval = 50 * time.Millisecond
+	// Requests waiting for a session, beyond which Join refuses new ones
+	DefaultMaxWaiting = 10000
+)
+
+// Queue serves booking requests through one line per session.
+type Queue struct {
+	repo store.ReservationRepository
+
+	mu    sync.Mutex
+	lines map[lineKey]*line
+
+	Interval   time.Duration
+	MaxWaiting int
+}
+
+// Line of a session, in the gym its requests are scoped to
+type lineKey struct {
+	gymID     string
+	sessionID int64
+}
+
+type line struct {
+	waiting []*Ticket
+	joined  int64 // Tickets that joined so far
+	served  int64 // Tickets taken off the line so far, served or abandoned
+}
+
+// Ticket is a booking request waiting in a Queue.
+type Ticket struct {
+	q           *Queue
+	line        *line
+	seq         int64
+	ctx         context.Context
+	reservation *store.Reservation
+	abandoned   bool
+
+	done chan struct{}
+	err  error
+}
+
+// New returns a Queue booking the reservations with repo.
+func New(repo store.ReservationRepository) *Queue {
+	return &Queue{
+		repo:       repo,
+		lines:      make(map[lineKey]*line),
+		Interval:   DefaultInterval,
+		MaxWaiting: DefaultMaxWaiting,
+	}
+}
+
+// Join puts the booking of r at the end of the line of its session. The
+// booking is scoped to the gym of ctx, but does not otherwise depend on ctx:
+// use Leave to give up.
+func (q *Queue) Join(ctx context.Context, r *store.Reservation) (*Ticket, error) {
+	bookCtx := context.Background()
+	gymID := store.GymFromContext(ctx)
+	if gymID != "" {
+		bookCtx = store.WithGym(bookCtx, gymID)
+	}
+	key := lineKey{gymID, r.SessionID}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	l, ok := q.lines[key]
+	if !ok {
+		l = &line{}
+		q.lines[key] = l
+		go q.serve(key, l)
+	}
+	if len(l.waiting) >= q.MaxWaiting {
+		return nil, ErrQueueFull
+	}
+	t := &Ticket{q: q, line: l, seq: l.joined, ctx: bookCtx, reservation: r, done: make(chan struct{})}
+	l.joined++
+	l.waiting = append(l.waiting, t)
+	return t, nil
+}
+
+// Book the requests of l in order until it is empty, or until the session
+// stops taking bookings
+func (q *Queue) serve(key lineKey, l *line) {
+	for {
+		t := q.next(key, l)
+		if t == nil {
+			return
+		}
+		t.err = q.repo.CreateReservation(t.ctx, t.reservation)
+		close(t.done)
+		switch t.err {
+		case store.ErrSessionFull, store.ErrAlreadyCancelled, store.ErrSessionCompleted:
+			q.fail(key, l, t.err)
+			return
+		}
+		time.Sleep(q.Interval)
+	}
+}
+
+// Fail the requests still waiting in l with err, which every one of them
+// would get, and remove l
+func (q *Queue) fail(key lineKey, l *line, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, t := range l.waiting {
+		if !t.abandoned {
+			t.err = err
+			close(t.done)
+		}
+	}
+	l.served += int64(len(l.waiting))
+	l.waiting = nil
+	delete(q.lines, key)
+}
+
+// Take the next request off l, skipping abandoned ones, or remove l if it is
+// empty
+func (q *Queue) next(key lineKey, l *line) *Ticket {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for len(l.waiting) > 0 {
+		t := l.waiting[0]
+		l.waiting[0] = nil
+		l.waiting = l.waiting[1:]
+		l.served++
+		if !t.abandoned {
+			return t
+		}
+	}
+	delete(q.lines, key)
+	return nil
+}
+
+// Position returns the number of requests ahead of t, some of which may have
+// been abandoned. It is 0 once t is being served.
+func (t *Ticket) Position() int {
+	t.q.mu.Lock()
+	defer t.q.mu.Unlock()
+
+	if ahead := t.seq - t.line.served; ahead > 0 {
+		return int(ahead)
+	}
+	return 0
+}
+
+// Leave gives up the place of t, unless it is being served already.
+func (t *Ticket) Leave() {
+	t.q.mu.Lock()
+	defer t.q.mu.Unlock()
+	t.abandoned = true
+}
+
+// Done is closed once the booking of t was attempted.
+func (t *Ticket) Done() <-chan struct{} {
+	return t.done
+}
+
+// Result returns the booked reservation, or the error CreateReservation
+// returned. It must be called after Done is closed.
+func (t *Ticket) Result() (*store.Reservation, error) {
+	if t.err != nil {
+		return nil, t.err
+	}
+	return t.reservation, nil
+}
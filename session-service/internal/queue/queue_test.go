@@ -0,0 +1,183 @@
+package queue_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"session-service/internal/fixtures"
+	"session-service/internal/queue"
+	"session-service/internal/store"
+)
+
+// Repository whose bookings wait until released
+type gatedRepo struct {
+	store.Repository
+	gate chan struct{}
+}
+
+func (g *gatedRepo) CreateReservation(ctx context.Context, r *store.Reservation) error {
+	<-g.gate
+	return g.Repository.CreateReservation(ctx, r)
+}
+
+func newSession(t *testing.T, repo store.Repository, capacity int32) *store.Session {
+	t.Helper()
+	s, err := fixtures.NewTestSession().WithCapacity(capacity).Queued().Create(context.Background(), repo)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	return s
+}
+
+func join(t *testing.T, q *queue.Queue, ctx context.Context, sessionID int64, userID string) *queue.Ticket {
+	t.Helper()
+	ticket, err := q.Join(ctx, &store.Reservation{SessionID: sessionID, UserID: userID, UserName: "Member"})
+	if err != nil {
+		t.Fatalf("Join failed: %v", err)
+	}
+	return ticket
+}
+
+func result(t *testing.T, ticket *queue.Ticket) (*store.Reservation, error) {
+	t.Helper()
+	select {
+	case <-ticket.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("Booking request never served")
+	}
+	return ticket.Result()
+}
+
+func TestQueueServesInOrder(t *testing.T) {
+	repo := store.NewMemory()
+	q := queue.New(repo)
+	q.Interval = time.Millisecond
+	session := newSession(t, repo, 3)
+
+	tickets := make([]*queue.Ticket, 6)
+	for i := range tickets {
+		tickets[i] = join(t, q, context.Background(), session.ID, fmt.Sprintf("member-%d", i))
+	}
+	for i, ticket := range tickets {
+		r, err := result(t, ticket)
+		switch {
+		case i < 3 && (err != nil || r.Status != store.ReservationConfirmed):
+			t.Errorf("Request %d: expected a booking, got %+v, %v", i, r, err)
+		case i >= 3 && err != store.ErrSessionFull:
+			t.Errorf("Request %d: expected %v, got %v", i, store.ErrSessionFull, err)
+		}
+	}
+}
+
+func TestQueuePositionAndLeave(t *testing.T) {
+	repo := &gatedRepo{Repository: store.NewMemory(), gate: make(chan struct{})}
+	q := queue.New(repo)
+	q.Interval = 0
+	session := newSession(t, repo, 10)
+
+	first := join(t, q, context.Background(), session.ID, "member-1")
+	second := join(t, q, context.Background(), session.ID, "member-2")
+	third := join(t, q, context.Background(), session.ID, "member-3")
+	if p := third.Position(); p != 2 && p != 1 {
+		t.Errorf("Expected 1 or 2 requests ahead of the third, got %d", p)
+	}
+
+	second.Leave()
+	close(repo.gate)
+	for _, ticket := range []*queue.Ticket{first, third} {
+		if _, err := result(t, ticket); err != nil {
+			t.Errorf("Booking failed: %v", err)
+		}
+		if p := ticket.Position(); p != 0 {
+			t.Errorf("Expected position 0 once served, got %d", p)
+		}
+	}
+	select {
+	case <-second.Done():
+		t.Error("Abandoned request was served")
+	default:
+	}
+	if s, err := repo.GetSession(context.Background(), session.ID); err != nil || s.ReservedSpots != 2 {
+		t.Errorf("Expected 2 bookings, got %+v, %v", s, err)
+	}
+}
+
+func TestQueueFailsWaitingOnceClosed(t *testing.T) {
+	repo := store.NewMemory()
+	q := queue.New(repo)
+	q.Interval = time.Hour
+	session, err := fixtures.NewTestSession().Queued().Cancelled("Coach is sick").Create(context.Background(), repo)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	// The requests behind the first get its answer without waiting their turn
+	tickets := make([]*queue.Ticket, 4)
+	for i := range tickets {
+		tickets[i] = join(t, q, context.Background(), session.ID, fmt.Sprintf("member-%d", i))
+	}
+	tickets[2].Leave()
+	for i, ticket := range tickets {
+		if i == 2 {
+			continue
+		}
+		if _, err := result(t, ticket); err != store.ErrAlreadyCancelled {
+			t.Errorf("Request %d: expected %v, got %v", i, store.ErrAlreadyCancelled, err)
+		}
+		if pos := ticket.Position(); pos != 0 {
+			t.Errorf("Request %d: expected position 0, got %d", i, pos)
+		}
+	}
+
+	// A new request starts a new line
+	late := join(t, q, context.Background(), session.ID, "member-5")
+	if _, err := result(t, late); err != store.ErrAlreadyCancelled {
+		t.Errorf("Late request: expected %v, got %v", store.ErrAlreadyCancelled, err)
+	}
+}
+
+func TestQueueFull(t *testing.T) {
+	repo := &gatedRepo{Repository: store.NewMemory(), gate: make(chan struct{})}
+	defer close(repo.gate)
+	q := queue.New(repo)
+	q.MaxWaiting = 2
+	session := newSession(t, repo, 10)
+
+	// The first request leaves the line once it is being served, which
+	// makes room for one more
+	joined := 0
+	for i := 1; i <= 4; i++ {
+		_, err := q.Join(context.Background(), &store.Reservation{SessionID: session.ID, UserID: fmt.Sprintf("member-%d", i)})
+		if err == queue.ErrQueueFull {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Join failed: %v", err)
+		}
+		joined++
+	}
+	if joined != 2 && joined != 3 {
+		t.Errorf("Expected the line full after 2 or 3 requests, got %d", joined)
+	}
+}
+
+func TestQueueGymScope(t *testing.T) {
+	repo := store.NewMemory()
+	q := queue.New(repo)
+	q.Interval = time.Millisecond
+	session, err := fixtures.NewTestSession().InGym("north").Queued().Create(context.Background(), repo)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	south := join(t, q, store.WithGym(context.Background(), "south"), session.ID, "member-1")
+	if _, err := result(t, south); err != store.ErrNotFound {
+		t.Errorf("Booking from another gym: expected %v, got %v", store.ErrNotFound, err)
+	}
+	north := join(t, q, store.WithGym(context.Background(), "north"), session.ID, "member-1")
+	if r, err := result(t, north); err != nil || r.GymID != "north" {
+		t.Errorf("Expected a booking in gym north, got %+v, %v", r, err)
+	}
+}
@@ -0,0 +1,153 @@
+// Package recording captures unary RPCs to files, with personal data replaced
+// by pseudonyms, and plays them back against another server. It is used to
+// reproduce production bug reports on a local instance.
+package recording
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"session-service/internal/anonymize"
+)
+
+// Record is one captured call, stored as a line of JSON. Messages use the
+// protobuf JSON mapping with the field names of the .proto file.
+type Record struct {
+	Time     time.Time       `json:"time"`
+	Method   string          `json:"method"`
+	Request  json.RawMessage `json:"request"`
+	Response json.RawMessage `json:"response,omitempty"`
+	Code     string          `json:"code"`
+	Message  string          `json:"message,omitempty"`
+}
+
+// Recorder appends the calls it intercepts to a file.
+type Recorder struct {
+	mu   sync.Mutex
+	file *os.File
+	enc  *json.Encoder
+	anon anonymize.Hasher
+}
+
+// NewRecorder creates a new recording file in dir.
+func NewRecorder(dir string, anon anonymize.Hasher) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	name := fmt.Sprintf("rpc-%s-%d.jsonl", time.Now().UTC().Format("20060102T150405"), os.Getpid())
+	f, err := os.OpenFile(filepath.Join(dir, name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{file: f, enc: json.NewEncoder(f), anon: anon}, nil
+}
+
+// Path returns the file being written.
+func (r *Recorder) Path() string {
+	return r.file.Name()
+}
+
+// Close flushes and closes the recording file.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.file.Close()
+}
+
+// UnaryInterceptor records every call passing through it.
+func (r *Recorder) UnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	resp, err := handler(ctx, req)
+
+	rec := Record{
+		Time:    time.Now().UTC(),
+		Method:  info.FullMethod,
+		Request: r.encode(req),
+		Code:    status.Code(err).String(),
+	}
+	if err != nil {
+		rec.Message = status.Convert(err).Message()
+	} else {
+		rec.Response = r.encode(resp)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if encErr := r.enc.Encode(rec); encErr != nil {
+		log.Printf("Failed to record %s: %v", info.FullMethod, encErr)
+	}
+	return resp, err
+}
+
+// Marshal a message to JSON with personal data replaced
+func (r *Recorder) encode(m interface{}) json.RawMessage {
+	msg, ok := m.(proto.Message)
+	if !ok {
+		return nil
+	}
+	b, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(msg)
+	if err != nil {
+		return nil
+	}
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil
+	}
+	b, err = json.Marshal(r.scrub("", v))
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+// Replace personal data found under the given field name, recursively
+func (r *Recorder) scrub(field string, v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			v[k] = r.scrub(k, child)
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = r.scrub(field, child)
+		}
+	case string:
+		return r.anon.Field(field, v)
+	}
+	return v
+}
+
+// ReadFile loads the records of a recording file.
+func ReadFile(path string) ([]Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var rec Record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		records = append(records, rec)
+	}
+	return records, scanner.Err()
+}
@@ -0,0 +1,65 @@
+package recording
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"session-service/internal/anonymize"
+)
+
+func TestScrubReplacesPersonalData(t *testing.T) {
+	anon := anonymize.New("key")
+	r := &Recorder{anon: anon}
+
+	var v interface{}
+	json.Unmarshal([]byte(`{"session_id":"7","user_id":"u1","reservations":[{"user_id":"u2","user_name":"Jane Doe"}]}`), &v)
+	got := r.scrub("", v).(map[string]interface{})
+
+	if got["session_id"] != "7" {
+		t.Errorf("session_id should be kept, got %v", got["session_id"])
+	}
+	if got["user_id"] != anon.ID("u1") {
+		t.Errorf("user_id not replaced: %v", got["user_id"])
+	}
+	nested := got["reservations"].([]interface{})[0].(map[string]interface{})
+	if nested["user_id"] != anon.ID("u2") || nested["user_name"] != anon.Name("Jane Doe") {
+		t.Errorf("Nested personal data not replaced: %v", nested)
+	}
+}
+
+func TestReplayerRewritesLearnedIDs(t *testing.T) {
+	p := NewReplayer(nil)
+	p.learnIDs("Session", json.RawMessage(`{"id":"41","title":"Yoga"}`), json.RawMessage(`{"id":"3","title":"Yoga"}`))
+
+	var req map[string]string
+	json.Unmarshal(p.rewriteIDs(json.RawMessage(`{"session_id":"41","user_id":"anon-1"}`)), &req)
+	if req["session_id"] != "3" || req["user_id"] != "anon-1" {
+		t.Errorf("Unexpected rewritten request %v", req)
+	}
+
+	json.Unmarshal(p.rewriteIDs(json.RawMessage(`{"session_id":"99"}`)), &req)
+	if req["session_id"] != "99" {
+		t.Errorf("Unknown IDs should be left alone, got %v", req["session_id"])
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rpc.jsonl")
+	content := `{"method":"/session.SessionService/GetSession","request":{"session_id":"1"},"code":"NotFound"}
+
+{"method":"/session.SessionService/CreateSession","request":{},"code":"InvalidArgument","message":"Missing required fields"}
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if len(records) != 2 || records[1].Message != "Missing required fields" {
+		t.Errorf("Unexpected records %+v", records)
+	}
+}
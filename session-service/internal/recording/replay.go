@@ -0,0 +1,150 @@
+package recording
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Result is the outcome of replaying one record.
+type Result struct {
+	Record   Record
+	Code     codes.Code
+	Message  string
+	Response json.RawMessage
+}
+
+// Matches reports whether the replayed call ended with the recorded code.
+func (r Result) Matches() bool {
+	return r.Code.String() == r.Record.Code
+}
+
+// Replayer sends recorded calls to a server. Message types are looked up in
+// the protobuf registry, so the generated code of the recorded services must
+// be linked into the binary.
+//
+// IDs created during the replay differ from the recorded ones, so the
+// Replayer learns the mapping from responses (the "id" of a Session answers
+// the "session_id" of later requests) and rewrites requests accordingly.
+type Replayer struct {
+	conn grpc.ClientConnInterface
+	ids  map[string]map[string]string
+}
+
+// NewReplayer returns a Replayer sending calls over conn.
+func NewReplayer(conn grpc.ClientConnInterface) *Replayer {
+	return &Replayer{conn: conn, ids: make(map[string]map[string]string)}
+}
+
+// Replay re-sends one recorded call. The error is only set when the record
+// cannot be replayed at all; RPC failures are reported in the Result.
+func (p *Replayer) Replay(ctx context.Context, rec Record) (Result, error) {
+	method, err := lookupMethod(rec.Method)
+	if err != nil {
+		return Result{}, err
+	}
+
+	req := dynamicpb.NewMessage(method.Input())
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(p.rewriteIDs(rec.Request), req); err != nil {
+		return Result{}, fmt.Errorf("decoding %s request: %w", rec.Method, err)
+	}
+	resp := dynamicpb.NewMessage(method.Output())
+
+	err = p.conn.Invoke(ctx, rec.Method, req, resp)
+	result := Result{Record: rec, Code: status.Code(err)}
+	if err != nil {
+		result.Message = status.Convert(err).Message()
+		return result, nil
+	}
+
+	result.Response, err = protojson.MarshalOptions{UseProtoNames: true}.Marshal(resp)
+	if err != nil {
+		return result, fmt.Errorf("encoding %s response: %w", rec.Method, err)
+	}
+	p.learnIDs(string(method.Output().Name()), rec.Response, result.Response)
+	return result, nil
+}
+
+// Find the descriptor of a method named like "/session.SessionService/GetSession"
+func lookupMethod(fullMethod string) (protoreflect.MethodDescriptor, error) {
+	parts := strings.Split(strings.TrimPrefix(fullMethod, "/"), "/")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("malformed method name %q", fullMethod)
+	}
+	d, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(parts[0]))
+	if err != nil {
+		return nil, fmt.Errorf("unknown service %q: %w", parts[0], err)
+	}
+	service, ok := d.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%q is not a service", parts[0])
+	}
+	method := service.Methods().ByName(protoreflect.Name(parts[1]))
+	if method == nil {
+		return nil, fmt.Errorf("unknown method %q", fullMethod)
+	}
+	return method, nil
+}
+
+// Remember which ID the replay got for a recorded ID
+func (p *Replayer) learnIDs(messageName string, recorded, replayed json.RawMessage) {
+	var before, after struct {
+		ID string `json:"id"`
+	}
+	if json.Unmarshal(recorded, &before) != nil || json.Unmarshal(replayed, &after) != nil {
+		return
+	}
+	if before.ID == "" || after.ID == "" || before.ID == after.ID {
+		return
+	}
+
+	field := snakeCase(messageName) + "_id"
+	if p.ids[field] == nil {
+		p.ids[field] = make(map[string]string)
+	}
+	p.ids[field][before.ID] = after.ID
+}
+
+// Replace recorded IDs by the ones created during the replay
+func (p *Replayer) rewriteIDs(request json.RawMessage) []byte {
+	var fields map[string]interface{}
+	if len(p.ids) == 0 || json.Unmarshal(request, &fields) != nil {
+		return request
+	}
+	for field, mapping := range p.ids {
+		if recorded, ok := fields[field].(string); ok {
+			if replayed, ok := mapping[recorded]; ok {
+				fields[field] = replayed
+			}
+		}
+	}
+	b, err := json.Marshal(fields)
+	if err != nil {
+		return request
+	}
+	return b
+}
+
+func snakeCase(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
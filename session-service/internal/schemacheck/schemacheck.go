@@ -0,0 +1,142 @@
+// Package schemacheck compares database schemas, to catch tables that were
+// changed by hand instead of through the service's schema setup.
+package schemacheck
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Schema describes the tables of a Postgres schema: one entry per table,
+// column, constraint and index, keyed by kind and name.
+type Schema map[string]string
+
+// Querier is satisfied by *sql.DB, *sql.Conn and *sql.Tx.
+type Querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// Snapshot reads the definition of every table in the named schema. Names
+// qualified with the schema name are written unqualified, so that snapshots
+// of two schemas holding the same tables compare equal.
+func Snapshot(ctx context.Context, q Querier, schema string) (Schema, error) {
+	s := make(Schema)
+	unqualify := strings.NewReplacer(schema+".", "").Replace
+
+	err := scan(ctx, q, `
+		SELECT table_name, column_name, data_type, COALESCE(character_maximum_length, 0),
+			is_nullable, COALESCE(column_default, '')
+		FROM information_schema.columns WHERE table_schema = $1`,
+		schema, func(rows *sql.Rows) error {
+			var table, column, dataType, nullable, def string
+			var length int
+			if err := rows.Scan(&table, &column, &dataType, &length, &nullable, &def); err != nil {
+				return err
+			}
+			desc := dataType
+			if length > 0 {
+				desc += fmt.Sprintf("(%d)", length)
+			}
+			if nullable == "NO" {
+				desc += " NOT NULL"
+			}
+			if def != "" {
+				desc += " DEFAULT " + unqualify(def)
+			}
+			s["table "+table] = ""
+			s["column "+table+"."+column] = desc
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	// NOT NULL shows up as CHECK constraints named after table OIDs; columns
+	// already cover it. The copies of a partitioned table's constraints on
+	// its partitions are left out, as they follow from the parent's.
+	err = scan(ctx, q, `
+		SELECT c.conrelid::regclass::text, c.conname, pg_get_constraintdef(c.oid)
+		FROM pg_constraint c JOIN pg_namespace n ON n.oid = c.connamespace
+		WHERE n.nspname = $1 AND c.conrelid <> 0 AND c.conparentid = 0`,
+		schema, func(rows *sql.Rows) error {
+			var table, name, def string
+			if err := rows.Scan(&table, &name, &def); err != nil {
+				return err
+			}
+			s["constraint "+unqualify(table)+"."+name] = unqualify(def)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	err = scan(ctx, q, `SELECT tablename, indexname, indexdef FROM pg_indexes WHERE schemaname = $1`,
+		schema, func(rows *sql.Rows) error {
+			var table, name, def string
+			if err := rows.Scan(&table, &name, &def); err != nil {
+				return err
+			}
+			s["index "+table+"."+name] = unqualify(def)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Run query with a single argument and call fn for every row
+func scan(ctx context.Context, q Querier, query string, arg interface{}, fn func(*sql.Rows) error) error {
+	rows, err := q.QueryContext(ctx, query, arg)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := fn(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Diff lists the differences between the expected and the actual schema,
+// one line per table, column, constraint or index, in name order. It
+// returns nil when the schemas match.
+func Diff(expected, actual Schema) []string {
+	keys := make(map[string]bool)
+	for k := range expected {
+		keys[k] = true
+	}
+	for k := range actual {
+		keys[k] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	var diff []string
+	for _, k := range sorted {
+		want, inExpected := expected[k]
+		got, inActual := actual[k]
+		switch {
+		case !inActual:
+			diff = append(diff, diffLine("-", k, want))
+		case !inExpected:
+			diff = append(diff, diffLine("+", k, got))
+		case want != got:
+			diff = append(diff, diffLine("-", k, want), diffLine("+", k, got))
+		}
+	}
+	return diff
+}
+
+// Format a line of Diff output
+func diffLine(sign, key, desc string) string {
+	return strings.TrimSpace(sign + " " + key + " " + desc)
+}
@@ -0,0 +1,37 @@
+package schemacheck
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	expected := Schema{
+		"table sessions":                 "",
+		"column sessions.title":          "character varying(255) NOT NULL",
+		"column sessions.reserved_spots": "integer DEFAULT 0",
+		"index sessions.sessions_pkey":   "CREATE UNIQUE INDEX sessions_pkey ON sessions USING btree (id)",
+	}
+
+	if diff := Diff(expected, expected); diff != nil {
+		t.Errorf("Expected no diff, got %q", diff)
+	}
+
+	actual := Schema{
+		"table sessions":               "",
+		"column sessions.title":        "character varying(300) NOT NULL",
+		"column sessions.notes":        "text",
+		"index sessions.sessions_pkey": "CREATE UNIQUE INDEX sessions_pkey ON sessions USING btree (id)",
+		"table tmp_fix":                "",
+	}
+	want := []string{
+		"+ column sessions.notes text",
+		"- column sessions.reserved_spots integer DEFAULT 0",
+		"- column sessions.title character varying(255) NOT NULL",
+		"+ column sessions.title character varying(300) NOT NULL",
+		"+ table tmp_fix",
+	}
+	if diff := Diff(expected, actual); !reflect.DeepEqual(diff, want) {
+		t.Errorf("Diff returned\n%q\nwant\n%q", diff, want)
+	}
+}
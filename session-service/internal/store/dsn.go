@@ -0,0 +1,41 @@
+package store
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// WithSessionParams adds Postgres session parameters, such as
+// statement_timeout, to a lib/pq connection string in URL or key=value form.
+// The server applies them to every connection opened with the result.
+// Parameters already in dsn are left as they are.
+func WithSessionParams(dsn string, params map[string]string) (string, error) {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			return "", err
+		}
+		q := u.Query()
+		for _, name := range names {
+			if !q.Has(name) {
+				q.Set(name, params[name])
+			}
+		}
+		u.RawQuery = q.Encode()
+		return u.String(), nil
+	}
+
+	for _, name := range names {
+		if !strings.Contains(" "+dsn, " "+name+"=") {
+			dsn += " " + name + "=" + params[name]
+		}
+	}
+	return strings.TrimSpace(dsn), nil
+}
@@ -0,0 +1,43 @@
+package store
+
+import (
+	"context"
+	"fmt"
+)
+
+// DefaultGym is the gym of sessions created without one, and of the
+// sessions that existed before the tables were partitioned by gym.
+const DefaultGym = "default"
+
+type gymKey struct{}
+
+// WithGym scopes the store calls made with the returned context to one gym:
+// sessions and reservations of other gyms are not found, and Postgres only
+// reads the partition holding the gym. Without a gym, calls look at every
+// gym, which admin tools and background jobs rely on.
+func WithGym(ctx context.Context, gymID string) context.Context {
+	return context.WithValue(ctx, gymKey{}, gymID)
+}
+
+// GymFromContext returns the gym set with WithGym, or "" if there is none.
+func GymFromContext(ctx context.Context) string {
+	gymID, _ := ctx.Value(gymKey{}).(string)
+	return gymID
+}
+
+// Whether a record of gymID can be seen with ctx
+func inGym(ctx context.Context, gymID string) bool {
+	scope := GymFromContext(ctx)
+	return scope == "" || scope == gymID
+}
+
+// Condition restricting a query to the gym of ctx, if any. The gym is
+// appended to args and the condition refers to it by position.
+func gymCondition(ctx context.Context, column string, args []interface{}) (string, []interface{}) {
+	gymID := GymFromContext(ctx)
+	if gymID == "" {
+		return "", args
+	}
+	args = append(args, gymID)
+	return fmt.Sprintf(" AND %s = $%d", column, len(args)), args
+}
@@ -0,0 +1,313 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"session-service/internal/clock"
+)
+
+// Memory is an in-memory Repository with the same semantics as Postgres.
+// It is meant for unit tests and local development.
+type Memory struct {
+	mu                sync.Mutex
+	clock             clock.Clock
+	nextID            int64
+	sessions          map[int64]*Session
+	nextSeriesID      int64
+	nextReservationID int64
+	reservations      map[int64]*Reservation
+	// Reservation of each member for each session, like the unique index
+	bySessionUser  map[sessionUser]*Reservation
+	nextWaitlistID int64
+	waitlist       map[sessionUser]*WaitlistEntry
+}
+
+// Key of Memory.bySessionUser
+type sessionUser struct {
+	sessionID int64
+	userID    string
+}
+
+// NewMemory returns an empty in-memory Repository.
+func NewMemory() *Memory {
+	return NewMemoryWithClock(clock.Real{})
+}
+
+// NewMemoryWithClock returns an empty in-memory Repository that timestamps
+// records with c.
+func NewMemoryWithClock(c clock.Clock) *Memory {
+	return &Memory{
+		clock:         c,
+		sessions:      make(map[int64]*Session),
+		reservations:  make(map[int64]*Reservation),
+		bySessionUser: make(map[sessionUser]*Reservation),
+		waitlist:      make(map[sessionUser]*WaitlistEntry),
+	}
+}
+
+// CreateSession stores a copy of s
+func (m *Memory) CreateSession(ctx context.Context, s *Session, checkConflicts bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if s.GymID == "" {
+		s.GymID = GymFromContext(ctx)
+	}
+	if s.GymID == "" {
+		s.GymID = DefaultGym
+	}
+	if checkConflicts {
+		if err := m.conflict(s); err != nil {
+			return err
+		}
+	}
+	m.insert(s)
+	return nil
+}
+
+// Store a copy of s and fill in its ID and timestamps; the caller holds m.mu
+func (m *Memory) insert(s *Session) {
+	m.nextID++
+	now := m.clock.Now().UTC()
+	s.ID = m.nextID
+	s.CreatedAt = now
+	s.UpdatedAt = now
+
+	stored := *s
+	stored.StartTime = s.StartTime.UTC()
+	stored.EndTime = s.EndTime.UTC()
+	m.sessions[s.ID] = &stored
+}
+
+// GetSession returns a copy of the stored session
+func (m *Memory) GetSession(ctx context.Context, id int64) (*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.session(ctx, id)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	found := *s
+	return &found, nil
+}
+
+// ListSessions returns copies of the stored sessions matching f in start
+// time order
+func (m *Memory) ListSessions(ctx context.Context, f SessionFilter, after *SessionCursor, descending bool, limit int) ([]*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Whether a comes before b in the requested order
+	before := func(a, b SessionCursor) bool {
+		if descending {
+			a, b = b, a
+		}
+		if !a.StartTime.Equal(b.StartTime) {
+			return a.StartTime.Before(b.StartTime)
+		}
+		return a.ID < b.ID
+	}
+
+	var sessions []*Session
+	for _, s := range m.sessions {
+		if !inGym(ctx, s.GymID) || !f.matches(s) || (after != nil && !before(*after, s.Cursor())) {
+			continue
+		}
+		found := *s
+		sessions = append(sessions, &found)
+	}
+	sort.Slice(sessions, func(i, j int) bool { return before(sessions[i].Cursor(), sessions[j].Cursor()) })
+	if len(sessions) > limit {
+		sessions = sessions[:limit]
+	}
+	return sessions, nil
+}
+
+// ListSessionsAfter returns copies of the stored sessions matching f
+func (m *Memory) ListSessionsAfter(ctx context.Context, f SessionFilter, afterID int64, limit int) ([]*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var sessions []*Session
+	for id, s := range m.sessions {
+		if id <= afterID || !inGym(ctx, s.GymID) || !f.matches(s) {
+			continue
+		}
+		found := *s
+		sessions = append(sessions, &found)
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].ID < sessions[j].ID })
+	if len(sessions) > limit {
+		sessions = sessions[:limit]
+	}
+	return sessions, nil
+}
+
+// Whether f selects s
+func (f SessionFilter) matches(s *Session) bool {
+	switch {
+	case !f.StartsFrom.IsZero() && s.StartTime.Before(f.StartsFrom),
+		!f.StartsBefore.IsZero() && !s.StartTime.Before(f.StartsBefore),
+		f.SessionType != "" && s.SessionType != f.SessionType,
+		f.DifficultyLevel != "" && s.DifficultyLevel != f.DifficultyLevel,
+		f.CoachID != "" && s.CoachID != f.CoachID,
+		f.Location != "" && s.Location != f.Location,
+		f.SeriesID != 0 && s.SeriesID != f.SeriesID,
+		f.ExcludeCancelled && s.IsCancelled:
+		return false
+	}
+	return true
+}
+
+// UpdateSession replaces the fields of the stored session and books
+// waitlisted members into the spots added
+func (m *Memory) UpdateSession(ctx context.Context, s *Session, checkConflicts bool) (*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.session(ctx, s.ID)
+	switch {
+	case !ok:
+		return nil, ErrNotFound
+	case stored.IsCancelled:
+		return nil, ErrAlreadyCancelled
+	case stored.IsCompleted:
+		return nil, ErrSessionCompleted
+	case s.Capacity < stored.ReservedSpots:
+		return nil, ErrCapacityBelowReserved
+	}
+	updated := withUpdate(*stored, s)
+	updated.UpdatedAt = m.clock.Now().UTC()
+	if checkConflicts {
+		if err := m.conflict(&updated); err != nil {
+			return nil, err
+		}
+	}
+	added := updated.Capacity > stored.Capacity
+	*stored = updated
+
+	if added {
+		m.promoteWaitlisted(ctx, stored.ID)
+	}
+	found := *stored
+	return &found, nil
+}
+
+// The stored session with the fields UpdateSession replaces taken from s
+func withUpdate(stored Session, s *Session) Session {
+	stored.Title = s.Title
+	stored.Description = s.Description
+	stored.CoachID = s.CoachID
+	stored.CoachName = s.CoachName
+	stored.Capacity = s.Capacity
+	stored.StartTime = s.StartTime.UTC()
+	stored.EndTime = s.EndTime.UTC()
+	stored.Location = s.Location
+	stored.SessionType = s.SessionType
+	stored.DifficultyLevel = s.DifficultyLevel
+	return stored
+}
+
+// The *ConflictError of the first session to start that s overlaps, or nil;
+// the caller holds m.mu
+func (m *Memory) conflict(s *Session) error {
+	var first *Session
+	for _, other := range m.sessions {
+		if other.ID == s.ID || other.GymID != s.GymID || other.IsCancelled ||
+			(other.CoachID != s.CoachID && other.Location != s.Location) || !overlaps(other, s) {
+			continue
+		}
+		if first == nil || other.StartTime.Before(first.StartTime) ||
+			(other.StartTime.Equal(first.StartTime) && other.ID < first.ID) {
+			first = other
+		}
+	}
+	if first == nil {
+		return nil
+	}
+	found := *first
+	return &ConflictError{Session: &found}
+}
+
+// CancelSession flags the stored session as cancelled
+func (m *Memory) CancelSession(ctx context.Context, id int64, reason string) (*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.session(ctx, id)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	switch {
+	case s.IsCancelled:
+		return nil, ErrAlreadyCancelled
+	case s.IsCompleted:
+		return nil, ErrSessionCompleted
+	}
+	s.IsCancelled = true
+	s.CancellationReason = reason
+	s.UpdatedAt = m.clock.Now().UTC()
+
+	cancelled := *s
+	return &cancelled, nil
+}
+
+// DeleteSession removes the stored session
+func (m *Memory) DeleteSession(ctx context.Context, id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.session(ctx, id); !ok {
+		return ErrNotFound
+	}
+	delete(m.sessions, id)
+	for rid, r := range m.reservations {
+		if r.SessionID == id {
+			delete(m.reservations, rid)
+			delete(m.bySessionUser, sessionUser{r.SessionID, r.UserID})
+		}
+	}
+	return nil
+}
+
+// CompleteSessions completes the stored sessions that ended and marks their
+// reservations still confirmed, not checked in, no-shows
+func (m *Memory) CompleteSessions(ctx context.Context, endedBy time.Time) ([]int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.clock.Now().UTC()
+	var completed []int64
+	for id, s := range m.sessions {
+		if !inGym(ctx, s.GymID) || s.IsCancelled || s.IsCompleted || s.EndTime.After(endedBy) {
+			continue
+		}
+		s.IsCompleted = true
+		s.UpdatedAt = now
+		completed = append(completed, id)
+	}
+	// Completed sessions only have confirmed reservations if they were just
+	// completed
+	for _, r := range m.reservations {
+		if r.Status == ReservationConfirmed && m.sessions[r.SessionID].IsCompleted {
+			r.Status = ReservationNoShow
+			r.UpdatedAt = now
+		}
+	}
+	sort.Slice(completed, func(i, j int) bool { return completed[i] < completed[j] })
+	return completed, nil
+}
+
+// Stored session with the given ID, if ctx can see its gym; the caller holds
+// m.mu
+func (m *Memory) session(ctx context.Context, id int64) (*Session, bool) {
+	s, ok := m.sessions[id]
+	if !ok || !inGym(ctx, s.GymID) {
+		return nil, false
+	}
+	return s, true
+}
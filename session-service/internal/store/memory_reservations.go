@@ -0,0 +1,227 @@
+package store
+
+import (
+	"context"
+	"sort"
+)
+
+// CreateReservation takes a spot of the stored session and records the booking
+func (m *Memory) CreateReservation(ctx context.Context, r *Reservation) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.reserve(ctx, r)
+}
+
+// CreateReservations books the stored sessions one reservation at a time,
+// remembering how to undo each booking in case the batch is aborted
+func (m *Memory) CreateReservations(ctx context.Context, rs []*Reservation, allOrNothing bool) ([]error, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var undo []func()
+	errs := make([]error, len(rs))
+	failed := false
+	for i, r := range rs {
+		undo = append(undo, m.snapshot(r))
+		if errs[i] = m.reserve(ctx, r); errs[i] != nil {
+			failed = true
+		}
+	}
+
+	if failed && allOrNothing {
+		for i := len(undo) - 1; i >= 0; i-- {
+			undo[i]()
+		}
+		for i, err := range errs {
+			if err == nil {
+				errs[i] = ErrBatchAborted
+			}
+		}
+	}
+	return errs, nil
+}
+
+// Return a function restoring what booking r may change
+func (m *Memory) snapshot(r *Reservation) func() {
+	key := sessionUser{r.SessionID, r.UserID}
+	var session *Session
+	if s, ok := m.sessions[r.SessionID]; ok {
+		saved := *s
+		session = &saved
+	}
+	existing, hadReservation := m.bySessionUser[key]
+	var saved Reservation
+	if hadReservation {
+		saved = *existing
+	}
+
+	return func() {
+		if session != nil {
+			*m.sessions[r.SessionID] = *session
+		}
+		if hadReservation {
+			*existing = saved
+			return
+		}
+		if created, ok := m.bySessionUser[key]; ok {
+			delete(m.reservations, created.ID)
+			delete(m.bySessionUser, key)
+		}
+	}
+}
+
+// Book r; the caller holds m.mu
+func (m *Memory) reserve(ctx context.Context, r *Reservation) error {
+	s, ok := m.session(ctx, r.SessionID)
+	if !ok {
+		return ErrNotFound
+	}
+	existing := m.bySessionUser[sessionUser{r.SessionID, r.UserID}]
+	switch {
+	case s.IsCancelled:
+		return ErrAlreadyCancelled
+	case s.IsCompleted:
+		return ErrSessionCompleted
+	case existing != nil && existing.Status == ReservationConfirmed:
+		return ErrAlreadyBooked
+	case s.ReservedSpots >= s.Capacity:
+		return ErrSessionFull
+	}
+
+	now := m.clock.Now().UTC()
+	s.ReservedSpots++
+	s.UpdatedAt = now
+
+	// Like Postgres, a cancelled reservation of the same user is booked again
+	if existing == nil {
+		m.nextReservationID++
+		existing = &Reservation{ID: m.nextReservationID, GymID: s.GymID, SessionID: r.SessionID, UserID: r.UserID, CreatedAt: now}
+		m.reservations[existing.ID] = existing
+		m.bySessionUser[sessionUser{r.SessionID, r.UserID}] = existing
+	}
+	existing.UserName = r.UserName
+	existing.Status = ReservationConfirmed
+	existing.ReservationTime = now
+	existing.UpdatedAt = now
+
+	*r = *existing
+	return nil
+}
+
+// GetReservation returns a copy of the stored reservation
+func (m *Memory) GetReservation(ctx context.Context, id int64) (*Reservation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r, ok := m.reservations[id]
+	if !ok || !inGym(ctx, r.GymID) {
+		return nil, ErrNotFound
+	}
+	found := *r
+	return &found, nil
+}
+
+// ListReservations returns copies of the stored reservations matching f
+func (m *Memory) ListReservations(ctx context.Context, f ReservationFilter, afterID int64, limit int) ([]*Reservation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var reservations []*Reservation
+	for id, r := range m.reservations {
+		switch {
+		case id <= afterID, !inGym(ctx, r.GymID),
+			f.SessionID != 0 && r.SessionID != f.SessionID,
+			f.UserID != "" && r.UserID != f.UserID,
+			f.Status != "" && r.Status != f.Status:
+			continue
+		}
+		if !f.SessionsEndingAfter.IsZero() {
+			if s, ok := m.sessions[r.SessionID]; !ok || !s.EndTime.After(f.SessionsEndingAfter) {
+				continue
+			}
+		}
+		found := *r
+		reservations = append(reservations, &found)
+	}
+	sort.Slice(reservations, func(i, j int) bool { return reservations[i].ID < reservations[j].ID })
+	if len(reservations) > limit {
+		reservations = reservations[:limit]
+	}
+	return reservations, nil
+}
+
+// CancelReservation flags the stored reservation as cancelled and frees its
+// spot for the first member waiting, if any
+func (m *Memory) CancelReservation(ctx context.Context, id int64) (*Reservation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r, ok := m.reservations[id]
+	if !ok || !inGym(ctx, r.GymID) {
+		return nil, ErrNotFound
+	}
+	switch r.Status {
+	case ReservationAttended:
+		return nil, ErrCheckedIn
+	case ReservationNoShow:
+		return nil, ErrSessionCompleted
+	case ReservationCancelled:
+		return nil, ErrAlreadyCancelled
+	}
+	now := m.clock.Now().UTC()
+	r.Status = ReservationCancelled
+	r.UpdatedAt = now
+	if s, ok := m.sessions[r.SessionID]; ok && s.ReservedSpots > 0 {
+		s.ReservedSpots--
+		s.UpdatedAt = now
+	}
+	m.promoteWaitlisted(ctx, r.SessionID)
+
+	cancelled := *r
+	return &cancelled, nil
+}
+
+// CheckIn marks the stored reservation attended
+func (m *Memory) CheckIn(ctx context.Context, id int64) (*Reservation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r, ok := m.reservations[id]
+	if !ok || !inGym(ctx, r.GymID) {
+		return nil, ErrNotFound
+	}
+	if s, ok := m.sessions[r.SessionID]; r.Status == ReservationCancelled || ok && s.IsCancelled {
+		return nil, ErrAlreadyCancelled
+	}
+	r.Status = ReservationAttended
+	r.UpdatedAt = m.clock.Now().UTC()
+
+	checkedIn := *r
+	return &checkedIn, nil
+}
+
+// ReconcileReservedSpots recounts the reservations of every session that are
+// not cancelled
+func (m *Memory) ReconcileReservedSpots(ctx context.Context) ([]SpotDrift, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	booked := make(map[int64]int32)
+	for _, r := range m.reservations {
+		if r.Status != ReservationCancelled {
+			booked[r.SessionID]++
+		}
+	}
+
+	var repaired []SpotDrift
+	for id, s := range m.sessions {
+		if inGym(ctx, s.GymID) && s.ReservedSpots != booked[id] {
+			repaired = append(repaired, SpotDrift{SessionID: id, Recorded: s.ReservedSpots, Actual: booked[id]})
+			s.ReservedSpots = booked[id]
+			s.UpdatedAt = m.clock.Now().UTC()
+		}
+	}
+	sort.Slice(repaired, func(i, j int) bool { return repaired[i].SessionID < repaired[j].SessionID })
+	return repaired, nil
+}
@@ -0,0 +1,161 @@
+package store
+
+import "context"
+
+// CreateSessionSeries stores copies of the occurrences, or none of them if
+// one conflicts with the sessions stored or the occurrences before it
+func (m *Memory) CreateSessionSeries(ctx context.Context, ss []*Session, checkConflicts bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.nextSeriesID++
+	for i, s := range ss {
+		if s.GymID == "" {
+			s.GymID = GymFromContext(ctx)
+		}
+		if s.GymID == "" {
+			s.GymID = DefaultGym
+		}
+		if checkConflicts {
+			if err := m.conflict(s); err != nil {
+				for _, inserted := range ss[:i] {
+					delete(m.sessions, inserted.ID)
+					inserted.ID, inserted.SeriesID = 0, 0
+				}
+				return err
+			}
+		}
+		s.SeriesID = m.nextSeriesID
+		m.insert(s)
+	}
+	return nil
+}
+
+// FindConflicts returns copies of the stored sessions one of ss overlaps
+func (m *Memory) FindConflicts(ctx context.Context, ss []*Session) ([]*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var found []*Session
+	for _, other := range m.sessions {
+		for _, s := range ss {
+			gymID := s.GymID
+			if gymID == "" {
+				gymID = GymFromContext(ctx)
+			}
+			if gymID == "" {
+				gymID = DefaultGym
+			}
+			if other.GymID == gymID && !other.IsCancelled &&
+				(other.CoachID == s.CoachID || other.Location == s.Location) && overlaps(other, s) {
+				copied := *other
+				found = append(found, &copied)
+				break
+			}
+		}
+	}
+	sortByStart(found)
+	return found, nil
+}
+
+// UpdateSessionSeries replaces the fields of the stored session and the rest
+// of its series, moved by as much as the session, and books waitlisted
+// members into the spots added
+func (m *Memory) UpdateSessionSeries(ctx context.Context, s *Session, checkConflicts bool) ([]*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, ok := m.session(ctx, s.ID)
+	switch {
+	case !ok:
+		return nil, ErrNotFound
+	case current.IsCancelled:
+		return nil, ErrAlreadyCancelled
+	case current.IsCompleted:
+		return nil, ErrSessionCompleted
+	}
+	occurrences := m.seriesFrom(current)
+
+	now := m.clock.Now().UTC()
+	shift, length := s.StartTime.Sub(current.StartTime), s.EndTime.Sub(s.StartTime)
+	previous := make([]Session, len(occurrences))
+	for i, o := range occurrences {
+		if s.Capacity < o.ReservedSpots {
+			return nil, ErrCapacityBelowReserved
+		}
+		previous[i] = *o
+	}
+	// Moved all at once, then checked, like in a transaction
+	for _, o := range occurrences {
+		next := withUpdate(*o, s)
+		next.StartTime = o.StartTime.Add(shift)
+		next.EndTime = next.StartTime.Add(length)
+		next.UpdatedAt = now
+		*o = next
+	}
+	if checkConflicts {
+		for _, o := range occurrences {
+			if err := m.conflict(o); err != nil {
+				for i, moved := range occurrences {
+					*moved = previous[i]
+				}
+				return nil, err
+			}
+		}
+	}
+
+	updated := make([]*Session, len(occurrences))
+	for i, o := range occurrences {
+		if o.Capacity > previous[i].Capacity {
+			m.promoteWaitlisted(ctx, o.ID)
+		}
+		found := *o
+		updated[i] = &found
+	}
+	return updated, nil
+}
+
+// CancelSessionSeries flags the stored session and the rest of its series as
+// cancelled
+func (m *Memory) CancelSessionSeries(ctx context.Context, id int64, reason string) ([]*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, ok := m.session(ctx, id)
+	switch {
+	case !ok:
+		return nil, ErrNotFound
+	case current.IsCancelled:
+		return nil, ErrAlreadyCancelled
+	case current.IsCompleted:
+		return nil, ErrSessionCompleted
+	}
+
+	now := m.clock.Now().UTC()
+	var cancelled []*Session
+	for _, s := range m.seriesFrom(current) {
+		s.IsCancelled = true
+		s.CancellationReason = reason
+		s.UpdatedAt = now
+		found := *s
+		cancelled = append(cancelled, &found)
+	}
+	return cancelled, nil
+}
+
+// The stored session s and the later sessions of its series that are neither
+// cancelled nor completed, in start time order; the caller holds m.mu
+func (m *Memory) seriesFrom(s *Session) []*Session {
+	series := []*Session{s}
+	if s.SeriesID == 0 {
+		return series
+	}
+	for _, other := range m.sessions {
+		if other.ID != s.ID && other.GymID == s.GymID && other.SeriesID == s.SeriesID &&
+			!other.StartTime.Before(s.StartTime) && !other.IsCancelled && !other.IsCompleted {
+			series = append(series, other)
+		}
+	}
+	sortByStart(series)
+	return series
+}
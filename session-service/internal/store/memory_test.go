@@ -0,0 +1,50 @@
+package store_test
+
+import (
+	"testing"
+
+	"pgregory.net/rapid"
+
+	"session-service/internal/store"
+	"session-service/internal/store/storetest"
+)
+
+func TestMemoryCapacity(t *testing.T) {
+	rapid.Check(t, storetest.Capacity(store.NewMemory()))
+}
+
+func TestMemoryGymScope(t *testing.T) {
+	storetest.GymScope(t, store.NewMemory())
+}
+
+func TestMemoryLifecycle(t *testing.T) {
+	storetest.Lifecycle(t, store.NewMemory())
+}
+
+func TestMemoryListAfter(t *testing.T) {
+	storetest.ListAfter(t, store.NewMemory())
+}
+
+func TestMemoryList(t *testing.T) {
+	storetest.List(t, store.NewMemory())
+}
+
+func TestMemoryListReservations(t *testing.T) {
+	storetest.ListReservations(t, store.NewMemory())
+}
+
+func TestMemoryWaitlist(t *testing.T) {
+	storetest.Waitlist(t, store.NewMemory())
+}
+
+func TestMemoryConflicts(t *testing.T) {
+	storetest.Conflicts(t, store.NewMemory())
+}
+
+func TestMemoryUpdate(t *testing.T) {
+	storetest.Update(t, store.NewMemory())
+}
+
+func TestMemorySeries(t *testing.T) {
+	storetest.Series(t, store.NewMemory())
+}
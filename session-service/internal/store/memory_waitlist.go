@@ -0,0 +1,86 @@
+package store
+
+import (
+	"context"
+	"sort"
+)
+
+// JoinWaitlist adds the member to the stored waitlist of a full session not
+// taking queued bookings
+func (m *Memory) JoinWaitlist(ctx context.Context, e *WaitlistEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.session(ctx, e.SessionID)
+	if !ok {
+		return ErrNotFound
+	}
+	key := sessionUser{e.SessionID, e.UserID}
+	booked := m.bySessionUser[key]
+	switch {
+	case s.IsCancelled:
+		return ErrAlreadyCancelled
+	case s.IsCompleted:
+		return ErrSessionCompleted
+	case s.QueuedBooking:
+		return ErrQueuedBooking
+	case booked != nil && booked.Status == ReservationConfirmed:
+		return ErrAlreadyBooked
+	case s.ReservedSpots < s.Capacity:
+		return ErrSessionNotFull
+	case m.waitlist[key] != nil:
+		return ErrAlreadyWaitlisted
+	}
+
+	m.nextWaitlistID++
+	entry := &WaitlistEntry{
+		ID:        m.nextWaitlistID,
+		GymID:     s.GymID,
+		SessionID: e.SessionID,
+		UserID:    e.UserID,
+		UserName:  e.UserName,
+		CreatedAt: m.clock.Now().UTC(),
+	}
+	m.waitlist[key] = entry
+	*e = *entry
+	e.Position = int32(len(m.waiting(e.SessionID)))
+	return nil
+}
+
+// LeaveWaitlist removes the member's stored waitlist entry
+func (m *Memory) LeaveWaitlist(ctx context.Context, sessionID int64, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := sessionUser{sessionID, userID}
+	if e, ok := m.waitlist[key]; !ok || !inGym(ctx, e.GymID) {
+		return ErrNotFound
+	}
+	delete(m.waitlist, key)
+	return nil
+}
+
+// Waitlist entries of a session in the order they joined; the caller holds
+// m.mu
+func (m *Memory) waiting(sessionID int64) []*WaitlistEntry {
+	var entries []*WaitlistEntry
+	for key, e := range m.waitlist {
+		if key.sessionID == sessionID {
+			entries = append(entries, e)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+	return entries
+}
+
+// Book the members waiting for the session into its free spots, like
+// Postgres; the caller holds m.mu
+func (m *Memory) promoteWaitlisted(ctx context.Context, sessionID int64) {
+	for _, e := range m.waiting(sessionID) {
+		err := m.reserve(ctx, &Reservation{SessionID: e.SessionID, UserID: e.UserID, UserName: e.UserName})
+		if err != nil && err != ErrAlreadyBooked {
+			return
+		}
+		delete(m.waitlist, sessionUser{e.SessionID, e.UserID})
+	}
+}
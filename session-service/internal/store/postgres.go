@@ -0,0 +1,460 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Columns read by every session query, in the order expected by scanSession
+const sessionColumns = `id, gym_id, title, description, coach_id, coach_name, capacity, reserved_spots,
+		start_time, end_time, location, session_type, difficulty_level, is_cancelled,
+		COALESCE(cancellation_reason, ''), is_completed, queued_booking, COALESCE(series_id, 0), created_at, updated_at`
+
+// Postgres is the Repository backed by the PostgreSQL database.
+type Postgres struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+// NewPostgres returns a Repository using db.
+func NewPostgres(db *sql.DB) *Postgres {
+	return &Postgres{db: db}
+}
+
+// NewPostgresWithTimeout returns a Repository using db whose calls give up
+// after timeout, even if the caller's context has no deadline.
+func NewPostgresWithTimeout(db *sql.DB, timeout time.Duration) *Postgres {
+	return &Postgres{db: db, timeout: timeout}
+}
+
+// Bound ctx by the per-call timeout, if any
+func (p *Postgres) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if p.timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, p.timeout)
+}
+
+// IsLockTimeout reports whether err is Postgres giving up waiting for a lock
+// after lock_timeout. Like a serialization failure, the call can be retried.
+func IsLockTimeout(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "55P03"
+}
+
+// IsTimeout reports whether err comes from a deadline: the caller's, the
+// per-call timeout or the server's statement_timeout.
+func IsTimeout(err error) bool {
+	var pqErr *pq.Error
+	return errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &pqErr) && pqErr.Code == "57014")
+}
+
+// IsSerializationFailure reports whether err is a serialization failure or
+// deadlock reported by Postgres, after which the operation can be retried.
+func IsSerializationFailure(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && (pqErr.Code == "40001" || pqErr.Code == "40P01")
+}
+
+// Scan a row selected with sessionColumns, from a *sql.Row or *sql.Rows
+func scanSession(row interface{ Scan(...interface{}) error }) (*Session, error) {
+	var s Session
+	err := row.Scan(
+		&s.ID, &s.GymID, &s.Title, &s.Description, &s.CoachID, &s.CoachName,
+		&s.Capacity, &s.ReservedSpots, &s.StartTime, &s.EndTime, &s.Location,
+		&s.SessionType, &s.DifficultyLevel, &s.IsCancelled, &s.CancellationReason,
+		&s.IsCompleted, &s.QueuedBooking, &s.SeriesID, &s.CreatedAt, &s.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// CreateSession inserts a new session row into the partition of its gym.
+// Checking conflicts, the insert runs in a transaction holding the schedule
+// locks of the coach and the location until it commits.
+func (p *Postgres) CreateSession(ctx context.Context, s *Session, checkConflicts bool) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	if s.GymID == "" {
+		s.GymID = GymFromContext(ctx)
+	}
+	if s.GymID == "" {
+		s.GymID = DefaultGym
+	}
+	if !checkConflicts {
+		return insertSession(ctx, p.db, s)
+	}
+	return p.inTx(ctx, func(tx *sql.Tx) error {
+		if err := lockSchedules(ctx, tx, s); err != nil {
+			return err
+		}
+		if err := findConflict(ctx, tx, s); err != nil {
+			return err
+		}
+		return insertSession(ctx, tx, s)
+	})
+}
+
+// Database or transaction running a query returning one row
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Insert s and fill in its ID and timestamps
+func insertSession(ctx context.Context, q queryRower, s *Session) error {
+	return q.QueryRowContext(
+		ctx,
+		`INSERT INTO sessions
+		(gym_id, title, description, coach_id, coach_name, capacity, reserved_spots, start_time, end_time,
+		location, session_type, difficulty_level, is_cancelled, cancellation_reason, is_completed, queued_booking, series_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, NULLIF($14, ''), $15, $16, NULLIF($17, 0))
+		RETURNING id, created_at, updated_at`,
+		s.GymID, s.Title, s.Description, s.CoachID, s.CoachName, s.Capacity, s.ReservedSpots, s.StartTime.UTC(), s.EndTime.UTC(),
+		s.Location, s.SessionType, s.DifficultyLevel, s.IsCancelled, s.CancellationReason, s.IsCompleted, s.QueuedBooking, s.SeriesID,
+	).Scan(&s.ID, &s.CreatedAt, &s.UpdatedAt)
+}
+
+// Lock the schedules of the coaches and the locations of ss in their gym
+// until tx ends, so that sessions checked for conflicts concurrently are
+// checked one after the other. The locks are taken in the order of their
+// keys, the same in every transaction.
+func lockSchedules(ctx context.Context, tx *sql.Tx, ss ...*Session) error {
+	var keys []int64
+	for _, s := range ss {
+		keys = append(keys, scheduleLock(s.GymID, "coach", s.CoachID), scheduleLock(s.GymID, "location", s.Location))
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	for i, key := range keys {
+		// A series shares its coach and location
+		if i > 0 && key == keys[i-1] {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Advisory lock key of a schedule
+func scheduleLock(parts ...string) int64 {
+	h := fnv.New64a()
+	for _, part := range parts {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return int64(h.Sum64())
+}
+
+// Return a *ConflictError for the first session to start that s overlaps,
+// if any. The columns hold UTC times without a zone: the bounds are compared
+// as timestamptz converted to UTC, whatever the TimeZone of the connection,
+// which keeps the (gym_id, start_time, id) index usable.
+func findConflict(ctx context.Context, tx *sql.Tx, s *Session) error {
+	other, err := scanSession(tx.QueryRowContext(
+		ctx,
+		`SELECT `+sessionColumns+` FROM sessions
+		WHERE gym_id = $1 AND id <> $2 AND NOT is_cancelled AND (coach_id = $3 OR location = $4)
+		AND start_time < ($6::timestamptz AT TIME ZONE 'UTC') AND end_time > ($5::timestamptz AT TIME ZONE 'UTC')
+		ORDER BY start_time, id LIMIT 1`,
+		s.GymID, s.ID, s.CoachID, s.Location, s.StartTime, s.EndTime,
+	))
+	if err == ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return &ConflictError{Session: other}
+}
+
+// GetSession loads a session by ID
+func (p *Postgres) GetSession(ctx context.Context, id int64) (*Session, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	gym, args := gymCondition(ctx, "gym_id", []interface{}{id})
+	return scanSession(p.db.QueryRowContext(
+		ctx,
+		`SELECT `+sessionColumns+` FROM sessions WHERE id = $1`+gym,
+		args...,
+	))
+}
+
+// ListSessions reads a page of sessions in start time order. The row
+// comparison on (start_time, id) lets the (gym_id, start_time, id) index
+// find where the page starts.
+func (p *Postgres) ListSessions(ctx context.Context, f SessionFilter, after *SessionCursor, descending bool, limit int) ([]*Session, error) {
+	conditions, args := sessionConditions(f, nil)
+	direction, comparison := "", ">"
+	if descending {
+		direction, comparison = " DESC", "<"
+	}
+	if after != nil {
+		args = append(args, after.StartTime.UTC(), after.ID)
+		conditions += fmt.Sprintf(" AND (start_time, id) %s ($%d, $%d)", comparison, len(args)-1, len(args))
+	}
+	return p.listSessions(ctx, conditions, args, "start_time"+direction+", id"+direction, limit)
+}
+
+// ListSessionsAfter reads a page of sessions by ID; the primary key index
+// finds where the page starts
+func (p *Postgres) ListSessionsAfter(ctx context.Context, f SessionFilter, afterID int64, limit int) ([]*Session, error) {
+	conditions, args := sessionConditions(f, []interface{}{afterID})
+	return p.listSessions(ctx, " AND id > $1"+conditions, args, "id", limit)
+}
+
+// Conditions selecting the sessions matching f, each starting with AND. The
+// values are appended to args and referred to by position.
+func sessionConditions(f SessionFilter, args []interface{}) (string, []interface{}) {
+	var conditions string
+	where := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions += fmt.Sprintf(condition, len(args))
+	}
+	if !f.StartsFrom.IsZero() {
+		where(" AND start_time >= $%d", f.StartsFrom.UTC())
+	}
+	if !f.StartsBefore.IsZero() {
+		where(" AND start_time < $%d", f.StartsBefore.UTC())
+	}
+	if f.SessionType != "" {
+		where(" AND session_type = $%d", f.SessionType)
+	}
+	if f.DifficultyLevel != "" {
+		where(" AND difficulty_level = $%d", f.DifficultyLevel)
+	}
+	if f.CoachID != "" {
+		where(" AND coach_id = $%d", f.CoachID)
+	}
+	if f.Location != "" {
+		where(" AND location = $%d", f.Location)
+	}
+	if f.SeriesID != 0 {
+		where(" AND series_id = $%d", f.SeriesID)
+	}
+	if f.ExcludeCancelled {
+		conditions += " AND NOT is_cancelled"
+	}
+	return conditions, args
+}
+
+// Read up to limit sessions matching conditions, in the gym of ctx, sorted
+// by order
+func (p *Postgres) listSessions(ctx context.Context, conditions string, args []interface{}, order string, limit int) ([]*Session, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	gym, args := gymCondition(ctx, "gym_id", args)
+	args = append(args, limit)
+	rows, err := p.db.QueryContext(ctx,
+		`SELECT `+sessionColumns+` FROM sessions WHERE TRUE`+conditions+gym+
+			fmt.Sprintf(` ORDER BY %s LIMIT $%d`, order, len(args)),
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := make([]*Session, 0, limit)
+	for rows.Next() {
+		s, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+// UpdateSession rewrites the session row, locked until the transaction
+// ends, then books waitlisted members into the spots added
+func (p *Postgres) UpdateSession(ctx context.Context, s *Session, checkConflicts bool) (*Session, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	var updated *Session
+	err := p.inTx(ctx, func(tx *sql.Tx) error {
+		current, err := lockSession(ctx, tx, s.ID)
+		if err != nil {
+			return err
+		}
+		switch {
+		case current.IsCancelled:
+			return ErrAlreadyCancelled
+		case current.IsCompleted:
+			return ErrSessionCompleted
+		case s.Capacity < current.ReservedSpots:
+			return ErrCapacityBelowReserved
+		}
+
+		next := *s
+		next.GymID = current.GymID
+		if checkConflicts {
+			if err := lockSchedules(ctx, tx, &next); err != nil {
+				return err
+			}
+			if err := findConflict(ctx, tx, &next); err != nil {
+				return err
+			}
+		}
+		if err := updateSessionRow(ctx, tx, &next); err != nil {
+			return err
+		}
+		if next.Capacity > current.Capacity {
+			if err := p.promoteWaitlisted(ctx, tx, next.GymID, next.ID); err != nil {
+				return err
+			}
+		}
+
+		updated, err = scanSession(tx.QueryRowContext(
+			ctx,
+			`SELECT `+sessionColumns+` FROM sessions WHERE gym_id = $1 AND id = $2`,
+			next.GymID, next.ID,
+		))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// Select the session with the given ID, in the gym of ctx if any, and lock
+// its row until tx ends
+func lockSession(ctx context.Context, tx *sql.Tx, id int64) (*Session, error) {
+	gym, args := gymCondition(ctx, "gym_id", []interface{}{id})
+	return scanSession(tx.QueryRowContext(
+		ctx,
+		`SELECT `+sessionColumns+` FROM sessions WHERE id = $1`+gym+` FOR UPDATE`,
+		args...,
+	))
+}
+
+// Write the fields UpdateSession replaces to the row of s in the gym of s
+func updateSessionRow(ctx context.Context, tx *sql.Tx, s *Session) error {
+	_, err := tx.ExecContext(
+		ctx,
+		`UPDATE sessions SET title = $3, description = $4, coach_id = $5, coach_name = $6, capacity = $7,
+		start_time = $8, end_time = $9, location = $10, session_type = $11, difficulty_level = $12,
+		updated_at = CURRENT_TIMESTAMP
+		WHERE gym_id = $1 AND id = $2`,
+		s.GymID, s.ID, s.Title, s.Description, s.CoachID, s.CoachName, s.Capacity,
+		s.StartTime.UTC(), s.EndTime.UTC(), s.Location, s.SessionType, s.DifficultyLevel,
+	)
+	return err
+}
+
+// CancelSession flags a session as cancelled
+func (p *Postgres) CancelSession(ctx context.Context, id int64, reason string) (*Session, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	gym, args := gymCondition(ctx, "gym_id", []interface{}{id, reason})
+	s, err := scanSession(p.db.QueryRowContext(
+		ctx,
+		`UPDATE sessions SET is_cancelled = TRUE, cancellation_reason = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND NOT is_cancelled AND NOT is_completed`+gym+`
+		RETURNING `+sessionColumns,
+		args...,
+	))
+	if err != ErrNotFound {
+		return s, err
+	}
+
+	// Nothing updated: the session is missing, already cancelled or completed
+	s, err = p.GetSession(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if s.IsCompleted {
+		return nil, ErrSessionCompleted
+	}
+	return nil, ErrAlreadyCancelled
+}
+
+// DeleteSession removes a session row; reservations go with it
+func (p *Postgres) DeleteSession(ctx context.Context, id int64) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	gym, args := gymCondition(ctx, "gym_id", []interface{}{id})
+	res, err := p.db.ExecContext(ctx, `DELETE FROM sessions WHERE id = $1`+gym, args...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// CompleteSessions completes the sessions and marks their no-shows in a
+// single statement. A check-in at the same time waits for the reservation's
+// row lock, then checks in the no-show: the member attended either way.
+func (p *Postgres) CompleteSessions(ctx context.Context, endedBy time.Time) ([]int64, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	gym, args := gymCondition(ctx, "gym_id", []interface{}{endedBy.UTC(), ReservationNoShow, ReservationConfirmed})
+	rows, err := p.db.QueryContext(
+		ctx,
+		`WITH completed AS (
+			UPDATE sessions SET is_completed = TRUE, updated_at = CURRENT_TIMESTAMP
+			WHERE end_time <= $1 AND NOT is_cancelled AND NOT is_completed`+gym+`
+			RETURNING gym_id, id
+		), no_shows AS (
+			UPDATE reservations r SET status = $2, updated_at = CURRENT_TIMESTAMP
+			FROM completed c
+			WHERE r.gym_id = c.gym_id AND r.session_id = c.id AND r.status = $3
+		)
+		SELECT id FROM completed ORDER BY id`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var completed []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		completed = append(completed, id)
+	}
+	return completed, rows.Err()
+}
+
+// Run fn in a transaction, committed if fn returns nil
+func (p *Postgres) inTx(ctx context.Context, fn func(*sql.Tx) error) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,411 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Columns read by every reservation query, in the order expected by
+// scanReservation
+const reservationColumns = `id, gym_id, session_id, user_id, user_name, reservation_time, status, created_at, updated_at`
+
+// Scan a row selected with reservationColumns, from a *sql.Row or *sql.Rows
+func scanReservation(row interface{ Scan(...interface{}) error }) (*Reservation, error) {
+	var r Reservation
+	err := row.Scan(&r.ID, &r.GymID, &r.SessionID, &r.UserID, &r.UserName, &r.ReservationTime, &r.Status, &r.CreatedAt, &r.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// CreateReservation takes a spot and records the booking in one transaction.
+// The spot is taken with a conditional UPDATE, which locks the session row,
+// so concurrent bookings queue up and can never exceed capacity.
+func (p *Postgres) CreateReservation(ctx context.Context, r *Reservation) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	return p.inTx(ctx, func(tx *sql.Tx) error {
+		return p.reserve(ctx, tx, r)
+	})
+}
+
+// CreateReservations books every reservation in one transaction. Each one
+// runs under a savepoint, so a failed booking is undone without losing the
+// others.
+func (p *Postgres) CreateReservations(ctx context.Context, rs []*Reservation, allOrNothing bool) ([]error, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	errs := make([]error, len(rs))
+	err := p.inTx(ctx, func(tx *sql.Tx) error {
+		failed := false
+		for i, r := range rs {
+			if _, err := tx.ExecContext(ctx, `SAVEPOINT booking`); err != nil {
+				return err
+			}
+			err := p.reserve(ctx, tx, r)
+			if err == nil {
+				continue
+			}
+			if !isBookingError(err) {
+				return err
+			}
+			if _, err := tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT booking`); err != nil {
+				return err
+			}
+			errs[i] = err
+			failed = true
+		}
+
+		if failed && allOrNothing {
+			for i, err := range errs {
+				if err == nil {
+					errs[i] = ErrBatchAborted
+				}
+			}
+			return errBatchFailed
+		}
+		return nil
+	})
+	if err == errBatchFailed {
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return errs, nil
+}
+
+// Take a spot and record the booking of r within tx
+func (p *Postgres) reserve(ctx context.Context, tx *sql.Tx, r *Reservation) error {
+	var gymID string
+	gym, args := gymCondition(ctx, "gym_id", []interface{}{r.SessionID})
+	err := tx.QueryRowContext(
+		ctx,
+		`UPDATE sessions SET reserved_spots = reserved_spots + 1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND NOT is_cancelled AND NOT is_completed AND reserved_spots < capacity`+gym+`
+		RETURNING gym_id`,
+		args...,
+	).Scan(&gymID)
+	if err == sql.ErrNoRows {
+		return p.bookingError(ctx, tx, r)
+	}
+	if err != nil {
+		return err
+	}
+
+	// A cancelled reservation of the same user is booked again, as
+	// (gym_id, session_id, user_id) is unique
+	created, err := scanReservation(tx.QueryRowContext(
+		ctx,
+		`INSERT INTO reservations (gym_id, session_id, user_id, user_name, status)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (gym_id, session_id, user_id) DO UPDATE
+		SET user_name = EXCLUDED.user_name, status = EXCLUDED.status,
+			reservation_time = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE reservations.status <> $5
+		RETURNING `+reservationColumns,
+		gymID, r.SessionID, r.UserID, r.UserName, ReservationConfirmed,
+	))
+	if err == ErrNotFound {
+		// The user already has a confirmed reservation; rolling back frees
+		// the spot taken above
+		return ErrAlreadyBooked
+	}
+	if err != nil {
+		return err
+	}
+	*r = *created
+	return nil
+}
+
+// Find out why no spot could be taken for r
+func (p *Postgres) bookingError(ctx context.Context, tx *sql.Tx, r *Reservation) error {
+	var cancelled, completed, booked bool
+	gym, args := gymCondition(ctx, "s.gym_id", []interface{}{r.SessionID, r.UserID, ReservationConfirmed})
+	err := tx.QueryRowContext(
+		ctx,
+		`SELECT s.is_cancelled, s.is_completed, EXISTS (
+			SELECT 1 FROM reservations r
+			WHERE r.gym_id = s.gym_id AND r.session_id = $1 AND r.user_id = $2 AND r.status = $3
+		) FROM sessions s WHERE s.id = $1`+gym,
+		args...,
+	).Scan(&cancelled, &completed, &booked)
+	switch {
+	case err == sql.ErrNoRows:
+		return ErrNotFound
+	case err != nil:
+		return err
+	case cancelled:
+		return ErrAlreadyCancelled
+	case completed:
+		return ErrSessionCompleted
+	case booked:
+		return ErrAlreadyBooked
+	}
+	return ErrSessionFull
+}
+
+// GetReservation loads a reservation by ID
+func (p *Postgres) GetReservation(ctx context.Context, id int64) (*Reservation, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	gym, args := gymCondition(ctx, "gym_id", []interface{}{id})
+	return scanReservation(p.db.QueryRowContext(
+		ctx,
+		`SELECT `+reservationColumns+` FROM reservations WHERE id = $1`+gym,
+		args...,
+	))
+}
+
+// ListReservations reads a page of reservations by ID. A session's are found
+// with the (gym_id, session_id, user_id) index, a user's with
+// (gym_id, user_id, id).
+func (p *Postgres) ListReservations(ctx context.Context, f ReservationFilter, afterID int64, limit int) ([]*Reservation, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + reservationColumns + ` FROM reservations WHERE id > $1`
+	args := []interface{}{afterID}
+	where := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		query += fmt.Sprintf(condition, len(args))
+	}
+	if f.SessionID != 0 {
+		where(" AND session_id = $%d", f.SessionID)
+	}
+	if f.UserID != "" {
+		where(" AND user_id = $%d", f.UserID)
+	}
+	if f.Status != "" {
+		where(" AND status = $%d", f.Status)
+	}
+	if !f.SessionsEndingAfter.IsZero() {
+		where(` AND EXISTS (
+			SELECT 1 FROM sessions s
+			WHERE s.gym_id = reservations.gym_id AND s.id = reservations.session_id AND s.end_time > $%d
+		)`, f.SessionsEndingAfter.UTC())
+	}
+	gym, args := gymCondition(ctx, "gym_id", args)
+	args = append(args, limit)
+	query += gym + fmt.Sprintf(" ORDER BY id LIMIT $%d", len(args))
+
+	rows, err := p.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reservations := make([]*Reservation, 0, limit)
+	for rows.Next() {
+		r, err := scanReservation(rows)
+		if err != nil {
+			return nil, err
+		}
+		reservations = append(reservations, r)
+	}
+	return reservations, rows.Err()
+}
+
+// CancelReservation flags a reservation as cancelled and gives its spot back,
+// to the first member waiting if any, in one transaction. The session row is
+// locked before the reservation, in the order a booking takes them, so a
+// booking and a cancellation of the same session can't deadlock.
+func (p *Postgres) CancelReservation(ctx context.Context, id int64) (*Reservation, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	var cancelled *Reservation
+	err := p.inTx(ctx, func(tx *sql.Tx) error {
+		var sessionID int64
+		gym, args := gymCondition(ctx, "gym_id", []interface{}{id})
+		err := tx.QueryRowContext(ctx, `SELECT session_id FROM reservations WHERE id = $1`+gym, args...).Scan(&sessionID)
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := lockSession(ctx, tx, sessionID); err != nil {
+			return err
+		}
+
+		gym, args = gymCondition(ctx, "gym_id", []interface{}{id, ReservationCancelled, ReservationConfirmed})
+		cancelled, err = scanReservation(tx.QueryRowContext(
+			ctx,
+			`UPDATE reservations SET status = $2, updated_at = CURRENT_TIMESTAMP
+			WHERE id = $1 AND status = $3`+gym+`
+			RETURNING `+reservationColumns,
+			args...,
+		))
+		if err == ErrNotFound {
+			// Nothing updated: either the reservation is missing or it was not
+			// confirmed, and so is cancelled, checked in or a no-show of a
+			// completed session. Read it in the transaction: a second
+			// connection would not see its snapshot, and may never come from
+			// a pool exhausted by transactions waiting like this one.
+			gym, args := gymCondition(ctx, "gym_id", []interface{}{id})
+			r, err := scanReservation(tx.QueryRowContext(ctx, `SELECT `+reservationColumns+` FROM reservations WHERE id = $1`+gym, args...))
+			if err != nil {
+				return err
+			}
+			switch r.Status {
+			case ReservationAttended:
+				return ErrCheckedIn
+			case ReservationNoShow:
+				return ErrSessionCompleted
+			}
+			return ErrAlreadyCancelled
+		}
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(
+			ctx,
+			`UPDATE sessions SET reserved_spots = GREATEST(reserved_spots - 1, 0), updated_at = CURRENT_TIMESTAMP
+			WHERE gym_id = $1 AND id = $2`,
+			cancelled.GymID, cancelled.SessionID,
+		)
+		if err != nil {
+			return err
+		}
+		return p.promoteWaitlisted(ctx, tx, cancelled.GymID, cancelled.SessionID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cancelled, nil
+}
+
+// CheckIn marks the reservation attended in a single statement, unless it or
+// its session is cancelled. Its spot stays taken, so the session is left
+// alone.
+func (p *Postgres) CheckIn(ctx context.Context, id int64) (*Reservation, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	gym, args := gymCondition(ctx, "gym_id", []interface{}{id, ReservationAttended, ReservationCancelled})
+	r, err := scanReservation(p.db.QueryRowContext(
+		ctx,
+		`UPDATE reservations SET status = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND status <> $3 AND NOT EXISTS (
+			SELECT 1 FROM sessions s
+			WHERE s.gym_id = reservations.gym_id AND s.id = reservations.session_id AND s.is_cancelled
+		)`+gym+`
+		RETURNING `+reservationColumns,
+		args...,
+	))
+	if err == ErrNotFound {
+		// Nothing updated: either the reservation is missing or it, or its
+		// session, is cancelled
+		if _, err := p.GetReservation(ctx, id); err != nil {
+			return nil, err
+		}
+		return nil, ErrAlreadyCancelled
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// ReconcileReservedSpots repairs drifted sessions one at a time. Each is
+// locked before its reservations are counted, so a booking committing at
+// the same time is either fully counted or waits for the repair.
+func (p *Postgres) ReconcileReservedSpots(ctx context.Context) ([]SpotDrift, error) {
+	candidates, err := p.driftCandidates(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var repaired []SpotDrift
+	for _, c := range candidates {
+		drift, err := p.repairReservedSpots(ctx, c)
+		if err == sql.ErrNoRows {
+			// Deleted since the candidates were listed
+			continue
+		}
+		if err != nil {
+			return repaired, err
+		}
+		if drift.Recorded != drift.Actual {
+			repaired = append(repaired, drift)
+		}
+	}
+	return repaired, nil
+}
+
+// Key of a session row, which the partition holding it is found by
+type sessionKey struct {
+	gymID string
+	id    int64
+}
+
+// List the sessions whose reserved_spots looks wrong. The counts may be stale
+// by the time each session is locked.
+func (p *Postgres) driftCandidates(ctx context.Context) ([]sessionKey, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	gym, args := gymCondition(ctx, "s.gym_id", []interface{}{ReservationCancelled})
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT s.gym_id, s.id FROM sessions s
+		LEFT JOIN reservations r ON r.gym_id = s.gym_id AND r.session_id = s.id AND r.status <> $1
+		WHERE TRUE`+gym+`
+		GROUP BY s.gym_id, s.id
+		HAVING COALESCE(MAX(s.reserved_spots), 0) <> COUNT(r.id)`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	var candidates []sessionKey
+	for rows.Next() {
+		var c sessionKey
+		if err := rows.Scan(&c.gymID, &c.id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		candidates = append(candidates, c)
+	}
+	rows.Close()
+	return candidates, rows.Err()
+}
+
+// Lock a session, count its reservations that are not cancelled and fix
+// reserved_spots
+func (p *Postgres) repairReservedSpots(ctx context.Context, key sessionKey) (SpotDrift, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	drift := SpotDrift{SessionID: key.id}
+	err := p.inTx(ctx, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx,
+			`SELECT COALESCE(reserved_spots, 0) FROM sessions WHERE gym_id = $1 AND id = $2 FOR UPDATE`, key.gymID, key.id,
+		).Scan(&drift.Recorded)
+		if err != nil {
+			return err
+		}
+		err = tx.QueryRowContext(ctx,
+			`SELECT COUNT(*) FROM reservations WHERE gym_id = $1 AND session_id = $2 AND status <> $3`,
+			key.gymID, key.id, ReservationCancelled,
+		).Scan(&drift.Actual)
+		if err != nil || drift.Recorded == drift.Actual {
+			return err
+		}
+		_, err = tx.ExecContext(ctx,
+			`UPDATE sessions SET reserved_spots = $3, updated_at = CURRENT_TIMESTAMP WHERE gym_id = $1 AND id = $2`,
+			key.gymID, key.id, drift.Actual,
+		)
+		return err
+	})
+	return drift, err
+}
@@ -0,0 +1,253 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// CreateSessionSeries inserts the occurrences in one transaction, checking
+// each against the sessions stored and the occurrences inserted before it
+func (p *Postgres) CreateSessionSeries(ctx context.Context, ss []*Session, checkConflicts bool) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	for _, s := range ss {
+		if s.GymID == "" {
+			s.GymID = GymFromContext(ctx)
+		}
+		if s.GymID == "" {
+			s.GymID = DefaultGym
+		}
+	}
+	err := p.inTx(ctx, func(tx *sql.Tx) error {
+		var seriesID int64
+		if err := tx.QueryRowContext(ctx, `SELECT nextval('session_series_id_seq')`).Scan(&seriesID); err != nil {
+			return err
+		}
+		if checkConflicts {
+			if err := lockSchedules(ctx, tx, ss...); err != nil {
+				return err
+			}
+		}
+		for _, s := range ss {
+			if checkConflicts {
+				if err := findConflict(ctx, tx, s); err != nil {
+					return err
+				}
+			}
+			s.SeriesID = seriesID
+			if err := insertSession(ctx, tx, s); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		// Rolled back
+		for _, s := range ss {
+			s.ID, s.SeriesID = 0, 0
+		}
+	}
+	return err
+}
+
+// FindConflicts selects the sessions overlapping any of the occurrences in
+// one query, matching them against the occurrences unnested from arrays
+func (p *Postgres) FindConflicts(ctx context.Context, ss []*Session) ([]*Session, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	gyms := make([]string, len(ss))
+	coaches := make([]string, len(ss))
+	locations := make([]string, len(ss))
+	starts := make([]string, len(ss))
+	ends := make([]string, len(ss))
+	for i, s := range ss {
+		gyms[i] = s.GymID
+		if gyms[i] == "" {
+			gyms[i] = GymFromContext(ctx)
+		}
+		if gyms[i] == "" {
+			gyms[i] = DefaultGym
+		}
+		coaches[i], locations[i] = s.CoachID, s.Location
+		starts[i] = s.StartTime.UTC().Format(time.RFC3339Nano)
+		ends[i] = s.EndTime.UTC().Format(time.RFC3339Nano)
+	}
+	rows, err := p.db.QueryContext(
+		ctx,
+		`SELECT `+sessionColumns+` FROM sessions
+		WHERE NOT is_cancelled AND EXISTS (
+			SELECT 1 FROM unnest($1::text[], $2::text[], $3::text[], $4::timestamptz[], $5::timestamptz[])
+				AS o(gym_id, coach_id, location, start_time, end_time)
+			WHERE sessions.gym_id = o.gym_id AND (sessions.coach_id = o.coach_id OR sessions.location = o.location)
+			AND sessions.start_time < (o.end_time AT TIME ZONE 'UTC') AND sessions.end_time > (o.start_time AT TIME ZONE 'UTC')
+		)
+		ORDER BY start_time, id`,
+		pq.Array(gyms), pq.Array(coaches), pq.Array(locations), pq.Array(starts), pq.Array(ends),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanSessions(rows)
+}
+
+// UpdateSessionSeries locks the session and the rest of its series, rewrites
+// their rows, then checks conflicts with every occurrence at its new time,
+// so that occurrences moving together don't get in each other's way
+func (p *Postgres) UpdateSessionSeries(ctx context.Context, s *Session, checkConflicts bool) ([]*Session, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	var updated []*Session
+	err := p.inTx(ctx, func(tx *sql.Tx) error {
+		current, err := lockSession(ctx, tx, s.ID)
+		if err != nil {
+			return err
+		}
+		switch {
+		case current.IsCancelled:
+			return ErrAlreadyCancelled
+		case current.IsCompleted:
+			return ErrSessionCompleted
+		}
+		occurrences, err := lockSeriesFrom(ctx, tx, current)
+		if err != nil {
+			return err
+		}
+
+		shift, length := s.StartTime.Sub(current.StartTime), s.EndTime.Sub(s.StartTime)
+		moved := make([]*Session, len(occurrences))
+		ids := make([]int64, len(occurrences))
+		for i, o := range occurrences {
+			if s.Capacity < o.ReservedSpots {
+				return ErrCapacityBelowReserved
+			}
+			next := *s
+			next.ID, next.GymID = o.ID, o.GymID
+			next.StartTime = o.StartTime.Add(shift)
+			next.EndTime = next.StartTime.Add(length)
+			moved[i], ids[i] = &next, o.ID
+		}
+		if checkConflicts {
+			if err := lockSchedules(ctx, tx, moved[0]); err != nil {
+				return err
+			}
+		}
+		for _, next := range moved {
+			if err := updateSessionRow(ctx, tx, next); err != nil {
+				return err
+			}
+		}
+		for i, next := range moved {
+			if checkConflicts {
+				if err := findConflict(ctx, tx, next); err != nil {
+					return err
+				}
+			}
+			if next.Capacity > occurrences[i].Capacity {
+				if err := p.promoteWaitlisted(ctx, tx, next.GymID, next.ID); err != nil {
+					return err
+				}
+			}
+		}
+
+		updated, err = selectSessions(ctx, tx, current.GymID, ids)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// CancelSessionSeries cancels the session and the rest of its series with
+// the session row locked, which tells why nothing was cancelled
+func (p *Postgres) CancelSessionSeries(ctx context.Context, id int64, reason string) ([]*Session, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	var cancelled []*Session
+	err := p.inTx(ctx, func(tx *sql.Tx) error {
+		current, err := lockSession(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		switch {
+		case current.IsCancelled:
+			return ErrAlreadyCancelled
+		case current.IsCompleted:
+			return ErrSessionCompleted
+		}
+
+		rows, err := tx.QueryContext(
+			ctx,
+			`UPDATE sessions SET is_cancelled = TRUE, cancellation_reason = $5, updated_at = CURRENT_TIMESTAMP
+			WHERE gym_id = $1 AND (id = $2 OR (series_id = $3 AND start_time >= $4))
+			AND NOT is_cancelled AND NOT is_completed
+			RETURNING `+sessionColumns,
+			current.GymID, current.ID, current.SeriesID, current.StartTime.UTC(), reason,
+		)
+		if err != nil {
+			return err
+		}
+		cancelled, err = scanSessions(rows)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortByStart(cancelled)
+	return cancelled, nil
+}
+
+// Select the session, and the later sessions of its series that are neither
+// cancelled nor completed, in start time order, and lock their rows until tx
+// ends
+func lockSeriesFrom(ctx context.Context, tx *sql.Tx, s *Session) ([]*Session, error) {
+	if s.SeriesID == 0 {
+		return []*Session{s}, nil
+	}
+	rows, err := tx.QueryContext(
+		ctx,
+		`SELECT `+sessionColumns+` FROM sessions
+		WHERE gym_id = $1 AND (id = $2 OR (series_id = $3 AND start_time >= $4 AND NOT is_cancelled AND NOT is_completed))
+		ORDER BY start_time, id FOR UPDATE`,
+		s.GymID, s.ID, s.SeriesID, s.StartTime.UTC(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanSessions(rows)
+}
+
+// Select the sessions of a gym with the given IDs in start time order
+func selectSessions(ctx context.Context, tx *sql.Tx, gymID string, ids []int64) ([]*Session, error) {
+	rows, err := tx.QueryContext(
+		ctx,
+		`SELECT `+sessionColumns+` FROM sessions WHERE gym_id = $1 AND id = ANY($2) ORDER BY start_time, id`,
+		gymID, pq.Array(ids),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanSessions(rows)
+}
+
+// Scan and close rows selected with sessionColumns
+func scanSessions(rows *sql.Rows) ([]*Session, error) {
+	defer rows.Close()
+
+	var sessions []*Session
+	for rows.Next() {
+		s, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
@@ -0,0 +1,154 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Columns read by every waitlist query, in the order expected by
+// scanWaitlistEntry
+const waitlistColumns = `id, gym_id, session_id, user_id, user_name, created_at`
+
+// Scan a row selected with waitlistColumns
+func scanWaitlistEntry(row *sql.Row) (*WaitlistEntry, error) {
+	var e WaitlistEntry
+	err := row.Scan(&e.ID, &e.GymID, &e.SessionID, &e.UserID, &e.UserName, &e.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// JoinWaitlist adds a member to the waitlist of a full session. The session
+// row is locked while checking that it is full, like a booking does, so a
+// member never starts waiting after a cancellation freed a spot. Sessions
+// taking queued bookings have no waitlist: promoting from it would book
+// members ahead of the queue.
+func (p *Postgres) JoinWaitlist(ctx context.Context, e *WaitlistEntry) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	return p.inTx(ctx, func(tx *sql.Tx) error {
+		var gymID string
+		var cancelled, completed, queued, full, booked bool
+		gym, args := gymCondition(ctx, "s.gym_id", []interface{}{e.SessionID, e.UserID, ReservationConfirmed})
+		err := tx.QueryRowContext(
+			ctx,
+			`SELECT s.gym_id, s.is_cancelled, s.is_completed, s.queued_booking, s.reserved_spots >= s.capacity, EXISTS (
+				SELECT 1 FROM reservations r
+				WHERE r.gym_id = s.gym_id AND r.session_id = $1 AND r.user_id = $2 AND r.status = $3
+			) FROM sessions s WHERE s.id = $1`+gym+`
+			FOR UPDATE OF s`,
+			args...,
+		).Scan(&gymID, &cancelled, &completed, &queued, &full, &booked)
+		switch {
+		case err == sql.ErrNoRows:
+			return ErrNotFound
+		case err != nil:
+			return err
+		case cancelled:
+			return ErrAlreadyCancelled
+		case completed:
+			return ErrSessionCompleted
+		case queued:
+			return ErrQueuedBooking
+		case booked:
+			return ErrAlreadyBooked
+		case !full:
+			return ErrSessionNotFull
+		}
+
+		created, err := scanWaitlistEntry(tx.QueryRowContext(
+			ctx,
+			`INSERT INTO waitlist (gym_id, session_id, user_id, user_name) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (gym_id, session_id, user_id) DO NOTHING
+			RETURNING `+waitlistColumns,
+			gymID, e.SessionID, e.UserID, e.UserName,
+		))
+		if err == ErrNotFound {
+			return ErrAlreadyWaitlisted
+		}
+		if err != nil {
+			return err
+		}
+
+		err = tx.QueryRowContext(
+			ctx,
+			`SELECT COUNT(*) FROM waitlist WHERE gym_id = $1 AND session_id = $2 AND id <= $3`,
+			gymID, e.SessionID, created.ID,
+		).Scan(&created.Position)
+		if err != nil {
+			return err
+		}
+		*e = *created
+		return nil
+	})
+}
+
+// LeaveWaitlist deletes the member's waitlist entry
+func (p *Postgres) LeaveWaitlist(ctx context.Context, sessionID int64, userID string) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	gym, args := gymCondition(ctx, "gym_id", []interface{}{sessionID, userID})
+	result, err := p.db.ExecContext(ctx, `DELETE FROM waitlist WHERE session_id = $1 AND user_id = $2`+gym, args...)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Book the members waiting for the session into its free spots, in the
+// order they joined, within tx. Each booking runs under a savepoint: the
+// member who is first when the session is full again, or can't be booked
+// anymore, stays on the waitlist.
+func (p *Postgres) promoteWaitlisted(ctx context.Context, tx *sql.Tx, gymID string, sessionID int64) error {
+	for {
+		if _, err := tx.ExecContext(ctx, `SAVEPOINT promotion`); err != nil {
+			return err
+		}
+		var r Reservation
+		var entryID int64
+		err := tx.QueryRowContext(
+			ctx,
+			`DELETE FROM waitlist WHERE gym_id = $1 AND id = (
+				SELECT id FROM waitlist WHERE gym_id = $1 AND session_id = $2 ORDER BY id LIMIT 1
+			) RETURNING id, session_id, user_id, user_name`,
+			gymID, sessionID,
+		).Scan(&entryID, &r.SessionID, &r.UserID, &r.UserName)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		err = p.reserve(ctx, tx, &r)
+		if err == nil {
+			continue
+		}
+		if !isBookingError(err) {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT promotion`); err != nil {
+			return err
+		}
+		if err != ErrAlreadyBooked {
+			return nil
+		}
+		// Booked some other way meanwhile: the entry is of no more use
+		if _, err := tx.ExecContext(ctx, `DELETE FROM waitlist WHERE gym_id = $1 AND id = $2`, gymID, entryID); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,317 @@
+// Package store contains the persistence layer of the session service.
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned when the requested record does not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrAlreadyCancelled is returned when cancelling a session or
+	// reservation that is already cancelled, or booking a cancelled session.
+	ErrAlreadyCancelled = errors.New("already cancelled")
+	// ErrSessionFull is returned when booking a session with no spots left.
+	ErrSessionFull = errors.New("session full")
+	// ErrSessionCompleted is returned when booking, cancelling or cancelling
+	// a reservation of a session that was completed after it ended.
+	ErrSessionCompleted = errors.New("session completed")
+	// ErrAlreadyBooked is returned when a user books a session twice.
+	ErrAlreadyBooked = errors.New("already booked")
+	// ErrBatchAborted is returned for the reservations of an all-or-nothing
+	// batch that were not booked because another one failed.
+	ErrBatchAborted = errors.New("batch aborted")
+	// ErrSessionNotFull is returned when joining the waitlist of a session
+	// that has spots left.
+	ErrSessionNotFull = errors.New("session not full")
+	// ErrAlreadyWaitlisted is returned when a user joins a waitlist twice.
+	ErrAlreadyWaitlisted = errors.New("already waitlisted")
+	// ErrQueuedBooking is returned when joining the waitlist of a session
+	// that takes queued bookings only: its spots go to the queue.
+	ErrQueuedBooking = errors.New("queued booking")
+	// ErrCapacityBelowReserved is returned when lowering the capacity of a
+	// session below its reserved spots.
+	ErrCapacityBelowReserved = errors.New("capacity below reserved spots")
+	// ErrCheckedIn is returned when cancelling a reservation whose member
+	// checked in.
+	ErrCheckedIn = errors.New("checked in")
+
+	// Rolls back a batch from inside its transaction
+	errBatchFailed = errors.New("batch failed")
+)
+
+// Errors that make a booking fail without anything going wrong
+func isBookingError(err error) bool {
+	switch err {
+	case ErrNotFound, ErrAlreadyCancelled, ErrSessionCompleted, ErrAlreadyBooked, ErrSessionFull:
+		return true
+	}
+	return false
+}
+
+// Session is a training session at the gym.
+type Session struct {
+	ID                 int64
+	GymID              string
+	Title              string
+	Description        string
+	CoachID            string
+	CoachName          string
+	Capacity           int32
+	ReservedSpots      int32
+	StartTime          time.Time
+	EndTime            time.Time
+	Location           string
+	SessionType        string
+	DifficultyLevel    string
+	IsCancelled        bool
+	CancellationReason string
+	IsCompleted        bool
+	QueuedBooking      bool  // Booked through a queue only, see package queue
+	SeriesID           int64 // Series the session recurs in, 0 if none
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+}
+
+// ConflictError is returned when a session would overlap another one of the
+// same gym that isn't cancelled, with the same coach or at the same location.
+type ConflictError struct {
+	// The session in the way, the first to start if several are
+	Session *Session
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("overlaps session %d", e.Session.ID)
+}
+
+// Whether two sessions overlap in time. One may start when the other ends.
+func overlaps(a, b *Session) bool {
+	return a.StartTime.Before(b.EndTime) && b.StartTime.Before(a.EndTime)
+}
+
+// SessionFilter selects sessions. Its zero value matches every session.
+type SessionFilter struct {
+	StartsFrom       time.Time // If set, only sessions starting at or after it
+	StartsBefore     time.Time // If set, only sessions starting before it
+	SessionType      string
+	DifficultyLevel  string
+	CoachID          string
+	Location         string
+	SeriesID         int64
+	ExcludeCancelled bool
+}
+
+// SessionCursor is a position in the start time order of sessions, where
+// sessions starting at the same time are ordered by ID.
+type SessionCursor struct {
+	StartTime time.Time
+	ID        int64
+}
+
+// Cursor returns the position of s in start time order.
+func (s *Session) Cursor() SessionCursor {
+	return SessionCursor{StartTime: s.StartTime, ID: s.ID}
+}
+
+// Sort sessions in start time order
+func sortByStart(ss []*Session) {
+	sort.Slice(ss, func(i, j int) bool {
+		if !ss[i].StartTime.Equal(ss[j].StartTime) {
+			return ss[i].StartTime.Before(ss[j].StartTime)
+		}
+		return ss[i].ID < ss[j].ID
+	})
+}
+
+// SessionRepository stores training sessions. Calls with a gym in their
+// context (see WithGym) only see the sessions of that gym.
+type SessionRepository interface {
+	// CreateSession inserts s and fills in its ID and timestamps. A session
+	// without a gym goes to the gym of ctx, or else to DefaultGym. With
+	// checkConflicts, it returns a *ConflictError instead if s overlaps
+	// another session; sessions created concurrently are checked against
+	// each other.
+	CreateSession(ctx context.Context, s *Session, checkConflicts bool) error
+	// GetSession returns the session with the given ID or ErrNotFound.
+	GetSession(ctx context.Context, id int64) (*Session, error)
+	// UpdateSession replaces the title, description, coach, capacity, times,
+	// location, type and difficulty level of the session s.ID with those of
+	// s, and returns the updated session. Spots added go to the members on
+	// its waitlist, in the same transaction. It returns ErrNotFound,
+	// ErrAlreadyCancelled if the session is cancelled, ErrSessionCompleted
+	// if it is completed and ErrCapacityBelowReserved if s.Capacity is
+	// below its reserved spots. With checkConflicts, it returns a
+	// *ConflictError like CreateSession.
+	UpdateSession(ctx context.Context, s *Session, checkConflicts bool) (*Session, error)
+	// CancelSession marks the session cancelled and returns it. It returns
+	// ErrAlreadyCancelled if the session was cancelled before and
+	// ErrSessionCompleted if it was completed.
+	CancelSession(ctx context.Context, id int64, reason string) (*Session, error)
+	// CreateSessionSeries inserts the occurrences of a recurring session
+	// like CreateSession, in one transaction, and fills in the same new
+	// SeriesID in each. Nothing is inserted if any of them conflicts.
+	CreateSessionSeries(ctx context.Context, ss []*Session, checkConflicts bool) error
+	// FindConflicts returns the sessions that the occurrences ss, not
+	// stored yet, would overlap as CreateSession checks them, in start time
+	// order. It stores nothing.
+	FindConflicts(ctx context.Context, ss []*Session) ([]*Session, error)
+	// UpdateSessionSeries updates the session s.ID like UpdateSession, and
+	// the later sessions of its series that are neither cancelled nor
+	// completed in the same transaction. They get the fields of s, and
+	// times moved by as much as those of s.ID, so that they keep their day
+	// in the series. It returns the updated sessions in start time order,
+	// or the errors of UpdateSession, with ErrCapacityBelowReserved if any
+	// of them has more reserved spots than s.Capacity. A session outside
+	// any series is a series of its own.
+	UpdateSessionSeries(ctx context.Context, s *Session, checkConflicts bool) ([]*Session, error)
+	// CancelSessionSeries cancels the session like CancelSession, and the
+	// later sessions of its series that are neither cancelled nor completed
+	// in the same transaction. It returns the cancelled sessions in start
+	// time order, or the errors of CancelSession.
+	CancelSessionSeries(ctx context.Context, id int64, reason string) ([]*Session, error)
+	// ListSessions returns up to limit sessions matching f in start time
+	// order, or the reverse with descending. If after is not nil, the list
+	// starts after that position, typically the last session of the
+	// previous page: sessions created or removed meanwhile do not shift the
+	// next page.
+	ListSessions(ctx context.Context, f SessionFilter, after *SessionCursor, descending bool, limit int) ([]*Session, error)
+	// ListSessionsAfter returns, by ID, up to limit sessions matching f
+	// whose ID is greater than afterID. Reading page after page, each
+	// starting after the last ID of the previous one, goes through any
+	// number of sessions without holding them all in memory, nor a
+	// connection between pages.
+	ListSessionsAfter(ctx context.Context, f SessionFilter, afterID int64, limit int) ([]*Session, error)
+	// DeleteSession removes the session and its reservations. It returns
+	// ErrNotFound if there is no such session.
+	DeleteSession(ctx context.Context, id int64) error
+	// CompleteSessions marks completed the sessions that ended at or before
+	// endedBy and are neither cancelled nor completed yet, and their
+	// reservations still confirmed, whose members did not check in,
+	// no-shows, in one transaction. Completed sessions
+	// can no longer be booked or cancelled, nor their reservations
+	// cancelled. It returns the IDs of the sessions it completed.
+	CompleteSessions(ctx context.Context, endedBy time.Time) ([]int64, error)
+}
+
+// Reservation statuses
+const (
+	ReservationConfirmed = "confirmed"
+	ReservationCancelled = "cancelled"
+	// A reservation whose member checked in
+	ReservationAttended = "attended"
+	// A reservation still confirmed when its session was completed
+	ReservationNoShow = "no_show"
+)
+
+// Reservation is a member's booking of a session.
+type Reservation struct {
+	ID              int64
+	GymID           string // Gym of the session, filled in by the store
+	SessionID       int64
+	UserID          string
+	UserName        string
+	ReservationTime time.Time
+	Status          string
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+// ReservationFilter selects reservations. Its zero value matches every
+// reservation.
+type ReservationFilter struct {
+	SessionID int64
+	UserID    string
+	Status    string
+	// If set, only reservations of sessions ending after it
+	SessionsEndingAfter time.Time
+}
+
+// SpotDrift is a session whose reserved_spots did not match its confirmed
+// reservations.
+type SpotDrift struct {
+	SessionID int64
+	Recorded  int32
+	Actual    int32
+}
+
+// ReservationRepository stores bookings. A session's ReservedSpots is the
+// number of its reservations that are not cancelled: every change to a
+// reservation updates it in the same transaction. Like sessions,
+// reservations are scoped to the gym in the context of the call, if any.
+type ReservationRepository interface {
+	// CreateReservation books a spot of r.SessionID for r.UserID, and fills
+	// in the ID, status and timestamps of r. It returns ErrNotFound if the
+	// session does not exist, ErrAlreadyCancelled if it is cancelled,
+	// ErrSessionCompleted if it is completed, ErrAlreadyBooked if the user
+	// has a confirmed reservation for it and ErrSessionFull if every spot is
+	// taken, checked in that order.
+	CreateReservation(ctx context.Context, r *Reservation) error
+	// CreateReservations books each of rs like CreateReservation, in one
+	// transaction, and returns the outcome of each: nil or the error
+	// CreateReservation would have returned. With allOrNothing, nothing is
+	// booked if any booking fails, and the ones that would have succeeded get
+	// ErrBatchAborted. The second result reports a failure of the store
+	// itself, after which nothing is booked.
+	CreateReservations(ctx context.Context, rs []*Reservation, allOrNothing bool) ([]error, error)
+	// GetReservation returns the reservation with the given ID or ErrNotFound.
+	GetReservation(ctx context.Context, id int64) (*Reservation, error)
+	// ListReservations returns, by ID, up to limit reservations matching f
+	// whose ID is greater than afterID, like ListSessionsAfter.
+	ListReservations(ctx context.Context, f ReservationFilter, afterID int64, limit int) ([]*Reservation, error)
+	// CancelReservation cancels the reservation and frees its spot, which
+	// goes to the first member of the session's waitlist, if any, in the
+	// same transaction. It returns ErrAlreadyCancelled if the reservation
+	// was cancelled before, ErrCheckedIn if its member checked in and
+	// ErrSessionCompleted if its session was completed.
+	CancelReservation(ctx context.Context, id int64) (*Reservation, error)
+	// CheckIn records that the member of the reservation attended, and
+	// returns the reservation. A no-show can still be checked in, for
+	// attendance taken after the session was completed, and checking in
+	// twice changes nothing. It returns ErrNotFound, and ErrAlreadyCancelled
+	// if the reservation or its session was cancelled.
+	CheckIn(ctx context.Context, id int64) (*Reservation, error)
+	// ReconcileReservedSpots sets ReservedSpots back to the number of
+	// reservations that are not cancelled wherever they differ, and returns
+	// the sessions it repaired.
+	ReconcileReservedSpots(ctx context.Context) ([]SpotDrift, error)
+}
+
+// WaitlistEntry is a member waiting for a spot of a full session.
+type WaitlistEntry struct {
+	ID        int64
+	GymID     string // Gym of the session, filled in by the store
+	SessionID int64
+	UserID    string
+	UserName  string
+	Position  int32 // 1 for the next member to get a spot
+	CreatedAt time.Time
+}
+
+// WaitlistRepository keeps the members waiting for spots of full sessions,
+// first come first served. Whenever a spot is freed, the store books the
+// first member waiting and takes them off the waitlist in the same
+// transaction, so reserved_spots counts them like any other booking.
+type WaitlistRepository interface {
+	// JoinWaitlist adds e.UserID at the end of the waitlist of e.SessionID,
+	// and fills in the ID, position and creation time of e. It returns the
+	// errors of CreateReservation but ErrSessionFull, ErrSessionNotFull if
+	// the session has a spot left and ErrAlreadyWaitlisted if the user is on
+	// its waitlist already.
+	JoinWaitlist(ctx context.Context, e *WaitlistEntry) error
+	// LeaveWaitlist takes the user off the waitlist of the session, or
+	// returns ErrNotFound if they are not on it.
+	LeaveWaitlist(ctx context.Context, sessionID int64, userID string) error
+}
+
+// Repository is the full set of storage operations used by the server.
+//
+//go:generate go run github.com/matryer/moq@v0.2.7 -out storemock/repository.go -pkg storemock . Repository
+type Repository interface {
+	SessionRepository
+	ReservationRepository
+	WaitlistRepository
+}
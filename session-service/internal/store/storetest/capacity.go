@@ -0,0 +1,260 @@
+// Package storetest holds property checks that every store.Repository must
+// pass, run with rapid against the in-memory store in unit tests and against
+// Postgres in the integration tests.
+package storetest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"pgregory.net/rapid"
+
+	"session-service/internal/fixtures"
+	"session-service/internal/store"
+)
+
+// Members drawn for bookings. Few enough that the same member often books
+// the same session twice.
+var members = []string{"member-a", "member-b", "member-c", "member-d"}
+
+// Expected state of a session under test
+type sessionModel struct {
+	id        int64
+	capacity  int32
+	cancelled bool
+	// Reservation ID of each member with a confirmed booking
+	confirmed map[string]int64
+}
+
+// Capacity returns a property that throws random sequences of bookings,
+// concurrent booking bursts, batch bookings, booking cancellations, session
+// cancellations and reconciliations at sessions in repo. After every step it
+// checks the stored session against a simple model: reserved_spots never
+// exceeds capacity and always equals the number of confirmed reservations, a
+// member never holds two confirmed reservations for a session, a cancelled
+// session takes no bookings and an aborted batch books nobody.
+func Capacity(repo store.Repository) func(*rapid.T) {
+	return func(t *rapid.T) {
+		ctx := context.Background()
+
+		sessions := make([]*sessionModel, rapid.IntRange(1, 3).Draw(t, "sessions"))
+		for i := range sessions {
+			capacity := rapid.Int32Range(1, 5).Draw(t, "capacity")
+			s, err := fixtures.NewTestSession().WithCapacity(capacity).Create(ctx, repo)
+			if err != nil {
+				t.Fatalf("Failed to create session: %v", err)
+			}
+			sessions[i] = &sessionModel{id: s.ID, capacity: capacity, confirmed: make(map[string]int64)}
+		}
+
+		burst := 0
+		actions := map[string]func(*sessionModel){
+			"book": func(m *sessionModel) {
+				member := rapid.SampledFrom(members).Draw(t, "member")
+				r := &store.Reservation{SessionID: m.id, UserID: member, UserName: "Member"}
+				err := repo.CreateReservation(ctx, r)
+				if want := m.bookingError(member); err != want {
+					t.Fatalf("CreateReservation(%d, %s): expected %v, got %v", m.id, member, want, err)
+				}
+				if err == nil {
+					if r.Status != store.ReservationConfirmed {
+						t.Fatalf("New reservation %d has status %q", r.ID, r.Status)
+					}
+					m.confirmed[member] = r.ID
+				}
+			},
+			"book concurrently": func(m *sessionModel) {
+				n := rapid.IntRange(2, 8).Draw(t, "bookers")
+				want := m.free(int32(n))
+				burst++
+				type result struct {
+					member string
+					id     int64
+					err    error
+				}
+				results := make(chan result, n)
+				var wg sync.WaitGroup
+				for i := 0; i < n; i++ {
+					wg.Add(1)
+					go func(member string) {
+						defer wg.Done()
+						r := &store.Reservation{SessionID: m.id, UserID: member, UserName: "Member"}
+						err := repo.CreateReservation(ctx, r)
+						results <- result{member, r.ID, err}
+					}(fmt.Sprintf("burst-%d-%d", burst, i))
+				}
+				wg.Wait()
+				close(results)
+
+				booked := int32(0)
+				for res := range results {
+					switch res.err {
+					case nil:
+						booked++
+						m.confirmed[res.member] = res.id
+					case store.ErrSessionFull, store.ErrAlreadyCancelled:
+					default:
+						t.Fatalf("CreateReservation(%d): unexpected error %v", m.id, res.err)
+					}
+				}
+				if booked != want {
+					t.Fatalf("%d concurrent bookings of session %d: expected %d to succeed, got %d", n, m.id, want, booked)
+				}
+			},
+			"book batch": func(m *sessionModel) {
+				var batch []*store.Reservation
+				for _, member := range members {
+					if rapid.Bool().Draw(t, "in batch") {
+						batch = append(batch, &store.Reservation{SessionID: m.id, UserID: member, UserName: "Member"})
+					}
+				}
+				if len(batch) == 0 {
+					return
+				}
+				allOrNothing := rapid.Bool().Draw(t, "all or nothing")
+
+				// Book the model one member at a time, then undo if needed
+				want := make([]error, len(batch))
+				var booked []string
+				for i, r := range batch {
+					if want[i] = m.bookingError(r.UserID); want[i] == nil {
+						m.confirmed[r.UserID] = -1
+						booked = append(booked, r.UserID)
+					}
+				}
+				if allOrNothing && len(booked) < len(batch) {
+					for _, member := range booked {
+						delete(m.confirmed, member)
+					}
+					for i := range want {
+						if want[i] == nil {
+							want[i] = store.ErrBatchAborted
+						}
+					}
+				}
+
+				errs, err := repo.CreateReservations(ctx, batch, allOrNothing)
+				if err != nil {
+					t.Fatalf("CreateReservations(%d) failed: %v", m.id, err)
+				}
+				for i, r := range batch {
+					if errs[i] != want[i] {
+						t.Fatalf("CreateReservations(%d, %s, allOrNothing=%v): expected %v, got %v", m.id, r.UserID, allOrNothing, want[i], errs[i])
+					}
+					if errs[i] == nil {
+						m.confirmed[r.UserID] = r.ID
+					}
+				}
+			},
+			"cancel booking": func(m *sessionModel) {
+				if len(m.confirmed) == 0 {
+					return
+				}
+				booked := make([]string, 0, len(m.confirmed))
+				for member := range m.confirmed {
+					booked = append(booked, member)
+				}
+				sort.Strings(booked)
+				member := rapid.SampledFrom(booked).Draw(t, "booked member")
+
+				id := m.confirmed[member]
+				if _, err := repo.CancelReservation(ctx, id); err != nil {
+					t.Fatalf("CancelReservation(%d) failed: %v", id, err)
+				}
+				delete(m.confirmed, member)
+				if _, err := repo.CancelReservation(ctx, id); err != store.ErrAlreadyCancelled {
+					t.Fatalf("Second CancelReservation(%d): expected %v, got %v", id, store.ErrAlreadyCancelled, err)
+				}
+			},
+			"cancel session": func(m *sessionModel) {
+				_, err := repo.CancelSession(ctx, m.id, "Property test")
+				if m.cancelled {
+					if err != store.ErrAlreadyCancelled {
+						t.Fatalf("CancelSession(%d): expected %v, got %v", m.id, store.ErrAlreadyCancelled, err)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("CancelSession(%d) failed: %v", m.id, err)
+				}
+				m.cancelled = true
+			},
+			"reconcile": func(m *sessionModel) {
+				drift, err := repo.ReconcileReservedSpots(ctx)
+				if err != nil {
+					t.Fatalf("ReconcileReservedSpots failed: %v", err)
+				}
+				for _, d := range drift {
+					for _, s := range sessions {
+						if d.SessionID == s.id {
+							t.Fatalf("Reconciliation found drift in session %d: %+v", s.id, d)
+						}
+					}
+				}
+			},
+		}
+		names := make([]string, 0, len(actions))
+		for name := range actions {
+			names = append(names, name)
+		}
+		// Map order is random; rapid needs the same choices for the same draws
+		sort.Strings(names)
+
+		steps := rapid.IntRange(1, 30).Draw(t, "steps")
+		for i := 0; i < steps; i++ {
+			m := rapid.SampledFrom(sessions).Draw(t, "session")
+			actions[rapid.SampledFrom(names).Draw(t, "action")](m)
+			checkSession(ctx, t, repo, m)
+		}
+	}
+}
+
+// Error that booking m for member should return in its current state
+func (m *sessionModel) bookingError(member string) error {
+	switch {
+	case m.cancelled:
+		return store.ErrAlreadyCancelled
+	case m.confirmed[member] != 0:
+		return store.ErrAlreadyBooked
+	case int32(len(m.confirmed)) >= m.capacity:
+		return store.ErrSessionFull
+	}
+	return nil
+}
+
+// Number of n new members that can book m
+func (m *sessionModel) free(n int32) int32 {
+	if m.cancelled {
+		return 0
+	}
+	if left := m.capacity - int32(len(m.confirmed)); left < n {
+		return left
+	}
+	return n
+}
+
+// Compare the stored session and its reservations with the model
+func checkSession(ctx context.Context, t *rapid.T, repo store.Repository, m *sessionModel) {
+	s, err := repo.GetSession(ctx, m.id)
+	if err != nil {
+		t.Fatalf("GetSession(%d) failed: %v", m.id, err)
+	}
+	if s.ReservedSpots > s.Capacity {
+		t.Fatalf("Session %d overbooked: %d reserved for %d spots", m.id, s.ReservedSpots, s.Capacity)
+	}
+	if s.ReservedSpots != int32(len(m.confirmed)) || s.IsCancelled != m.cancelled {
+		t.Fatalf("Session %d: expected %d reserved, cancelled=%v; got %d, cancelled=%v",
+			m.id, len(m.confirmed), m.cancelled, s.ReservedSpots, s.IsCancelled)
+	}
+	for member, id := range m.confirmed {
+		r, err := repo.GetReservation(ctx, id)
+		if err != nil {
+			t.Fatalf("GetReservation(%d) failed: %v", id, err)
+		}
+		if r.SessionID != m.id || r.UserID != member || r.Status != store.ReservationConfirmed {
+			t.Fatalf("Reservation %d: expected confirmed booking of session %d by %s, got %+v", id, m.id, member, r)
+		}
+	}
+}
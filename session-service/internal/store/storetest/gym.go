@@ -0,0 +1,73 @@
+package storetest
+
+import (
+	"context"
+	"testing"
+
+	"session-service/internal/fixtures"
+	"session-service/internal/store"
+)
+
+// GymScope checks that calls scoped to a gym with store.WithGym neither see
+// nor change the sessions and reservations of other gyms, and that calls
+// without a gym see them all.
+func GymScope(t *testing.T, repo store.Repository) {
+	ctx := context.Background()
+	north := store.WithGym(ctx, "north")
+	south := store.WithGym(ctx, "south")
+
+	session, err := fixtures.NewTestSession().Create(north, repo)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	if session.GymID != "north" {
+		t.Errorf("Session created in gym north has gym %q", session.GymID)
+	}
+	other, err := fixtures.NewTestSession().Create(ctx, repo)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	if other.GymID != store.DefaultGym {
+		t.Errorf("Session created without a gym has gym %q", other.GymID)
+	}
+
+	r := &store.Reservation{SessionID: session.ID, UserID: "member-1", UserName: "Member"}
+	if err := repo.CreateReservation(south, r); err != store.ErrNotFound {
+		t.Errorf("Booking a session of another gym: expected %v, got %v", store.ErrNotFound, err)
+	}
+	if err := repo.CreateReservation(north, r); err != nil {
+		t.Fatalf("CreateReservation failed: %v", err)
+	}
+	if r.GymID != "north" {
+		t.Errorf("Reservation of a session in gym north has gym %q", r.GymID)
+	}
+
+	if _, err := repo.GetSession(south, session.ID); err != store.ErrNotFound {
+		t.Errorf("GetSession from another gym: expected %v, got %v", store.ErrNotFound, err)
+	}
+	if _, err := repo.GetReservation(south, r.ID); err != store.ErrNotFound {
+		t.Errorf("GetReservation from another gym: expected %v, got %v", store.ErrNotFound, err)
+	}
+	if _, err := repo.CancelReservation(south, r.ID); err != store.ErrNotFound {
+		t.Errorf("CancelReservation from another gym: expected %v, got %v", store.ErrNotFound, err)
+	}
+	if _, err := repo.CancelSession(south, session.ID, "Wrong gym"); err != store.ErrNotFound {
+		t.Errorf("CancelSession from another gym: expected %v, got %v", store.ErrNotFound, err)
+	}
+	if err := repo.DeleteSession(south, session.ID); err != store.ErrNotFound {
+		t.Errorf("DeleteSession from another gym: expected %v, got %v", store.ErrNotFound, err)
+	}
+
+	for _, c := range []context.Context{north, ctx} {
+		got, err := repo.GetSession(c, session.ID)
+		if err != nil || got.ReservedSpots != 1 || got.IsCancelled {
+			t.Errorf("Expected the session untouched with 1 booking, got %+v, %v", got, err)
+		}
+	}
+	if _, err := repo.CancelReservation(north, r.ID); err != nil {
+		t.Errorf("CancelReservation failed: %v", err)
+	}
+	if err := repo.DeleteSession(ctx, session.ID); err != nil {
+		t.Errorf("DeleteSession without a gym failed: %v", err)
+	}
+}
@@ -0,0 +1,121 @@
+package storetest
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"session-service/internal/fixtures"
+	"session-service/internal/store"
+)
+
+// Lifecycle checks that CompleteSessions completes the sessions that ended,
+// and only those, turns their reservations not checked in into no-shows and
+// locks them against bookings and cancellations.
+func Lifecycle(t *testing.T, repo store.Repository) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	ended, err := fixtures.NewTestSession().RelativeTo(now).StartingIn(-2*time.Hour).Create(ctx, repo)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	upcoming, err := fixtures.NewTestSession().RelativeTo(now).Create(ctx, repo)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	cancelled, err := fixtures.NewTestSession().RelativeTo(now).StartingIn(-2*time.Hour).
+		Cancelled("Coach is sick").Create(ctx, repo)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	attended := &store.Reservation{SessionID: ended.ID, UserID: "member-1", UserName: "Member"}
+	dropped := &store.Reservation{SessionID: ended.ID, UserID: "member-2", UserName: "Member"}
+	absent := &store.Reservation{SessionID: ended.ID, UserID: "member-4", UserName: "Member"}
+	for _, r := range []*store.Reservation{attended, dropped, absent} {
+		if err := repo.CreateReservation(ctx, r); err != nil {
+			t.Fatalf("CreateReservation failed: %v", err)
+		}
+	}
+	if _, err := repo.CancelReservation(ctx, dropped.ID); err != nil {
+		t.Fatalf("CancelReservation failed: %v", err)
+	}
+
+	checkedIn, err := repo.CheckIn(ctx, attended.ID)
+	if err != nil || checkedIn.Status != store.ReservationAttended {
+		t.Fatalf("Expected member-1 checked in, got %+v, %v", checkedIn, err)
+	}
+	if checkedIn, err := repo.CheckIn(ctx, attended.ID); err != nil || checkedIn.Status != store.ReservationAttended {
+		t.Errorf("Checking in twice: expected no change, got %+v, %v", checkedIn, err)
+	}
+	if _, err := repo.CheckIn(ctx, dropped.ID); err != store.ErrAlreadyCancelled {
+		t.Errorf("Checking in a cancelled reservation: expected %v, got %v", store.ErrAlreadyCancelled, err)
+	}
+	if _, err := repo.CheckIn(store.WithGym(ctx, "elsewhere"), absent.ID); err != store.ErrNotFound {
+		t.Errorf("Checking in the reservation of another gym: expected %v, got %v", store.ErrNotFound, err)
+	}
+	if _, err := repo.CheckIn(ctx, 999999); err != store.ErrNotFound {
+		t.Errorf("Checking in an unknown reservation: expected %v, got %v", store.ErrNotFound, err)
+	}
+	if _, err := repo.CancelReservation(ctx, attended.ID); err != store.ErrCheckedIn {
+		t.Errorf("Cancelling after checking in: expected %v, got %v", store.ErrCheckedIn, err)
+	}
+
+	completed, err := repo.CompleteSessions(store.WithGym(ctx, "elsewhere"), now)
+	if err != nil || len(completed) != 0 {
+		t.Errorf("Completing the sessions of another gym: expected none, got %v, %v", completed, err)
+	}
+	completed, err = repo.CompleteSessions(ctx, now)
+	if err != nil {
+		t.Fatalf("CompleteSessions failed: %v", err)
+	}
+	if !reflect.DeepEqual(completed, []int64{ended.ID}) {
+		t.Errorf("Expected session %d completed, got %v", ended.ID, completed)
+	}
+	if completed, err := repo.CompleteSessions(ctx, now); err != nil || len(completed) != 0 {
+		t.Errorf("Completing again: expected none, got %v, %v", completed, err)
+	}
+
+	got, err := repo.GetSession(ctx, ended.ID)
+	if err != nil || !got.IsCompleted || got.ReservedSpots != 2 {
+		t.Errorf("Expected the ended session completed with 2 reserved spots, got %+v, %v", got, err)
+	}
+	for _, id := range []int64{upcoming.ID, cancelled.ID} {
+		if got, err := repo.GetSession(ctx, id); err != nil || got.IsCompleted {
+			t.Errorf("Expected session %d not completed, got %+v, %v", id, got, err)
+		}
+	}
+	for r, want := range map[*store.Reservation]string{
+		attended: store.ReservationAttended,
+		dropped:  store.ReservationCancelled,
+		absent:   store.ReservationNoShow,
+	} {
+		if got, err := repo.GetReservation(ctx, r.ID); err != nil || got.Status != want {
+			t.Errorf("Expected reservation %d %s, got %+v, %v", r.ID, want, got, err)
+		}
+	}
+
+	late := &store.Reservation{SessionID: ended.ID, UserID: "member-3", UserName: "Member"}
+	if err := repo.CreateReservation(ctx, late); err != store.ErrSessionCompleted {
+		t.Errorf("Booking a completed session: expected %v, got %v", store.ErrSessionCompleted, err)
+	}
+	if _, err := repo.CancelReservation(ctx, absent.ID); err != store.ErrSessionCompleted {
+		t.Errorf("Cancelling a no-show: expected %v, got %v", store.ErrSessionCompleted, err)
+	}
+	if _, err := repo.CancelReservation(ctx, dropped.ID); err != store.ErrAlreadyCancelled {
+		t.Errorf("Cancelling a cancelled reservation: expected %v, got %v", store.ErrAlreadyCancelled, err)
+	}
+	if _, err := repo.CancelSession(ctx, ended.ID, "Too late"); err != store.ErrSessionCompleted {
+		t.Errorf("Cancelling a completed session: expected %v, got %v", store.ErrSessionCompleted, err)
+	}
+	if drift, err := repo.ReconcileReservedSpots(ctx); err != nil || len(drift) != 0 {
+		t.Errorf("Expected no-shows to keep their spot, got drift %v, %v", drift, err)
+	}
+
+	// Attendance taken after the session turns the no-show around
+	if checkedIn, err := repo.CheckIn(ctx, absent.ID); err != nil || checkedIn.Status != store.ReservationAttended {
+		t.Errorf("Expected the no-show checked in late, got %+v, %v", checkedIn, err)
+	}
+}
@@ -0,0 +1,164 @@
+package storetest
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"session-service/internal/fixtures"
+	"session-service/internal/store"
+)
+
+// ListAfter checks that ListSessionsAfter pages through the sessions by ID,
+// applies every field of the filter and stays in the gym of the call.
+func ListAfter(t *testing.T, repo store.Repository) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	builders := []*fixtures.SessionBuilder{
+		fixtures.NewTestSession().RelativeTo(now).StartingIn(time.Hour).OfType("yoga", "beginner"),
+		fixtures.NewTestSession().RelativeTo(now).StartingIn(-time.Hour).OfType("yoga", "beginner"),
+		fixtures.NewTestSession().RelativeTo(now).StartingIn(2*time.Hour).OfType("cardio", "beginner").WithCoach("coach-2", "Coach"),
+		fixtures.NewTestSession().RelativeTo(now).StartingIn(26*time.Hour).OfType("yoga", "advanced"),
+		fixtures.NewTestSession().RelativeTo(now).StartingIn(3*time.Hour).OfType("cardio", "beginner").InGym("north"),
+	}
+	ids := make([]int64, len(builders))
+	for i, b := range builders {
+		s, err := b.Create(ctx, repo)
+		if err != nil {
+			t.Fatalf("Failed to create session: %v", err)
+		}
+		ids[i] = s.ID
+	}
+
+	// Every page, read 2 sessions at a time
+	list := func(ctx context.Context, f store.SessionFilter) []int64 {
+		t.Helper()
+		var found []int64
+		var after int64
+		for {
+			page, err := repo.ListSessionsAfter(ctx, f, after, 2)
+			if err != nil {
+				t.Fatalf("ListSessionsAfter failed: %v", err)
+			}
+			if len(page) > 2 {
+				t.Fatalf("Expected at most 2 sessions, got %d", len(page))
+			}
+			for _, s := range page {
+				found = append(found, s.ID)
+			}
+			if len(page) < 2 {
+				return found
+			}
+			after = page[len(page)-1].ID
+		}
+	}
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		filter store.SessionFilter
+		want   []int64
+	}{
+		{"all", ctx, store.SessionFilter{}, ids},
+		{"type", ctx, store.SessionFilter{SessionType: "yoga"}, []int64{ids[0], ids[1], ids[3]}},
+		{"coach", ctx, store.SessionFilter{CoachID: "coach-2"}, []int64{ids[2]}},
+		{"upcoming", ctx, store.SessionFilter{StartsFrom: now}, []int64{ids[0], ids[2], ids[3], ids[4]}},
+		{"next day", ctx, store.SessionFilter{StartsFrom: now, StartsBefore: now.Add(24 * time.Hour)},
+			[]int64{ids[0], ids[2], ids[4]}},
+		{"several", ctx, store.SessionFilter{StartsFrom: now, SessionType: "yoga"}, []int64{ids[0], ids[3]}},
+		{"gym", store.WithGym(ctx, "north"), store.SessionFilter{}, []int64{ids[4]}},
+		{"none", ctx, store.SessionFilter{CoachID: "nobody"}, nil},
+	}
+	for _, tt := range tests {
+		if got := list(tt.ctx, tt.filter); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expected sessions %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+// List checks that ListSessions sorts by start time then ID, either way,
+// resumes after a cursor, and applies the filters.
+func List(t *testing.T, repo store.Repository) {
+	ctx := context.Background()
+	start := time.Date(2030, 5, 13, 8, 0, 0, 0, time.UTC)
+
+	builders := []*fixtures.SessionBuilder{
+		fixtures.NewTestSession().StartingAt(start.Add(48 * time.Hour)),
+		fixtures.NewTestSession().StartingAt(start).OfType("cardio", "advanced"),
+		fixtures.NewTestSession().StartingAt(start.Add(24 * time.Hour)).AtLocation("Studio B"),
+		fixtures.NewTestSession().StartingAt(start).Cancelled("Coach is sick"),
+		fixtures.NewTestSession().StartingAt(start.Add(-24*time.Hour)).WithCoach("coach-2", "Coach"),
+		fixtures.NewTestSession().StartingAt(start.Add(24 * time.Hour)).InGym("north"),
+	}
+	ids := make([]int64, len(builders))
+	for i, b := range builders {
+		s, err := b.Create(ctx, repo)
+		if err != nil {
+			t.Fatalf("Failed to create session: %v", err)
+		}
+		ids[i] = s.ID
+	}
+
+	// Every page, read 2 sessions at a time
+	list := func(ctx context.Context, f store.SessionFilter, descending bool) []int64 {
+		t.Helper()
+		var found []int64
+		var after *store.SessionCursor
+		for {
+			page, err := repo.ListSessions(ctx, f, after, descending, 2)
+			if err != nil {
+				t.Fatalf("ListSessions failed: %v", err)
+			}
+			for _, s := range page {
+				found = append(found, s.ID)
+			}
+			if len(page) < 2 {
+				return found
+			}
+			cursor := page[len(page)-1].Cursor()
+			after = &cursor
+		}
+	}
+
+	byStart := []int64{ids[4], ids[1], ids[3], ids[2], ids[5], ids[0]}
+	tests := []struct {
+		name       string
+		ctx        context.Context
+		filter     store.SessionFilter
+		descending bool
+		want       []int64
+	}{
+		{"all", ctx, store.SessionFilter{}, false, byStart},
+		{"descending", ctx, store.SessionFilter{}, true, []int64{ids[0], ids[5], ids[2], ids[3], ids[1], ids[4]}},
+		{"range", ctx, store.SessionFilter{StartsFrom: start, StartsBefore: start.Add(48 * time.Hour)}, false,
+			[]int64{ids[1], ids[3], ids[2], ids[5]}},
+		{"type", ctx, store.SessionFilter{SessionType: "cardio"}, false, []int64{ids[1]}},
+		{"difficulty", ctx, store.SessionFilter{DifficultyLevel: "advanced"}, false, []int64{ids[1]}},
+		{"coach", ctx, store.SessionFilter{CoachID: "coach-2"}, false, []int64{ids[4]}},
+		{"location", ctx, store.SessionFilter{Location: "Studio B"}, false, []int64{ids[2]}},
+		{"not cancelled", ctx, store.SessionFilter{ExcludeCancelled: true}, false,
+			[]int64{ids[4], ids[1], ids[2], ids[5], ids[0]}},
+		{"gym", store.WithGym(ctx, "north"), store.SessionFilter{}, false, []int64{ids[5]}},
+	}
+	for _, tt := range tests {
+		if got := list(tt.ctx, tt.filter, tt.descending); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expected sessions %v, got %v", tt.name, tt.want, got)
+		}
+	}
+
+	// A page resumes after its cursor even if that session is gone
+	first, err := repo.ListSessions(ctx, store.SessionFilter{}, nil, false, 3)
+	if err != nil || len(first) != 3 {
+		t.Fatalf("Expected 3 sessions, got %d, %v", len(first), err)
+	}
+	if err := repo.DeleteSession(ctx, first[2].ID); err != nil {
+		t.Fatalf("DeleteSession failed: %v", err)
+	}
+	cursor := first[2].Cursor()
+	next, err := repo.ListSessions(ctx, store.SessionFilter{}, &cursor, false, 1)
+	if err != nil || len(next) != 1 || next[0].ID != byStart[3] {
+		t.Errorf("Expected session %d after the deleted one, got %v, %v", byStart[3], next, err)
+	}
+}
@@ -0,0 +1,99 @@
+package storetest
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"session-service/internal/fixtures"
+	"session-service/internal/store"
+)
+
+// ListReservations checks that ListReservations pages through the
+// reservations by ID, applies every field of the filter and stays in the gym
+// of the call.
+func ListReservations(t *testing.T, repo store.Repository) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	upcoming, err := fixtures.NewTestSession().RelativeTo(now).StartingIn(time.Hour).Create(ctx, repo)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	past, err := fixtures.NewTestSession().RelativeTo(now).StartingIn(-2*time.Hour).Create(ctx, repo)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	north, err := fixtures.NewTestSession().RelativeTo(now).StartingIn(time.Hour).InGym("north").Create(ctx, repo)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	bookings := []struct {
+		ctx     context.Context
+		session *store.Session
+		user    string
+	}{
+		{ctx, upcoming, "user-1"},
+		{ctx, upcoming, "user-2"},
+		{ctx, past, "user-1"},
+		{ctx, upcoming, "user-3"},
+		{store.WithGym(ctx, "north"), north, "user-1"},
+	}
+	ids := make([]int64, len(bookings))
+	for i, b := range bookings {
+		r := &store.Reservation{SessionID: b.session.ID, UserID: b.user, UserName: "Member Name"}
+		if err := repo.CreateReservation(b.ctx, r); err != nil {
+			t.Fatalf("CreateReservation failed: %v", err)
+		}
+		ids[i] = r.ID
+	}
+	if _, err := repo.CancelReservation(ctx, ids[1]); err != nil {
+		t.Fatalf("CancelReservation failed: %v", err)
+	}
+
+	// Every page, read 2 reservations at a time
+	list := func(ctx context.Context, f store.ReservationFilter) []int64 {
+		t.Helper()
+		var found []int64
+		var after int64
+		for {
+			page, err := repo.ListReservations(ctx, f, after, 2)
+			if err != nil {
+				t.Fatalf("ListReservations failed: %v", err)
+			}
+			if len(page) > 2 {
+				t.Fatalf("Expected at most 2 reservations, got %d", len(page))
+			}
+			for _, r := range page {
+				found = append(found, r.ID)
+			}
+			if len(page) < 2 {
+				return found
+			}
+			after = page[len(page)-1].ID
+		}
+	}
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		filter store.ReservationFilter
+		want   []int64
+	}{
+		{"all", ctx, store.ReservationFilter{}, ids},
+		{"session", ctx, store.ReservationFilter{SessionID: upcoming.ID}, []int64{ids[0], ids[1], ids[3]}},
+		{"user", ctx, store.ReservationFilter{UserID: "user-1"}, []int64{ids[0], ids[2], ids[4]}},
+		{"status", ctx, store.ReservationFilter{SessionID: upcoming.ID, Status: store.ReservationConfirmed}, []int64{ids[0], ids[3]}},
+		{"not ended", ctx, store.ReservationFilter{UserID: "user-1", SessionsEndingAfter: now}, []int64{ids[0], ids[4]}},
+		{"gym", store.WithGym(ctx, "north"), store.ReservationFilter{UserID: "user-1"}, []int64{ids[4]}},
+		{"other gym", store.WithGym(ctx, "north"), store.ReservationFilter{SessionID: upcoming.ID}, nil},
+		{"none", ctx, store.ReservationFilter{UserID: "nobody"}, nil},
+	}
+	for _, tt := range tests {
+		if got := list(tt.ctx, tt.filter); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expected reservations %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
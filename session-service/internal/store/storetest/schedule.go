@@ -0,0 +1,171 @@
+package storetest
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"session-service/internal/fixtures"
+	"session-service/internal/store"
+)
+
+// Conflicts checks that a session checked for conflicts can't overlap
+// another one of the same coach or at the same location, even when created
+// concurrently, whatever the time zone of its times. Cancelled sessions,
+// sessions of other gyms and sessions back to back don't conflict.
+func Conflicts(t *testing.T, repo store.Repository) {
+	ctx := context.Background()
+	at := time.Date(2030, 5, 15, 8, 0, 0, 0, time.UTC)
+
+	existing, err := fixtures.NewTestSession().StartingAt(at).WithCoach("coach-1", "Sarah Johnson").AtLocation("Studio A").Create(ctx, repo)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	if _, err := fixtures.NewTestSession().StartingAt(at).WithCoach("coach-2", "Mike Chen").AtLocation("Studio B").
+		Cancelled("Coach is sick").Create(ctx, repo); err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	// Half past ten in Paris is half past eight UTC
+	paris := time.FixedZone("CEST", 2*60*60)
+	tests := map[string]struct {
+		session  *fixtures.SessionBuilder
+		conflict bool
+	}{
+		"same coach":       {fixtures.NewTestSession().StartingAt(at.Add(30*time.Minute)).WithCoach("coach-1", "").AtLocation("Studio B"), true},
+		"same location":    {fixtures.NewTestSession().StartingAt(at.Add(-30*time.Minute)).WithCoach("coach-2", "").AtLocation("Studio A"), true},
+		"inside":           {fixtures.NewTestSession().StartingAt(at.Add(15 * time.Minute)).Lasting(15 * time.Minute).AtLocation("Studio A"), true},
+		"other time zone":  {fixtures.NewTestSession().StartingAt(time.Date(2030, 5, 15, 10, 30, 0, 0, paris)).AtLocation("Studio A"), true},
+		"right after":      {fixtures.NewTestSession().StartingAt(at.Add(time.Hour)).AtLocation("Studio A"), false},
+		"right before":     {fixtures.NewTestSession().StartingAt(at.Add(-time.Hour)).AtLocation("Studio A"), false},
+		"elsewhere":        {fixtures.NewTestSession().StartingAt(at).WithCoach("coach-3", "").AtLocation("Studio C"), false},
+		"cancelled in way": {fixtures.NewTestSession().StartingAt(at).WithCoach("coach-2", "").AtLocation("Studio B"), false},
+		"other gym":        {fixtures.NewTestSession().StartingAt(at).InGym("gym-2"), false},
+	}
+	for name, tt := range tests {
+		s := tt.session.Build()
+		// Kept in the zone they were given in
+		if name == "other time zone" {
+			s.StartTime, s.EndTime = s.StartTime.In(paris), s.EndTime.In(paris)
+		}
+		err := repo.CreateSession(ctx, s, true)
+		var conflict *store.ConflictError
+		switch {
+		case tt.conflict && !errors.As(err, &conflict):
+			t.Errorf("%s: expected a conflict, got %v", name, err)
+		case tt.conflict && conflict.Session.ID != existing.ID:
+			t.Errorf("%s: expected a conflict with session %d, got %d", name, existing.ID, conflict.Session.ID)
+		case !tt.conflict && err != nil:
+			t.Errorf("%s: expected no conflict, got %v", name, err)
+		}
+		if !tt.conflict && err == nil {
+			// Out of the way of the next cases
+			if err := repo.DeleteSession(ctx, s.ID); err != nil {
+				t.Fatalf("DeleteSession failed: %v", err)
+			}
+		}
+	}
+
+	if err := repo.CreateSession(ctx, fixtures.NewTestSession().StartingAt(at).Build(), false); err != nil {
+		t.Errorf("Expected a conflict allowed without checking, got %v", err)
+	}
+
+	// Only one of the sessions created at once for the same room gets it
+	slot := at.Add(24 * time.Hour)
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = repo.CreateSession(ctx, fixtures.NewTestSession().StartingAt(slot).AtLocation("Studio D").Build(), true)
+		}(i)
+	}
+	wg.Wait()
+	created := 0
+	for _, err := range errs {
+		var conflict *store.ConflictError
+		switch {
+		case err == nil:
+			created++
+		case !errors.As(err, &conflict):
+			t.Errorf("Concurrent create: expected a conflict, got %v", err)
+		}
+	}
+	if created != 1 {
+		t.Errorf("Expected one of the concurrent sessions created, got %d", created)
+	}
+}
+
+// Update checks that UpdateSession replaces the fields of a session, keeps
+// its capacity above its reserved spots, books waitlisted members into the
+// spots added and checks conflicts like CreateSession.
+func Update(t *testing.T, repo store.Repository) {
+	ctx := context.Background()
+	at := time.Date(2030, 5, 15, 8, 0, 0, 0, time.UTC)
+
+	session, err := fixtures.NewTestSession().StartingAt(at).WithCapacity(2).Full().Create(ctx, repo)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	other, err := fixtures.NewTestSession().StartingAt(at.Add(2*time.Hour)).AtLocation("Studio B").Create(ctx, repo)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	for _, user := range []string{"member-3", "member-4", "member-5"} {
+		if err := repo.JoinWaitlist(ctx, &store.WaitlistEntry{SessionID: session.ID, UserID: user, UserName: "Member"}); err != nil {
+			t.Fatalf("JoinWaitlist of %s failed: %v", user, err)
+		}
+	}
+
+	change := *session
+	change.Title = "Evening Yoga"
+	change.Capacity = 1
+	if _, err := repo.UpdateSession(ctx, &change, true); err != store.ErrCapacityBelowReserved {
+		t.Errorf("Capacity below the reserved spots: expected ErrCapacityBelowReserved, got %v", err)
+	}
+
+	change.Capacity = 4
+	change.StartTime, change.EndTime = at.Add(time.Hour), at.Add(2*time.Hour)
+	updated, err := repo.UpdateSession(ctx, &change, true)
+	if err != nil {
+		t.Fatalf("UpdateSession failed: %v", err)
+	}
+	if updated.Title != "Evening Yoga" || updated.Capacity != 4 || !updated.StartTime.Equal(change.StartTime) {
+		t.Errorf("Expected the session updated, got %+v", updated)
+	}
+	if updated.ReservedSpots != 4 {
+		t.Errorf("Expected the 2 spots added booked from the waitlist, got %d reserved spots", updated.ReservedSpots)
+	}
+	rs, err := repo.ListReservations(ctx, store.ReservationFilter{SessionID: session.ID, UserID: "member-5", Status: store.ReservationConfirmed}, 0, 1)
+	if err != nil || len(rs) != 0 {
+		t.Errorf("Expected member-5 still waiting, got %v, %v", rs, err)
+	}
+
+	// The coach runs the other session from 10:00
+	change.StartTime, change.EndTime = at.Add(90*time.Minute), at.Add(150*time.Minute)
+	var conflict *store.ConflictError
+	if _, err := repo.UpdateSession(ctx, &change, true); !errors.As(err, &conflict) || conflict.Session.ID != other.ID {
+		t.Errorf("Expected a conflict with session %d, got %v", other.ID, err)
+	}
+	if _, err := repo.UpdateSession(ctx, &change, false); err != nil {
+		t.Errorf("Expected the conflict allowed without checking, got %v", err)
+	}
+
+	change.ID = other.ID + 1000
+	if _, err := repo.UpdateSession(ctx, &change, true); err != store.ErrNotFound {
+		t.Errorf("Missing session: expected ErrNotFound, got %v", err)
+	}
+	change.ID = session.ID
+	if _, err := repo.UpdateSession(store.WithGym(ctx, "elsewhere"), &change, true); err != store.ErrNotFound {
+		t.Errorf("Session of another gym: expected ErrNotFound, got %v", err)
+	}
+	if _, err := repo.CancelSession(ctx, session.ID, "Coach is sick"); err != nil {
+		t.Fatalf("CancelSession failed: %v", err)
+	}
+	if _, err := repo.UpdateSession(ctx, &change, true); err != store.ErrAlreadyCancelled {
+		t.Errorf("Cancelled session: expected ErrAlreadyCancelled, got %v", err)
+	}
+}
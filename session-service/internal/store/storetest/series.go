@@ -0,0 +1,167 @@
+package storetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"session-service/internal/fixtures"
+	"session-service/internal/store"
+)
+
+// Series checks that the occurrences of a series are created all or none,
+// and that changing a series changes the given occurrence and the later
+// ones still scheduled, moving them together.
+func Series(t *testing.T, repo store.Repository) {
+	ctx := context.Background()
+	at := time.Date(2030, 5, 15, 8, 0, 0, 0, time.UTC)
+	week := 7 * 24 * time.Hour
+	weekly := func(first time.Time, n int, b *fixtures.SessionBuilder) []*store.Session {
+		ss := make([]*store.Session, n)
+		for i := range ss {
+			ss[i] = b.StartingAt(first.Add(time.Duration(i) * week)).Build()
+		}
+		return ss
+	}
+
+	ss := weekly(at, 4, fixtures.NewTestSession())
+	if err := repo.CreateSessionSeries(ctx, ss, true); err != nil {
+		t.Fatalf("CreateSessionSeries failed: %v", err)
+	}
+	for _, s := range ss {
+		if s.ID == 0 || s.SeriesID == 0 || s.SeriesID != ss[0].SeriesID {
+			t.Errorf("Expected every occurrence stored in the same series, got %+v", s)
+		}
+	}
+	other := weekly(at, 2, fixtures.NewTestSession().WithCoach("coach-2", "Mike Chen").AtLocation("Studio B"))
+	if err := repo.CreateSessionSeries(ctx, other, true); err != nil {
+		t.Fatalf("CreateSessionSeries failed: %v", err)
+	}
+	if other[0].SeriesID == ss[0].SeriesID {
+		t.Errorf("Expected another series, got series %d twice", other[0].SeriesID)
+	}
+
+	// The second occurrence is run by the coach of the first series
+	overlapping := weekly(at.Add(-week+30*time.Minute), 2, fixtures.NewTestSession().AtLocation("Studio C"))
+	var conflict *store.ConflictError
+	if err := repo.CreateSessionSeries(ctx, overlapping, true); !errors.As(err, &conflict) || conflict.Session.ID != ss[0].ID {
+		t.Errorf("Expected a conflict with session %d, got %v", ss[0].ID, err)
+	}
+	if found, err := repo.ListSessions(ctx, store.SessionFilter{Location: "Studio C"}, nil, false, 10); err != nil || len(found) != 0 {
+		t.Errorf("Expected no occurrence of a conflicting series stored, got %d, %v", len(found), err)
+	}
+	conflicts, err := repo.FindConflicts(ctx, overlapping)
+	if err != nil || len(conflicts) != 1 || conflicts[0].ID != ss[0].ID {
+		t.Errorf("Expected session %d in the way, got %v, %v", ss[0].ID, conflicts, err)
+	}
+	otherGym := weekly(at.Add(-week+30*time.Minute), 2, fixtures.NewTestSession().InGym("elsewhere"))
+	if conflicts, err := repo.FindConflicts(ctx, otherGym); err != nil || len(conflicts) != 0 {
+		t.Errorf("Expected no conflict in another gym, got %v, %v", conflicts, err)
+	}
+
+	found, err := repo.ListSessions(ctx, store.SessionFilter{SeriesID: ss[0].SeriesID}, nil, false, 10)
+	if err != nil {
+		t.Fatalf("ListSessions failed: %v", err)
+	}
+	if len(found) != len(ss) {
+		t.Fatalf("Expected the %d occurrences of the series, got %d", len(ss), len(found))
+	}
+	for i, s := range found {
+		if s.ID != ss[i].ID {
+			t.Errorf("Occurrence %d: expected session %d, got %d", i, ss[i].ID, s.ID)
+		}
+	}
+
+	for _, user := range []string{"member-1", "member-2"} {
+		if err := repo.CreateReservation(ctx, &store.Reservation{SessionID: ss[3].ID, UserID: user, UserName: "Member"}); err != nil {
+			t.Fatalf("CreateReservation failed: %v", err)
+		}
+	}
+	change := *ss[1]
+	change.Capacity = 1
+	if _, err := repo.UpdateSessionSeries(ctx, &change, true); err != store.ErrCapacityBelowReserved {
+		t.Errorf("Capacity below the reserved spots of a later occurrence: expected ErrCapacityBelowReserved, got %v", err)
+	}
+
+	// Each occurrence takes the slot of the next one
+	change = *ss[0]
+	change.Title = "Weekly Yoga"
+	change.StartTime, change.EndTime = ss[0].StartTime.Add(week), ss[0].EndTime.Add(week)
+	updated, err := repo.UpdateSessionSeries(ctx, &change, true)
+	if err != nil {
+		t.Fatalf("UpdateSessionSeries failed: %v", err)
+	}
+	if len(updated) != len(ss) {
+		t.Fatalf("Expected the %d occurrences updated, got %d", len(ss), len(updated))
+	}
+	for i, s := range updated {
+		if s.ID != ss[i].ID || s.Title != "Weekly Yoga" || !s.StartTime.Equal(ss[i].StartTime.Add(week)) || !s.EndTime.Equal(ss[i].EndTime.Add(week)) {
+			t.Errorf("Occurrence %d: expected it moved a week later, got %+v", i, s)
+		}
+	}
+
+	// Moved an hour later, the second occurrence would overlap a session
+	blocker, err := fixtures.NewTestSession().StartingAt(updated[1].StartTime.Add(time.Hour)).WithCoach("coach-3", "Alex Rivera").Create(ctx, repo)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	change = *updated[0]
+	change.StartTime, change.EndTime = change.StartTime.Add(time.Hour), change.EndTime.Add(time.Hour)
+	if _, err := repo.UpdateSessionSeries(ctx, &change, true); !errors.As(err, &conflict) || conflict.Session.ID != blocker.ID {
+		t.Errorf("Expected a conflict with session %d, got %v", blocker.ID, err)
+	}
+	if s, err := repo.GetSession(ctx, ss[0].ID); err != nil || !s.StartTime.Equal(updated[0].StartTime) {
+		t.Errorf("Expected the series left as it was after a conflict, got %+v, %v", s, err)
+	}
+
+	// Cancelled occurrences and the ones before are left alone
+	if _, err := repo.CancelSession(ctx, ss[2].ID, "Holiday"); err != nil {
+		t.Fatalf("CancelSession failed: %v", err)
+	}
+	change = *updated[1]
+	change.Description = "Bring a mat"
+	updated, err = repo.UpdateSessionSeries(ctx, &change, true)
+	if err != nil {
+		t.Fatalf("UpdateSessionSeries failed: %v", err)
+	}
+	if len(updated) != 2 || updated[0].ID != ss[1].ID || updated[1].ID != ss[3].ID || updated[1].Description != "Bring a mat" {
+		t.Errorf("Expected occurrences %d and %d updated, got %+v", ss[1].ID, ss[3].ID, updated)
+	}
+	if s, err := repo.GetSession(ctx, ss[0].ID); err != nil || s.Description == "Bring a mat" {
+		t.Errorf("Expected the first occurrence left alone, got %+v, %v", s, err)
+	}
+
+	elsewhere := store.WithGym(ctx, "elsewhere")
+	if _, err := repo.UpdateSessionSeries(elsewhere, &change, true); err != store.ErrNotFound {
+		t.Errorf("Series of another gym: expected ErrNotFound, got %v", err)
+	}
+	if _, err := repo.CancelSessionSeries(elsewhere, ss[1].ID, "Coach left"); err != store.ErrNotFound {
+		t.Errorf("Series of another gym: expected ErrNotFound, got %v", err)
+	}
+
+	cancelled, err := repo.CancelSessionSeries(ctx, ss[1].ID, "Coach left")
+	if err != nil {
+		t.Fatalf("CancelSessionSeries failed: %v", err)
+	}
+	if len(cancelled) != 2 || cancelled[0].ID != ss[1].ID || cancelled[1].ID != ss[3].ID {
+		t.Errorf("Expected occurrences %d and %d cancelled, got %+v", ss[1].ID, ss[3].ID, cancelled)
+	}
+	for _, s := range cancelled {
+		if !s.IsCancelled || s.CancellationReason != "Coach left" {
+			t.Errorf("Expected session %d cancelled, got %+v", s.ID, s)
+		}
+	}
+	if s, err := repo.GetSession(ctx, ss[0].ID); err != nil || s.IsCancelled {
+		t.Errorf("Expected the first occurrence still scheduled, got %+v, %v", s, err)
+	}
+	if _, err := repo.CancelSessionSeries(ctx, ss[1].ID, "Coach left"); err != store.ErrAlreadyCancelled {
+		t.Errorf("Cancelled occurrence: expected ErrAlreadyCancelled, got %v", err)
+	}
+
+	// A session outside any series is a series of its own
+	cancelled, err = repo.CancelSessionSeries(ctx, blocker.ID, "Coach is sick")
+	if err != nil || len(cancelled) != 1 || cancelled[0].ID != blocker.ID {
+		t.Errorf("Expected only session %d cancelled, got %+v, %v", blocker.ID, cancelled, err)
+	}
+}
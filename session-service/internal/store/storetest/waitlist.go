@@ -0,0 +1,144 @@
+package storetest
+
+import (
+	"context"
+	"testing"
+
+	"session-service/internal/fixtures"
+	"session-service/internal/store"
+)
+
+// Waitlist checks who may join a waitlist, that sessions taking queued
+// bookings have none, and that cancelling a reservation books the first
+// member waiting in the freed spot.
+func Waitlist(t *testing.T, repo store.Repository) {
+	ctx := context.Background()
+
+	session, err := fixtures.NewTestSession().WithCapacity(2).Create(ctx, repo)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	open, err := fixtures.NewTestSession().Create(ctx, repo)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	cancelled, err := fixtures.NewTestSession().Cancelled("Coach is sick").Create(ctx, repo)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	// Full, yet its freed spots go to the queue
+	queued, err := fixtures.NewTestSession().WithCapacity(1).Queued().Create(ctx, repo)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	if err := repo.CreateReservation(ctx, &store.Reservation{SessionID: queued.ID, UserID: "member-1", UserName: "Member Name"}); err != nil {
+		t.Fatalf("CreateReservation failed: %v", err)
+	}
+
+	book := func(user string) *store.Reservation {
+		t.Helper()
+		r := &store.Reservation{SessionID: session.ID, UserID: user, UserName: "Member Name"}
+		if err := repo.CreateReservation(ctx, r); err != nil {
+			t.Fatalf("CreateReservation of %s failed: %v", user, err)
+		}
+		return r
+	}
+	join := func(sessionID int64, user string) (*store.WaitlistEntry, error) {
+		e := &store.WaitlistEntry{SessionID: sessionID, UserID: user, UserName: "Member Name"}
+		return e, repo.JoinWaitlist(ctx, e)
+	}
+	spots := func(want int32) {
+		t.Helper()
+		s, err := repo.GetSession(ctx, session.ID)
+		if err != nil {
+			t.Fatalf("GetSession failed: %v", err)
+		}
+		if s.ReservedSpots != want {
+			t.Errorf("Expected %d reserved spots, got %d", want, s.ReservedSpots)
+		}
+	}
+	confirmed := func(user string) bool {
+		t.Helper()
+		rs, err := repo.ListReservations(ctx, store.ReservationFilter{SessionID: session.ID, UserID: user, Status: store.ReservationConfirmed}, 0, 1)
+		if err != nil {
+			t.Fatalf("ListReservations failed: %v", err)
+		}
+		return len(rs) == 1
+	}
+
+	first := book("member-1")
+	if _, err := join(session.ID, "member-3"); err != store.ErrSessionNotFull {
+		t.Errorf("Joining the waitlist of a session with spots left: expected ErrSessionNotFull, got %v", err)
+	}
+	book("member-2")
+
+	for i, user := range []string{"member-3", "member-4", "member-5"} {
+		e, err := join(session.ID, user)
+		if err != nil {
+			t.Fatalf("JoinWaitlist of %s failed: %v", user, err)
+		}
+		if e.ID == 0 || e.Position != int32(i+1) || e.GymID != store.DefaultGym {
+			t.Errorf("Unexpected waitlist entry %+v", e)
+		}
+	}
+
+	errs := map[string]struct {
+		sessionID int64
+		user      string
+		want      error
+	}{
+		"twice":             {session.ID, "member-3", store.ErrAlreadyWaitlisted},
+		"booked":            {session.ID, "member-1", store.ErrAlreadyBooked},
+		"spots left":        {open.ID, "member-3", store.ErrSessionNotFull},
+		"cancelled session": {cancelled.ID, "member-3", store.ErrAlreadyCancelled},
+		"queued session":    {queued.ID, "member-3", store.ErrQueuedBooking},
+		"unknown session":   {999999, "member-3", store.ErrNotFound},
+	}
+	for name, tt := range errs {
+		if _, err := join(tt.sessionID, tt.user); err != tt.want {
+			t.Errorf("%s: expected %v, got %v", name, tt.want, err)
+		}
+	}
+	if _, err := join(session.ID, "member-6"); err != nil {
+		t.Fatalf("JoinWaitlist failed: %v", err)
+	}
+	if err := repo.LeaveWaitlist(store.WithGym(ctx, "north"), session.ID, "member-6"); err != store.ErrNotFound {
+		t.Errorf("Leaving the waitlist of another gym: expected ErrNotFound, got %v", err)
+	}
+	if err := repo.LeaveWaitlist(ctx, session.ID, "member-6"); err != nil {
+		t.Errorf("LeaveWaitlist failed: %v", err)
+	}
+	if err := repo.LeaveWaitlist(ctx, session.ID, "member-6"); err != store.ErrNotFound {
+		t.Errorf("Leaving the waitlist twice: expected ErrNotFound, got %v", err)
+	}
+
+	// member-3 waited first and gets the spot; member-4 now waits first
+	if _, err := repo.CancelReservation(ctx, first.ID); err != nil {
+		t.Fatalf("CancelReservation failed: %v", err)
+	}
+	spots(2)
+	if !confirmed("member-3") || confirmed("member-4") {
+		t.Errorf("Expected member-3 promoted and member-4 still waiting")
+	}
+	if err := repo.LeaveWaitlist(ctx, session.ID, "member-3"); err != store.ErrNotFound {
+		t.Errorf("Expected the promoted member off the waitlist, got %v", err)
+	}
+	if e, err := join(session.ID, "member-1"); err != nil || e.Position != 3 {
+		t.Errorf("Expected member-1 to wait third after cancelling, got %+v, %v", e, err)
+	}
+
+	// With nobody waiting, a cancellation frees the spot
+	for _, user := range []string{"member-4", "member-5", "member-1"} {
+		if err := repo.LeaveWaitlist(ctx, session.ID, user); err != nil {
+			t.Fatalf("LeaveWaitlist of %s failed: %v", user, err)
+		}
+	}
+	promoted, err := repo.ListReservations(ctx, store.ReservationFilter{SessionID: session.ID, UserID: "member-3"}, 0, 1)
+	if err != nil || len(promoted) != 1 {
+		t.Fatalf("Expected the reservation of member-3, got %v, %v", promoted, err)
+	}
+	if _, err := repo.CancelReservation(ctx, promoted[0].ID); err != nil {
+		t.Fatalf("CancelReservation failed: %v", err)
+	}
+	spots(1)
+}
@@ -0,0 +1,48 @@
+package users_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"session-service/internal/clock"
+	"session-service/internal/users"
+	"session-service/internal/users/usersmock"
+)
+
+func TestCache(t *testing.T) {
+	dir := &usersmock.DirectoryMock{
+		GetUserFunc: func(ctx context.Context, id, token string) (*users.User, error) {
+			if id == "nobody" {
+				return nil, users.ErrNotFound
+			}
+			return &users.User{ID: id, FirstName: "Sarah", Role: "coach"}, nil
+		},
+	}
+	clk := clock.NewFake(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	c := users.NewCache(dir, time.Minute, clk)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if _, err := c.GetUser(ctx, "coach-1", ""); err != nil {
+			t.Fatalf("GetUser failed: %v", err)
+		}
+	}
+	if n := len(dir.GetUserCalls()); n != 1 {
+		t.Errorf("Expected one lookup, got %d", n)
+	}
+	clk.Advance(time.Minute)
+	c.GetUser(ctx, "coach-1", "")
+	if n := len(dir.GetUserCalls()); n != 2 {
+		t.Errorf("Expected a new lookup once expired, got %d", n)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.GetUser(ctx, "nobody", ""); err != users.ErrNotFound {
+			t.Errorf("Expected ErrNotFound, got %v", err)
+		}
+	}
+	if n := len(dir.GetUserCalls()); n != 4 {
+		t.Errorf("Expected users not found asked for again, got %d lookups", n)
+	}
+}
@@ -0,0 +1,171 @@
+// Package users looks up users in the user service, which sessions ask for
+// the name of their coach. The user service serves HTTP; Client calls it
+// with the caller's token, retrying failures that may not last, and Cache
+// keeps the users found for a while.
+package users
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"session-service/internal/clock"
+)
+
+// ErrNotFound is returned for a user the user service doesn't know.
+var ErrNotFound = errors.New("user not found")
+
+// ErrUnavailable is wrapped by the errors of a user service that can't be
+// reached or fails, after retrying.
+var ErrUnavailable = errors.New("user service unavailable")
+
+// User is what the sessions need to know about a user.
+type User struct {
+	ID        string `json:"_id"`
+	FirstName string `json:"firstName"`
+	LastName  string `json:"lastName"`
+	Role      string `json:"role"`
+}
+
+// Name returns the full name of the user.
+func (u *User) Name() string {
+	return strings.TrimSpace(u.FirstName + " " + u.LastName)
+}
+
+// Directory finds users by ID, calling the user service with token, the
+// bearer token of the caller.
+//
+//go:generate go run github.com/matryer/moq@v0.2.7 -out usersmock/directory.go -pkg usersmock . Directory
+type Directory interface {
+	GetUser(ctx context.Context, id, token string) (*User, error)
+}
+
+// Client is a Directory calling the user service.
+type Client struct {
+	baseURL string
+	http    *http.Client
+	// Tries of a call, and pause before the second one, doubled after each
+	attempts int
+	backoff  time.Duration
+}
+
+// NewClient returns a Client of the user service at addr, host:port or a
+// URL, giving up on each attempt of a call after timeout.
+func NewClient(addr string, timeout time.Duration) *Client {
+	if !strings.Contains(addr, "://") {
+		addr = "http://" + addr
+	}
+	return &Client{
+		baseURL:  strings.TrimSuffix(addr, "/"),
+		http:     &http.Client{Timeout: timeout},
+		attempts: 3,
+		backoff:  100 * time.Millisecond,
+	}
+}
+
+// GetUser returns the user with the given ID. Network errors and server
+// errors are retried; a refused token is not, and is reported as
+// ErrUnavailable like them.
+func (c *Client) GetUser(ctx context.Context, id, token string) (*User, error) {
+	backoff := c.backoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		var user *User
+		var retry bool
+		user, retry, err = c.getUser(ctx, id, token)
+		if !retry || attempt == c.attempts {
+			return user, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// One attempt of GetUser, and whether a failure may be retried
+func (c *Client) getUser(ctx context.Context, id, token string) (*User, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/api/users/"+url.PathEscape(id), nil)
+	if err != nil {
+		return nil, false, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, ctx.Err() == nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, false, ErrNotFound
+	case resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests:
+		return nil, true, fmt.Errorf("%w: %s", ErrUnavailable, resp.Status)
+	case resp.StatusCode != http.StatusOK:
+		return nil, false, fmt.Errorf("%w: %s", ErrUnavailable, resp.Status)
+	}
+	var user User
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
+		return nil, false, fmt.Errorf("%w: decoding user: %v", ErrUnavailable, err)
+	}
+	return &user, false, nil
+}
+
+// Cache is a Directory keeping the users another one found for a while.
+// Users not found are asked for again. It is safe for concurrent use.
+type Cache struct {
+	directory Directory
+	ttl       time.Duration
+	clock     clock.Clock
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	user    *User
+	expires time.Time
+}
+
+// NewCache returns a Cache of directory keeping users for ttl.
+func NewCache(directory Directory, ttl time.Duration, clk clock.Clock) *Cache {
+	return &Cache{directory: directory, ttl: ttl, clock: clk, entries: make(map[string]cacheEntry)}
+}
+
+// GetUser returns the cached user with the given ID, or finds it.
+func (c *Cache) GetUser(ctx context.Context, id, token string) (*User, error) {
+	c.mu.Lock()
+	e, ok := c.entries[id]
+	c.mu.Unlock()
+	now := c.clock.Now()
+	if ok && now.Before(e.expires) {
+		return e.user, nil
+	}
+
+	user, err := c.directory.GetUser(ctx, id, token)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Expired entries are dropped as they are replaced, or all at once
+	// once too many pile up
+	if len(c.entries) >= maxCacheEntries {
+		c.entries = make(map[string]cacheEntry)
+	}
+	c.entries[id] = cacheEntry{user: user, expires: now.Add(c.ttl)}
+	return user, nil
+}
+
+// Most users a Cache holds
+const maxCacheEntries = 10000
@@ -0,0 +1,78 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClientGetUser(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if got := r.Header.Get("Authorization"); got != "Bearer token" {
+			t.Errorf("Expected the caller's token, got %q", got)
+		}
+		switch r.URL.Path {
+		case "/api/users/coach-1":
+			w.Write([]byte(`{"_id":"coach-1","firstName":"Sarah","lastName":"Johnson","email":"sarah@example.com","role":"coach"}`))
+		default:
+			http.Error(w, `{"message":"User not found"}`, http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	c := NewClient(strings.TrimPrefix(srv.URL, "http://"), time.Second)
+
+	user, err := c.GetUser(context.Background(), "coach-1", "token")
+	if err != nil || user.Name() != "Sarah Johnson" || user.Role != "coach" {
+		t.Errorf("Expected coach Sarah Johnson, got %+v, %v", user, err)
+	}
+	if _, err := c.GetUser(context.Background(), "nobody", "token"); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+	if calls := atomic.LoadInt32(&calls); calls != 2 {
+		t.Errorf("Expected no retries, got %d calls", calls)
+	}
+}
+
+func TestClientRetries(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			http.Error(w, "overloaded", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"_id":"coach-1","firstName":"Sarah","lastName":"Johnson","role":"coach"}`))
+	}))
+	defer srv.Close()
+	c := NewClient(srv.URL, time.Second)
+	c.backoff = time.Millisecond
+
+	if user, err := c.GetUser(context.Background(), "coach-1", ""); err != nil || user.ID != "coach-1" {
+		t.Errorf("Expected the user on the third try, got %+v, %v", user, err)
+	}
+
+	atomic.StoreInt32(&calls, -10)
+	if _, err := c.GetUser(context.Background(), "coach-1", ""); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Expected ErrUnavailable after the last try, got %v", err)
+	}
+	if got := atomic.LoadInt32(&calls); got != -7 {
+		t.Errorf("Expected 3 tries, got %d", got+10)
+	}
+}
+
+func TestClientUnreachable(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+	c := NewClient(srv.URL, time.Second)
+	c.backoff = time.Millisecond
+
+	if _, err := c.GetUser(context.Background(), "coach-1", ""); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Expected ErrUnavailable, got %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+// Package watch tells the watchers of a session that it may have changed, so
+// that they read it again instead of polling. A notification carries no
+// data, and the ones a watcher has yet to receive coalesce into one: a
+// burst of bookings costs each watcher a single read, and a slow watcher
+// never holds up the writer notifying it.
+package watch
+
+import "sync"
+
+// Hub keeps track of the watchers of every session. It is safe for
+// concurrent use.
+type Hub struct {
+	mu       sync.Mutex
+	watchers map[int64]map[*Watcher]struct{}
+}
+
+// Watcher is one watch of a session.
+type Watcher struct {
+	hub     *Hub
+	id      int64
+	changed chan struct{}
+}
+
+// NewHub returns a Hub without watchers.
+func NewHub() *Hub {
+	return &Hub{watchers: make(map[int64]map[*Watcher]struct{})}
+}
+
+// Watch starts watching the session with the given ID. Call Stop when done.
+func (h *Hub) Watch(id int64) *Watcher {
+	w := &Watcher{hub: h, id: id, changed: make(chan struct{}, 1)}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.watchers[id] == nil {
+		h.watchers[id] = make(map[*Watcher]struct{})
+	}
+	h.watchers[id][w] = struct{}{}
+	return w
+}
+
+// Notify tells the watchers of the sessions that they may have changed, or
+// those of every session if ids is empty, e.g. after notifications may
+// have been lost.
+func (h *Hub) Notify(ids ...int64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(ids) == 0 {
+		for _, watchers := range h.watchers {
+			notify(watchers)
+		}
+		return
+	}
+	for _, id := range ids {
+		notify(h.watchers[id])
+	}
+}
+
+// Watching returns the number of watchers.
+func (h *Hub) Watching() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	n := 0
+	for _, watchers := range h.watchers {
+		n += len(watchers)
+	}
+	return n
+}
+
+// Signal the watchers without waiting for the ones already signalled
+func notify(watchers map[*Watcher]struct{}) {
+	for w := range watchers {
+		select {
+		case w.changed <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Changed receives a value once the session may have changed since the
+// previous value was received, or since Watch.
+func (w *Watcher) Changed() <-chan struct{} {
+	return w.changed
+}
+
+// Stop ends the watch.
+func (w *Watcher) Stop() {
+	w.hub.mu.Lock()
+	defer w.hub.mu.Unlock()
+
+	delete(w.hub.watchers[w.id], w)
+	if len(w.hub.watchers[w.id]) == 0 {
+		delete(w.hub.watchers, w.id)
+	}
+}
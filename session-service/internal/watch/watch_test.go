@@ -0,0 +1,78 @@
+package watch_test
+
+import (
+	"testing"
+
+	"session-service/internal/watch"
+)
+
+// Whether w was notified, consuming the notification
+func notified(w *watch.Watcher) bool {
+	select {
+	case <-w.Changed():
+		return true
+	default:
+		return false
+	}
+}
+
+func TestNotify(t *testing.T) {
+	h := watch.NewHub()
+	first, second, other := h.Watch(1), h.Watch(1), h.Watch(2)
+	defer first.Stop()
+	defer second.Stop()
+	defer other.Stop()
+
+	h.Notify(1)
+	if !notified(first) || !notified(second) {
+		t.Errorf("Expected both watchers of session 1 notified")
+	}
+	if notified(other) {
+		t.Errorf("Watcher of session 2 notified of session 1")
+	}
+
+	h.Notify()
+	for i, w := range []*watch.Watcher{first, second, other} {
+		if !notified(w) {
+			t.Errorf("Watcher %d not notified of every session", i)
+		}
+	}
+}
+
+func TestNotificationsCoalesce(t *testing.T) {
+	h := watch.NewHub()
+	w := h.Watch(1)
+	defer w.Stop()
+
+	// A watcher that doesn't read never blocks Notify
+	for i := 0; i < 100; i++ {
+		h.Notify(1)
+	}
+	if !notified(w) {
+		t.Fatalf("Watcher not notified")
+	}
+	if notified(w) {
+		t.Errorf("Expected pending notifications to coalesce into one")
+	}
+}
+
+func TestStop(t *testing.T) {
+	h := watch.NewHub()
+	first, second := h.Watch(1), h.Watch(1)
+	if n := h.Watching(); n != 2 {
+		t.Errorf("Expected 2 watchers, got %d", n)
+	}
+
+	first.Stop()
+	h.Notify(1)
+	if notified(first) {
+		t.Errorf("Stopped watcher notified")
+	}
+	if !notified(second) {
+		t.Errorf("Remaining watcher not notified")
+	}
+	second.Stop()
+	if n := h.Watching(); n != 0 {
+		t.Errorf("Expected no watchers left, got %d", n)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"time"
+
+	"session-service/internal/clock"
+	"session-service/internal/jobs"
+	"session-service/internal/leader"
+	"session-service/internal/store"
+)
+
+// Advisory lock held by the replica running the background jobs
+const backgroundJobsLock = "session-service background jobs"
+
+// Build the scheduler of the background jobs enabled by the environment
+func backgroundJobs(repo store.Repository) (*jobs.Scheduler, error) {
+	clk := clock.Real{}
+	scheduler := jobs.NewScheduler(clk)
+
+	if interval := durationEnv("RECONCILE_INTERVAL", defaultReconcileInterval); interval > 0 {
+		err := scheduler.Register(jobs.Job{
+			Name:     "reconcile-reserved-spots",
+			Schedule: jobs.Every(interval),
+			Jitter:   interval / 10,
+			Retries:  2,
+			Timeout:  time.Minute,
+			Run: func(ctx context.Context) error {
+				return reconcileReservedSpots(ctx, repo)
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if expr := os.Getenv("SESSION_COMPLETION_SCHEDULE"); expr != "off" {
+		if expr == "" {
+			expr = defaultCompletionSchedule
+		}
+		schedule, err := jobs.ParseCron(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SESSION_COMPLETION_SCHEDULE: %w", err)
+		}
+		err = scheduler.Register(jobs.Job{
+			Name:     "complete-sessions",
+			Schedule: schedule,
+			Jitter:   10 * time.Second,
+			Retries:  2,
+			Timeout:  time.Minute,
+			Run: func(ctx context.Context) error {
+				return completeSessions(ctx, repo, clk.Now())
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return scheduler, nil
+}
+
+// Run work on one replica at a time: only while this one is the leader, if
+// replicas share db, or right away in development mode where there is no
+// database and a single replica
+func runSingleton(ctx context.Context, db *sql.DB, work func(ctx context.Context)) {
+	if db == nil {
+		work(ctx)
+		return
+	}
+	leader.New(db, backgroundJobsLock).Run(ctx, work)
+}
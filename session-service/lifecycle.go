@@ -0,0 +1,25 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"session-service/internal/store"
+)
+
+// Schedule of the session completion unless SESSION_COMPLETION_SCHEDULE is set
+const defaultCompletionSchedule = "*/5 * * * *"
+
+// Complete the sessions that ended by now, which locks them and turns their
+// confirmed reservations into no-shows, so reports see a final state
+func completeSessions(ctx context.Context, repo store.SessionRepository, now time.Time) error {
+	completed, err := repo.CompleteSessions(ctx, now)
+	if err != nil {
+		return err
+	}
+	if len(completed) > 0 {
+		log.Printf("Completed %d sessions: %v", len(completed), completed)
+	}
+	return nil
+}
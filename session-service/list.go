@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"session-service/internal/store"
+	pb "session-service/proto"
+)
+
+// Content of a page token: the position of the last item of the page, and a
+// hash of the request, so that the token only continues the list it was
+// issued for. The start time is kept as seconds and nanoseconds, as Unix
+// nanoseconds overflow after 2262. Lists ordered by ID alone leave it unset.
+type pageToken struct {
+	StartSeconds int64  `json:"s,omitempty"`
+	StartNanos   int32  `json:"ns,omitempty"`
+	ID           int64  `json:"id"`
+	Query        uint64 `json:"q"`
+}
+
+// Hash of the request fields that select and order the items of a list. The
+// page size may change from one page to the next, so it is left out.
+func queryHash(fields ...interface{}) uint64 {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%#v", fields)
+	return h.Sum64()
+}
+
+// Hash of what selects and orders the sessions of a ListSessions request
+func listQueryHash(req *pb.ListSessionsRequest) uint64 {
+	return queryHash(req.Date, req.SessionType, req.CoachId, req.IncludePast, req.StartFrom, req.StartBefore,
+		req.DifficultyLevel, req.Location, req.ExcludeCancelled, req.Descending, req.SeriesId)
+}
+
+func encodePageToken(token pageToken) string {
+	data, _ := json.Marshal(token)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Decode the page token of a request whose query hashes to query. It
+// returns nil for the first page.
+func decodePageToken(encoded string, query uint64) (*pageToken, error) {
+	if encoded == "" {
+		return nil, nil
+	}
+	var token pageToken
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err == nil {
+		err = json.Unmarshal(data, &token)
+	}
+	if err != nil || token.ID == 0 {
+		return nil, status.Error(codes.InvalidArgument, "Invalid page_token")
+	}
+	if token.Query != query {
+		return nil, status.Error(codes.InvalidArgument, "Invalid page_token: the filters or order changed since the previous page")
+	}
+	return &token, nil
+}
+
+// Implementation of ListSessions RPC
+func (s *server) ListSessions(ctx context.Context, req *pb.ListSessionsRequest) (*pb.ListSessionsResponse, error) {
+	now := s.clock.Now()
+	filter, pageSize, err := validateListSessions(req, now)
+	if err != nil {
+		return nil, err
+	}
+	token, err := decodePageToken(req.PageToken, listQueryHash(req))
+	if err != nil {
+		return nil, err
+	}
+	var after *store.SessionCursor
+	if token != nil {
+		after = &store.SessionCursor{StartTime: time.Unix(token.StartSeconds, int64(token.StartNanos)).UTC(), ID: token.ID}
+	}
+
+	// One more than a page tells whether another page follows
+	sessions, err := s.repo.ListSessions(ctx, filter, after, req.Descending, pageSize+1)
+	if err != nil {
+		return nil, status.Errorf(storeErrorCode(err), "Failed to list sessions: %v", err)
+	}
+
+	resp := &pb.ListSessionsResponse{}
+	if len(sessions) > pageSize {
+		sessions = sessions[:pageSize]
+		last := sessions[pageSize-1].Cursor()
+		resp.NextPageToken = encodePageToken(pageToken{
+			StartSeconds: last.StartTime.Unix(),
+			StartNanos:   int32(last.StartTime.Nanosecond()),
+			ID:           last.ID,
+			Query:        listQueryHash(req),
+		})
+	}
+	for _, session := range sessions {
+		resp.Sessions = append(resp.Sessions, sessionToProto(session, now))
+	}
+	return resp, nil
+}
@@ -0,0 +1,433 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// A schema change in two phases, so that it can be rolled out while old and
+// new versions of the service both serve traffic:
+//
+//   - expand only adds: tables, nullable or defaulted columns, indexes. The
+//     code of the previous version keeps working on the expanded schema.
+//     It runs before the new version is deployed.
+//   - contract removes or tightens what only earlier versions use: dropped
+//     columns, new NOT NULL constraints. It runs once no replica of an
+//     earlier version is left.
+//
+// A rename is an expand adding the new column and backfilling it, code
+// writing both columns, and a contract dropping the old one a release later.
+//
+// down undoes expand, to roll a release back before its contract ran.
+type schemaMigration struct {
+	version  int
+	name     string
+	expand   func(ctx context.Context, db execer) error
+	contract func(ctx context.Context, db execer) error
+	down     func(ctx context.Context, db execer) error
+}
+
+// The steps of every schema change, one SQL file each, named
+// NNNN_name.PHASE.sql after the version and phase they belong to. Version 1
+// is the schema the service created before it was versioned. New changes
+// get the next version and never change once released.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Every schema change, in order
+var schemaMigrations = mustLoadMigrations(migrationFiles, "migrations")
+
+// Name of a migration file: version, name and phase
+var migrationFileName = regexp.MustCompile(`^(\d{4})_(\w+)\.(expand|contract|down)\.sql$`)
+
+// Read the migrations from the SQL files in dir. Versions start at 1 and
+// follow each other; each has an expand or a contract step, and a down step
+// only undoes an expand step.
+func loadMigrations(fsys fs.FS, dir string) ([]schemaMigration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int]*schemaMigration)
+	for _, entry := range entries {
+		match := migrationFileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("migration file %s is not named NNNN_name.expand|contract|down.sql", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		m := byVersion[version]
+		if m == nil {
+			m = &schemaMigration{version: version, name: match[2]}
+			byVersion[version] = m
+		}
+		if m.name != match[2] {
+			return nil, fmt.Errorf("version %d is named both %s and %s", version, m.name, match[2])
+		}
+		query, err := fs.ReadFile(fsys, dir+"/"+entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		step := sqlStep(string(query))
+		switch match[3] {
+		case expandPhase:
+			m.expand = step
+		case contractPhase:
+			m.contract = step
+		case downPhase:
+			m.down = step
+		}
+	}
+
+	migrations := make([]schemaMigration, 0, len(byVersion))
+	for _, m := range byVersion {
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].version < migrations[j].version })
+	for i, m := range migrations {
+		switch {
+		case m.version != i+1:
+			return nil, fmt.Errorf("missing migration version %d", i+1)
+		case m.expand == nil && m.contract == nil:
+			return nil, fmt.Errorf("version %d has neither an expand nor a contract step", m.version)
+		case m.down != nil && m.expand == nil:
+			return nil, fmt.Errorf("version %d has a down step but nothing to undo", m.version)
+		}
+	}
+	if len(migrations) == 0 {
+		return nil, fmt.Errorf("no migrations in %s", dir)
+	}
+	return migrations, nil
+}
+
+// Load the embedded migrations, which are checked by the tests
+func mustLoadMigrations(fsys fs.FS, dir string) []schemaMigration {
+	migrations, err := loadMigrations(fsys, dir)
+	if err != nil {
+		panic(err)
+	}
+	return migrations
+}
+
+// Step running the statements of a migration file
+func sqlStep(query string) func(ctx context.Context, db execer) error {
+	return func(ctx context.Context, db execer) error {
+		_, err := db.ExecContext(ctx, query)
+		return err
+	}
+}
+
+// Version of the schema this build runs on
+var schemaVersion = schemaMigrations[len(schemaMigrations)-1].version
+
+// Migration phases
+const (
+	expandPhase   = "expand"
+	contractPhase = "contract"
+	downPhase     = "down"
+)
+
+// Advisory lock serializing migrations
+const migrationLock = "session-service schema migrations"
+
+// Create the schema_version table, which records the last expanded and
+// contracted versions, unless it exists
+func createSchemaVersionTable(ctx context.Context, db execer) error {
+	_, err := db.ExecContext(ctx, `
+	CREATE TABLE IF NOT EXISTS schema_version (
+		singleton BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (singleton),
+		expanded INT NOT NULL,
+		contracted INT NOT NULL,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`)
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, `INSERT INTO schema_version (expanded, contracted) VALUES (0, 0) ON CONFLICT DO NOTHING`)
+	return err
+}
+
+// Read the versions recorded in schema_version. ok is false if the schema is
+// not versioned yet.
+func readSchemaVersion(ctx context.Context, q queryRower) (expanded, contracted int, ok bool, err error) {
+	var exists bool
+	if err := q.QueryRowContext(ctx, `SELECT to_regclass('schema_version') IS NOT NULL`).Scan(&exists); err != nil || !exists {
+		return 0, 0, false, err
+	}
+	err = q.QueryRowContext(ctx, `SELECT expanded, contracted FROM schema_version`).Scan(&expanded, &contracted)
+	if err == sql.ErrNoRows {
+		return 0, 0, true, nil
+	}
+	return expanded, contracted, err == nil, err
+}
+
+// Check that code of the given version can run on a schema expanded and
+// contracted to the given versions: everything it uses must have been added
+// and nothing it uses removed
+func schemaCompatible(version, expanded, contracted int) error {
+	if expanded < version {
+		return fmt.Errorf("schema version %d is older than %d: run the migrate expand command before deploying", expanded, version)
+	}
+	if contracted > version {
+		return fmt.Errorf("schema was contracted to version %d, which removed what version %d uses: deploy a newer version", contracted, version)
+	}
+	return nil
+}
+
+// Apply the migrations of phase up to version target, in one transaction,
+// and return the version the phase was at before. Contracting beyond the
+// expanded version is refused. The down phase undoes the expand steps of
+// the versions after target and returns the expanded version it started
+// from.
+func migrateSchema(ctx context.Context, db *sql.DB, migrations []schemaMigration, phase string, target int) (int, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, migrationLock); err != nil {
+		return 0, err
+	}
+	if err := createSchemaVersionTable(ctx, tx); err != nil {
+		return 0, err
+	}
+	expanded, contracted, _, err := readSchemaVersion(ctx, tx)
+	if err != nil {
+		return 0, err
+	}
+
+	if phase == downPhase {
+		if target >= expanded {
+			return expanded, nil
+		}
+		if err := undoMigrations(ctx, tx, migrations, expanded, contracted, target); err != nil {
+			return 0, err
+		}
+		_, err = tx.ExecContext(ctx,
+			`UPDATE schema_version SET expanded = $1, contracted = LEAST(contracted, $1), updated_at = CURRENT_TIMESTAMP`,
+			target)
+		if err != nil {
+			return 0, err
+		}
+		return expanded, tx.Commit()
+	}
+
+	from := expanded
+	if phase == contractPhase {
+		from = contracted
+		if target > expanded {
+			return 0, fmt.Errorf("cannot contract to version %d, the schema is only expanded to %d", target, expanded)
+		}
+	}
+	if err := applyMigrations(ctx, tx, migrations, phase, from, target); err != nil {
+		return 0, err
+	}
+
+	if target > from {
+		column := "expanded"
+		if phase == contractPhase {
+			column = "contracted"
+		}
+		_, err = tx.ExecContext(ctx, `UPDATE schema_version SET `+column+` = $1, updated_at = CURRENT_TIMESTAMP`, target)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return from, tx.Commit()
+}
+
+// Create the whole schema from scratch, without recording its version
+func createSchema(ctx context.Context, db execer) error {
+	if err := createSchemaVersionTable(ctx, db); err != nil {
+		return err
+	}
+	for _, phase := range []string{expandPhase, contractPhase} {
+		if err := applyMigrations(ctx, db, schemaMigrations, phase, 0, schemaVersion); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Create the whole schema and record it at the current version, unless it
+// is versioned already
+func createVersionedSchema(ctx context.Context, db execer) error {
+	if err := createSchema(ctx, db); err != nil {
+		return err
+	}
+	_, err := db.ExecContext(ctx,
+		`UPDATE schema_version SET expanded = $1, contracted = $1, updated_at = CURRENT_TIMESTAMP WHERE expanded = 0`,
+		schemaVersion)
+	return err
+}
+
+// Run the steps of phase of the migrations after version from, up to target
+func applyMigrations(ctx context.Context, db execer, migrations []schemaMigration, phase string, from, target int) error {
+	for _, m := range migrations {
+		if m.version <= from || m.version > target {
+			continue
+		}
+		step := m.expand
+		if phase == contractPhase {
+			step = m.contract
+		}
+		if step == nil {
+			continue
+		}
+		if err := step(ctx, db); err != nil {
+			return fmt.Errorf("%s to version %d (%s): %w", phase, m.version, m.name, err)
+		}
+	}
+	return nil
+}
+
+// Run the down steps of the versions after target, up to expanded, latest
+// first. A version whose contract step ran can't be undone: the contract
+// removed what the earlier versions used.
+func undoMigrations(ctx context.Context, db execer, migrations []schemaMigration, expanded, contracted, target int) error {
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if m.version <= target || m.version > expanded {
+			continue
+		}
+		if m.contract != nil && m.version <= contracted {
+			return fmt.Errorf("cannot go down to version %d, version %d was contracted", target, m.version)
+		}
+		if m.expand == nil {
+			continue
+		}
+		if m.down == nil {
+			return fmt.Errorf("cannot go down to version %d, version %d (%s) has no down step", target, m.version, m.name)
+		}
+		if err := m.down(ctx, db); err != nil {
+			return fmt.Errorf("down from version %d (%s): %w", m.version, m.name, err)
+		}
+	}
+	return nil
+}
+
+// Bring a database whose schema is not versioned yet under versioning. A new
+// database gets the whole schema. One created before versioning has the
+// schema of version 1, which its expand step brings up to date.
+func versionSchema(ctx context.Context, db *sql.DB) error {
+	var exists bool
+	if err := db.QueryRowContext(ctx, `SELECT to_regclass('sessions') IS NOT NULL`).Scan(&exists); err != nil {
+		return err
+	}
+	target := schemaVersion
+	if exists {
+		target = 1
+	}
+	for _, phase := range []string{expandPhase, contractPhase} {
+		if _, err := migrateSchema(ctx, db, schemaMigrations, phase, target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Expand the schema to the version of this build, for the -migrate flag.
+// Expanding is safe while earlier versions still serve; contracting is not,
+// and is left to the migrate command. The connection has no query timeout,
+// since building an index on a large table can outlast it.
+func expandSchema(ctx context.Context) error {
+	db, err := sql.Open("postgres", databaseURL())
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := requirePartitioned(ctx, db); err != nil {
+		return err
+	}
+	from, err := migrateSchema(ctx, db, schemaMigrations, expandPhase, schemaVersion)
+	if err != nil {
+		return err
+	}
+	if from < schemaVersion {
+		log.Printf("Schema expanded from version %d to %d", from, schemaVersion)
+	}
+	return nil
+}
+
+// Implementation of the migrate command
+func runMigrate(args []string) {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	to := flags.Int("to", schemaVersion, "Version to migrate to; required by down")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: session-service migrate [-to VERSION] expand|contract|down|status")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	db, err := sql.Open("postgres", databaseURL())
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	switch phase := flags.Arg(0); phase {
+	case expandPhase, contractPhase:
+		if *to > schemaVersion {
+			log.Fatalf("This build only knows schema versions up to %d", schemaVersion)
+		}
+		from, err := migrateSchema(ctx, db, schemaMigrations, phase, *to)
+		if err != nil {
+			log.Fatalf("Failed to %s the schema: %v", phase, err)
+		}
+		if from >= *to {
+			log.Printf("Schema already %sed to version %d", phase, from)
+			return
+		}
+		log.Printf("Schema %sed from version %d to %d", phase, from, *to)
+	case downPhase:
+		toSet := false
+		flags.Visit(func(f *flag.Flag) { toSet = toSet || f.Name == "to" })
+		if !toSet || *to < 0 {
+			log.Fatalf("down needs the version to go down to, with -to")
+		}
+		from, err := migrateSchema(ctx, db, schemaMigrations, phase, *to)
+		if err != nil {
+			log.Fatalf("Failed to take the schema down: %v", err)
+		}
+		if from <= *to {
+			log.Printf("Schema already at version %d", from)
+			return
+		}
+		log.Printf("Schema taken down from version %d to %d", from, *to)
+	case "status":
+		expanded, contracted, ok, err := readSchemaVersion(ctx, db)
+		if err != nil {
+			log.Fatalf("Failed to read the schema version: %v", err)
+		}
+		if !ok {
+			fmt.Println("Schema not versioned yet; the service versions it when it starts")
+			return
+		}
+		fmt.Printf("Schema expanded to version %d, contracted to version %d\n", expanded, contracted)
+		for _, m := range schemaMigrations {
+			if m.version > expanded || (m.contract != nil && m.version > contracted) {
+				fmt.Printf("Pending: version %d (%s)\n", m.version, m.name)
+			}
+		}
+		if err := schemaCompatible(schemaVersion, expanded, contracted); err != nil {
+			fmt.Printf("This build (version %d) cannot run on it: %v\n", schemaVersion, err)
+			os.Exit(1)
+		}
+		fmt.Printf("This build (version %d) can run on it\n", schemaVersion)
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+}
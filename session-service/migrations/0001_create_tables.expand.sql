@@ -0,0 +1,69 @@
+-- The schema before it was versioned. Both tables are partitioned by gym_id,
+-- the first column of their keys, so queries scoped to a gym only read one
+-- partition. IDs still come from one sequence per table and are unique
+-- across gyms; the id indexes serve lookups without a gym. The rows of a gym
+-- are all in the same of the 8 partitions of each table; changing the number
+-- means moving every row.
+
+CREATE TABLE IF NOT EXISTS sessions (
+	id SERIAL,
+	gym_id VARCHAR(100) NOT NULL,
+	title VARCHAR(255) NOT NULL,
+	description TEXT,
+	coach_id VARCHAR(100) NOT NULL,
+	coach_name VARCHAR(255) NOT NULL,
+	capacity INT NOT NULL,
+	reserved_spots INT DEFAULT 0,
+	start_time TIMESTAMP NOT NULL,
+	end_time TIMESTAMP NOT NULL,
+	location VARCHAR(255) NOT NULL,
+	session_type VARCHAR(100) NOT NULL,
+	difficulty_level VARCHAR(50) NOT NULL,
+	is_cancelled BOOLEAN DEFAULT FALSE,
+	cancellation_reason TEXT,
+	is_completed BOOLEAN DEFAULT FALSE,
+	queued_booking BOOLEAN DEFAULT FALSE,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (gym_id, id)
+) PARTITION BY HASH (gym_id);
+
+-- Columns introduced after the table was first created
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS cancellation_reason TEXT;
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS is_completed BOOLEAN DEFAULT FALSE;
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS queued_booking BOOLEAN DEFAULT FALSE;
+
+CREATE TABLE IF NOT EXISTS reservations (
+	id SERIAL,
+	gym_id VARCHAR(100) NOT NULL,
+	session_id INT NOT NULL,
+	user_id VARCHAR(100) NOT NULL,
+	user_name VARCHAR(255) NOT NULL,
+	reservation_time TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	status VARCHAR(50) DEFAULT 'confirmed',
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (gym_id, id),
+	UNIQUE (gym_id, session_id, user_id),
+	FOREIGN KEY (gym_id, session_id) REFERENCES sessions (gym_id, id) ON DELETE CASCADE
+) PARTITION BY HASH (gym_id);
+
+CREATE TABLE IF NOT EXISTS sessions_p0 PARTITION OF sessions FOR VALUES WITH (MODULUS 8, REMAINDER 0);
+CREATE TABLE IF NOT EXISTS sessions_p1 PARTITION OF sessions FOR VALUES WITH (MODULUS 8, REMAINDER 1);
+CREATE TABLE IF NOT EXISTS sessions_p2 PARTITION OF sessions FOR VALUES WITH (MODULUS 8, REMAINDER 2);
+CREATE TABLE IF NOT EXISTS sessions_p3 PARTITION OF sessions FOR VALUES WITH (MODULUS 8, REMAINDER 3);
+CREATE TABLE IF NOT EXISTS sessions_p4 PARTITION OF sessions FOR VALUES WITH (MODULUS 8, REMAINDER 4);
+CREATE TABLE IF NOT EXISTS sessions_p5 PARTITION OF sessions FOR VALUES WITH (MODULUS 8, REMAINDER 5);
+CREATE TABLE IF NOT EXISTS sessions_p6 PARTITION OF sessions FOR VALUES WITH (MODULUS 8, REMAINDER 6);
+CREATE TABLE IF NOT EXISTS sessions_p7 PARTITION OF sessions FOR VALUES WITH (MODULUS 8, REMAINDER 7);
+CREATE INDEX IF NOT EXISTS sessions_id_idx ON sessions (id);
+
+CREATE TABLE IF NOT EXISTS reservations_p0 PARTITION OF reservations FOR VALUES WITH (MODULUS 8, REMAINDER 0);
+CREATE TABLE IF NOT EXISTS reservations_p1 PARTITION OF reservations FOR VALUES WITH (MODULUS 8, REMAINDER 1);
+CREATE TABLE IF NOT EXISTS reservations_p2 PARTITION OF reservations FOR VALUES WITH (MODULUS 8, REMAINDER 2);
+CREATE TABLE IF NOT EXISTS reservations_p3 PARTITION OF reservations FOR VALUES WITH (MODULUS 8, REMAINDER 3);
+CREATE TABLE IF NOT EXISTS reservations_p4 PARTITION OF reservations FOR VALUES WITH (MODULUS 8, REMAINDER 4);
+CREATE TABLE IF NOT EXISTS reservations_p5 PARTITION OF reservations FOR VALUES WITH (MODULUS 8, REMAINDER 5);
+CREATE TABLE IF NOT EXISTS reservations_p6 PARTITION OF reservations FOR VALUES WITH (MODULUS 8, REMAINDER 6);
+CREATE TABLE IF NOT EXISTS reservations_p7 PARTITION OF reservations FOR VALUES WITH (MODULUS 8, REMAINDER 7);
+CREATE INDEX IF NOT EXISTS reservations_id_idx ON reservations (id);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS sessions_start_time_idx;
@@ -0,0 +1,2 @@
+-- ListSessions pages through a gym's sessions in start time order
+CREATE INDEX IF NOT EXISTS sessions_start_time_idx ON sessions (gym_id, start_time, id);
@@ -0,0 +1,2 @@
+-- ListUserReservations pages through a member's reservations
+CREATE INDEX IF NOT EXISTS reservations_user_idx ON reservations (gym_id, user_id, id);
@@ -0,0 +1,24 @@
+-- Members wait for a spot of a full session, in ID order; a member is on a
+-- session's waitlist at most once
+CREATE TABLE IF NOT EXISTS waitlist (
+	id SERIAL,
+	gym_id VARCHAR(100) NOT NULL,
+	session_id INT NOT NULL,
+	user_id VARCHAR(100) NOT NULL,
+	user_name VARCHAR(255) NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (gym_id, id),
+	UNIQUE (gym_id, session_id, user_id),
+	FOREIGN KEY (gym_id, session_id) REFERENCES sessions (gym_id, id) ON DELETE CASCADE
+) PARTITION BY HASH (gym_id);
+
+CREATE TABLE IF NOT EXISTS waitlist_p0 PARTITION OF waitlist FOR VALUES WITH (MODULUS 8, REMAINDER 0);
+CREATE TABLE IF NOT EXISTS waitlist_p1 PARTITION OF waitlist FOR VALUES WITH (MODULUS 8, REMAINDER 1);
+CREATE TABLE IF NOT EXISTS waitlist_p2 PARTITION OF waitlist FOR VALUES WITH (MODULUS 8, REMAINDER 2);
+CREATE TABLE IF NOT EXISTS waitlist_p3 PARTITION OF waitlist FOR VALUES WITH (MODULUS 8, REMAINDER 3);
+CREATE TABLE IF NOT EXISTS waitlist_p4 PARTITION OF waitlist FOR VALUES WITH (MODULUS 8, REMAINDER 4);
+CREATE TABLE IF NOT EXISTS waitlist_p5 PARTITION OF waitlist FOR VALUES WITH (MODULUS 8, REMAINDER 5);
+CREATE TABLE IF NOT EXISTS waitlist_p6 PARTITION OF waitlist FOR VALUES WITH (MODULUS 8, REMAINDER 6);
+CREATE TABLE IF NOT EXISTS waitlist_p7 PARTITION OF waitlist FOR VALUES WITH (MODULUS 8, REMAINDER 7);
+CREATE INDEX IF NOT EXISTS waitlist_id_idx ON waitlist (id);
+CREATE INDEX IF NOT EXISTS waitlist_session_idx ON waitlist (gym_id, session_id, id);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS sessions_series_idx;
+ALTER TABLE sessions DROP COLUMN IF EXISTS series_id;
+DROP SEQUENCE IF EXISTS session_series_id_seq;
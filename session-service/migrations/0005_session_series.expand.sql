@@ -0,0 +1,7 @@
+-- Occurrences of a recurring session share a series_id, NULL for sessions
+-- created on their own. Series IDs come from their own sequence, like the
+-- session IDs unique across gyms.
+CREATE SEQUENCE IF NOT EXISTS session_series_id_seq;
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS series_id BIGINT;
+-- The rest of a series is changed in start time order
+CREATE INDEX IF NOT EXISTS sessions_series_idx ON sessions (gym_id, series_id, start_time);
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := loadMigrations(migrationFiles, "migrations")
+	if err != nil {
+		t.Fatalf("Failed to load the embedded migrations: %v", err)
+	}
+	if migrations[0].name != "create_tables" || migrations[0].expand == nil {
+		t.Errorf("Expected version 1 to create the tables, got %+v", migrations[0])
+	}
+	for _, m := range migrations[1:] {
+		if m.expand != nil && m.down == nil {
+			t.Errorf("Version %d (%s) can't be undone: add its down step", m.version, m.name)
+		}
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	file := &fstest.MapFile{Data: []byte("SELECT 1")}
+	tests := map[string]struct {
+		files []string
+		err   string
+	}{
+		"phases": {
+			files: []string{"0001_a.expand.sql", "0002_b.expand.sql", "0002_b.contract.sql", "0002_b.down.sql", "0003_c.contract.sql"},
+		},
+		"badly named":       {files: []string{"0001_a.up.sql"}, err: "is not named"},
+		"missing version":   {files: []string{"0001_a.expand.sql", "0003_c.expand.sql"}, err: "missing migration version 2"},
+		"two names":         {files: []string{"0001_a.expand.sql", "0001_b.contract.sql"}, err: "named both"},
+		"down only":         {files: []string{"0001_a.down.sql"}, err: "neither an expand nor a contract"},
+		"down of contract":  {files: []string{"0001_a.contract.sql", "0001_a.down.sql"}, err: "nothing to undo"},
+		"without migration": {err: "no migrations"},
+	}
+	for name, tt := range tests {
+		fsys := fstest.MapFS{"migrations": &fstest.MapFile{Mode: fs.ModeDir | 0755}}
+		for _, f := range tt.files {
+			fsys["migrations/"+f] = file
+		}
+		migrations, err := loadMigrations(fsys, "migrations")
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: expected an error containing %q, got %v", name, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(migrations) != 3 || migrations[1].name != "b" || migrations[1].down == nil || migrations[2].expand != nil {
+			t.Errorf("%s: unexpected migrations %+v", name, migrations)
+		}
+	}
+}
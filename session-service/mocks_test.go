@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"session-service/internal/clock"
+	"session-service/internal/store"
+	"session-service/internal/store/storemock"
+	pb "session-service/proto"
+)
+
+func newMockedServer(repo *storemock.RepositoryMock) *server {
+	return newServer(repo, clock.NewFake(testSessionStart.Add(-24*time.Hour)))
+}
+
+func TestServerCreateSessionStoreError(t *testing.T) {
+	repo := &storemock.RepositoryMock{
+		CreateSessionFunc: func(ctx context.Context, s *store.Session, checkConflicts bool) error {
+			return errors.New("connection refused")
+		},
+		// The coach's latest session, for their name
+		ListSessionsFunc: func(ctx context.Context, f store.SessionFilter, after *store.SessionCursor, descending bool, limit int) ([]*store.Session, error) {
+			return nil, nil
+		},
+	}
+
+	_, err := newMockedServer(repo).CreateSession(context.Background(), validCreateSessionRequest())
+	if status.Code(err) != codes.Internal {
+		t.Errorf("Expected Internal, got %v", err)
+	}
+
+	calls := repo.CreateSessionCalls()
+	if len(calls) != 1 {
+		t.Fatalf("Expected 1 CreateSession call, got %d", len(calls))
+	}
+	if got := calls[0].S; got.CoachID != "coach-1" || got.CoachName == "" || !got.StartTime.Equal(testSessionStart) {
+		t.Errorf("Unexpected session passed to the store: %+v", got)
+	}
+}
+
+func TestServerRejectsInvalidRequestsBeforeStore(t *testing.T) {
+	repo := &storemock.RepositoryMock{}
+	s := newMockedServer(repo)
+
+	req := validCreateSessionRequest()
+	req.Title = ""
+	if _, err := s.CreateSession(context.Background(), req); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument, got %v", err)
+	}
+	if _, err := s.CancelSession(context.Background(), &pb.CancelSessionRequest{SessionId: "abc"}); status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound, got %v", err)
+	}
+	// The mock panics on any call, so reaching this point means the store was not touched
+}
+
+func TestServerCancelSessionDryRunDoesNotWrite(t *testing.T) {
+	repo := &storemock.RepositoryMock{
+		GetSessionFunc: func(ctx context.Context, id int64) (*store.Session, error) {
+			return &store.Session{ID: id, StartTime: testSessionStart, EndTime: testSessionStart.Add(time.Hour)}, nil
+		},
+	}
+
+	_, err := newMockedServer(repo).CancelSession(context.Background(), &pb.CancelSessionRequest{SessionId: "7", DryRun: true})
+	if err != nil {
+		t.Fatalf("Dry run failed: %v", err)
+	}
+	if calls := repo.GetSessionCalls(); len(calls) != 1 || calls[0].ID != 7 {
+		t.Errorf("Expected one GetSession(7) call, got %+v", calls)
+	}
+	if n := len(repo.CancelSessionCalls()); n != 0 {
+		t.Errorf("Dry run called CancelSession %d times", n)
+	}
+}
+
+func TestServerRunSelfTestCleansUpAfterFailure(t *testing.T) {
+	repo := &storemock.RepositoryMock{
+		CreateSessionFunc: func(ctx context.Context, s *store.Session, checkConflicts bool) error {
+			s.ID = 7
+			return nil
+		},
+		CreateReservationFunc: func(ctx context.Context, r *store.Reservation) error {
+			return errors.New("connection reset")
+		},
+		DeleteSessionFunc: func(ctx context.Context, id int64) error {
+			return nil
+		},
+	}
+
+	resp, err := newMockedServer(repo).RunSelfTest(context.Background(), &pb.RunSelfTestRequest{})
+	if err != nil {
+		t.Fatalf("RunSelfTest failed: %v", err)
+	}
+	if resp.Ok || len(resp.Steps) != 3 || resp.Steps[1].Error != "connection reset" {
+		t.Errorf("Expected create, failed book and delete, got %+v", resp.Steps)
+	}
+	if calls := repo.DeleteSessionCalls(); len(calls) != 1 || calls[0].ID != 7 {
+		t.Errorf("Expected the scratch session to be deleted, got %+v", calls)
+	}
+}
+
+func TestServerRunSelfTestChecksCancelledSpot(t *testing.T) {
+	repo := &storemock.RepositoryMock{
+		CreateSessionFunc: func(ctx context.Context, s *store.Session, checkConflicts bool) error {
+			s.ID = 7
+			return nil
+		},
+		CreateReservationFunc: func(ctx context.Context, r *store.Reservation) error {
+			r.ID = 11
+			return nil
+		},
+		// The spot is never given back
+		GetSessionFunc: func(ctx context.Context, id int64) (*store.Session, error) {
+			return &store.Session{ID: id, Capacity: 1, ReservedSpots: 1}, nil
+		},
+		CancelReservationFunc: func(ctx context.Context, id int64) (*store.Reservation, error) {
+			return &store.Reservation{ID: id, SessionID: 7, Status: store.ReservationCancelled}, nil
+		},
+		DeleteSessionFunc: func(ctx context.Context, id int64) error {
+			return nil
+		},
+	}
+
+	resp, err := newMockedServer(repo).RunSelfTest(context.Background(), &pb.RunSelfTestRequest{})
+	if err != nil {
+		t.Fatalf("RunSelfTest failed: %v", err)
+	}
+	if resp.Ok || len(resp.Steps) != 4 || resp.Steps[2].Error != "expected 0 reserved spots, got 1" {
+		t.Errorf("Expected the cancel step to fail on the reserved spot, got %+v", resp.Steps)
+	}
+	if calls := repo.CancelReservationCalls(); len(calls) != 1 || calls[0].ID != 11 {
+		t.Errorf("Expected the booked reservation to be cancelled, got %+v", calls)
+	}
+	if calls := repo.CancelSessionCalls(); len(calls) != 0 {
+		t.Errorf("Expected the scratch session not to be cancelled, got %+v", calls)
+	}
+}
+
+func TestServerRetryableErrorsAreAborted(t *testing.T) {
+	for _, code := range []pq.ErrorCode{"40001", "40P01", "55P03"} {
+		repo := &storemock.RepositoryMock{
+			GetSessionFunc: func(ctx context.Context, id int64) (*store.Session, error) {
+				return nil, &pq.Error{Code: code, Message: "try again"}
+			},
+		}
+
+		_, err := newMockedServer(repo).GetSession(context.Background(), &pb.GetSessionRequest{SessionId: "7"})
+		if status.Code(err) != codes.Aborted {
+			t.Errorf("SQLSTATE %s: expected Aborted, got %v", code, err)
+		}
+	}
+}
+
+func TestServerStoreTimeoutIsDeadlineExceeded(t *testing.T) {
+	repo := &storemock.RepositoryMock{
+		GetSessionFunc: func(ctx context.Context, id int64) (*store.Session, error) {
+			return nil, context.DeadlineExceeded
+		},
+	}
+
+	_, err := newMockedServer(repo).GetSession(context.Background(), &pb.GetSessionRequest{SessionId: "7"})
+	if status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("Expected DeadlineExceeded, got %v", err)
+	}
+}
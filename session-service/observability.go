@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/XSAM/otelsql"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc/filters"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+
+	"session-service/internal/jobs"
+	pb "session-service/proto"
+)
+
+// Default address of the HTTP listener serving /metrics and /healthz
+const defaultMetricsListen = ":9090"
+
+// Default pause between two database checks of the health service
+const defaultHealthCheckInterval = 10 * time.Second
+
+// Registry of the metrics served on /metrics, with those of the Go runtime
+// and of the process
+func newMetricsRegistry() *prometheus.Registry {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	return reg
+}
+
+// Per-RPC counters and latencies, named like those of go-grpc-prometheus so
+// that the usual dashboards work
+type rpcMetrics struct {
+	started  *prometheus.CounterVec
+	handled  *prometheus.CounterVec
+	handling *prometheus.HistogramVec
+}
+
+func newRPCMetrics(reg prometheus.Registerer) *rpcMetrics {
+	f := promauto.With(reg)
+	return &rpcMetrics{
+		started: f.NewCounterVec(prometheus.CounterOpts{
+			Name: "grpc_server_started_total",
+			Help: "Total number of RPCs started on the server.",
+		}, []string{"grpc_type", "grpc_service", "grpc_method"}),
+		handled: f.NewCounterVec(prometheus.CounterOpts{
+			Name: "grpc_server_handled_total",
+			Help: "Total number of RPCs completed on the server, regardless of success or failure.",
+		}, []string{"grpc_type", "grpc_service", "grpc_method", "grpc_code"}),
+		handling: f.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "grpc_server_handling_seconds",
+			Help:    "Histogram of response latency (seconds) of gRPC that had been application-level handled by the server.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"grpc_type", "grpc_service", "grpc_method"}),
+	}
+}
+
+// Record a call of fullMethod, /package.Service/Method, of type kind
+func (m *rpcMetrics) observe(kind, fullMethod string, call func() error) error {
+	service, method := "unknown", "unknown"
+	if i := strings.LastIndex(fullMethod, "/"); i > 0 {
+		service, method = fullMethod[1:i], fullMethod[i+1:]
+	}
+	m.started.WithLabelValues(kind, service, method).Inc()
+	start := time.Now()
+	err := call()
+	m.handled.WithLabelValues(kind, service, method, status.Code(err).String()).Inc()
+	m.handling.WithLabelValues(kind, service, method).Observe(time.Since(start).Seconds())
+	return err
+}
+
+func (m *rpcMetrics) unary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	var resp interface{}
+	err := m.observe("unary", info.FullMethod, func() error {
+		var err error
+		resp, err = handler(ctx, req)
+		return err
+	})
+	return resp, err
+}
+
+func (m *rpcMetrics) stream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	kind := "server_stream"
+	if info.IsClientStream {
+		kind = "bidi_stream"
+		if !info.IsServerStream {
+			kind = "client_stream"
+		}
+	}
+	return m.observe(kind, info.FullMethod, func() error { return handler(srv, ss) })
+}
+
+// Export the connection pool statistics of db, as go_sql_* metrics
+func registerDBMetrics(reg prometheus.Registerer, db *sql.DB) {
+	reg.MustRegister(collectors.NewDBStatsCollector(db, "sessions"))
+}
+
+// Export the counters of the background jobs, read from stats on each
+// scrape. Only the leader replica runs the jobs: the others report no runs.
+func registerJobMetrics(reg prometheus.Registerer, stats func() []jobs.Stats) {
+	reg.MustRegister(jobMetrics{stats})
+}
+
+var (
+	jobRunsDesc = prometheus.NewDesc("job_runs_total",
+		"Runs of the background job, retries included in one run.", []string{"job"}, nil)
+	jobFailuresDesc = prometheus.NewDesc("job_failures_total",
+		"Runs of the background job that failed after their retries.", []string{"job"}, nil)
+	jobRetriesDesc = prometheus.NewDesc("job_retries_total",
+		"Retried attempts of the background job.", []string{"job"}, nil)
+	jobRunningDesc = prometheus.NewDesc("job_running",
+		"Whether the background job is running.", []string{"job"}, nil)
+	jobLastRunDesc = prometheus.NewDesc("job_last_run_timestamp_seconds",
+		"Start of the last run of the background job.", []string{"job"}, nil)
+	jobDurationDesc = prometheus.NewDesc("job_duration_seconds",
+		"Duration of the last run of the background job.", []string{"job"}, nil)
+	jobLastSuccessDesc = prometheus.NewDesc("job_last_run_success",
+		"Whether the last run of the background job succeeded.", []string{"job"}, nil)
+)
+
+// Collector of the jobs.Stats returned by its function
+type jobMetrics struct {
+	stats func() []jobs.Stats
+}
+
+func (m jobMetrics) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{
+		jobRunsDesc, jobFailuresDesc, jobRetriesDesc, jobRunningDesc, jobLastRunDesc, jobDurationDesc, jobLastSuccessDesc,
+	} {
+		ch <- d
+	}
+}
+
+func (m jobMetrics) Collect(ch chan<- prometheus.Metric) {
+	for _, st := range m.stats() {
+		ch <- prometheus.MustNewConstMetric(jobRunsDesc, prometheus.CounterValue, float64(st.Runs), st.Name)
+		ch <- prometheus.MustNewConstMetric(jobFailuresDesc, prometheus.CounterValue, float64(st.Failures), st.Name)
+		ch <- prometheus.MustNewConstMetric(jobRetriesDesc, prometheus.CounterValue, float64(st.Retries), st.Name)
+		ch <- prometheus.MustNewConstMetric(jobRunningDesc, prometheus.GaugeValue, boolValue(st.Running), st.Name)
+		// Nothing to tell about the last run until there was one
+		if st.LastRun.IsZero() {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(jobLastRunDesc, prometheus.GaugeValue, float64(st.LastRun.UnixNano())/1e9, st.Name)
+		ch <- prometheus.MustNewConstMetric(jobDurationDesc, prometheus.GaugeValue, st.LastDuration.Seconds(), st.Name)
+		ch <- prometheus.MustNewConstMetric(jobLastSuccessDesc, prometheus.GaugeValue, boolValue(st.LastError == ""), st.Name)
+	}
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Trace context format of the callers: W3C traceparent and baggage
+var tracePropagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// Set up OpenTelemetry tracing if an OTLP endpoint is set through the
+// standard OTEL_EXPORTER_OTLP_* variables, which also configure the
+// exporter. Spans are sent in batches, sampled as OTEL_TRACES_SAMPLER says,
+// for the service OTEL_SERVICE_NAME, session-service by default. It returns
+// nil when tracing is off.
+func setupTracing(ctx context.Context) (*sdktrace.TracerProvider, error) {
+	if os.Getenv("OTEL_SDK_DISABLED") == "true" ||
+		os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		return nil, nil
+	}
+	exporter, err := otlptracegrpc.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceNameKey.String("session-service")),
+		resource.WithFromEnv(), resource.WithTelemetrySDK(), resource.WithHost())
+	if err != nil {
+		return nil, err
+	}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	// The database spans are made with the global provider
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(tracePropagator)
+	return tp, nil
+}
+
+// Interceptors starting a span for each call, a child of the caller's span
+// when the metadata has its trace context. Health checks aren't traced.
+func tracingInterceptors(tp trace.TracerProvider) (grpc.UnaryServerInterceptor, grpc.StreamServerInterceptor) {
+	opts := []otelgrpc.Option{
+		otelgrpc.WithTracerProvider(tp),
+		otelgrpc.WithPropagators(tracePropagator),
+		otelgrpc.WithInterceptorFilter(filters.Not(filters.HealthCheck())),
+	}
+	return otelgrpc.UnaryServerInterceptor(opts...), otelgrpc.StreamServerInterceptor(opts...)
+}
+
+// Open the Postgres database at dsn. Each query is a span, a child of the
+// span of the call it is made for through the context, once tracing is set
+// up; before that, or without it, the spans aren't recorded.
+func openDatabase(dsn string) (*sql.DB, error) {
+	return otelsql.Open("postgres", dsn,
+		otelsql.WithAttributes(semconv.DBSystemPostgreSQL),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{DisableErrSkip: true, OmitConnResetSession: true}))
+}
+
+// Handler of the metrics and health status: /metrics for Prometheus, and
+// /healthz for probes that don't speak gRPC
+func metricsHandler(reg prometheus.Gatherer, healthServer *health.Server) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		resp, err := healthServer.Check(r.Context(), &healthpb.HealthCheckRequest{})
+		if err != nil || resp.Status != healthpb.HealthCheckResponse_SERVING {
+			http.Error(w, "not serving", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok\n"))
+	})
+	return mux
+}
+
+// Report the service as not serving while the database doesn't answer, so
+// that a replica cut off from it is taken out of rotation. It checks every
+// interval until ctx is done.
+func checkDatabaseHealth(ctx context.Context, db *sql.DB, healthServer *health.Server, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	serving := true
+	for {
+		pingCtx, cancel := context.WithTimeout(ctx, interval)
+		err := db.PingContext(pingCtx)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+		if (err == nil) != serving {
+			serving = err == nil
+			state := healthpb.HealthCheckResponse_SERVING
+			if serving {
+				log.Println("Database reachable again: serving")
+			} else {
+				state = healthpb.HealthCheckResponse_NOT_SERVING
+				log.Printf("Database unreachable, reporting not serving: %v", err)
+			}
+			healthServer.SetServingStatus("", state)
+			healthServer.SetServingStatus(pb.SessionService_ServiceDesc.ServiceName, state)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
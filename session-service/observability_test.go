@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"session-service/internal/jobs"
+	pb "session-service/proto"
+)
+
+// Metrics of reg as Prometheus scrapes them
+func scrape(reg prometheus.Gatherer) string {
+	rec := httptest.NewRecorder()
+	promhttp.HandlerFor(reg, promhttp.HandlerOpts{}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	return rec.Body.String()
+}
+
+func TestRPCMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := newRPCMetrics(reg)
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/session.SessionService/GetSession"}
+	for _, err := range []error{nil, status.Error(codes.NotFound, "Session not found: 1"), nil} {
+		m.unary(context.Background(), nil, info, func(context.Context, interface{}) (interface{}, error) { return nil, err })
+	}
+	streamInfo := &grpc.StreamServerInfo{FullMethod: "/session.SessionService/WatchSession", IsServerStream: true}
+	m.stream(nil, nil, streamInfo, func(interface{}, grpc.ServerStream) error { return nil })
+
+	out := scrape(reg)
+	for _, want := range []string{
+		`grpc_server_started_total{grpc_method="GetSession",grpc_service="session.SessionService",grpc_type="unary"} 3`,
+		`grpc_server_handled_total{grpc_code="OK",grpc_method="GetSession",grpc_service="session.SessionService",grpc_type="unary"} 2`,
+		`grpc_server_handled_total{grpc_code="NotFound",grpc_method="GetSession",grpc_service="session.SessionService",grpc_type="unary"} 1`,
+		`grpc_server_handled_total{grpc_code="OK",grpc_method="WatchSession",grpc_service="session.SessionService",grpc_type="server_stream"} 1`,
+		`grpc_server_handling_seconds_count{grpc_method="GetSession",grpc_service="session.SessionService",grpc_type="unary"} 3`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %s in:\n%s", want, out)
+		}
+	}
+}
+
+func TestJobMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	lastRun := time.Date(2025, 5, 15, 8, 0, 0, 0, time.UTC)
+	registerJobMetrics(reg, func() []jobs.Stats {
+		return []jobs.Stats{
+			{Name: "complete-sessions", Runs: 3, Failures: 1, Retries: 2, LastRun: lastRun, LastDuration: 1500 * time.Millisecond, LastError: "timeout"},
+			{Name: "reconcile-reserved-spots", Running: true},
+		}
+	})
+
+	out := scrape(reg)
+	for _, want := range []string{
+		`job_runs_total{job="complete-sessions"} 3`,
+		`job_failures_total{job="complete-sessions"} 1`,
+		`job_retries_total{job="complete-sessions"} 2`,
+		`job_last_run_timestamp_seconds{job="complete-sessions"} 1.747296e+09`,
+		`job_duration_seconds{job="complete-sessions"} 1.5`,
+		`job_last_run_success{job="complete-sessions"} 0`,
+		`job_running{job="reconcile-reserved-spots"} 1`,
+		`job_runs_total{job="reconcile-reserved-spots"} 0`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %s in:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, `job_last_run_timestamp_seconds{job="reconcile-reserved-spots"}`) {
+		t.Errorf("Expected no last run of a job that never ran:\n%s", out)
+	}
+}
+
+func TestMetricsHandler(t *testing.T) {
+	s := newTestServer()
+	reg := newMetricsRegistry()
+	promauto.With(reg).NewGaugeFunc(prometheus.GaugeOpts{Name: "session_watchers", Help: "Open WatchSession streams."},
+		func() float64 { return 2 })
+	handler := metricsHandler(reg, s.health)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+	if rec := get("/metrics"); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "session_watchers 2") ||
+		!strings.Contains(rec.Body.String(), "go_goroutines") {
+		t.Errorf("Unexpected /metrics: %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := get("/healthz"); rec.Code != http.StatusOK {
+		t.Errorf("Expected /healthz OK while serving, got %d", rec.Code)
+	}
+	s.health.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	if rec := get("/healthz"); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected /healthz unavailable while not serving, got %d", rec.Code)
+	}
+}
+
+func TestBufconnTracing(t *testing.T) {
+	spans := tracetest.NewSpanRecorder()
+	tp := trace.NewTracerProvider(trace.WithSpanProcessor(spans))
+	client := startBufconnServer(t, newTestServer(), serverOptions{tracing: tp})
+
+	// A call made in the trace of the caller's span
+	const traceID, callerSpanID = "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7"
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "traceparent", "00-"+traceID+"-"+callerSpanID+"-01")
+	if _, err := client.GetSession(ctx, &pb.GetSessionRequest{SessionId: "42"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("Expected NotFound, got %v", err)
+	}
+	client.ListSessions(context.Background(), &pb.ListSessionsRequest{})
+
+	ended := spans.Ended()
+	if len(ended) != 2 {
+		t.Fatalf("Expected a span per call, got %d", len(ended))
+	}
+	get := ended[0]
+	if get.Name() != "session.SessionService/GetSession" || get.SpanContext().TraceID().String() != traceID ||
+		get.Parent().SpanID().String() != callerSpanID || !get.Parent().IsRemote() {
+		t.Errorf("Expected GetSession traced as a child of the caller's span, got %s in trace %s under %s",
+			get.Name(), get.SpanContext().TraceID(), get.Parent().SpanID())
+	}
+	if list := ended[1]; list.Name() != "session.SessionService/ListSessions" || list.Parent().IsValid() {
+		t.Errorf("Expected ListSessions traced as a root span, got %s under %s", list.Name(), list.Parent().SpanID())
+	}
+}
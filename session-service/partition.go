@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+
+	"session-service/internal/store"
+)
+
+// Schema the unpartitioned tables are moved to while their rows are copied
+const unpartitionedSchema = "unpartitioned"
+
+// Columns copied from the unpartitioned tables, which lack gym_id
+var partitionedCopies = []struct {
+	table   string
+	columns string
+}{
+	{"sessions", `id, title, description, coach_id, coach_name, capacity, reserved_spots,
+		start_time, end_time, location, session_type, difficulty_level, is_cancelled,
+		cancellation_reason, created_at, updated_at`},
+	{"reservations", `id, session_id, user_id, user_name, reservation_time, status, created_at, updated_at`},
+}
+
+// Columns copied above that the tables of the first release lack. Later
+// releases added them when starting, so they may exist already.
+const unpartitionedAddedColumns = `ALTER TABLE ` + unpartitionedSchema + `.sessions ADD COLUMN IF NOT EXISTS cancellation_reason TEXT`
+
+// Implementation of the partition command
+func runPartition(args []string) {
+	flags := flag.NewFlagSet("partition", flag.ExitOnError)
+	gym := flags.String("gym", store.DefaultGym, "Gym the existing sessions belong to")
+	keep := flags.Bool("keep", false, "Keep the old tables in the "+unpartitionedSchema+" schema")
+	flags.Parse(args)
+
+	db, err := sql.Open("postgres", databaseURL())
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	copied, err := partitionTables(context.Background(), db, *gym, *keep)
+	if err != nil {
+		log.Fatalf("Failed to partition tables: %v", err)
+	}
+	if copied == nil {
+		log.Printf("Tables are already partitioned")
+		return
+	}
+	log.Printf("Moved %d sessions and %d reservations to gym %s", copied["sessions"], copied["reservations"], *gym)
+}
+
+// Replace the unpartitioned tables by partitioned ones holding the same
+// rows, all in gymID. It runs in one transaction, which holds the old
+// tables locked: nothing changes if it fails, but the service must be
+// stopped meanwhile. It returns the number of rows copied per table, or
+// nil if the tables were partitioned already.
+func partitionTables(ctx context.Context, db *sql.DB, gymID string, keep bool) (map[string]int64, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	partitioned, err := sessionsPartitioned(ctx, tx)
+	if err != nil || partitioned {
+		return nil, err
+	}
+
+	// The old tables take their sequences, indexes and constraints along,
+	// which frees the names for the new ones
+	if _, err := tx.ExecContext(ctx, `CREATE SCHEMA `+unpartitionedSchema); err != nil {
+		return nil, err
+	}
+	for _, c := range partitionedCopies {
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf(`ALTER TABLE %s SET SCHEMA %s`, c.table, unpartitionedSchema)); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, unpartitionedAddedColumns); err != nil {
+		return nil, err
+	}
+	if err := createVersionedSchema(ctx, tx); err != nil {
+		return nil, fmt.Errorf("creating the partitioned tables: %w", err)
+	}
+
+	copied := make(map[string]int64)
+	for _, c := range partitionedCopies {
+		res, err := tx.ExecContext(ctx, fmt.Sprintf(
+			`INSERT INTO %[1]s (gym_id, %[2]s) SELECT $1, %[2]s FROM %[3]s.%[1]s`,
+			c.table, c.columns, unpartitionedSchema), gymID)
+		if err != nil {
+			return nil, fmt.Errorf("copying %s: %w", c.table, err)
+		}
+		if copied[c.table], err = res.RowsAffected(); err != nil {
+			return nil, err
+		}
+
+		// Continue the ID sequence after the copied rows
+		_, err = tx.ExecContext(ctx, fmt.Sprintf(
+			`SELECT setval(pg_get_serial_sequence('%[1]s', 'id'), COALESCE(MAX(id), 1), MAX(id) IS NOT NULL) FROM %[1]s`,
+			c.table))
+		if err != nil {
+			return nil, fmt.Errorf("resetting %s id sequence: %w", c.table, err)
+		}
+	}
+
+	if !keep {
+		if _, err := tx.ExecContext(ctx, `DROP SCHEMA `+unpartitionedSchema+` CASCADE`); err != nil {
+			return nil, err
+		}
+	}
+	return copied, tx.Commit()
+}
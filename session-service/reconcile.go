@@ -0,0 +1,26 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"session-service/internal/store"
+)
+
+// Period of the reserved_spots reconciliation unless RECONCILE_INTERVAL is set
+const defaultReconcileInterval = 15 * time.Minute
+
+// Repair reserved_spots drift. Bookings keep the column in step by
+// themselves; drift means a bug or a manual edit, so every repair is logged.
+func reconcileReservedSpots(ctx context.Context, repo store.ReservationRepository) error {
+	drift, err := repo.ReconcileReservedSpots(ctx)
+	if err != nil {
+		return err
+	}
+	for _, d := range drift {
+		log.Printf("Repaired reserved spots of session %d: recorded %d, confirmed reservations %d",
+			d.SessionID, d.Recorded, d.Actual)
+	}
+	return nil
+}
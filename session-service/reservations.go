@@ -0,0 +1,337 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"session-service/internal/auth"
+	"session-service/internal/queue"
+	"session-service/internal/store"
+	pb "session-service/proto"
+)
+
+// Convert a stored reservation to its protobuf representation
+func reservationToProto(r *store.Reservation) *pb.Reservation {
+	return &pb.Reservation{
+		Id:              strconv.FormatInt(r.ID, 10),
+		SessionId:       strconv.FormatInt(r.SessionID, 10),
+		UserId:          r.UserID,
+		UserName:        r.UserName,
+		ReservationTime: formatTimestamp(r.ReservationTime),
+		Status:          r.Status,
+		CreatedAt:       formatTimestamp(r.CreatedAt),
+		UpdatedAt:       formatTimestamp(r.UpdatedAt),
+	}
+}
+
+// Status of a failed booking
+func bookingStatus(err error) *status.Status {
+	switch err {
+	case store.ErrNotFound:
+		return status.New(codes.NotFound, "Session not found")
+	case store.ErrAlreadyCancelled:
+		return status.New(codes.FailedPrecondition, "Session cancelled")
+	case store.ErrSessionCompleted:
+		return status.New(codes.FailedPrecondition, "Session already completed")
+	case store.ErrAlreadyBooked:
+		return status.New(codes.AlreadyExists, "Already booked")
+	case store.ErrSessionFull:
+		return status.New(codes.ResourceExhausted, "Session full")
+	case store.ErrBatchAborted:
+		return status.New(codes.Aborted, "Not booked because another booking of the batch failed")
+	}
+	return status.Newf(storeErrorCode(err), "Failed to create reservation: %v", err)
+}
+
+// Implementation of CreateReservation RPC. Capacity is enforced by the
+// store, which takes the spot and records the booking in one transaction.
+func (s *server) CreateReservation(ctx context.Context, req *pb.CreateReservationRequest) (*pb.Reservation, error) {
+	id, err := strconv.ParseInt(req.SessionId, 10, 64)
+	if err != nil {
+		return nil, status.Errorf(codes.NotFound, "Session not found: %v", req.SessionId)
+	}
+	reservation, err := validateReservation(id, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	reservation.UserName = "Member Name" // In a real app, would fetch this from the User service
+	if err := s.checkDirectBooking(ctx, id); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.CreateReservation(ctx, reservation); err != nil {
+		return nil, bookingStatus(err).Err()
+	}
+	return reservationToProto(reservation), nil
+}
+
+// Implementation of BatchCreateReservations RPC
+func (s *server) BatchCreateReservations(ctx context.Context, req *pb.BatchCreateReservationsRequest) (*pb.BatchCreateReservationsResponse, error) {
+	id, err := strconv.ParseInt(req.SessionId, 10, 64)
+	if err != nil {
+		return nil, status.Errorf(codes.NotFound, "Session not found: %v", req.SessionId)
+	}
+	reservations, err := validateBatchCreateReservations(id, req)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range reservations {
+		r.UserName = "Member Name" // In a real app, would fetch this from the User service
+	}
+	if err := s.checkDirectBooking(ctx, id); err != nil {
+		return nil, err
+	}
+
+	errs, err := s.repo.CreateReservations(ctx, reservations, !req.BestEffort)
+	if err != nil {
+		return nil, status.Errorf(storeErrorCode(err), "Failed to create reservations: %v", err)
+	}
+
+	// Every booking fails the same way when the session itself can't be booked
+	switch errs[0] {
+	case store.ErrNotFound:
+		return nil, status.Errorf(codes.NotFound, "Session not found: %v", req.SessionId)
+	case store.ErrAlreadyCancelled:
+		return nil, status.Errorf(codes.FailedPrecondition, "Session cancelled: %v", req.SessionId)
+	case store.ErrSessionCompleted:
+		return nil, status.Errorf(codes.FailedPrecondition, "Session already completed: %v", req.SessionId)
+	}
+
+	resp := &pb.BatchCreateReservationsResponse{Results: make([]*pb.BatchReservationResult, len(reservations))}
+	for i, r := range reservations {
+		result := &pb.BatchReservationResult{UserId: r.UserID, Code: codes.OK.String()}
+		if errs[i] == nil {
+			result.Reservation = reservationToProto(r)
+			resp.Booked++
+		} else {
+			st := bookingStatus(errs[i])
+			result.Code = st.Code().String()
+			result.Message = st.Message()
+		}
+		resp.Results[i] = result
+	}
+	return resp, nil
+}
+
+// Refuse to book a session that takes queued bookings only, which would let
+// the caller jump the line
+func (s *server) checkDirectBooking(ctx context.Context, id int64) error {
+	session, err := s.repo.GetSession(ctx, id)
+	if err == store.ErrNotFound {
+		return status.Errorf(codes.NotFound, "Session not found: %v", id)
+	}
+	if err != nil {
+		return status.Errorf(storeErrorCode(err), "Failed to get session: %v", err)
+	}
+	if session.QueuedBooking {
+		return status.Errorf(codes.FailedPrecondition, "Session takes queued bookings only: %v", id)
+	}
+	return nil
+}
+
+// How often a queued booking request is told its position
+const queueStatusInterval = time.Second
+
+// Implementation of QueueReservation RPC
+func (s *server) QueueReservation(req *pb.QueueReservationRequest, stream pb.SessionService_QueueReservationServer) error {
+	ctx := stream.Context()
+	id, err := strconv.ParseInt(req.SessionId, 10, 64)
+	if err != nil {
+		return status.Errorf(codes.NotFound, "Session not found: %v", req.SessionId)
+	}
+	reservation, err := validateReservation(id, req.UserId)
+	if err != nil {
+		return err
+	}
+	reservation.UserName = "Member Name" // In a real app, would fetch this from the User service
+
+	session, err := s.repo.GetSession(ctx, id)
+	if err == store.ErrNotFound {
+		return status.Errorf(codes.NotFound, "Session not found: %v", req.SessionId)
+	}
+	if err != nil {
+		return status.Errorf(storeErrorCode(err), "Failed to get session: %v", err)
+	}
+	if !session.QueuedBooking {
+		return status.Errorf(codes.FailedPrecondition, "Session does not take queued bookings: %v", req.SessionId)
+	}
+
+	ticket, err := s.queue.Join(ctx, reservation)
+	if err == queue.ErrQueueFull {
+		return status.Errorf(codes.ResourceExhausted, "Booking queue full: %v", req.SessionId)
+	}
+	if err != nil {
+		return status.Errorf(codes.Internal, "Failed to queue reservation: %v", err)
+	}
+	defer ticket.Leave()
+
+	ticker := time.NewTicker(queueStatusInterval)
+	defer ticker.Stop()
+	position := -1
+	for {
+		if p := ticket.Position(); p != position {
+			position = p
+			if err := stream.Send(&pb.QueueStatus{State: "queued", Position: int32(position)}); err != nil {
+				return err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case <-ticket.Done():
+			return stream.Send(queueResult(ticket))
+		case <-ticker.C:
+		}
+	}
+}
+
+// Final status of a served booking request
+func queueResult(ticket *queue.Ticket) *pb.QueueStatus {
+	r, err := ticket.Result()
+	if err != nil {
+		st := bookingStatus(err)
+		return &pb.QueueStatus{State: "failed", Code: st.Code().String(), Message: st.Message()}
+	}
+	return &pb.QueueStatus{State: "booked", Reservation: reservationToProto(r)}
+}
+
+// Implementation of GetReservation RPC
+func (s *server) GetReservation(ctx context.Context, req *pb.GetReservationRequest) (*pb.Reservation, error) {
+	id, err := strconv.ParseInt(req.ReservationId, 10, 64)
+	if err != nil {
+		return nil, status.Errorf(codes.NotFound, "Reservation not found: %v", req.ReservationId)
+	}
+
+	reservation, err := s.repo.GetReservation(ctx, id)
+	if err == store.ErrNotFound {
+		return nil, status.Errorf(codes.NotFound, "Reservation not found: %v", req.ReservationId)
+	}
+	if err != nil {
+		return nil, status.Errorf(storeErrorCode(err), "Failed to get reservation: %v", err)
+	}
+	// Members only see their own reservations, or whether others exist
+	if id, ok := auth.FromContext(ctx); ok && !id.IsStaff() && id.UserID != reservation.UserID {
+		return nil, status.Errorf(codes.NotFound, "Reservation not found: %v", req.ReservationId)
+	}
+	return reservationToProto(reservation), nil
+}
+
+// Implementation of CancelReservation RPC. Members may only cancel their own
+// reservations.
+func (s *server) CancelReservation(ctx context.Context, req *pb.CancelReservationRequest) (*pb.CancelReservationResponse, error) {
+	id, err := strconv.ParseInt(req.ReservationId, 10, 64)
+	if err != nil {
+		return nil, status.Errorf(codes.NotFound, "Reservation not found: %v", req.ReservationId)
+	}
+	if req.UserId == "" {
+		return nil, status.Error(codes.InvalidArgument, "Missing required fields")
+	}
+
+	reservation, err := s.repo.GetReservation(ctx, id)
+	if err == nil && reservation.UserID != req.UserId {
+		return nil, status.Errorf(codes.PermissionDenied, "Reservation %v belongs to another user", req.ReservationId)
+	}
+	if err == nil {
+		_, err = s.repo.CancelReservation(ctx, id)
+	}
+	switch err {
+	case nil:
+	case store.ErrNotFound:
+		return nil, status.Errorf(codes.NotFound, "Reservation not found: %v", req.ReservationId)
+	case store.ErrAlreadyCancelled:
+		return nil, status.Errorf(codes.FailedPrecondition, "Reservation already cancelled: %v", req.ReservationId)
+	case store.ErrCheckedIn:
+		return nil, status.Errorf(codes.FailedPrecondition, "Member already checked in: %v", req.ReservationId)
+	case store.ErrSessionCompleted:
+		return nil, status.Errorf(codes.FailedPrecondition, "Session already completed: %v", reservation.SessionID)
+	default:
+		return nil, status.Errorf(storeErrorCode(err), "Failed to cancel reservation: %v", err)
+	}
+
+	return &pb.CancelReservationResponse{Success: true, Message: "Reservation cancelled successfully"}, nil
+}
+
+// Implementation of CheckInReservation RPC. Staff check members in as they
+// arrive; those left confirmed when the session completes are no-shows.
+func (s *server) CheckInReservation(ctx context.Context, req *pb.CheckInReservationRequest) (*pb.Reservation, error) {
+	id, err := strconv.ParseInt(req.ReservationId, 10, 64)
+	if err != nil {
+		return nil, status.Errorf(codes.NotFound, "Reservation not found: %v", req.ReservationId)
+	}
+
+	reservation, err := s.repo.CheckIn(ctx, id)
+	switch err {
+	case nil:
+	case store.ErrNotFound:
+		return nil, status.Errorf(codes.NotFound, "Reservation not found: %v", req.ReservationId)
+	case store.ErrAlreadyCancelled:
+		return nil, status.Errorf(codes.FailedPrecondition, "Reservation or its session cancelled: %v", req.ReservationId)
+	default:
+		return nil, status.Errorf(storeErrorCode(err), "Failed to check in: %v", err)
+	}
+	return reservationToProto(reservation), nil
+}
+
+// Implementation of ListUserReservations RPC
+func (s *server) ListUserReservations(ctx context.Context, req *pb.ListUserReservationsRequest) (*pb.ListReservationsResponse, error) {
+	filter, pageSize, err := validateListUserReservations(req, s.clock.Now())
+	if err != nil {
+		return nil, err
+	}
+	query := queryHash(req.UserId, req.Status, req.IncludePast)
+	return s.listReservations(ctx, filter, req.PageToken, query, pageSize)
+}
+
+// Implementation of ListSessionReservations RPC
+func (s *server) ListSessionReservations(ctx context.Context, req *pb.ListSessionReservationsRequest) (*pb.ListReservationsResponse, error) {
+	id, err := strconv.ParseInt(req.SessionId, 10, 64)
+	if err != nil {
+		return nil, status.Errorf(codes.NotFound, "Session not found: %v", req.SessionId)
+	}
+	filter, pageSize, err := validateListSessionReservations(id, req)
+	if err != nil {
+		return nil, err
+	}
+	// An empty list must not hide a typo in the session ID
+	if _, err := s.repo.GetSession(ctx, id); err == store.ErrNotFound {
+		return nil, status.Errorf(codes.NotFound, "Session not found: %v", req.SessionId)
+	} else if err != nil {
+		return nil, status.Errorf(storeErrorCode(err), "Failed to get session: %v", err)
+	}
+	query := queryHash(req.SessionId, req.Status)
+	return s.listReservations(ctx, filter, req.PageToken, query, pageSize)
+}
+
+// Read the page of reservations that encoded, a page token of a request
+// whose query hashes to query, starts after
+func (s *server) listReservations(ctx context.Context, filter store.ReservationFilter, encoded string, query uint64, pageSize int) (*pb.ListReservationsResponse, error) {
+	token, err := decodePageToken(encoded, query)
+	if err != nil {
+		return nil, err
+	}
+	var afterID int64
+	if token != nil {
+		afterID = token.ID
+	}
+
+	// One more than a page tells whether another page follows
+	reservations, err := s.repo.ListReservations(ctx, filter, afterID, pageSize+1)
+	if err != nil {
+		return nil, status.Errorf(storeErrorCode(err), "Failed to list reservations: %v", err)
+	}
+
+	resp := &pb.ListReservationsResponse{}
+	if len(reservations) > pageSize {
+		reservations = reservations[:pageSize]
+		resp.NextPageToken = encodePageToken(pageToken{ID: reservations[pageSize-1].ID, Query: query})
+	}
+	for _, r := range reservations {
+		resp.Reservations = append(resp.Reservations, reservationToProto(r))
+	}
+	return resp, nil
+}
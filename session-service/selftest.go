@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"session-service/internal/store"
+	pb "session-service/proto"
+)
+
+// Coach and member ID used for the scratch records of RunSelfTest
+const selfTestUserID = "selftest"
+
+// Implementation of RunSelfTest RPC. It runs a create, book, cancel and
+// delete round trip on a scratch session and reports how long each step took.
+// The cancel step cancels the reservation, the path members take, and checks
+// that its spot was given back.
+// A failed step skips the remaining ones, except for delete, which always
+// runs once the scratch session exists so that it does not leak.
+func (s *server) RunSelfTest(ctx context.Context, req *pb.RunSelfTestRequest) (*pb.RunSelfTestResponse, error) {
+	resp := &pb.RunSelfTestResponse{Ok: true}
+	// Latencies are wall-clock time, whatever clock the server runs on
+	began := time.Now()
+	step := func(name string, run func() error) bool {
+		start := time.Now()
+		err := run()
+		result := &pb.SelfTestStep{Name: name, Ok: err == nil, LatencyMs: milliseconds(time.Since(start))}
+		if err != nil {
+			result.Error = err.Error()
+			resp.Ok = false
+		}
+		resp.Steps = append(resp.Steps, result)
+		return err == nil
+	}
+
+	// Far enough in the future not to show up in anyone's schedule
+	startTime := s.clock.Now().AddDate(1, 0, 0).Truncate(time.Hour)
+	session := &store.Session{
+		Title:           "Self-test",
+		CoachID:         selfTestUserID,
+		CoachName:       "Self-test",
+		Capacity:        1,
+		StartTime:       startTime,
+		EndTime:         startTime.Add(time.Hour),
+		Location:        "Self-test",
+		SessionType:     "selftest",
+		DifficultyLevel: "beginner",
+	}
+
+	// Reserved spots of the scratch session, checked after each change
+	checkSpots := func(want int32) error {
+		got, err := s.repo.GetSession(ctx, session.ID)
+		if err == nil && got.ReservedSpots != want {
+			err = fmt.Errorf("expected %d reserved spots, got %d", want, got.ReservedSpots)
+		}
+		return err
+	}
+
+	if step("create", func() error { return s.repo.CreateSession(ctx, session, false) }) {
+		reservation := &store.Reservation{
+			SessionID: session.ID,
+			UserID:    selfTestUserID,
+			UserName:  "Self-test",
+		}
+		booked := step("book", func() error {
+			if err := s.repo.CreateReservation(ctx, reservation); err != nil {
+				return err
+			}
+			return checkSpots(1)
+		})
+		if booked {
+			step("cancel", func() error {
+				if _, err := s.repo.CancelReservation(ctx, reservation.ID); err != nil {
+					return err
+				}
+				return checkSpots(0)
+			})
+		}
+		step("delete", func() error { return s.repo.DeleteSession(ctx, session.ID) })
+	}
+
+	resp.TotalLatencyMs = milliseconds(time.Since(began))
+	return resp, nil
+}
+
+// Express d in fractional milliseconds
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+	// The image has no zoneinfo for the time zones of series
+	_ "time/tzdata"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"session-service/internal/store"
+	pb "session-service/proto"
+)
+
+// Most occurrences a series may have
+const maxSeriesOccurrences = 200
+
+// A validated Recurrence
+type recurrence struct {
+	weekdays [7]bool // Indexed by time.Weekday
+	interval int     // In weeks
+	until    time.Time
+	count    int
+	location *time.Location
+}
+
+// Start times of the occurrences of a series whose first may start at start.
+// Dates are counted in days of r.location, and every occurrence starts at
+// the time of day of start there. It returns nil if there are more than
+// maxSeriesOccurrences.
+func (r *recurrence) startTimes(start time.Time) []time.Time {
+	start = start.In(r.location)
+	first := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+	monday := first.AddDate(0, 0, -(int(first.Weekday())+6)%7)
+
+	var times []time.Time
+	for week := 0; ; week += r.interval {
+		for day := 0; day < 7; day++ {
+			date := monday.AddDate(0, 0, 7*week+day)
+			if !r.weekdays[date.Weekday()] || date.Before(first) {
+				continue
+			}
+			if !r.until.IsZero() && date.After(r.until) {
+				return times
+			}
+			if len(times) == maxSeriesOccurrences {
+				return nil
+			}
+			times = append(times, time.Date(date.Year(), date.Month(), date.Day(),
+				start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), r.location).UTC())
+			if len(times) == r.count {
+				return times
+			}
+		}
+	}
+}
+
+// Convert the sessions of a series to their protobuf representation
+func seriesToProto(sessions []*store.Session, now time.Time) *pb.SessionSeries {
+	series := &pb.SessionSeries{}
+	for _, session := range sessions {
+		series.Sessions = append(series.Sessions, sessionToProto(session, now))
+	}
+	if len(sessions) > 0 {
+		series.SeriesId = series.Sessions[0].SeriesId
+	}
+	return series
+}
+
+// Implementation of CreateSessionSeries RPC. The coach is looked up once
+// for every occurrence.
+func (s *server) CreateSessionSeries(ctx context.Context, req *pb.CreateSessionSeriesRequest) (*pb.SessionSeries, error) {
+	sessions, err := validateCreateSessionSeries(req)
+	if err != nil {
+		return nil, err
+	}
+	first := sessions[0]
+	if gym := store.GymFromContext(ctx); gym != "" && first.GymID != "" && first.GymID != gym {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid gym_id: the call is scoped to gym %v", gym)
+	}
+	if err := checkAllowConflicts(ctx, req.Session.AllowConflicts); err != nil {
+		return nil, err
+	}
+	coachName, err := s.coachName(ctx, first.CoachID)
+	if err != nil {
+		return nil, err
+	}
+	for _, session := range sessions {
+		session.CoachName = coachName
+	}
+	if req.DryRun {
+		return s.previewCreateSessionSeries(ctx, sessions)
+	}
+
+	if err := s.repo.CreateSessionSeries(ctx, sessions, !req.Session.AllowConflicts); err != nil {
+		var conflict *store.ConflictError
+		if errors.As(err, &conflict) {
+			return nil, conflictError(first, conflict.Session)
+		}
+		return nil, status.Errorf(storeErrorCode(err), "Failed to create session series: %v", err)
+	}
+
+	return seriesToProto(sessions, s.clock.Now()), nil
+}
+
+// The occurrences of a new series and the sessions they would overlap,
+// without creating them. The conflicts are reported whether or not the
+// request allows them.
+func (s *server) previewCreateSessionSeries(ctx context.Context, sessions []*store.Session) (*pb.SessionSeries, error) {
+	conflicts, err := s.repo.FindConflicts(ctx, sessions)
+	if err != nil {
+		return nil, status.Errorf(storeErrorCode(err), "Failed to check session series conflicts: %v", err)
+	}
+
+	gymID := store.GymFromContext(ctx)
+	if gymID == "" {
+		gymID = store.DefaultGym
+	}
+	now := s.clock.Now()
+	series := &pb.SessionSeries{}
+	for _, session := range sessions {
+		if session.GymID == "" {
+			session.GymID = gymID
+		}
+		// Not stored: no ID or timestamps yet
+		preview := sessionToProto(session, now)
+		preview.Id, preview.CreatedAt, preview.UpdatedAt = "", "", ""
+		series.Sessions = append(series.Sessions, preview)
+	}
+	for _, conflict := range conflicts {
+		series.Conflicts = append(series.Conflicts, sessionToProto(conflict, now))
+	}
+	return series, nil
+}
+
+// Implementation of UpdateSessionSeries RPC
+func (s *server) UpdateSessionSeries(ctx context.Context, req *pb.UpdateSessionRequest) (*pb.SessionSeries, error) {
+	session, err := s.sessionUpdate(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := s.repo.UpdateSessionSeries(ctx, session, !req.AllowConflicts)
+	if err != nil {
+		return nil, updateSessionError(req, session, err)
+	}
+	return seriesToProto(updated, s.clock.Now()), nil
+}
+
+// Implementation of CancelSessionSeries RPC
+func (s *server) CancelSessionSeries(ctx context.Context, req *pb.CancelSessionRequest) (*pb.SessionSeries, error) {
+	id, err := strconv.ParseInt(req.SessionId, 10, 64)
+	if err != nil {
+		return nil, status.Errorf(codes.NotFound, "Session not found: %v", req.SessionId)
+	}
+	if err := validateText("reason", req.Reason, 0); err != nil {
+		return nil, err
+	}
+
+	var sessions []*store.Session
+	if req.DryRun {
+		sessions, err = s.previewCancelSessionSeries(ctx, id, req.Reason)
+	} else {
+		sessions, err = s.repo.CancelSessionSeries(ctx, id, req.Reason)
+	}
+	if err != nil {
+		return nil, cancelSessionError(req, err)
+	}
+
+	return seriesToProto(sessions, s.clock.Now()), nil
+}
+
+// Compute the result of cancelling a session and the rest of its series
+// without saving it
+func (s *server) previewCancelSessionSeries(ctx context.Context, id int64, reason string) ([]*store.Session, error) {
+	session, err := s.previewCancelSession(ctx, id, reason)
+	if err != nil {
+		return nil, err
+	}
+	if session.SeriesID == 0 {
+		return []*store.Session{session}, nil
+	}
+	later, err := s.repo.ListSessions(ctx, store.SessionFilter{
+		StartsFrom:       session.StartTime,
+		SeriesID:         session.SeriesID,
+		ExcludeCancelled: true,
+	}, nil, false, maxSeriesOccurrences)
+	if err != nil {
+		return nil, err
+	}
+	sessions := []*store.Session{session}
+	for _, other := range later {
+		if other.ID == session.ID || other.IsCompleted {
+			continue
+		}
+		other.IsCancelled = true
+		other.CancellationReason = reason
+		sessions = append(sessions, other)
+	}
+	return sessions, nil
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "session-service/proto"
+)
+
+func validCreateSessionSeriesRequest() *pb.CreateSessionSeriesRequest {
+	return &pb.CreateSessionSeriesRequest{
+		Session:    validCreateSessionRequest(),
+		Recurrence: &pb.Recurrence{Weekdays: []string{"monday", "wednesday"}, Count: 4},
+	}
+}
+
+// Start times of the sessions of a series
+func seriesStartTimes(series *pb.SessionSeries) []string {
+	times := make([]string, len(series.Sessions))
+	for i, session := range series.Sessions {
+		times[i] = session.StartTime
+	}
+	return times
+}
+
+func TestServerCreateSessionSeries(t *testing.T) {
+	tests := map[string]struct {
+		mutate func(*pb.CreateSessionSeriesRequest)
+		starts []string
+	}{
+		// The first occurrence is on the Wednesday of start_time
+		"weekdays": {
+			func(r *pb.CreateSessionSeriesRequest) {},
+			[]string{"2030-05-15T08:00:00Z", "2030-05-20T08:00:00Z", "2030-05-22T08:00:00Z", "2030-05-27T08:00:00Z"},
+		},
+		"day of start_time": {
+			func(r *pb.CreateSessionSeriesRequest) { r.Recurrence.Weekdays = nil },
+			[]string{"2030-05-15T08:00:00Z", "2030-05-22T08:00:00Z", "2030-05-29T08:00:00Z", "2030-06-05T08:00:00Z"},
+		},
+		"every other week until": {
+			func(r *pb.CreateSessionSeriesRequest) {
+				r.Recurrence = &pb.Recurrence{Weekdays: []string{"friday"}, IntervalWeeks: 2, Until: "2030-06-07"}
+			},
+			[]string{"2030-05-17T08:00:00Z", "2030-05-31T08:00:00Z"},
+		},
+		"until reached first": {
+			func(r *pb.CreateSessionSeriesRequest) { r.Recurrence.Until = "2030-05-20" },
+			[]string{"2030-05-15T08:00:00Z", "2030-05-20T08:00:00Z"},
+		},
+		// Monday in Los Angeles, Tuesday in UTC
+		"weekdays in the offset of start_time": {
+			func(r *pb.CreateSessionSeriesRequest) {
+				r.Session.StartTime, r.Session.EndTime = "2030-05-13T18:00:00-07:00", "2030-05-13T19:00:00-07:00"
+				r.Recurrence = &pb.Recurrence{Weekdays: []string{"monday"}, Count: 2}
+			},
+			[]string{"2030-05-14T01:00:00Z", "2030-05-21T01:00:00Z"},
+		},
+		// Summer time starts in Paris on March 31, 2030
+		"time zone": {
+			func(r *pb.CreateSessionSeriesRequest) {
+				r.Session.StartTime, r.Session.EndTime = "2030-03-25T18:00:00+01:00", "2030-03-25T19:00:00+01:00"
+				r.Recurrence = &pb.Recurrence{Count: 2}
+				r.TimeZone = "Europe/Paris"
+			},
+			[]string{"2030-03-25T17:00:00Z", "2030-04-01T16:00:00Z"},
+		},
+		"offset without time zone": {
+			func(r *pb.CreateSessionSeriesRequest) {
+				r.Session.StartTime, r.Session.EndTime = "2030-03-25T18:00:00+01:00", "2030-03-25T19:00:00+01:00"
+				r.Recurrence = &pb.Recurrence{Count: 2}
+			},
+			[]string{"2030-03-25T17:00:00Z", "2030-04-01T17:00:00Z"},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := newTestServer()
+			req := validCreateSessionSeriesRequest()
+			tt.mutate(req)
+			series, err := s.CreateSessionSeries(context.Background(), req)
+			if err != nil {
+				t.Fatalf("CreateSessionSeries failed: %v", err)
+			}
+			if got := seriesStartTimes(series); !reflect.DeepEqual(got, tt.starts) {
+				t.Errorf("Expected occurrences at %v, got %v", tt.starts, got)
+			}
+			for _, session := range series.Sessions {
+				if session.SeriesId == "" || session.SeriesId != series.SeriesId || session.Title != req.Session.Title {
+					t.Errorf("Expected an occurrence of series %s, got %+v", series.SeriesId, session)
+				}
+			}
+		})
+	}
+}
+
+func TestServerCreateSessionSeriesDryRun(t *testing.T) {
+	s := newTestServer()
+	ctx := context.Background()
+
+	// The coach runs another class during the second occurrence
+	other := validCreateSessionRequest()
+	other.Location = "Studio B"
+	other.StartTime, other.EndTime = "2030-05-20T08:30:00Z", "2030-05-20T09:30:00Z"
+	conflict, err := s.CreateSession(ctx, other)
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	other.StartTime, other.EndTime = "2030-05-22T08:30:00Z", "2030-05-22T09:30:00Z"
+	cancelled, err := s.CreateSession(ctx, other)
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	if _, err := s.CancelSession(ctx, &pb.CancelSessionRequest{SessionId: cancelled.Id}); err != nil {
+		t.Fatalf("CancelSession failed: %v", err)
+	}
+
+	req := validCreateSessionSeriesRequest()
+	req.DryRun = true
+	preview, err := s.CreateSessionSeries(ctx, req)
+	if err != nil {
+		t.Fatalf("CreateSessionSeries failed: %v", err)
+	}
+	want := []string{"2030-05-15T08:00:00Z", "2030-05-20T08:00:00Z", "2030-05-22T08:00:00Z", "2030-05-27T08:00:00Z"}
+	if got := seriesStartTimes(preview); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected occurrences at %v, got %v", want, got)
+	}
+	for _, session := range preview.Sessions {
+		if session.Id != "" || session.SeriesId != "" || session.GymId == "" || session.CoachName == "" {
+			t.Errorf("Expected an occurrence not stored, got %+v", session)
+		}
+	}
+	if len(preview.Conflicts) != 1 || preview.Conflicts[0].Id != conflict.Id {
+		t.Errorf("Expected session %s in the way, got %v", conflict.Id, preview.Conflicts)
+	}
+
+	listed, err := s.ListSessions(ctx, &pb.ListSessionsRequest{})
+	if err != nil || len(listed.Sessions) != 2 {
+		t.Errorf("Expected no occurrence stored, got %v, %v", listed, err)
+	}
+}
+
+func TestServerCreateSessionSeriesValidation(t *testing.T) {
+	s := newTestServer()
+	ctx := context.Background()
+	if _, err := s.CreateSessionSeries(ctx, validCreateSessionSeriesRequest()); err != nil {
+		t.Fatalf("CreateSessionSeries failed: %v", err)
+	}
+
+	tests := map[string]struct {
+		mutate func(*pb.CreateSessionSeriesRequest)
+		code   codes.Code
+	}{
+		"missing session":    {func(r *pb.CreateSessionSeriesRequest) { r.Session = nil }, codes.InvalidArgument},
+		"missing recurrence": {func(r *pb.CreateSessionSeriesRequest) { r.Recurrence = nil }, codes.InvalidArgument},
+		"invalid session":    {func(r *pb.CreateSessionSeriesRequest) { r.Session.Title = "" }, codes.InvalidArgument},
+		"no end":             {func(r *pb.CreateSessionSeriesRequest) { r.Recurrence.Count = 0 }, codes.InvalidArgument},
+		"unknown weekday":    {func(r *pb.CreateSessionSeriesRequest) { r.Recurrence.Weekdays = []string{"Monday"} }, codes.InvalidArgument},
+		"negative interval":  {func(r *pb.CreateSessionSeriesRequest) { r.Recurrence.IntervalWeeks = -1 }, codes.InvalidArgument},
+		"count too high":     {func(r *pb.CreateSessionSeriesRequest) { r.Recurrence.Count = 201 }, codes.InvalidArgument},
+		"malformed until":    {func(r *pb.CreateSessionSeriesRequest) { r.Recurrence.Until = "15/05/2030" }, codes.InvalidArgument},
+		"until before start": {func(r *pb.CreateSessionSeriesRequest) { r.Recurrence.Until = "2030-05-14" }, codes.InvalidArgument},
+		"unknown time zone":  {func(r *pb.CreateSessionSeriesRequest) { r.TimeZone = "Europe/Atlantis" }, codes.InvalidArgument},
+		"too many": {
+			func(r *pb.CreateSessionSeriesRequest) { r.Recurrence.Count, r.Recurrence.Until = 0, "2040-01-01" },
+			codes.InvalidArgument,
+		},
+		// The second occurrence meets coach-1 on May 15
+		"conflict": {
+			func(r *pb.CreateSessionSeriesRequest) {
+				r.Session.CoachId, r.Session.StartTime, r.Session.EndTime = "coach-1", "2030-05-08T08:00:00Z", "2030-05-08T09:00:00Z"
+				r.Recurrence.Weekdays = nil
+			},
+			codes.FailedPrecondition,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := validCreateSessionSeriesRequest()
+			req.Session.Location, req.Session.CoachId = "Studio C", "coach-3"
+			tt.mutate(req)
+			if _, err := s.CreateSessionSeries(ctx, req); status.Code(err) != tt.code {
+				t.Errorf("Expected %v, got %v", tt.code, err)
+			}
+		})
+	}
+
+	// The conflicting series was refused as a whole
+	list, err := s.ListSessions(ctx, &pb.ListSessionsRequest{CoachId: "coach-1", IncludePast: true})
+	if err != nil {
+		t.Fatalf("ListSessions failed: %v", err)
+	}
+	if len(list.Sessions) != 4 {
+		t.Errorf("Expected only the 4 occurrences of the first series of coach-1, got %d", len(list.Sessions))
+	}
+}
+
+func TestServerUpdateSessionSeries(t *testing.T) {
+	s := newTestServer()
+	ctx := context.Background()
+	series, err := s.CreateSessionSeries(ctx, validCreateSessionSeriesRequest())
+	if err != nil {
+		t.Fatalf("CreateSessionSeries failed: %v", err)
+	}
+	second := series.Sessions[1]
+	request := func() *pb.UpdateSessionRequest {
+		return &pb.UpdateSessionRequest{
+			SessionId:       second.Id,
+			Title:           "Evening Yoga",
+			CoachId:         second.CoachId,
+			Capacity:        10,
+			StartTime:       "2030-05-20T18:00:00Z",
+			EndTime:         "2030-05-20T19:30:00Z",
+			Location:        second.Location,
+			SessionType:     second.SessionType,
+			DifficultyLevel: second.DifficultyLevel,
+		}
+	}
+
+	updated, err := s.UpdateSessionSeries(ctx, request())
+	if err != nil {
+		t.Fatalf("UpdateSessionSeries failed: %v", err)
+	}
+	want := []string{"2030-05-20T18:00:00Z", "2030-05-22T18:00:00Z", "2030-05-27T18:00:00Z"}
+	if got := seriesStartTimes(updated); !reflect.DeepEqual(got, want) || updated.SeriesId != series.SeriesId {
+		t.Errorf("Expected the rest of series %s at %v, got %v in %s", series.SeriesId, want, got, updated.SeriesId)
+	}
+	for _, session := range updated.Sessions {
+		if session.Title != "Evening Yoga" || session.EndTime[11:] != "19:30:00Z" {
+			t.Errorf("Expected session %s updated, got %+v", session.Id, session)
+		}
+	}
+	first, err := s.GetSession(ctx, &pb.GetSessionRequest{SessionId: series.Sessions[0].Id})
+	if err != nil || first.Title != "Morning Yoga" {
+		t.Errorf("Expected the first occurrence left alone, got %+v, %v", first, err)
+	}
+
+	tests := map[string]struct {
+		mutate func(*pb.UpdateSessionRequest)
+		code   codes.Code
+	}{
+		"unknown session": {func(r *pb.UpdateSessionRequest) { r.SessionId = "42" }, codes.NotFound},
+		"missing title":   {func(r *pb.UpdateSessionRequest) { r.Title = "" }, codes.InvalidArgument},
+		// Onto the first occurrence, which doesn't move
+		"conflict": {func(r *pb.UpdateSessionRequest) {
+			r.StartTime, r.EndTime = "2030-05-15T08:30:00Z", "2030-05-15T09:30:00Z"
+		}, codes.FailedPrecondition},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := request()
+			tt.mutate(req)
+			if _, err := s.UpdateSessionSeries(ctx, req); status.Code(err) != tt.code {
+				t.Errorf("Expected %v, got %v", tt.code, err)
+			}
+		})
+	}
+}
+
+func TestServerCancelSessionSeries(t *testing.T) {
+	s := newTestServer()
+	ctx := context.Background()
+	series, err := s.CreateSessionSeries(ctx, validCreateSessionSeriesRequest())
+	if err != nil {
+		t.Fatalf("CreateSessionSeries failed: %v", err)
+	}
+	third := series.Sessions[2].Id
+	if _, err := s.CancelSession(ctx, &pb.CancelSessionRequest{SessionId: third, Reason: "Holiday"}); err != nil {
+		t.Fatalf("CancelSession failed: %v", err)
+	}
+
+	req := &pb.CancelSessionRequest{SessionId: series.Sessions[1].Id, Reason: "Coach left", DryRun: true}
+	want := []string{series.Sessions[1].Id, series.Sessions[3].Id}
+	for _, dryRun := range []bool{true, false} {
+		req.DryRun = dryRun
+		cancelled, err := s.CancelSessionSeries(ctx, req)
+		if err != nil {
+			t.Fatalf("CancelSessionSeries (dry run %v) failed: %v", dryRun, err)
+		}
+		var ids []string
+		for _, session := range cancelled.Sessions {
+			ids = append(ids, session.Id)
+			if session.Status != "cancelled" || session.CancellationReason != "Coach left" {
+				t.Errorf("Dry run %v: expected session %s cancelled, got %+v", dryRun, session.Id, session)
+			}
+		}
+		if !reflect.DeepEqual(ids, want) {
+			t.Errorf("Dry run %v: expected sessions %v cancelled, got %v", dryRun, want, ids)
+		}
+
+		last, err := s.GetSession(ctx, &pb.GetSessionRequest{SessionId: series.Sessions[3].Id})
+		if err != nil || last.IsCancelled != !dryRun {
+			t.Errorf("Dry run %v: expected the last occurrence cancelled %v, got %+v, %v", dryRun, !dryRun, last, err)
+		}
+	}
+
+	if _, err := s.CancelSessionSeries(ctx, req); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("Expected FailedPrecondition for a cancelled occurrence, got %v", err)
+	}
+	if _, err := s.CancelSessionSeries(ctx, &pb.CancelSessionRequest{SessionId: "abc"}); status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound for a malformed ID, got %v", err)
+	}
+
+	list, err := s.ListSessions(ctx, &pb.ListSessionsRequest{SeriesId: series.SeriesId, ExcludeCancelled: true})
+	if err != nil || len(list.Sessions) != 1 || list.Sessions[0].Id != series.Sessions[0].Id {
+		t.Errorf("Expected the first occurrence left in the series, got %+v, %v", list, err)
+	}
+	if _, err := s.ListSessions(ctx, &pb.ListSessionsRequest{SeriesId: "abc"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument for a malformed series_id, got %v", err)
+	}
+}
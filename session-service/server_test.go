@@ -0,0 +1,1159 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"session-service/internal/auth"
+	"session-service/internal/cache"
+	"session-service/internal/clock"
+	"session-service/internal/fixtures"
+	"session-service/internal/store"
+	"session-service/internal/users"
+	pb "session-service/proto"
+)
+
+// Start of the test sessions; test clocks are set relative to it
+var testSessionStart = time.Date(2030, 5, 15, 8, 0, 0, 0, time.UTC)
+
+func newTestServer() *server {
+	return newTestServerAt(testSessionStart.Add(-24 * time.Hour))
+}
+
+// Create a server whose clock is stopped at now
+func newTestServerAt(now time.Time) *server {
+	clk := clock.NewFake(now)
+	return newServer(store.NewMemoryWithClock(clk), clk)
+}
+
+func validCreateSessionRequest() *pb.CreateSessionRequest {
+	return &pb.CreateSessionRequest{
+		Title:           "Morning Yoga",
+		CoachId:         "coach-1",
+		Capacity:        15,
+		StartTime:       "2030-05-15T08:00:00Z",
+		EndTime:         "2030-05-15T09:00:00Z",
+		Location:        "Studio A",
+		SessionType:     "yoga",
+		DifficultyLevel: "intermediate",
+	}
+}
+
+func TestServerCreateSession(t *testing.T) {
+	s := newTestServer()
+	ctx := context.Background()
+
+	created, err := s.CreateSession(ctx, validCreateSessionRequest())
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+
+	got, err := s.GetSession(ctx, &pb.GetSessionRequest{SessionId: created.Id})
+	if err != nil {
+		t.Fatalf("GetSession failed: %v", err)
+	}
+	if got.Title != "Morning Yoga" || got.StartTime != "2030-05-15T08:00:00Z" || got.ReservedSpots != 0 {
+		t.Errorf("Unexpected session %+v", got)
+	}
+}
+
+func TestServerCreateSessionValidation(t *testing.T) {
+	s := newTestServer()
+
+	tests := map[string]func(*pb.CreateSessionRequest){
+		"missing title":      func(r *pb.CreateSessionRequest) { r.Title = "" },
+		"zero capacity":      func(r *pb.CreateSessionRequest) { r.Capacity = 0 },
+		"malformed start":    func(r *pb.CreateSessionRequest) { r.StartTime = "tomorrow" },
+		"malformed end":      func(r *pb.CreateSessionRequest) { r.EndTime = "2030-05-15 09:00" },
+		"missing difficulty": func(r *pb.CreateSessionRequest) { r.DifficultyLevel = "" },
+		"end before start":   func(r *pb.CreateSessionRequest) { r.EndTime = "2030-05-15T07:00:00Z" },
+	}
+	for name, mutate := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := validCreateSessionRequest()
+			mutate(req)
+			_, err := s.CreateSession(context.Background(), req)
+			if status.Code(err) != codes.InvalidArgument {
+				t.Errorf("Expected InvalidArgument, got %v", err)
+			}
+		})
+	}
+}
+
+func TestServerGetSessionNotFound(t *testing.T) {
+	s := newTestServer()
+
+	for _, id := range []string{"42", "abc", ""} {
+		_, err := s.GetSession(context.Background(), &pb.GetSessionRequest{SessionId: id})
+		if status.Code(err) != codes.NotFound {
+			t.Errorf("GetSession(%q): expected NotFound, got %v", id, err)
+		}
+	}
+}
+
+func TestServerCreateSessionConflicts(t *testing.T) {
+	s := newTestServer()
+	ctx := context.Background()
+	as := func(role string) context.Context {
+		return auth.WithIdentity(ctx, &auth.Identity{UserID: role + "-1", Role: role})
+	}
+
+	if _, err := s.CreateSession(ctx, validCreateSessionRequest()); err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	req := validCreateSessionRequest()
+	req.CoachId = "coach-2"
+	req.StartTime = "2030-05-15T10:30:00+02:00"
+	req.EndTime = "2030-05-15T11:30:00+02:00"
+	_, err := s.CreateSession(as(auth.RoleCoach), req)
+	if status.Code(err) != codes.FailedPrecondition ||
+		status.Convert(err).Message() != `Schedule conflict with session 1 "Morning Yoga": same location Studio A from 2030-05-15T08:00:00Z to 2030-05-15T09:00:00Z` {
+		t.Errorf("Expected a conflict with session 1, got %v", err)
+	}
+
+	req.AllowConflicts = true
+	if _, err := s.CreateSession(as(auth.RoleCoach), req); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Expected PermissionDenied allowing conflicts as a coach, got %v", err)
+	}
+	if _, err := s.CreateSession(as(auth.RoleAdmin), req); err != nil {
+		t.Errorf("Expected an admin to allow the conflict, got %v", err)
+	}
+}
+
+func TestServerUpdateSession(t *testing.T) {
+	s := newTestServer()
+	s.users = userDirectory(&users.User{ID: "coach-2", FirstName: "Mike", LastName: "Chen", Role: "coach"})
+	ctx := context.Background()
+
+	created, err := fixtures.NewTestSession().StartingAt(testSessionStart).WithCapacity(1).Full().Create(ctx, s.repo)
+	if err != nil {
+		t.Fatalf("Failed to create fixture: %v", err)
+	}
+	id := strconv.FormatInt(created.ID, 10)
+	if _, err := s.JoinWaitlist(ctx, &pb.JoinWaitlistRequest{SessionId: id, UserId: "member-2"}); err != nil {
+		t.Fatalf("JoinWaitlist failed: %v", err)
+	}
+	request := func() *pb.UpdateSessionRequest {
+		return &pb.UpdateSessionRequest{
+			SessionId:       id,
+			Title:           "Evening Yoga",
+			Description:     created.Description,
+			CoachId:         created.CoachID,
+			Capacity:        2,
+			StartTime:       formatTimestamp(created.StartTime),
+			EndTime:         formatTimestamp(created.EndTime),
+			Location:        created.Location,
+			SessionType:     created.SessionType,
+			DifficultyLevel: created.DifficultyLevel,
+		}
+	}
+
+	updated, err := s.UpdateSession(ctx, request())
+	if err != nil {
+		t.Fatalf("UpdateSession failed: %v", err)
+	}
+	if updated.Title != "Evening Yoga" || updated.CoachName != created.CoachName || updated.ReservedSpots != 2 {
+		t.Errorf("Expected the session updated and member-2 booked, got %+v", updated)
+	}
+
+	tests := map[string]struct {
+		mutate func(*pb.UpdateSessionRequest)
+		code   codes.Code
+	}{
+		"unknown session":     {func(r *pb.UpdateSessionRequest) { r.SessionId = "42" }, codes.NotFound},
+		"malformed ID":        {func(r *pb.UpdateSessionRequest) { r.SessionId = "abc" }, codes.NotFound},
+		"missing title":       {func(r *pb.UpdateSessionRequest) { r.Title = "" }, codes.InvalidArgument},
+		"cancelling":          {func(r *pb.UpdateSessionRequest) { r.IsCancelled = true }, codes.InvalidArgument},
+		"below reserved":      {func(r *pb.UpdateSessionRequest) { r.Capacity = 1 }, codes.FailedPrecondition},
+		"unknown coach":       {func(r *pb.UpdateSessionRequest) { r.CoachId = "coach-9" }, codes.NotFound},
+		"end before start":    {func(r *pb.UpdateSessionRequest) { r.EndTime, r.StartTime = r.StartTime, r.EndTime }, codes.InvalidArgument},
+		"timestamp no offset": {func(r *pb.UpdateSessionRequest) { r.StartTime = "2030-05-15T08:00:00" }, codes.InvalidArgument},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := request()
+			tt.mutate(req)
+			if _, err := s.UpdateSession(ctx, req); status.Code(err) != tt.code {
+				t.Errorf("Expected %v, got %v", tt.code, err)
+			}
+		})
+	}
+
+	req := request()
+	req.CoachId = "coach-2"
+	if updated, err := s.UpdateSession(ctx, req); err != nil || updated.CoachName != "Mike Chen" {
+		t.Errorf("Expected the new coach looked up, got %+v, %v", updated, err)
+	}
+
+	if _, err := s.CancelSession(ctx, &pb.CancelSessionRequest{SessionId: id}); err != nil {
+		t.Fatalf("CancelSession failed: %v", err)
+	}
+	if _, err := s.UpdateSession(ctx, req); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("Expected FailedPrecondition updating a cancelled session, got %v", err)
+	}
+}
+
+func TestServerCancelSession(t *testing.T) {
+	s := newTestServer()
+	ctx := context.Background()
+
+	created, err := fixtures.NewTestSession().Create(ctx, s.repo)
+	if err != nil {
+		t.Fatalf("Failed to create fixture: %v", err)
+	}
+	id := strconv.FormatInt(created.ID, 10)
+
+	cancelled, err := s.CancelSession(ctx, &pb.CancelSessionRequest{SessionId: id, Reason: "Coach is sick"})
+	if err != nil {
+		t.Fatalf("CancelSession failed: %v", err)
+	}
+	if !cancelled.IsCancelled || cancelled.CancellationReason != "Coach is sick" {
+		t.Errorf("Session not cancelled: %+v", cancelled)
+	}
+
+	_, err = s.CancelSession(ctx, &pb.CancelSessionRequest{SessionId: id})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("Expected FailedPrecondition on second cancel, got %v", err)
+	}
+
+	_, err = s.CancelSession(ctx, &pb.CancelSessionRequest{SessionId: "42"})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound, got %v", err)
+	}
+}
+
+func TestServerSessionStatus(t *testing.T) {
+	tests := []struct {
+		now  time.Time
+		want string
+	}{
+		{testSessionStart.Add(-2 * time.Hour), "scheduled"},
+		{testSessionStart, "in_progress"},
+		{testSessionStart.Add(59 * time.Minute), "in_progress"},
+		{testSessionStart.Add(time.Hour), "completed"},
+	}
+	for _, tt := range tests {
+		s := newTestServerAt(tt.now)
+		created, err := s.CreateSession(context.Background(), validCreateSessionRequest())
+		if err != nil {
+			t.Fatalf("CreateSession failed: %v", err)
+		}
+		if created.Status != tt.want {
+			t.Errorf("At %v: expected status %q, got %q", tt.now, tt.want, created.Status)
+		}
+	}
+
+	s := newTestServer()
+	created, err := fixtures.NewTestSession().RelativeTo(s.clock.Now()).StartingIn(-30*time.Minute).
+		Cancelled("Coach is sick").Create(context.Background(), s.repo)
+	if err != nil {
+		t.Fatalf("Failed to create fixture: %v", err)
+	}
+	got, err := s.GetSession(context.Background(), &pb.GetSessionRequest{SessionId: strconv.FormatInt(created.ID, 10)})
+	if err != nil || got.Status != "cancelled" {
+		t.Errorf("Expected cancelled status, got %+v, %v", got, err)
+	}
+}
+
+func TestServerCancelCompletedSession(t *testing.T) {
+	s := newTestServer()
+	ctx := context.Background()
+
+	created, err := fixtures.NewTestSession().RelativeTo(s.clock.Now()).StartingIn(-2*time.Hour).
+		Completed().Create(ctx, s.repo)
+	if err != nil {
+		t.Fatalf("Failed to create fixture: %v", err)
+	}
+	id := strconv.FormatInt(created.ID, 10)
+
+	for _, dryRun := range []bool{true, false} {
+		_, err = s.CancelSession(ctx, &pb.CancelSessionRequest{SessionId: id, Reason: "Too late", DryRun: dryRun})
+		if status.Code(err) != codes.FailedPrecondition {
+			t.Errorf("Dry run %v: expected FailedPrecondition, got %v", dryRun, err)
+		}
+	}
+	got, err := s.GetSession(ctx, &pb.GetSessionRequest{SessionId: id})
+	if err != nil || got.Status != "completed" || got.IsCancelled {
+		t.Errorf("Expected the session completed, got %+v, %v", got, err)
+	}
+}
+
+func TestServerCancelSessionDryRun(t *testing.T) {
+	s := newTestServer()
+	ctx := context.Background()
+
+	created, err := fixtures.NewTestSession().Create(ctx, s.repo)
+	if err != nil {
+		t.Fatalf("Failed to create fixture: %v", err)
+	}
+	id := strconv.FormatInt(created.ID, 10)
+
+	preview, err := s.CancelSession(ctx, &pb.CancelSessionRequest{SessionId: id, Reason: "Coach is sick", DryRun: true})
+	if err != nil {
+		t.Fatalf("Dry run failed: %v", err)
+	}
+	if !preview.IsCancelled || preview.Status != "cancelled" || preview.CancellationReason != "Coach is sick" {
+		t.Errorf("Dry run should show the cancelled session, got %+v", preview)
+	}
+
+	stored, err := s.GetSession(ctx, &pb.GetSessionRequest{SessionId: id})
+	if err != nil || stored.IsCancelled {
+		t.Errorf("Dry run must not cancel the session, got %+v, %v", stored, err)
+	}
+
+	// A dry run reports the same errors as the real thing
+	_, err = s.CancelSession(ctx, &pb.CancelSessionRequest{SessionId: "42", DryRun: true})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound, got %v", err)
+	}
+	if _, err := s.CancelSession(ctx, &pb.CancelSessionRequest{SessionId: id}); err != nil {
+		t.Fatalf("CancelSession failed: %v", err)
+	}
+	_, err = s.CancelSession(ctx, &pb.CancelSessionRequest{SessionId: id, DryRun: true})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("Expected FailedPrecondition, got %v", err)
+	}
+}
+
+func TestServerRunSelfTest(t *testing.T) {
+	s := newTestServer()
+	ctx := context.Background()
+
+	resp, err := s.RunSelfTest(ctx, &pb.RunSelfTestRequest{})
+	if err != nil {
+		t.Fatalf("RunSelfTest failed: %v", err)
+	}
+	if !resp.Ok || len(resp.Steps) != 4 {
+		t.Fatalf("Expected 4 successful steps, got %+v", resp.Steps)
+	}
+	for i, name := range []string{"create", "book", "cancel", "delete"} {
+		if resp.Steps[i].Name != name || !resp.Steps[i].Ok {
+			t.Errorf("Step %d: expected %s to succeed, got %+v", i, name, resp.Steps[i])
+		}
+	}
+
+	// The scratch session is gone
+	if _, err := s.repo.GetSession(ctx, 1); err != store.ErrNotFound {
+		t.Errorf("Expected scratch session to be deleted, got %v", err)
+	}
+}
+
+func TestServerBatchCreateReservations(t *testing.T) {
+	s := newTestServer()
+	ctx := context.Background()
+
+	created, err := fixtures.NewTestSession().WithCapacity(3).Create(ctx, s.repo)
+	if err != nil {
+		t.Fatalf("Failed to create fixture: %v", err)
+	}
+	id := strconv.FormatInt(created.ID, 10)
+
+	resp, err := s.BatchCreateReservations(ctx, &pb.BatchCreateReservationsRequest{SessionId: id, UserIds: []string{"member-1", "member-2"}})
+	if err != nil {
+		t.Fatalf("BatchCreateReservations failed: %v", err)
+	}
+	if resp.Booked != 2 || resp.Results[0].Code != "OK" || resp.Results[1].Reservation.GetUserId() != "member-2" {
+		t.Fatalf("Expected both members booked, got %+v", resp.Results)
+	}
+
+	// member-1 is booked already and only one spot is left
+	batch := []string{"member-3", "member-1", "member-4"}
+	resp, err = s.BatchCreateReservations(ctx, &pb.BatchCreateReservationsRequest{SessionId: id, UserIds: batch})
+	if err != nil {
+		t.Fatalf("BatchCreateReservations failed: %v", err)
+	}
+	if resp.Booked != 0 {
+		t.Errorf("All-or-nothing batch booked %d members", resp.Booked)
+	}
+	for i, want := range []string{"Aborted", "AlreadyExists", "ResourceExhausted"} {
+		if got := resp.Results[i]; got.Code != want || got.Reservation != nil {
+			t.Errorf("Result %d: expected %s, got %+v", i, want, got)
+		}
+	}
+
+	resp, err = s.BatchCreateReservations(ctx, &pb.BatchCreateReservationsRequest{SessionId: id, UserIds: batch, BestEffort: true})
+	if err != nil {
+		t.Fatalf("BatchCreateReservations failed: %v", err)
+	}
+	if resp.Booked != 1 || resp.Results[0].Code != "OK" || resp.Results[2].Code != "ResourceExhausted" {
+		t.Errorf("Expected only member-3 booked, got %+v", resp.Results)
+	}
+
+	session, err := s.GetSession(ctx, &pb.GetSessionRequest{SessionId: id})
+	if err != nil || session.ReservedSpots != 3 {
+		t.Errorf("Expected 3 reserved spots, got %+v, %v", session, err)
+	}
+}
+
+func TestServerBatchCreateReservationsErrors(t *testing.T) {
+	s := newTestServer()
+	ctx := context.Background()
+
+	cancelled, err := fixtures.NewTestSession().Cancelled("Coach is sick").Create(ctx, s.repo)
+	if err != nil {
+		t.Fatalf("Failed to create fixture: %v", err)
+	}
+	queued, err := fixtures.NewTestSession().Queued().Create(ctx, s.repo)
+	if err != nil {
+		t.Fatalf("Failed to create fixture: %v", err)
+	}
+
+	tests := map[string]struct {
+		req  *pb.BatchCreateReservationsRequest
+		want codes.Code
+	}{
+		"unknown session":   {&pb.BatchCreateReservationsRequest{SessionId: "42", UserIds: []string{"member-1"}}, codes.NotFound},
+		"cancelled session": {&pb.BatchCreateReservationsRequest{SessionId: strconv.FormatInt(cancelled.ID, 10), UserIds: []string{"member-1"}}, codes.FailedPrecondition},
+		"queued session":    {&pb.BatchCreateReservationsRequest{SessionId: strconv.FormatInt(queued.ID, 10), UserIds: []string{"member-1"}}, codes.FailedPrecondition},
+		"no users":          {&pb.BatchCreateReservationsRequest{SessionId: "1"}, codes.InvalidArgument},
+		"duplicate user":    {&pb.BatchCreateReservationsRequest{SessionId: "1", UserIds: []string{"member-1", "member-1"}}, codes.InvalidArgument},
+		"too many users":    {&pb.BatchCreateReservationsRequest{SessionId: "1", UserIds: make([]string, maxBatchSize+1)}, codes.InvalidArgument},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := s.BatchCreateReservations(ctx, tt.req)
+			if status.Code(err) != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestServerCreateReservation(t *testing.T) {
+	s := newTestServer()
+	ctx := context.Background()
+
+	created, err := fixtures.NewTestSession().WithCapacity(1).Create(ctx, s.repo)
+	if err != nil {
+		t.Fatalf("Failed to create fixture: %v", err)
+	}
+	cancelled, err := fixtures.NewTestSession().Cancelled("Coach is sick").Create(ctx, s.repo)
+	if err != nil {
+		t.Fatalf("Failed to create fixture: %v", err)
+	}
+	queued, err := fixtures.NewTestSession().Queued().Create(ctx, s.repo)
+	if err != nil {
+		t.Fatalf("Failed to create fixture: %v", err)
+	}
+	id := strconv.FormatInt(created.ID, 10)
+
+	reservation, err := s.CreateReservation(ctx, &pb.CreateReservationRequest{SessionId: id, UserId: "member-1"})
+	if err != nil {
+		t.Fatalf("CreateReservation failed: %v", err)
+	}
+	if reservation.SessionId != id || reservation.UserId != "member-1" || reservation.Status != "confirmed" {
+		t.Errorf("Unexpected reservation %+v", reservation)
+	}
+	got, err := s.GetReservation(ctx, &pb.GetReservationRequest{ReservationId: reservation.Id})
+	if err != nil || got.Id != reservation.Id {
+		t.Errorf("Expected reservation %s, got %+v, %v", reservation.Id, got, err)
+	}
+
+	tests := map[string]struct {
+		req  *pb.CreateReservationRequest
+		want codes.Code
+	}{
+		"already booked":    {&pb.CreateReservationRequest{SessionId: id, UserId: "member-1"}, codes.AlreadyExists},
+		"full":              {&pb.CreateReservationRequest{SessionId: id, UserId: "member-2"}, codes.ResourceExhausted},
+		"unknown session":   {&pb.CreateReservationRequest{SessionId: "42", UserId: "member-1"}, codes.NotFound},
+		"invalid session":   {&pb.CreateReservationRequest{SessionId: "yoga", UserId: "member-1"}, codes.NotFound},
+		"cancelled session": {&pb.CreateReservationRequest{SessionId: strconv.FormatInt(cancelled.ID, 10), UserId: "member-1"}, codes.FailedPrecondition},
+		"queued session":    {&pb.CreateReservationRequest{SessionId: strconv.FormatInt(queued.ID, 10), UserId: "member-1"}, codes.FailedPrecondition},
+		"no user":           {&pb.CreateReservationRequest{SessionId: id}, codes.InvalidArgument},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := s.CreateReservation(ctx, tt.req)
+			if status.Code(err) != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+
+	if _, err := s.GetReservation(ctx, &pb.GetReservationRequest{ReservationId: "42"}); status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound for an unknown reservation, got %v", err)
+	}
+}
+
+func TestServerCheckInReservation(t *testing.T) {
+	s := newTestServer()
+	ctx := context.Background()
+
+	created, err := fixtures.NewTestSession().Create(ctx, s.repo)
+	if err != nil {
+		t.Fatalf("Failed to create fixture: %v", err)
+	}
+	id := strconv.FormatInt(created.ID, 10)
+	var booked []*pb.Reservation
+	for _, user := range []string{"member-1", "member-2"} {
+		r, err := s.CreateReservation(ctx, &pb.CreateReservationRequest{SessionId: id, UserId: user})
+		if err != nil {
+			t.Fatalf("CreateReservation failed: %v", err)
+		}
+		booked = append(booked, r)
+	}
+
+	checkedIn, err := s.CheckInReservation(ctx, &pb.CheckInReservationRequest{ReservationId: booked[0].Id})
+	if err != nil || checkedIn.Status != "attended" {
+		t.Fatalf("Expected member-1 attended, got %+v, %v", checkedIn, err)
+	}
+	_, err = s.CancelReservation(ctx, &pb.CancelReservationRequest{ReservationId: booked[0].Id, UserId: "member-1"})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("Expected FailedPrecondition cancelling after checking in, got %v", err)
+	}
+	if _, err := s.CancelReservation(ctx, &pb.CancelReservationRequest{ReservationId: booked[1].Id, UserId: "member-2"}); err != nil {
+		t.Fatalf("CancelReservation failed: %v", err)
+	}
+	_, err = s.CheckInReservation(ctx, &pb.CheckInReservationRequest{ReservationId: booked[1].Id})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("Expected FailedPrecondition checking in a cancelled reservation, got %v", err)
+	}
+	_, err = s.CheckInReservation(ctx, &pb.CheckInReservationRequest{ReservationId: "42"})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound for an unknown reservation, got %v", err)
+	}
+
+	attended, err := s.ListSessionReservations(ctx, &pb.ListSessionReservationsRequest{SessionId: id, Status: "attended"})
+	if err != nil || len(attended.Reservations) != 1 || attended.Reservations[0].UserId != "member-1" {
+		t.Errorf("Expected member-1 listed as attended, got %+v, %v", attended, err)
+	}
+}
+
+func TestServerCancelReservation(t *testing.T) {
+	s := newTestServer()
+	ctx := context.Background()
+
+	created, err := fixtures.NewTestSession().WithCapacity(1).Create(ctx, s.repo)
+	if err != nil {
+		t.Fatalf("Failed to create fixture: %v", err)
+	}
+	id := strconv.FormatInt(created.ID, 10)
+	reservation, err := s.CreateReservation(ctx, &pb.CreateReservationRequest{SessionId: id, UserId: "member-1"})
+	if err != nil {
+		t.Fatalf("CreateReservation failed: %v", err)
+	}
+
+	_, err = s.CancelReservation(ctx, &pb.CancelReservationRequest{ReservationId: reservation.Id, UserId: "member-2"})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Expected PermissionDenied cancelling another member's reservation, got %v", err)
+	}
+	resp, err := s.CancelReservation(ctx, &pb.CancelReservationRequest{ReservationId: reservation.Id, UserId: "member-1"})
+	if err != nil || !resp.Success {
+		t.Fatalf("CancelReservation failed: %+v, %v", resp, err)
+	}
+	_, err = s.CancelReservation(ctx, &pb.CancelReservationRequest{ReservationId: reservation.Id, UserId: "member-1"})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("Expected FailedPrecondition cancelling twice, got %v", err)
+	}
+	_, err = s.CancelReservation(ctx, &pb.CancelReservationRequest{ReservationId: "42", UserId: "member-1"})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound for an unknown reservation, got %v", err)
+	}
+	_, err = s.CancelReservation(ctx, &pb.CancelReservationRequest{ReservationId: reservation.Id})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument without a user, got %v", err)
+	}
+
+	// The spot is free again
+	if _, err := s.CreateReservation(ctx, &pb.CreateReservationRequest{SessionId: id, UserId: "member-2"}); err != nil {
+		t.Errorf("Expected the cancelled spot to be bookable, got %v", err)
+	}
+}
+
+func TestServerListReservations(t *testing.T) {
+	s := newTestServer()
+	ctx := context.Background()
+
+	upcoming, err := fixtures.NewTestSession().StartingAt(testSessionStart).Create(ctx, s.repo)
+	if err != nil {
+		t.Fatalf("Failed to create fixture: %v", err)
+	}
+	past, err := fixtures.NewTestSession().StartingAt(testSessionStart.Add(-48*time.Hour)).Create(ctx, s.repo)
+	if err != nil {
+		t.Fatalf("Failed to create fixture: %v", err)
+	}
+	upcomingID, pastID := strconv.FormatInt(upcoming.ID, 10), strconv.FormatInt(past.ID, 10)
+
+	var ids []string
+	for _, b := range []struct{ session, user string }{
+		{upcomingID, "member-1"}, {pastID, "member-1"}, {upcomingID, "member-2"}, {upcomingID, "member-3"},
+	} {
+		r, err := s.CreateReservation(ctx, &pb.CreateReservationRequest{SessionId: b.session, UserId: b.user})
+		if err != nil {
+			t.Fatalf("CreateReservation failed: %v", err)
+		}
+		ids = append(ids, r.Id)
+	}
+	if _, err := s.CancelReservation(ctx, &pb.CancelReservationRequest{ReservationId: ids[2], UserId: "member-2"}); err != nil {
+		t.Fatalf("CancelReservation failed: %v", err)
+	}
+
+	// Every page of a list
+	collect := func(list func(token string) (*pb.ListReservationsResponse, error)) ([]string, int) {
+		t.Helper()
+		var got []string
+		var token string
+		for pages := 1; ; pages++ {
+			resp, err := list(token)
+			if err != nil {
+				t.Fatalf("Listing reservations failed: %v", err)
+			}
+			for _, r := range resp.Reservations {
+				got = append(got, r.Id)
+			}
+			if resp.NextPageToken == "" {
+				return got, pages
+			}
+			token = resp.NextPageToken
+		}
+	}
+	user := func(req *pb.ListUserReservationsRequest) func(string) (*pb.ListReservationsResponse, error) {
+		return func(token string) (*pb.ListReservationsResponse, error) {
+			req.PageToken = token
+			return s.ListUserReservations(ctx, req)
+		}
+	}
+	session := func(req *pb.ListSessionReservationsRequest) func(string) (*pb.ListReservationsResponse, error) {
+		return func(token string) (*pb.ListReservationsResponse, error) {
+			req.PageToken = token
+			return s.ListSessionReservations(ctx, req)
+		}
+	}
+
+	tests := map[string]struct {
+		list  func(string) (*pb.ListReservationsResponse, error)
+		want  []string
+		pages int
+	}{
+		"user upcoming":     {user(&pb.ListUserReservationsRequest{UserId: "member-1"}), []string{ids[0]}, 1},
+		"user all":          {user(&pb.ListUserReservationsRequest{UserId: "member-1", IncludePast: true}), []string{ids[0], ids[1]}, 1},
+		"user cancelled":    {user(&pb.ListUserReservationsRequest{UserId: "member-2", Status: "cancelled"}), []string{ids[2]}, 1},
+		"session":           {session(&pb.ListSessionReservationsRequest{SessionId: upcomingID}), []string{ids[0], ids[2], ids[3]}, 1},
+		"session paged":     {session(&pb.ListSessionReservationsRequest{SessionId: upcomingID, PageSize: 2}), []string{ids[0], ids[2], ids[3]}, 2},
+		"session confirmed": {session(&pb.ListSessionReservationsRequest{SessionId: upcomingID, Status: "confirmed", PageSize: 1}), []string{ids[0], ids[3]}, 2},
+		"no reservations":   {user(&pb.ListUserReservationsRequest{UserId: "nobody"}), nil, 1},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, pages := collect(tt.list)
+			if !reflect.DeepEqual(got, tt.want) || pages != tt.pages {
+				t.Errorf("Expected reservations %v in %d pages, got %v in %d", tt.want, tt.pages, got, pages)
+			}
+		})
+	}
+
+	first, err := s.ListSessionReservations(ctx, &pb.ListSessionReservationsRequest{SessionId: upcomingID, PageSize: 1})
+	if err != nil {
+		t.Fatalf("ListSessionReservations failed: %v", err)
+	}
+	invalid := map[string]func() error{
+		"no user": func() error {
+			_, err := s.ListUserReservations(ctx, &pb.ListUserReservationsRequest{})
+			return err
+		},
+		"unknown status": func() error {
+			_, err := s.ListUserReservations(ctx, &pb.ListUserReservationsRequest{UserId: "member-1", Status: "late"})
+			return err
+		},
+		"page size over maximum": func() error {
+			_, err := s.ListSessionReservations(ctx, &pb.ListSessionReservationsRequest{SessionId: upcomingID, PageSize: maxListPageSize + 1})
+			return err
+		},
+		"token of another query": func() error {
+			_, err := s.ListSessionReservations(ctx, &pb.ListSessionReservationsRequest{SessionId: upcomingID, Status: "confirmed", PageToken: first.NextPageToken})
+			return err
+		},
+	}
+	for name, call := range invalid {
+		t.Run(name, func(t *testing.T) {
+			if err := call(); status.Code(err) != codes.InvalidArgument {
+				t.Errorf("Expected InvalidArgument, got %v", err)
+			}
+		})
+	}
+
+	_, err = s.ListSessionReservations(ctx, &pb.ListSessionReservationsRequest{SessionId: "42"})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound for an unknown session, got %v", err)
+	}
+}
+
+func TestServerWaitlist(t *testing.T) {
+	s := newTestServer()
+	ctx := context.Background()
+
+	created, err := fixtures.NewTestSession().WithCapacity(1).Create(ctx, s.repo)
+	if err != nil {
+		t.Fatalf("Failed to create fixture: %v", err)
+	}
+	id := strconv.FormatInt(created.ID, 10)
+	queued, err := fixtures.NewTestSession().WithCapacity(1).Queued().Create(ctx, s.repo)
+	if err != nil {
+		t.Fatalf("Failed to create fixture: %v", err)
+	}
+	if err := s.repo.CreateReservation(ctx, &store.Reservation{SessionID: queued.ID, UserID: "member-1", UserName: "Member Name"}); err != nil {
+		t.Fatalf("Failed to book fixture: %v", err)
+	}
+
+	_, err = s.JoinWaitlist(ctx, &pb.JoinWaitlistRequest{SessionId: id, UserId: "member-2"})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("Expected FailedPrecondition joining the waitlist of a session with spots left, got %v", err)
+	}
+	booked, err := s.CreateReservation(ctx, &pb.CreateReservationRequest{SessionId: id, UserId: "member-1"})
+	if err != nil {
+		t.Fatalf("CreateReservation failed: %v", err)
+	}
+	entry, err := s.JoinWaitlist(ctx, &pb.JoinWaitlistRequest{SessionId: id, UserId: "member-2"})
+	if err != nil || entry.Position != 1 || entry.SessionId != id {
+		t.Fatalf("Expected member-2 first on the waitlist, got %+v, %v", entry, err)
+	}
+
+	tests := map[string]struct {
+		req  *pb.JoinWaitlistRequest
+		want codes.Code
+	}{
+		"twice":           {&pb.JoinWaitlistRequest{SessionId: id, UserId: "member-2"}, codes.AlreadyExists},
+		"booked":          {&pb.JoinWaitlistRequest{SessionId: id, UserId: "member-1"}, codes.AlreadyExists},
+		"unknown session": {&pb.JoinWaitlistRequest{SessionId: "42", UserId: "member-3"}, codes.NotFound},
+		"queued session":  {&pb.JoinWaitlistRequest{SessionId: strconv.FormatInt(queued.ID, 10), UserId: "member-3"}, codes.FailedPrecondition},
+		"no user":         {&pb.JoinWaitlistRequest{SessionId: id}, codes.InvalidArgument},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := s.JoinWaitlist(ctx, tt.req); status.Code(err) != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+
+	// The cancelled spot goes to member-2
+	if _, err := s.CancelReservation(ctx, &pb.CancelReservationRequest{ReservationId: booked.Id, UserId: "member-1"}); err != nil {
+		t.Fatalf("CancelReservation failed: %v", err)
+	}
+	roster, err := s.ListSessionReservations(ctx, &pb.ListSessionReservationsRequest{SessionId: id, Status: "confirmed"})
+	if err != nil || len(roster.Reservations) != 1 || roster.Reservations[0].UserId != "member-2" {
+		t.Errorf("Expected member-2 booked, got %+v, %v", roster, err)
+	}
+	_, err = s.LeaveWaitlist(ctx, &pb.LeaveWaitlistRequest{SessionId: id, UserId: "member-2"})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound leaving the waitlist after promotion, got %v", err)
+	}
+
+	if _, err := s.JoinWaitlist(ctx, &pb.JoinWaitlistRequest{SessionId: id, UserId: "member-1"}); err != nil {
+		t.Fatalf("JoinWaitlist failed: %v", err)
+	}
+	resp, err := s.LeaveWaitlist(ctx, &pb.LeaveWaitlistRequest{SessionId: id, UserId: "member-1"})
+	if err != nil || !resp.Success {
+		t.Errorf("LeaveWaitlist failed: %+v, %v", resp, err)
+	}
+}
+
+// Server side of a QueueReservation stream, keeping what is sent
+type queueStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	sent []*pb.QueueStatus
+}
+
+func (s *queueStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *queueStream) Send(st *pb.QueueStatus) error {
+	s.sent = append(s.sent, st)
+	return nil
+}
+
+func TestServerQueueReservation(t *testing.T) {
+	s := newTestServer()
+	s.queue.Interval = 0
+	ctx := context.Background()
+
+	queued, err := fixtures.NewTestSession().WithCapacity(1).Queued().Create(ctx, s.repo)
+	if err != nil {
+		t.Fatalf("Failed to create fixture: %v", err)
+	}
+	direct, err := fixtures.NewTestSession().Create(ctx, s.repo)
+	if err != nil {
+		t.Fatalf("Failed to create fixture: %v", err)
+	}
+	id := strconv.FormatInt(queued.ID, 10)
+
+	stream := &queueStream{ctx: ctx}
+	if err := s.QueueReservation(&pb.QueueReservationRequest{SessionId: id, UserId: "member-1"}, stream); err != nil {
+		t.Fatalf("QueueReservation failed: %v", err)
+	}
+	if len(stream.sent) != 2 || stream.sent[0].State != "queued" || stream.sent[1].State != "booked" ||
+		stream.sent[1].Reservation.GetUserId() != "member-1" {
+		t.Errorf("Expected queued then booked, got %+v", stream.sent)
+	}
+
+	stream = &queueStream{ctx: ctx}
+	if err := s.QueueReservation(&pb.QueueReservationRequest{SessionId: id, UserId: "member-2"}, stream); err != nil {
+		t.Fatalf("QueueReservation failed: %v", err)
+	}
+	last := stream.sent[len(stream.sent)-1]
+	if last.State != "failed" || last.Code != codes.ResourceExhausted.String() || last.Reservation != nil {
+		t.Errorf("Expected the full session to fail the booking, got %+v", last)
+	}
+
+	tests := map[string]struct {
+		req  *pb.QueueReservationRequest
+		want codes.Code
+	}{
+		"unknown session":    {&pb.QueueReservationRequest{SessionId: "42", UserId: "member-1"}, codes.NotFound},
+		"not queued session": {&pb.QueueReservationRequest{SessionId: strconv.FormatInt(direct.ID, 10), UserId: "member-1"}, codes.FailedPrecondition},
+		"missing user":       {&pb.QueueReservationRequest{SessionId: id}, codes.InvalidArgument},
+		"invalid session ID": {&pb.QueueReservationRequest{SessionId: "abc", UserId: "member-1"}, codes.NotFound},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := s.QueueReservation(tt.req, &queueStream{ctx: ctx})
+			if status.Code(err) != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestServerListSessions(t *testing.T) {
+	s := newTestServer()
+	ctx := context.Background()
+
+	var ids []string
+	for _, b := range []*fixtures.SessionBuilder{
+		fixtures.NewTestSession().StartingAt(testSessionStart.Add(2 * time.Hour)),
+		fixtures.NewTestSession().StartingAt(testSessionStart),
+		fixtures.NewTestSession().StartingAt(testSessionStart.Add(-48 * time.Hour)),
+		fixtures.NewTestSession().StartingAt(testSessionStart.Add(time.Hour)).Cancelled("Coach is sick"),
+		fixtures.NewTestSession().StartingAt(testSessionStart.Add(24 * time.Hour)).AtLocation("Studio B"),
+	} {
+		session, err := b.Create(ctx, s.repo)
+		if err != nil {
+			t.Fatalf("Failed to create fixture: %v", err)
+		}
+		ids = append(ids, strconv.FormatInt(session.ID, 10))
+	}
+
+	// Every page of req
+	list := func(req *pb.ListSessionsRequest) ([]string, int) {
+		t.Helper()
+		var got []string
+		for pages := 1; ; pages++ {
+			resp, err := s.ListSessions(ctx, req)
+			if err != nil {
+				t.Fatalf("ListSessions failed: %v", err)
+			}
+			for _, session := range resp.Sessions {
+				got = append(got, session.Id)
+			}
+			if resp.NextPageToken == "" {
+				return got, pages
+			}
+			req.PageToken = resp.NextPageToken
+		}
+	}
+
+	tests := map[string]struct {
+		req   *pb.ListSessionsRequest
+		want  []string
+		pages int
+	}{
+		"upcoming":      {&pb.ListSessionsRequest{}, []string{ids[1], ids[3], ids[0], ids[4]}, 1},
+		"paged":         {&pb.ListSessionsRequest{PageSize: 2}, []string{ids[1], ids[3], ids[0], ids[4]}, 2},
+		"past included": {&pb.ListSessionsRequest{IncludePast: true, PageSize: 3}, []string{ids[2], ids[1], ids[3], ids[0], ids[4]}, 2},
+		"descending":    {&pb.ListSessionsRequest{Descending: true, PageSize: 1}, []string{ids[4], ids[0], ids[3], ids[1]}, 4},
+		"not cancelled": {&pb.ListSessionsRequest{ExcludeCancelled: true}, []string{ids[1], ids[0], ids[4]}, 1},
+		"location":      {&pb.ListSessionsRequest{Location: "Studio B"}, []string{ids[4]}, 1},
+		"range": {&pb.ListSessionsRequest{StartFrom: "2030-05-15T10:00:00+02:00", StartBefore: "2030-05-15T10:30:00Z"},
+			[]string{ids[1], ids[3], ids[0]}, 1},
+		"range and date": {&pb.ListSessionsRequest{StartFrom: "2030-05-15T09:00:00Z", Date: "2030-05-15"},
+			[]string{ids[3], ids[0]}, 1},
+		"none": {&pb.ListSessionsRequest{CoachId: "nobody"}, nil, 1},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, pages := list(tt.req)
+			if !reflect.DeepEqual(got, tt.want) || pages != tt.pages {
+				t.Errorf("Expected sessions %v in %d pages, got %v in %d", tt.want, tt.pages, got, pages)
+			}
+		})
+	}
+
+	first, err := s.ListSessions(ctx, &pb.ListSessionsRequest{PageSize: 1})
+	if err != nil {
+		t.Fatalf("ListSessions failed: %v", err)
+	}
+	invalid := map[string]*pb.ListSessionsRequest{
+		"malformed start":        {StartFrom: "2030-05-15"},
+		"malformed end":          {StartBefore: "2030-05-15T08:00:00"},
+		"empty range":            {StartFrom: "2030-05-15T08:00:00Z", StartBefore: "2030-05-15T08:00:00Z"},
+		"invalid date":           {Date: "15/05/2030"},
+		"negative page size":     {PageSize: -1},
+		"page size over maximum": {PageSize: maxListPageSize + 1},
+		"invalid text":           {Location: "Studio\x00"},
+		"malformed token":        {PageToken: "not a token"},
+		"token of another query": {PageSize: 1, SessionType: "yoga", PageToken: first.NextPageToken},
+	}
+	for name, req := range invalid {
+		t.Run(name, func(t *testing.T) {
+			if _, err := s.ListSessions(ctx, req); status.Code(err) != codes.InvalidArgument {
+				t.Errorf("Expected InvalidArgument, got %v", err)
+			}
+		})
+	}
+
+	// Start times past the range of Unix nanoseconds page through as well
+	var late []string
+	for _, start := range []time.Time{testSessionStart.AddDate(400, 0, 0), testSessionStart.AddDate(400, 0, 1)} {
+		session, err := fixtures.NewTestSession().StartingAt(start).Create(ctx, s.repo)
+		if err != nil {
+			t.Fatalf("Failed to create fixture: %v", err)
+		}
+		late = append(late, strconv.FormatInt(session.ID, 10))
+	}
+	if got, pages := list(&pb.ListSessionsRequest{StartFrom: "2400-01-01T00:00:00Z", PageSize: 1}); !reflect.DeepEqual(got, late) || pages != 2 {
+		t.Errorf("Expected sessions %v in 2 pages, got %v in %d", late, got, pages)
+	}
+}
+
+type sessionStream struct {
+	grpc.ServerStream
+	ctx       context.Context
+	sent      []*pb.Session
+	failAfter int // Send fails once this many sessions were sent, if set
+}
+
+func (s *sessionStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *sessionStream) Send(session *pb.Session) error {
+	if s.failAfter > 0 && len(s.sent) == s.failAfter {
+		return status.Error(codes.Unavailable, "client gone")
+	}
+	s.sent = append(s.sent, session)
+	return nil
+}
+
+func TestServerStreamSessions(t *testing.T) {
+	s := newTestServer()
+	ctx := context.Background()
+
+	var ids []string
+	for _, b := range []*fixtures.SessionBuilder{
+		fixtures.NewTestSession().StartingAt(testSessionStart),
+		fixtures.NewTestSession().StartingAt(testSessionStart.Add(-48 * time.Hour)),
+		fixtures.NewTestSession().StartingAt(testSessionStart.Add(2*time.Hour)).OfType("cardio", "beginner"),
+		fixtures.NewTestSession().StartingAt(testSessionStart.Add(24 * time.Hour)),
+		fixtures.NewTestSession().StartingAt(testSessionStart.Add(3*time.Hour)).WithCoach("coach-2", "Coach"),
+	} {
+		session, err := b.Create(ctx, s.repo)
+		if err != nil {
+			t.Fatalf("Failed to create fixture: %v", err)
+		}
+		ids = append(ids, strconv.FormatInt(session.ID, 10))
+	}
+
+	tests := map[string]struct {
+		req  *pb.StreamSessionsRequest
+		want []string
+	}{
+		"upcoming":          {&pb.StreamSessionsRequest{}, []string{ids[0], ids[2], ids[3], ids[4]}},
+		"past included":     {&pb.StreamSessionsRequest{IncludePast: true, BatchSize: 2}, ids},
+		"exact batches":     {&pb.StreamSessionsRequest{BatchSize: 2}, []string{ids[0], ids[2], ids[3], ids[4]}},
+		"one at a time":     {&pb.StreamSessionsRequest{BatchSize: 1, SessionType: "cardio"}, []string{ids[2]}},
+		"date":              {&pb.StreamSessionsRequest{Date: "2030-05-15"}, []string{ids[0], ids[2], ids[4]}},
+		"past date":         {&pb.StreamSessionsRequest{Date: "2030-05-13", IncludePast: true}, []string{ids[1]}},
+		"past date skipped": {&pb.StreamSessionsRequest{Date: "2030-05-13"}, nil},
+		"coach":             {&pb.StreamSessionsRequest{CoachId: "coach-2"}, []string{ids[4]}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			stream := &sessionStream{ctx: ctx}
+			if err := s.StreamSessions(tt.req, stream); err != nil {
+				t.Fatalf("StreamSessions failed: %v", err)
+			}
+			var got []string
+			for _, session := range stream.sent {
+				got = append(got, session.Id)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected sessions %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	invalid := map[string]*pb.StreamSessionsRequest{
+		"invalid date":       {Date: "15/05/2030"},
+		"negative batch":     {BatchSize: -1},
+		"batch over maximum": {BatchSize: maxStreamBatchSize + 1},
+	}
+	for name, req := range invalid {
+		t.Run(name, func(t *testing.T) {
+			if err := s.StreamSessions(req, &sessionStream{ctx: ctx}); status.Code(err) != codes.InvalidArgument {
+				t.Errorf("Expected InvalidArgument, got %v", err)
+			}
+		})
+	}
+
+	// A client that goes away ends the stream
+	stream := &sessionStream{ctx: ctx, failAfter: 1}
+	err := s.StreamSessions(&pb.StreamSessionsRequest{IncludePast: true, BatchSize: 1}, stream)
+	if status.Code(err) != codes.Unavailable || len(stream.sent) != 1 {
+		t.Errorf("Expected the stream to stop at the failed send, got %d sessions, %v", len(stream.sent), err)
+	}
+}
+
+// Server side of a WatchSession stream, handing over what is sent
+type watchStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	sent chan *pb.Session
+}
+
+func (s *watchStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *watchStream) Send(session *pb.Session) error {
+	s.sent <- session
+	return nil
+}
+
+func TestServerWatchSession(t *testing.T) {
+	s := newTestServer()
+	cached := cache.New(s.repo, 0, s.clock, nil)
+	cached.OnChange(s.watchers.Notify)
+	s.repo = cached
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	created, err := fixtures.NewTestSession().WithCapacity(2).Create(ctx, s.repo)
+	if err != nil {
+		t.Fatalf("Failed to create fixture: %v", err)
+	}
+	id := strconv.FormatInt(created.ID, 10)
+
+	stream := &watchStream{ctx: ctx, sent: make(chan *pb.Session)}
+	done := make(chan error, 1)
+	go func() { done <- s.WatchSession(&pb.WatchSessionRequest{SessionId: id}, stream) }()
+	next := func() *pb.Session {
+		t.Helper()
+		select {
+		case session := <-stream.sent:
+			return session
+		case err := <-done:
+			t.Fatalf("WatchSession ended: %v", err)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("No update sent")
+		}
+		return nil
+	}
+
+	if got := next(); got.ReservedSpots != 0 {
+		t.Errorf("Expected the current state first, got %+v", got)
+	}
+	if _, err := s.CreateReservation(ctx, &pb.CreateReservationRequest{SessionId: id, UserId: "member-1"}); err != nil {
+		t.Fatalf("CreateReservation failed: %v", err)
+	}
+	if got := next(); got.ReservedSpots != 1 {
+		t.Errorf("Expected the booking to be sent, got %+v", got)
+	}
+	if _, err := s.UpdateSession(ctx, &pb.UpdateSessionRequest{
+		SessionId: id, Title: "Evening Yoga", CoachId: created.CoachID, Capacity: 3,
+		StartTime: formatTimestamp(created.StartTime), EndTime: formatTimestamp(created.EndTime),
+		Location: created.Location, SessionType: created.SessionType, DifficultyLevel: created.DifficultyLevel,
+	}); err != nil {
+		t.Fatalf("UpdateSession failed: %v", err)
+	}
+	if got := next(); got.Title != "Evening Yoga" || got.Capacity != 3 {
+		t.Errorf("Expected the update to be sent, got %+v", got)
+	}
+	if _, err := s.CancelSession(ctx, &pb.CancelSessionRequest{SessionId: id, Reason: "Coach is sick"}); err != nil {
+		t.Fatalf("CancelSession failed: %v", err)
+	}
+	if got := next(); !got.IsCancelled {
+		t.Errorf("Expected the cancellation to be sent, got %+v", got)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if status.Code(err) != codes.Canceled {
+			t.Errorf("Expected Canceled once the client went away, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("WatchSession still running after the client went away")
+	}
+	if n := s.watchers.Watching(); n != 0 {
+		t.Errorf("Expected the watch to be stopped, %d left", n)
+	}
+}
+
+func TestServerWatchSessionEnds(t *testing.T) {
+	s := newTestServer()
+	ctx := context.Background()
+
+	completed, err := fixtures.NewTestSession().Completed().Create(ctx, s.repo)
+	if err != nil {
+		t.Fatalf("Failed to create fixture: %v", err)
+	}
+	stream := &watchStream{ctx: ctx, sent: make(chan *pb.Session, 1)}
+	err = s.WatchSession(&pb.WatchSessionRequest{SessionId: strconv.FormatInt(completed.ID, 10)}, stream)
+	if err != nil || len(stream.sent) != 1 {
+		t.Errorf("Expected a completed session to be sent once, got %d sessions, %v", len(stream.sent), err)
+	}
+
+	err = s.WatchSession(&pb.WatchSessionRequest{SessionId: "42"}, stream)
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound, got %v", err)
+	}
+}
+
+func TestServerGymScope(t *testing.T) {
+	s := newTestServer()
+	call := func(gym string, req interface{}, rpc func(context.Context) (interface{}, error)) (interface{}, error) {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(gymMetadataKey, gym))
+		return gymInterceptor(ctx, req, &grpc.UnaryServerInfo{}, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return rpc(ctx)
+		})
+	}
+
+	req := validCreateSessionRequest()
+	resp, err := call("north", req, func(ctx context.Context) (interface{}, error) { return s.CreateSession(ctx, req) })
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	created := resp.(*pb.Session)
+	if created.GymId != "north" {
+		t.Errorf("Expected the session in the gym of the call, got %q", created.GymId)
+	}
+
+	get := &pb.GetSessionRequest{SessionId: created.Id}
+	_, err = call("south", get, func(ctx context.Context) (interface{}, error) { return s.GetSession(ctx, get) })
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound from another gym, got %v", err)
+	}
+	if _, err := s.GetSession(context.Background(), get); err != nil {
+		t.Errorf("Expected calls without a gym to see every gym, got %v", err)
+	}
+
+	req.GymId = "north"
+	_, err = call("south", req, func(ctx context.Context) (interface{}, error) { return s.CreateSession(ctx, req) })
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument creating a session in another gym, got %v", err)
+	}
+	_, err = call("", req, func(ctx context.Context) (interface{}, error) { return s.CreateSession(ctx, req) })
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument for an empty gym, got %v", err)
+	}
+}
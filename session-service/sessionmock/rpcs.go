@@ -0,0 +1,238 @@
+package sessionmock
+
+import (
+	"context"
+
+	pb "session-service/proto"
+)
+
+// SessionServiceServer methods. Each one records the call and answers with
+// the response programmed for it.
+
+func (s *Server) CreateSession(ctx context.Context, req *pb.CreateSessionRequest) (*pb.Session, error) {
+	resp, err := s.invoke(ctx, "CreateSession", req)
+	if resp == nil {
+		return nil, err
+	}
+	out, ok := resp.(*pb.Session)
+	if !ok {
+		return nil, wrongType("CreateSession", resp)
+	}
+	return out, err
+}
+
+func (s *Server) GetSession(ctx context.Context, req *pb.GetSessionRequest) (*pb.Session, error) {
+	resp, err := s.invoke(ctx, "GetSession", req)
+	if resp == nil {
+		return nil, err
+	}
+	out, ok := resp.(*pb.Session)
+	if !ok {
+		return nil, wrongType("GetSession", resp)
+	}
+	return out, err
+}
+
+func (s *Server) UpdateSession(ctx context.Context, req *pb.UpdateSessionRequest) (*pb.Session, error) {
+	resp, err := s.invoke(ctx, "UpdateSession", req)
+	if resp == nil {
+		return nil, err
+	}
+	out, ok := resp.(*pb.Session)
+	if !ok {
+		return nil, wrongType("UpdateSession", resp)
+	}
+	return out, err
+}
+
+func (s *Server) DeleteSession(ctx context.Context, req *pb.DeleteSessionRequest) (*pb.DeleteSessionResponse, error) {
+	resp, err := s.invoke(ctx, "DeleteSession", req)
+	if resp == nil {
+		return nil, err
+	}
+	out, ok := resp.(*pb.DeleteSessionResponse)
+	if !ok {
+		return nil, wrongType("DeleteSession", resp)
+	}
+	return out, err
+}
+
+func (s *Server) CancelSession(ctx context.Context, req *pb.CancelSessionRequest) (*pb.Session, error) {
+	resp, err := s.invoke(ctx, "CancelSession", req)
+	if resp == nil {
+		return nil, err
+	}
+	out, ok := resp.(*pb.Session)
+	if !ok {
+		return nil, wrongType("CancelSession", resp)
+	}
+	return out, err
+}
+
+func (s *Server) ListSessions(ctx context.Context, req *pb.ListSessionsRequest) (*pb.ListSessionsResponse, error) {
+	resp, err := s.invoke(ctx, "ListSessions", req)
+	if resp == nil {
+		return nil, err
+	}
+	out, ok := resp.(*pb.ListSessionsResponse)
+	if !ok {
+		return nil, wrongType("ListSessions", resp)
+	}
+	return out, err
+}
+
+func (s *Server) CreateSessionSeries(ctx context.Context, req *pb.CreateSessionSeriesRequest) (*pb.SessionSeries, error) {
+	resp, err := s.invoke(ctx, "CreateSessionSeries", req)
+	if resp == nil {
+		return nil, err
+	}
+	out, ok := resp.(*pb.SessionSeries)
+	if !ok {
+		return nil, wrongType("CreateSessionSeries", resp)
+	}
+	return out, err
+}
+
+func (s *Server) UpdateSessionSeries(ctx context.Context, req *pb.UpdateSessionRequest) (*pb.SessionSeries, error) {
+	resp, err := s.invoke(ctx, "UpdateSessionSeries", req)
+	if resp == nil {
+		return nil, err
+	}
+	out, ok := resp.(*pb.SessionSeries)
+	if !ok {
+		return nil, wrongType("UpdateSessionSeries", resp)
+	}
+	return out, err
+}
+
+func (s *Server) CancelSessionSeries(ctx context.Context, req *pb.CancelSessionRequest) (*pb.SessionSeries, error) {
+	resp, err := s.invoke(ctx, "CancelSessionSeries", req)
+	if resp == nil {
+		return nil, err
+	}
+	out, ok := resp.(*pb.SessionSeries)
+	if !ok {
+		return nil, wrongType("CancelSessionSeries", resp)
+	}
+	return out, err
+}
+
+func (s *Server) CreateReservation(ctx context.Context, req *pb.CreateReservationRequest) (*pb.Reservation, error) {
+	resp, err := s.invoke(ctx, "CreateReservation", req)
+	if resp == nil {
+		return nil, err
+	}
+	out, ok := resp.(*pb.Reservation)
+	if !ok {
+		return nil, wrongType("CreateReservation", resp)
+	}
+	return out, err
+}
+
+func (s *Server) BatchCreateReservations(ctx context.Context, req *pb.BatchCreateReservationsRequest) (*pb.BatchCreateReservationsResponse, error) {
+	resp, err := s.invoke(ctx, "BatchCreateReservations", req)
+	if resp == nil {
+		return nil, err
+	}
+	out, ok := resp.(*pb.BatchCreateReservationsResponse)
+	if !ok {
+		return nil, wrongType("BatchCreateReservations", resp)
+	}
+	return out, err
+}
+
+func (s *Server) GetReservation(ctx context.Context, req *pb.GetReservationRequest) (*pb.Reservation, error) {
+	resp, err := s.invoke(ctx, "GetReservation", req)
+	if resp == nil {
+		return nil, err
+	}
+	out, ok := resp.(*pb.Reservation)
+	if !ok {
+		return nil, wrongType("GetReservation", resp)
+	}
+	return out, err
+}
+
+func (s *Server) CancelReservation(ctx context.Context, req *pb.CancelReservationRequest) (*pb.CancelReservationResponse, error) {
+	resp, err := s.invoke(ctx, "CancelReservation", req)
+	if resp == nil {
+		return nil, err
+	}
+	out, ok := resp.(*pb.CancelReservationResponse)
+	if !ok {
+		return nil, wrongType("CancelReservation", resp)
+	}
+	return out, err
+}
+
+func (s *Server) CheckInReservation(ctx context.Context, req *pb.CheckInReservationRequest) (*pb.Reservation, error) {
+	resp, err := s.invoke(ctx, "CheckInReservation", req)
+	if resp == nil {
+		return nil, err
+	}
+	out, ok := resp.(*pb.Reservation)
+	if !ok {
+		return nil, wrongType("CheckInReservation", resp)
+	}
+	return out, err
+}
+
+func (s *Server) ListUserReservations(ctx context.Context, req *pb.ListUserReservationsRequest) (*pb.ListReservationsResponse, error) {
+	resp, err := s.invoke(ctx, "ListUserReservations", req)
+	if resp == nil {
+		return nil, err
+	}
+	out, ok := resp.(*pb.ListReservationsResponse)
+	if !ok {
+		return nil, wrongType("ListUserReservations", resp)
+	}
+	return out, err
+}
+
+func (s *Server) ListSessionReservations(ctx context.Context, req *pb.ListSessionReservationsRequest) (*pb.ListReservationsResponse, error) {
+	resp, err := s.invoke(ctx, "ListSessionReservations", req)
+	if resp == nil {
+		return nil, err
+	}
+	out, ok := resp.(*pb.ListReservationsResponse)
+	if !ok {
+		return nil, wrongType("ListSessionReservations", resp)
+	}
+	return out, err
+}
+
+func (s *Server) JoinWaitlist(ctx context.Context, req *pb.JoinWaitlistRequest) (*pb.WaitlistEntry, error) {
+	resp, err := s.invoke(ctx, "JoinWaitlist", req)
+	if resp == nil {
+		return nil, err
+	}
+	out, ok := resp.(*pb.WaitlistEntry)
+	if !ok {
+		return nil, wrongType("JoinWaitlist", resp)
+	}
+	return out, err
+}
+
+func (s *Server) LeaveWaitlist(ctx context.Context, req *pb.LeaveWaitlistRequest) (*pb.LeaveWaitlistResponse, error) {
+	resp, err := s.invoke(ctx, "LeaveWaitlist", req)
+	if resp == nil {
+		return nil, err
+	}
+	out, ok := resp.(*pb.LeaveWaitlistResponse)
+	if !ok {
+		return nil, wrongType("LeaveWaitlist", resp)
+	}
+	return out, err
+}
+
+func (s *Server) RunSelfTest(ctx context.Context, req *pb.RunSelfTestRequest) (*pb.RunSelfTestResponse, error) {
+	resp, err := s.invoke(ctx, "RunSelfTest", req)
+	if resp == nil {
+		return nil, err
+	}
+	out, ok := resp.(*pb.RunSelfTestResponse)
+	if !ok {
+		return nil, wrongType("RunSelfTest", resp)
+	}
+	return out, err
+}
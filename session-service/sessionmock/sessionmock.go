@@ -0,0 +1,131 @@
+// Package sessionmock provides a programmable fake of the SessionService gRPC
+// API, so services that integrate with the session service can test against
+// it without Postgres or real data.
+//
+//	mock := sessionmock.NewServer()
+//	mock.Return("GetSession", &pb.Session{Id: "1", Title: "Yoga"}, nil)
+//	addr, err := mock.Start()
+//	...
+//	defer mock.Stop()
+//	// point the code under test at addr, then inspect mock.CallsTo("GetSession")
+//
+// Methods without a programmed response fail with codes.Unimplemented.
+package sessionmock
+
+import (
+	"context"
+	"net"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "session-service/proto"
+)
+
+// HandlerFunc computes the response to a call. req is the request message,
+// e.g. *pb.GetSessionRequest, and the returned response must have the type
+// the RPC declares, e.g. *pb.Session.
+type HandlerFunc func(ctx context.Context, req interface{}) (interface{}, error)
+
+// Call is a request received by the mock server.
+type Call struct {
+	// Method is the RPC name without the service prefix, e.g. "GetSession"
+	Method  string
+	Request interface{}
+}
+
+// Server is a SessionServiceServer whose responses are programmed per method.
+type Server struct {
+	pb.UnimplementedSessionServiceServer
+
+	mu       sync.Mutex
+	handlers map[string]HandlerFunc
+	calls    []Call
+	grpc     *grpc.Server
+}
+
+// NewServer returns a mock with no programmed responses.
+func NewServer() *Server {
+	return &Server{handlers: make(map[string]HandlerFunc)}
+}
+
+// Return programs method to always answer with resp and err.
+func (s *Server) Return(method string, resp interface{}, err error) {
+	s.Handle(method, func(context.Context, interface{}) (interface{}, error) {
+		return resp, err
+	})
+}
+
+// Handle programs method to answer with the result of fn.
+func (s *Server) Handle(method string, fn HandlerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[method] = fn
+}
+
+// Calls returns every call received so far, in order.
+func (s *Server) Calls() []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Call(nil), s.calls...)
+}
+
+// CallsTo returns the calls received for one method, in order.
+func (s *Server) CallsTo(method string) []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var calls []Call
+	for _, c := range s.calls {
+		if c.Method == method {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+// Reset forgets programmed responses and recorded calls.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers = make(map[string]HandlerFunc)
+	s.calls = nil
+}
+
+// Start serves the mock on a random local port and returns its address.
+func (s *Server) Start() (string, error) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	s.grpc = grpc.NewServer()
+	pb.RegisterSessionServiceServer(s.grpc, s)
+	go s.grpc.Serve(lis)
+	return lis.Addr().String(), nil
+}
+
+// Stop shuts down a server started with Start.
+func (s *Server) Stop() {
+	if s.grpc != nil {
+		s.grpc.Stop()
+	}
+}
+
+// Record the call and run the programmed handler
+func (s *Server) invoke(ctx context.Context, method string, req interface{}) (interface{}, error) {
+	s.mu.Lock()
+	s.calls = append(s.calls, Call{Method: method, Request: req})
+	fn := s.handlers[method]
+	s.mu.Unlock()
+
+	if fn == nil {
+		return nil, status.Errorf(codes.Unimplemented, "sessionmock: no response programmed for %s", method)
+	}
+	return fn(ctx, req)
+}
+
+func wrongType(method string, resp interface{}) error {
+	return status.Errorf(codes.Internal, "sessionmock: %s handler returned %T", method, resp)
+}
@@ -0,0 +1,70 @@
+package sessionmock
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "session-service/proto"
+)
+
+func TestReturnAndRecord(t *testing.T) {
+	mock := NewServer()
+	mock.Return("GetSession", &pb.Session{Id: "7", Title: "Yoga"}, nil)
+
+	got, err := mock.GetSession(context.Background(), &pb.GetSessionRequest{SessionId: "7"})
+	if err != nil {
+		t.Fatalf("GetSession failed: %v", err)
+	}
+	if got.Title != "Yoga" {
+		t.Errorf("Expected programmed session, got %+v", got)
+	}
+
+	calls := mock.CallsTo("GetSession")
+	if len(calls) != 1 || calls[0].Request.(*pb.GetSessionRequest).SessionId != "7" {
+		t.Errorf("Unexpected recorded calls %+v", calls)
+	}
+}
+
+func TestHandleAndErrors(t *testing.T) {
+	mock := NewServer()
+	mock.Handle("CreateReservation", func(ctx context.Context, req interface{}) (interface{}, error) {
+		r := req.(*pb.CreateReservationRequest)
+		if r.UserId == "" {
+			return nil, status.Error(codes.InvalidArgument, "missing user")
+		}
+		return &pb.Reservation{SessionId: r.SessionId, UserId: r.UserId, Status: "confirmed"}, nil
+	})
+
+	res, err := mock.CreateReservation(context.Background(), &pb.CreateReservationRequest{SessionId: "1", UserId: "u1"})
+	if err != nil || res.Status != "confirmed" {
+		t.Errorf("Expected confirmed reservation, got %+v, %v", res, err)
+	}
+	_, err = mock.CreateReservation(context.Background(), &pb.CreateReservationRequest{SessionId: "1"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument, got %v", err)
+	}
+
+	// Not programmed
+	_, err = mock.ListSessions(context.Background(), &pb.ListSessionsRequest{})
+	if status.Code(err) != codes.Unimplemented {
+		t.Errorf("Expected Unimplemented, got %v", err)
+	}
+
+	// Programmed with the wrong message type
+	mock.Return("DeleteSession", &pb.Session{}, nil)
+	_, err = mock.DeleteSession(context.Background(), &pb.DeleteSessionRequest{})
+	if status.Code(err) != codes.Internal {
+		t.Errorf("Expected Internal, got %v", err)
+	}
+
+	if len(mock.Calls()) != 4 {
+		t.Errorf("Expected 4 recorded calls, got %d", len(mock.Calls()))
+	}
+	mock.Reset()
+	if len(mock.Calls()) != 0 {
+		t.Errorf("Reset should clear recorded calls")
+	}
+}
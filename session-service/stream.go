@@ -0,0 +1,38 @@
+package main
+
+import (
+	"google.golang.org/grpc/status"
+
+	pb "session-service/proto"
+)
+
+// Implementation of StreamSessions RPC. Sessions are read a batch at a time
+// and each batch is sent before the next is read. Send blocks while the
+// client's flow control window is full, so a slow reader slows the reads
+// down instead of piling sessions up in memory, and no connection is held
+// while it does.
+func (s *server) StreamSessions(req *pb.StreamSessionsRequest, stream pb.SessionService_StreamSessionsServer) error {
+	ctx := stream.Context()
+	now := s.clock.Now()
+	filter, batchSize, err := validateStreamSessions(req, now)
+	if err != nil {
+		return err
+	}
+
+	var after int64
+	for {
+		sessions, err := s.repo.ListSessionsAfter(ctx, filter, after, batchSize)
+		if err != nil {
+			return status.Errorf(storeErrorCode(err), "Failed to list sessions: %v", err)
+		}
+		for _, session := range sessions {
+			if err := stream.Send(sessionToProto(session, now)); err != nil {
+				return err
+			}
+		}
+		if len(sessions) < batchSize {
+			return nil
+		}
+		after = sessions[len(sessions)-1].ID
+	}
+}
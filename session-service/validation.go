@@ -0,0 +1,461 @@
+package main
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"session-service/internal/store"
+	pb "session-service/proto"
+)
+
+// Column sizes of the sessions table
+const (
+	maxTitleLength           = 255
+	maxCoachIDLength         = 100
+	maxLocationLength        = 255
+	maxSessionTypeLength     = 100
+	maxDifficultyLevelLength = 50
+	maxGymIDLength           = 100
+)
+
+// Column sizes of the reservations table
+const maxUserIDLength = 100
+
+// Sessions StreamSessions reads at a time, by default and at most
+const (
+	defaultStreamBatchSize = 500
+	maxStreamBatchSize     = 5000
+)
+
+// Items in a page of ListSessions and the reservation lists, by default and
+// at most
+const (
+	defaultListPageSize = 50
+	maxListPageSize     = 500
+)
+
+// Most users BatchCreateReservations books at once; a batch holds the
+// session row locked until every user is booked
+const maxBatchSize = 100
+
+// Timestamps clients send: RFC 3339 with seconds, optional fractional
+// seconds and a mandatory UTC offset. time.Parse alone is more lenient, e.g.
+// about the fraction separator and offsets past 23 hours.
+var (
+	timestampPattern = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d{1,9})?(Z|[+-](\d{2}):(\d{2}))$`)
+	// The same without the offset, to tell what is missing
+	localTimestampPattern = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(\.\d{1,9})?$`)
+)
+
+// Example given in timestamp errors
+const timestampExample = "2030-05-15T08:00:00Z"
+
+// Parse a client supplied RFC 3339 timestamp and return it in UTC. Errors
+// name the field, the value and what is wrong with it.
+func parseTimestamp(field, value string) (time.Time, error) {
+	invalid := func(problem string) error {
+		return status.Errorf(codes.InvalidArgument,
+			"Invalid %s %q: %s; expected an RFC 3339 timestamp with a UTC offset, e.g. %s or 2030-05-15T10:00:00+02:00",
+			field, value, problem, timestampExample)
+	}
+
+	m := timestampPattern.FindStringSubmatch(value)
+	switch {
+	case m == nil && localTimestampPattern.MatchString(value):
+		return time.Time{}, invalid("missing UTC offset, the time zone is ambiguous")
+	case m == nil:
+		return time.Time{}, invalid("not an RFC 3339 timestamp")
+	case m[3] != "" && (m[3] > "23" || m[4] > "59"):
+		return time.Time{}, invalid("UTC offset out of range")
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		// The format matched, so a field is out of range, like February 30
+		problem := "date or time out of range"
+		var parseErr *time.ParseError
+		if errors.As(err, &parseErr) && parseErr.Message != "" {
+			problem = strings.TrimPrefix(parseErr.Message, ": ")
+		}
+		return time.Time{}, invalid(problem)
+	}
+	return t.UTC(), nil
+}
+
+// Check that a text field can be stored in its column. Postgres rejects NUL
+// bytes and invalid UTF-8, and VARCHAR(n) limits are counted in characters.
+// A maxLength of 0 means unlimited (TEXT columns).
+func validateText(field, value string, maxLength int) error {
+	if !utf8.ValidString(value) || strings.ContainsRune(value, 0) {
+		return status.Errorf(codes.InvalidArgument, "Invalid %s: must be valid UTF-8 text", field)
+	}
+	if maxLength > 0 && utf8.RuneCountInString(value) > maxLength {
+		return status.Errorf(codes.InvalidArgument, "Invalid %s: longer than %d characters", field, maxLength)
+	}
+	return nil
+}
+
+// Validate a CreateSession request and convert it to a session to store
+func validateCreateSession(req *pb.CreateSessionRequest) (*store.Session, error) {
+	if req.Title == "" || req.CoachId == "" || req.Capacity < 1 || req.StartTime == "" || req.EndTime == "" || req.Location == "" || req.SessionType == "" || req.DifficultyLevel == "" {
+		return nil, status.Error(codes.InvalidArgument, "Missing required fields")
+	}
+
+	texts := []struct {
+		field, value string
+		maxLength    int
+	}{
+		{"title", req.Title, maxTitleLength},
+		{"description", req.Description, 0},
+		{"coach_id", req.CoachId, maxCoachIDLength},
+		{"location", req.Location, maxLocationLength},
+		{"session_type", req.SessionType, maxSessionTypeLength},
+		{"difficulty_level", req.DifficultyLevel, maxDifficultyLevelLength},
+		{"gym_id", req.GymId, maxGymIDLength},
+	}
+	for _, t := range texts {
+		if err := validateText(t.field, t.value, t.maxLength); err != nil {
+			return nil, err
+		}
+	}
+
+	startTime, err := parseTimestamp("start_time", req.StartTime)
+	if err != nil {
+		return nil, err
+	}
+	endTime, err := parseTimestamp("end_time", req.EndTime)
+	if err != nil {
+		return nil, err
+	}
+	if !endTime.After(startTime) {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid end_time %q: not after start_time %q", req.EndTime, req.StartTime)
+	}
+
+	return &store.Session{
+		GymID:           req.GymId,
+		Title:           req.Title,
+		Description:     req.Description,
+		CoachID:         req.CoachId,
+		Capacity:        req.Capacity,
+		StartTime:       startTime,
+		EndTime:         endTime,
+		Location:        req.Location,
+		SessionType:     req.SessionType,
+		DifficultyLevel: req.DifficultyLevel,
+		QueuedBooking:   req.QueuedBooking,
+	}, nil
+}
+
+// Validate an UpdateSession request and convert it to the session to store.
+// The fields it replaces are checked like those of CreateSession; sessions
+// are cancelled with CancelSession only.
+func validateUpdateSession(id int64, req *pb.UpdateSessionRequest) (*store.Session, error) {
+	if req.IsCancelled {
+		return nil, status.Error(codes.InvalidArgument, "Invalid is_cancelled: sessions are cancelled with CancelSession")
+	}
+	session, err := validateCreateSession(&pb.CreateSessionRequest{
+		Title:           req.Title,
+		Description:     req.Description,
+		CoachId:         req.CoachId,
+		Capacity:        req.Capacity,
+		StartTime:       req.StartTime,
+		EndTime:         req.EndTime,
+		Location:        req.Location,
+		SessionType:     req.SessionType,
+		DifficultyLevel: req.DifficultyLevel,
+	})
+	if err != nil {
+		return nil, err
+	}
+	session.ID = id
+	return session, nil
+}
+
+// Weekdays by their name in a Recurrence
+var weekdayNames = map[string]time.Weekday{
+	"monday": time.Monday, "tuesday": time.Tuesday, "wednesday": time.Wednesday, "thursday": time.Thursday,
+	"friday": time.Friday, "saturday": time.Saturday, "sunday": time.Sunday,
+}
+
+// Validate a CreateSessionSeries request and convert it to the occurrences
+// to store. The session is checked like that of CreateSession.
+func validateCreateSessionSeries(req *pb.CreateSessionSeriesRequest) ([]*store.Session, error) {
+	if req.Session == nil || req.Recurrence == nil {
+		return nil, status.Error(codes.InvalidArgument, "Missing required fields")
+	}
+	first, err := validateCreateSession(req.Session)
+	if err != nil {
+		return nil, err
+	}
+	// Valid already, and kept in its UTC offset
+	start, _ := time.Parse(time.RFC3339Nano, req.Session.StartTime)
+	r, err := validateRecurrence(req.Recurrence, req.TimeZone, start)
+	if err != nil {
+		return nil, err
+	}
+
+	times := r.startTimes(start)
+	switch {
+	case times == nil:
+		return nil, status.Errorf(codes.InvalidArgument, "Too many occurrences: at most %d per series", maxSeriesOccurrences)
+	case len(times) == 0:
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid until %q: no occurrence on or before it", req.Recurrence.Until)
+	}
+	length := first.EndTime.Sub(first.StartTime)
+	sessions := make([]*store.Session, len(times))
+	for i, t := range times {
+		session := *first
+		session.StartTime, session.EndTime = t, t.Add(length)
+		sessions[i] = &session
+	}
+	return sessions, nil
+}
+
+// Check a Recurrence and the time zone of its series, whose first
+// occurrence may start at start
+func validateRecurrence(req *pb.Recurrence, timeZone string, start time.Time) (*recurrence, error) {
+	r := &recurrence{interval: int(req.IntervalWeeks), count: int(req.Count), location: start.Location()}
+	if req.Until == "" && req.Count == 0 {
+		return nil, status.Error(codes.InvalidArgument, "Missing required fields: until or count")
+	}
+	if len(req.Weekdays) == 0 {
+		r.weekdays[start.Weekday()] = true
+	}
+	for _, name := range req.Weekdays {
+		day, ok := weekdayNames[name]
+		if !ok {
+			return nil, status.Errorf(codes.InvalidArgument, "Invalid weekday %q: expected monday to sunday", name)
+		}
+		r.weekdays[day] = true
+	}
+	switch {
+	case r.interval < 0:
+		return nil, status.Error(codes.InvalidArgument, "Invalid interval_weeks: must be at least 1")
+	case r.interval == 0:
+		r.interval = 1
+	}
+	if r.count < 0 || r.count > maxSeriesOccurrences {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid count: must be between 1 and %d", maxSeriesOccurrences)
+	}
+	if req.Until != "" {
+		until, err := time.Parse("2006-01-02", req.Until)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "Invalid until %q: expected YYYY-MM-DD", req.Until)
+		}
+		r.until = until
+	}
+	if timeZone != "" {
+		location, err := time.LoadLocation(timeZone)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "Invalid time_zone %q: expected an IANA time zone, e.g. Europe/Paris", timeZone)
+		}
+		r.location = location
+	}
+	return r, nil
+}
+
+// Validate a BatchCreateReservations request and convert it to the
+// reservations to store
+func validateBatchCreateReservations(sessionID int64, req *pb.BatchCreateReservationsRequest) ([]*store.Reservation, error) {
+	if len(req.UserIds) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "Missing required fields")
+	}
+	if len(req.UserIds) > maxBatchSize {
+		return nil, status.Errorf(codes.InvalidArgument, "Too many user_ids: at most %d per batch", maxBatchSize)
+	}
+
+	seen := make(map[string]bool, len(req.UserIds))
+	reservations := make([]*store.Reservation, len(req.UserIds))
+	for i, userID := range req.UserIds {
+		if userID == "" {
+			return nil, status.Error(codes.InvalidArgument, "Missing required fields")
+		}
+		if err := validateText("user_id", userID, maxUserIDLength); err != nil {
+			return nil, err
+		}
+		if seen[userID] {
+			return nil, status.Errorf(codes.InvalidArgument, "Duplicate user_id: %v", userID)
+		}
+		seen[userID] = true
+		reservations[i] = &store.Reservation{SessionID: sessionID, UserID: userID}
+	}
+	return reservations, nil
+}
+
+// Check the required user_id of a booking or waitlist request
+func validateUserID(userID string) error {
+	if userID == "" {
+		return status.Error(codes.InvalidArgument, "Missing required fields")
+	}
+	return validateText("user_id", userID, maxUserIDLength)
+}
+
+// Validate the user of a CreateReservation or QueueReservation request and
+// convert it to the reservation to book
+func validateReservation(sessionID int64, userID string) (*store.Reservation, error) {
+	if err := validateUserID(userID); err != nil {
+		return nil, err
+	}
+	return &store.Reservation{SessionID: sessionID, UserID: userID}, nil
+}
+
+// Check a StreamSessionsRequest and turn it into a filter, given the current
+// time. It also returns the batch size.
+func validateStreamSessions(req *pb.StreamSessionsRequest, now time.Time) (store.SessionFilter, int, error) {
+	f := store.SessionFilter{SessionType: req.SessionType, CoachID: req.CoachId}
+	if err := validateFilterText(f); err != nil {
+		return f, 0, err
+	}
+	if err := filterStartTime(&f, req.Date, req.IncludePast, now); err != nil {
+		return f, 0, err
+	}
+
+	size := int(req.BatchSize)
+	switch {
+	case size < 0 || size > maxStreamBatchSize:
+		return f, 0, status.Errorf(codes.InvalidArgument, "Invalid batch_size: must be between 1 and %d", maxStreamBatchSize)
+	case size == 0:
+		size = defaultStreamBatchSize
+	}
+	return f, size, nil
+}
+
+// Check a ListSessionsRequest and turn it into a filter, given the current
+// time. It also returns the page size.
+func validateListSessions(req *pb.ListSessionsRequest, now time.Time) (store.SessionFilter, int, error) {
+	f := store.SessionFilter{
+		SessionType:      req.SessionType,
+		DifficultyLevel:  req.DifficultyLevel,
+		CoachID:          req.CoachId,
+		Location:         req.Location,
+		ExcludeCancelled: req.ExcludeCancelled,
+	}
+	if err := validateFilterText(f); err != nil {
+		return f, 0, err
+	}
+	if req.SeriesId != "" {
+		var err error
+		if f.SeriesID, err = strconv.ParseInt(req.SeriesId, 10, 64); err != nil || f.SeriesID <= 0 {
+			return f, 0, status.Errorf(codes.InvalidArgument, "Invalid series_id %q", req.SeriesId)
+		}
+	}
+
+	var err error
+	if req.StartFrom != "" {
+		if f.StartsFrom, err = parseTimestamp("start_from", req.StartFrom); err != nil {
+			return f, 0, err
+		}
+	}
+	if req.StartBefore != "" {
+		if f.StartsBefore, err = parseTimestamp("start_before", req.StartBefore); err != nil {
+			return f, 0, err
+		}
+	}
+	if req.StartFrom != "" && req.StartBefore != "" && !f.StartsFrom.Before(f.StartsBefore) {
+		return f, 0, status.Error(codes.InvalidArgument, "Invalid start_before: must be after start_from")
+	}
+	if err := filterStartTime(&f, req.Date, req.IncludePast, now); err != nil {
+		return f, 0, err
+	}
+
+	size, err := validatePageSize(req.PageSize)
+	return f, size, err
+}
+
+// Check the page_size of a list request and apply the default
+func validatePageSize(pageSize int32) (int, error) {
+	size := int(pageSize)
+	switch {
+	case size < 0 || size > maxListPageSize:
+		return 0, status.Errorf(codes.InvalidArgument, "Invalid page_size: must be between 1 and %d", maxListPageSize)
+	case size == 0:
+		size = defaultListPageSize
+	}
+	return size, nil
+}
+
+// Check the status a reservation list is filtered by
+func validateReservationStatus(value string) error {
+	switch value {
+	case "", store.ReservationConfirmed, store.ReservationCancelled, store.ReservationAttended, store.ReservationNoShow:
+		return nil
+	}
+	return status.Errorf(codes.InvalidArgument, "Invalid status %q: must be %s, %s, %s or %s",
+		value, store.ReservationConfirmed, store.ReservationCancelled, store.ReservationAttended, store.ReservationNoShow)
+}
+
+// Check a ListUserReservationsRequest and turn it into a filter, given the
+// current time. It also returns the page size.
+func validateListUserReservations(req *pb.ListUserReservationsRequest, now time.Time) (store.ReservationFilter, int, error) {
+	f := store.ReservationFilter{UserID: req.UserId, Status: req.Status}
+	if req.UserId == "" {
+		return f, 0, status.Error(codes.InvalidArgument, "Missing required fields")
+	}
+	if err := validateText("user_id", req.UserId, maxUserIDLength); err != nil {
+		return f, 0, err
+	}
+	if err := validateReservationStatus(req.Status); err != nil {
+		return f, 0, err
+	}
+	if !req.IncludePast {
+		f.SessionsEndingAfter = now
+	}
+	size, err := validatePageSize(req.PageSize)
+	return f, size, err
+}
+
+// Check a ListSessionReservationsRequest of the given session and turn it
+// into a filter. It also returns the page size.
+func validateListSessionReservations(sessionID int64, req *pb.ListSessionReservationsRequest) (store.ReservationFilter, int, error) {
+	f := store.ReservationFilter{SessionID: sessionID, Status: req.Status}
+	if err := validateReservationStatus(req.Status); err != nil {
+		return f, 0, err
+	}
+	size, err := validatePageSize(req.PageSize)
+	return f, size, err
+}
+
+// Check the text a filter compares columns with, which Postgres would fail
+// on otherwise
+func validateFilterText(f store.SessionFilter) error {
+	texts := []struct{ field, value string }{
+		{"session_type", f.SessionType},
+		{"difficulty_level", f.DifficultyLevel},
+		{"coach_id", f.CoachID},
+		{"location", f.Location},
+	}
+	for _, t := range texts {
+		if err := validateText(t.field, t.value, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Narrow f to the sessions starting on date, a YYYY-MM-DD day in UTC, if
+// set, and to the sessions yet to start unless includePast is set
+func filterStartTime(f *store.SessionFilter, date string, includePast bool, now time.Time) error {
+	if date != "" {
+		day, err := time.Parse("2006-01-02", date)
+		if err != nil {
+			return status.Errorf(codes.InvalidArgument, "Invalid date %q: expected YYYY-MM-DD", date)
+		}
+		if f.StartsFrom.Before(day) {
+			f.StartsFrom = day
+		}
+		if next := day.AddDate(0, 0, 1); f.StartsBefore.IsZero() || next.Before(f.StartsBefore) {
+			f.StartsBefore = next
+		}
+	}
+	if !includePast && f.StartsFrom.Before(now) {
+		f.StartsFrom = now
+	}
+	return nil
+}
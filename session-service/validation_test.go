@@ -0,0 +1,179 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "session-service/proto"
+)
+
+func FuzzParseTimestamp(f *testing.F) {
+	for _, seed := range []string{
+		"2030-05-15T08:00:00Z",
+		"2030-05-15T08:00:00+02:00",
+		"2030-05-15T08:00:00.123456789-07:30",
+		"2030-05-15 08:00:00",
+		"2030-02-30T08:00:00Z",
+		"",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, value string) {
+		parsed, err := parseTimestamp("start_time", value)
+		if err != nil {
+			if status.Code(err) != codes.InvalidArgument {
+				t.Fatalf("Expected InvalidArgument for %q, got %v", value, err)
+			}
+			return
+		}
+
+		if parsed.Location() != time.UTC {
+			t.Fatalf("Parsed %q in %v, expected UTC", value, parsed.Location())
+		}
+
+		// Whatever we accept must survive the round trip through our own format
+		again, err := parseTimestamp("start_time", formatTimestamp(parsed))
+		if err != nil {
+			t.Fatalf("Formatted %q as %q which does not parse: %v", value, formatTimestamp(parsed), err)
+		}
+		if !again.Equal(parsed.Truncate(time.Second)) {
+			t.Fatalf("Round trip of %q changed the instant: %v != %v", value, again, parsed)
+		}
+	})
+}
+
+func TestParseTimestamp(t *testing.T) {
+	valid := map[string]time.Time{
+		"2030-05-15T08:00:00Z":           time.Date(2030, 5, 15, 8, 0, 0, 0, time.UTC),
+		"2030-05-15T10:00:00+02:00":      time.Date(2030, 5, 15, 8, 0, 0, 0, time.UTC),
+		"2030-05-15T00:30:00-07:30":      time.Date(2030, 5, 15, 8, 0, 0, 0, time.UTC),
+		"2030-05-15T08:00:00.123456789Z": time.Date(2030, 5, 15, 8, 0, 0, 123456789, time.UTC),
+		"2030-12-31T23:59:59-01:00":      time.Date(2031, 1, 1, 0, 59, 59, 0, time.UTC),
+		"2032-02-29T08:00:00+00:00":      time.Date(2032, 2, 29, 8, 0, 0, 0, time.UTC),
+		"2030-05-15T08:00:00.5+23:59":    time.Date(2030, 5, 14, 8, 1, 0, 500000000, time.UTC),
+	}
+	for value, want := range valid {
+		got, err := parseTimestamp("start_time", value)
+		if err != nil {
+			t.Errorf("parseTimestamp(%q) failed: %v", value, err)
+			continue
+		}
+		if !got.Equal(want) || got.Location() != time.UTC {
+			t.Errorf("parseTimestamp(%q): expected %v, got %v", value, want, got)
+		}
+	}
+
+	invalid := map[string]string{
+		"2030-05-15T08:00:00":       "missing UTC offset",
+		"2030-05-15 08:00:00.5":     "missing UTC offset",
+		"2030-05-15 08:00:00Z":      "not an RFC 3339 timestamp",
+		"2030-05-15t08:00:00z":      "not an RFC 3339 timestamp",
+		"2030-05-15T08:00Z":         "not an RFC 3339 timestamp",
+		"2030-05-15T08:00:00,5Z":    "not an RFC 3339 timestamp",
+		"2030-05-15T08:00:00+0200":  "not an RFC 3339 timestamp",
+		"2030-05-15T08:00:00 UTC":   "not an RFC 3339 timestamp",
+		"tomorrow":                  "not an RFC 3339 timestamp",
+		"":                          "not an RFC 3339 timestamp",
+		"2030-05-15T08:00:00+24:00": "UTC offset out of range",
+		"2030-05-15T08:00:00-02:60": "UTC offset out of range",
+		"2030-02-30T08:00:00Z":      "day out of range",
+		"2030-13-15T08:00:00Z":      "month out of range",
+		"2030-05-15T25:00:00Z":      "hour out of range",
+		"2031-02-29T08:00:00Z":      "day out of range",
+	}
+	for value, problem := range invalid {
+		_, err := parseTimestamp("end_time", value)
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("parseTimestamp(%q): expected InvalidArgument, got %v", value, err)
+			continue
+		}
+		msg := status.Convert(err).Message()
+		if !strings.Contains(msg, "end_time") || !strings.Contains(msg, problem) || !strings.Contains(msg, timestampExample) {
+			t.Errorf("parseTimestamp(%q): expected the field, %q and the expected format in %q", value, problem, msg)
+		}
+	}
+}
+
+func FuzzValidateCreateSession(f *testing.F) {
+	f.Add("Morning Yoga", "coach-1", int32(15), "2030-05-15T08:00:00Z", "2030-05-15T09:00:00Z", "Studio A", "yoga")
+	f.Add("Yoga\x00", "coach-1", int32(1), "2030-05-15T08:00:00Z", "2030-05-15T09:00:00Z", "Studio A", "yoga")
+	f.Add("\xff\xfe", "coach-1", int32(-1), "now", "later", "", "yoga")
+	f.Add(strings.Repeat("é", 256), "coach-1", int32(15), "2030-05-15T08:00:00Z", "2030-05-15T09:00:00Z", "Studio A", "yoga")
+
+	f.Fuzz(func(t *testing.T, title, coachID string, capacity int32, start, end, location, sessionType string) {
+		req := &pb.CreateSessionRequest{
+			Title:           title,
+			CoachId:         coachID,
+			Capacity:        capacity,
+			StartTime:       start,
+			EndTime:         end,
+			Location:        location,
+			SessionType:     sessionType,
+			DifficultyLevel: "beginner",
+		}
+
+		session, err := validateCreateSession(req)
+		if err != nil {
+			if status.Code(err) != codes.InvalidArgument {
+				t.Fatalf("Expected InvalidArgument, got %v", err)
+			}
+			return
+		}
+
+		// Anything accepted must be storable in the sessions table
+		for _, v := range []string{session.Title, session.CoachID, session.Location, session.SessionType} {
+			if v == "" || !utf8.ValidString(v) || strings.ContainsRune(v, 0) {
+				t.Fatalf("Accepted unstorable text %q", v)
+			}
+		}
+		if utf8.RuneCountInString(session.Title) > maxTitleLength || utf8.RuneCountInString(session.CoachID) > maxCoachIDLength {
+			t.Fatalf("Accepted text longer than its column")
+		}
+		if session.Capacity < 1 {
+			t.Fatalf("Accepted capacity %d", session.Capacity)
+		}
+		if session.StartTime.IsZero() || session.EndTime.IsZero() {
+			t.Fatalf("Accepted request without parsed times")
+		}
+	})
+}
+
+func FuzzDecodePageToken(f *testing.F) {
+	query := listQueryHash(&pb.ListSessionsRequest{})
+	valid := encodePageToken(pageToken{StartSeconds: 1904544000, StartNanos: 500, ID: 42, Query: query})
+	for _, seed := range []string{
+		valid,
+		valid[:len(valid)/2],
+		encodePageToken(pageToken{ID: 42, Query: query}),
+		encodePageToken(pageToken{StartSeconds: 1904544000, StartNanos: 500, ID: 42, Query: query + 1}),
+		encodePageToken(pageToken{ID: 42, Query: listQueryHash(&pb.ListSessionsRequest{Descending: true})}),
+		"garbage",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, encoded string) {
+		token, err := decodePageToken(encoded, query)
+		if err != nil {
+			if status.Code(err) != codes.InvalidArgument {
+				t.Fatalf("Expected InvalidArgument for %q, got %v", encoded, err)
+			}
+			return
+		}
+		if token == nil {
+			if encoded != "" {
+				t.Fatalf("Decoded %q as the first page", encoded)
+			}
+			return
+		}
+		if token.Query != query || token.ID == 0 {
+			t.Fatalf("Accepted %q for another query or without a position: %+v", encoded, token)
+		}
+	})
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/lib/pq"
+
+	"session-service/internal/schemacheck"
+)
+
+// Implementation of the verify-schema command. It creates the expected tables
+// in a scratch schema, compares them with the live tables and prints the
+// differences. Everything runs in a transaction that is rolled back, so the
+// database is left untouched. It reports whether the schemas match.
+func verifySchema(ctx context.Context, db *sql.DB, out io.Writer) (bool, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var live string
+	if err := tx.QueryRowContext(ctx, `SELECT current_schema()`).Scan(&live); err != nil {
+		return false, fmt.Errorf("finding the live schema: %w", err)
+	}
+
+	scratch := fmt.Sprintf("verify_schema_%d", os.Getpid())
+	if _, err := tx.ExecContext(ctx, "CREATE SCHEMA "+pq.QuoteIdentifier(scratch)); err != nil {
+		return false, err
+	}
+	if _, err := tx.ExecContext(ctx, "SET LOCAL search_path TO "+pq.QuoteIdentifier(scratch)); err != nil {
+		return false, err
+	}
+	if err := createSchema(ctx, tx); err != nil {
+		return false, fmt.Errorf("creating the expected tables: %w", err)
+	}
+
+	expected, err := schemacheck.Snapshot(ctx, tx, scratch)
+	if err != nil {
+		return false, fmt.Errorf("reading the expected schema: %w", err)
+	}
+	actual, err := schemacheck.Snapshot(ctx, tx, live)
+	if err != nil {
+		return false, fmt.Errorf("reading schema %s: %w", live, err)
+	}
+
+	diff := schemacheck.Diff(expected, actual)
+	if diff == nil {
+		fmt.Fprintf(out, "Schema %s matches the expected schema\n", live)
+		return true, nil
+	}
+	fmt.Fprintf(out, "Schema %s differs from the expected schema (- expected, + found):\n", live)
+	for _, line := range diff {
+		fmt.Fprintln(out, line)
+	}
+	return false, nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"strconv"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"session-service/internal/store"
+	pb "session-service/proto"
+)
+
+// Convert a stored waitlist entry to its protobuf representation
+func waitlistEntryToProto(e *store.WaitlistEntry) *pb.WaitlistEntry {
+	return &pb.WaitlistEntry{
+		Id:        strconv.FormatInt(e.ID, 10),
+		SessionId: strconv.FormatInt(e.SessionID, 10),
+		UserId:    e.UserID,
+		Position:  e.Position,
+		CreatedAt: formatTimestamp(e.CreatedAt),
+	}
+}
+
+// Implementation of JoinWaitlist RPC. Only full sessions have a waitlist:
+// while a spot is left, members book it instead. Sessions taking queued
+// bookings have none, members queue for them.
+func (s *server) JoinWaitlist(ctx context.Context, req *pb.JoinWaitlistRequest) (*pb.WaitlistEntry, error) {
+	id, err := strconv.ParseInt(req.SessionId, 10, 64)
+	if err != nil {
+		return nil, status.Errorf(codes.NotFound, "Session not found: %v", req.SessionId)
+	}
+	if err := validateUserID(req.UserId); err != nil {
+		return nil, err
+	}
+
+	entry := &store.WaitlistEntry{
+		SessionID: id,
+		UserID:    req.UserId,
+		UserName:  "Member Name", // In a real app, would fetch this from the User service
+	}
+	switch err := s.repo.JoinWaitlist(ctx, entry); err {
+	case nil:
+	case store.ErrNotFound:
+		return nil, status.Errorf(codes.NotFound, "Session not found: %v", req.SessionId)
+	case store.ErrAlreadyCancelled:
+		return nil, status.Errorf(codes.FailedPrecondition, "Session cancelled: %v", req.SessionId)
+	case store.ErrSessionCompleted:
+		return nil, status.Errorf(codes.FailedPrecondition, "Session already completed: %v", req.SessionId)
+	case store.ErrQueuedBooking:
+		return nil, status.Errorf(codes.FailedPrecondition, "Session takes queued bookings only, queue instead: %v", req.SessionId)
+	case store.ErrSessionNotFull:
+		return nil, status.Errorf(codes.FailedPrecondition, "Session has spots left, book one instead: %v", req.SessionId)
+	case store.ErrAlreadyBooked:
+		return nil, status.Errorf(codes.AlreadyExists, "Already booked: %v", req.SessionId)
+	case store.ErrAlreadyWaitlisted:
+		return nil, status.Errorf(codes.AlreadyExists, "Already on the waitlist: %v", req.SessionId)
+	default:
+		return nil, status.Errorf(storeErrorCode(err), "Failed to join waitlist: %v", err)
+	}
+	return waitlistEntryToProto(entry), nil
+}
+
+// Implementation of LeaveWaitlist RPC
+func (s *server) LeaveWaitlist(ctx context.Context, req *pb.LeaveWaitlistRequest) (*pb.LeaveWaitlistResponse, error) {
+	id, err := strconv.ParseInt(req.SessionId, 10, 64)
+	if err != nil {
+		return nil, status.Errorf(codes.NotFound, "Session not found: %v", req.SessionId)
+	}
+	if err := validateUserID(req.UserId); err != nil {
+		return nil, err
+	}
+
+	err = s.repo.LeaveWaitlist(ctx, id, req.UserId)
+	if err == store.ErrNotFound {
+		return nil, status.Errorf(codes.NotFound, "Not on the waitlist of session %v", req.SessionId)
+	}
+	if err != nil {
+		return nil, status.Errorf(storeErrorCode(err), "Failed to leave waitlist: %v", err)
+	}
+	return &pb.LeaveWaitlistResponse{Success: true, Message: "Left the waitlist"}, nil
+}
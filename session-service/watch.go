@@ -0,0 +1,62 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"session-service/internal/store"
+	pb "session-service/proto"
+)
+
+// How often a watched session is read again without being told it changed.
+// Its status moves on with time alone, and a change notification from
+// another replica may get lost.
+const watchRefreshInterval = 30 * time.Second
+
+// Implementation of WatchSession RPC. Every watcher reads the session again
+// when told it changed; the reads go through the session cache, so a change
+// costs each replica one database read however many members watch.
+func (s *server) WatchSession(req *pb.WatchSessionRequest, stream pb.SessionService_WatchSessionServer) error {
+	ctx := stream.Context()
+	id, err := strconv.ParseInt(req.SessionId, 10, 64)
+	if err != nil {
+		return status.Errorf(codes.NotFound, "Session not found: %v", req.SessionId)
+	}
+
+	// Watch before the first read, so that no change goes unnoticed
+	watcher := s.watchers.Watch(id)
+	defer watcher.Stop()
+	ticker := time.NewTicker(watchRefreshInterval)
+	defer ticker.Stop()
+
+	var sent *pb.Session
+	for {
+		session, err := s.repo.GetSession(ctx, id)
+		if err == store.ErrNotFound {
+			return status.Errorf(codes.NotFound, "Session not found: %v", req.SessionId)
+		}
+		if err != nil {
+			return status.Errorf(storeErrorCode(err), "Failed to get session: %v", err)
+		}
+		if current := sessionToProto(session, s.clock.Now()); !proto.Equal(current, sent) {
+			if err := stream.Send(current); err != nil {
+				return err
+			}
+			sent = current
+		}
+		if session.IsCompleted {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case <-watcher.Changed():
+		case <-ticker.C:
+		}
+	}
+}
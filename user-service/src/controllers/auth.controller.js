@@ -10,7 +10,7 @@ const register = async (req, res) => {
     return res.status(400).json({ errors: errors.array() });
   }
 
-  const { firstName, lastName, email, password, phone, dateOfBirth, role } = req.body;
+  const { firstName, lastName, email, password, phone, dateOfBirth } = req.body;
 
   try {
     // Check if user already exists
@@ -19,7 +19,9 @@ const register = async (req, res) => {
       return res.status(400).json({ message: 'User already exists' });
     }
 
-    // Create new user
+    // Create new user. The role is signed into the token other services
+    // trust, so anyone signing up is a member: only admins set other roles,
+    // through /api/users.
     user = new User({
       firstName,
       lastName,
@@ -27,7 +29,7 @@ const register = async (req, res) => {
       password,
       phone,
       dateOfBirth,
-      role: role || 'member' // Default to member if not specified
+      role: 'member'
     });
 
     // Save user to database
@@ -35,7 +37,7 @@ const register = async (req, res) => {
 
     // Generate JWT token
     const token = jwt.sign(
-      { userId: user._id },
+      { userId: user._id, role: user.role },
       process.env.JWT_SECRET || 'your_jwt_secret_key',
       { expiresIn: '24h' }
     );
@@ -81,7 +83,7 @@ const login = async (req, res) => {
 
     // Generate JWT token
     const token = jwt.sign(
-      { userId: user._id },
+      { userId: user._id, role: user.role },
       process.env.JWT_SECRET || 'your_jwt_secret_key',
       { expiresIn: '24h' }
     );
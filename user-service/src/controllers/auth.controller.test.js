@@ -0,0 +1,43 @@
+const jwt = require('jsonwebtoken');
+
+// Users are saved in memory, with the ID MongoDB would give the first one
+jest.mock('../models/user.model', () => {
+  const User = jest.fn(function (fields) {
+    Object.assign(this, fields, { _id: 'user-1' });
+    this.save = jest.fn().mockResolvedValue(this);
+  });
+  User.findOne = jest.fn().mockResolvedValue(null);
+  return User;
+});
+
+const { register } = require('./auth.controller');
+
+const mockResponse = () => {
+  const res = {};
+  res.status = jest.fn().mockReturnValue(res);
+  res.json = jest.fn().mockReturnValue(res);
+  return res;
+};
+
+describe('register', () => {
+  it('makes anyone signing up a member, whatever role they ask for', async () => {
+    const req = {
+      body: {
+        firstName: 'Eve',
+        lastName: 'Doe',
+        email: 'eve@example.com',
+        password: 'password123',
+        role: 'admin'
+      }
+    };
+    const res = mockResponse();
+
+    await register(req, res);
+
+    expect(res.status).toHaveBeenCalledWith(201);
+    const { token, user } = res.json.mock.calls[0][0];
+    expect(user.role).toBe('member');
+    const claims = jwt.verify(token, process.env.JWT_SECRET || 'your_jwt_secret_key');
+    expect(claims).toMatchObject({ userId: 'user-1', role: 'member' });
+  });
+});